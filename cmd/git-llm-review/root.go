@@ -49,7 +49,7 @@ A tool that uses LLMs to review code changes in Git repositories.
 `, version.AppName, version.Description),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// Initialize the logger with default logging config
-			cfg := config.Default()
+			cfg = config.Default()
 
 			// If config file is specified, try to load it first for logging settings
 			if configPath != "" {
@@ -199,6 +199,15 @@ A tool that uses LLMs to review code changes in Git repositories.
 				fmt.Fprintf(cmd.OutOrStdout(), "  LLM Provider: %s\n", cfg.LLM.Provider)
 				fmt.Fprintf(cmd.OutOrStdout(), "  LLM API URL: %s\n", cfg.LLM.APIURL)
 				fmt.Fprintf(cmd.OutOrStdout(), "  LLM Model: %s\n", cfg.LLM.Model)
+				// Never print the key itself
+				if err := cfg.ResolveAPIKeys(); err != nil {
+					return fmt.Errorf("failed to get API key: %w", err)
+				}
+				if cfg.LLM.APIKeySource != "" {
+					fmt.Fprintf(cmd.OutOrStdout(), "  LLM API Key: %s (from %s)\n", config.MaskSecret(cfg.LLM.APIKey), cfg.LLM.APIKeySource)
+				} else {
+					fmt.Fprintf(cmd.OutOrStdout(), "  LLM API Key: %s\n", config.MaskSecret(cfg.LLM.APIKey))
+				}
 				fmt.Fprintf(cmd.OutOrStdout(), "  LLM Timeout: %d seconds\n", cfg.LLM.Timeout)
 				fmt.Fprintf(cmd.OutOrStdout(), "  Max Concurrent Tasks: %d\n", cfg.Concurrency.MaxTasks)
 
@@ -207,6 +216,8 @@ A tool that uses LLMs to review code changes in Git repositories.
 					"provider":   cfg.LLM.Provider,
 					"api_url":    cfg.LLM.APIURL,
 					"model":      cfg.LLM.Model,
+					"api_key":    config.MaskSecret(cfg.LLM.APIKey),
+					"key_source": cfg.LLM.APIKeySource,
 					"timeout":    cfg.LLM.Timeout,
 					"max_tasks":  cfg.Concurrency.MaxTasks,
 				})
@@ -281,7 +292,7 @@ A tool that uses LLMs to review code changes in Git repositories.
 					logging.Info("Sending code review request to LLM...")
 					fmt.Fprintf(cmd.OutOrStdout(), "\nSending code review request to LLM...\n")
 
-					response, err := provider.GetCompletion(reviewPrompt)
+					response, err := provider.GetCompletion(cmd.Context(), reviewPrompt)
 					if err != nil {
 						logging.ErrorWith("Failed to get LLM response", map[string]interface{}{
 							"error": err.Error(),
@@ -401,7 +412,7 @@ A tool that uses LLMs to review code changes in Git repositories.
 					logging.Info("Sending code review request to LLM...")
 					fmt.Fprintf(cmd.OutOrStdout(), "\nSending code review request to LLM...\n")
 
-					response, err := provider.GetCompletion(reviewPrompt)
+					response, err := provider.GetCompletion(cmd.Context(), reviewPrompt)
 					if err != nil {
 						logging.ErrorWith("Failed to get LLM response", map[string]interface{}{
 							"error": err.Error(),
@@ -157,3 +157,23 @@ func TestShortFlagAliases(t *testing.T) {
 		})
 	}
 }
+
+func TestDebugOutputMasksAPIKey(t *testing.T) {
+	apiKey := "sk-test-secret-key-1234567890"
+	t.Setenv("LLM_API_KEY", apiKey)
+
+	mockDetector := createMockDetector()
+	cmd := NewRootCmdWithDetector(mockDetector)
+	output, err := executeCommand(cmd, "--debug")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	// The configuration dump shows where the key came from, but not the key
+	if strings.Contains(output, apiKey) {
+		t.Errorf("Debug output contains the API key: %s", output)
+	}
+	if !strings.Contains(output, "LLM API Key: ****7890 (from LLM_API_KEY)") {
+		t.Errorf("Expected the masked key in the debug output, got: %s", output)
+	}
+}
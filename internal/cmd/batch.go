@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/workflow"
+	"github.com/spf13/cobra"
+)
+
+// newBatchCmd creates the command that follows up on batch jobs submitted
+// with --batch
+func newBatchCmd() *cobra.Command {
+	batchCmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Check and collect batch reviews submitted with --batch",
+	}
+
+	batchCmd.AddCommand(&cobra.Command{
+		Use:   "status [batch-id]",
+		Short: "Show the progress of a batch job, by default the last one submitted",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reviewWorkflow, err := workflow.NewReviewWorkflow(workflowOptions())
+			if err != nil {
+				return fmt.Errorf("failed to create review workflow: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			job, info, err := reviewWorkflow.BatchStatus(ctx, batchID(args))
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Batch %s (%s, %s)\n", job.ID, job.Provider, job.Model)
+			fmt.Fprintf(out, "Submitted: %s, %d files\n", job.SubmittedAt.Format(time.RFC1123), len(job.Files))
+			fmt.Fprintf(out, "Status: %s\n", info.Status)
+			fmt.Fprintf(out, "Requests: %d succeeded, %d failed, %d total\n", info.Succeeded, info.Failed, info.Total)
+			if info.Done {
+				fmt.Fprintln(out, "The batch has finished; collect its results with 'batch collect'")
+			}
+			return nil
+		},
+	})
+
+	batchCmd.AddCommand(&cobra.Command{
+		Use:   "collect [batch-id]",
+		Short: "Report the results of a finished batch job, by default the last one submitted",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reviewWorkflow, err := workflow.NewReviewWorkflow(workflowOptions())
+			if err != nil {
+				return fmt.Errorf("failed to create review workflow: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			stats, err := reviewWorkflow.CollectBatch(ctx, batchID(args))
+			if err != nil {
+				return err
+			}
+
+			logging.InfoWith("Batch collected", map[string]interface{}{
+				"files_processed":   stats.FilesProcessed,
+				"files_with_errors": stats.FilesWithErrors,
+				"total_issues":      stats.TotalIssues,
+			})
+			return nil
+		},
+	})
+
+	return batchCmd
+}
+
+// batchID returns the batch ID given on the command line, or an empty string
+// to use the last batch submitted
+func batchID(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/niels/git-llm-review/pkg/workflow"
+	"github.com/spf13/cobra"
+)
+
+// newExplainCmd creates the command that explains a change in plain language
+func newExplainCmd() *cobra.Command {
+	var diagram bool
+
+	explainCmd := &cobra.Command{
+		Use:   "explain [commit | range | file:line]",
+		Short: "Explain what a change does and why it matters",
+		Long: `Explain what a change does and why it matters, for readers who do not know the code yet.
+
+Without an argument the staged changes are explained, or all uncommitted
+changes with --all. Otherwise the argument is a commit such as HEAD~2, a range
+such as main..feature, or a line such as pkg/server.go:42 to explain the commit
+that last changed it. The explanation is written to the output directory as
+markdown if one is set.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reviewWorkflow, err := workflow.NewReviewWorkflow(workflowOptions())
+			if err != nil {
+				return fmt.Errorf("failed to create review workflow: %w", err)
+			}
+
+			var target string
+			if len(args) > 0 {
+				target = args[0]
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			_, err = reviewWorkflow.Explain(ctx, target, diagram)
+			return err
+		},
+	}
+	explainCmd.Flags().BoolVar(&diagram, "diagram", false, "Include a mermaid sequence or call diagram of the change")
+
+	return explainCmd
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/niels/git-llm-review/pkg/workflow"
+	"github.com/spf13/cobra"
+)
+
+// newPRDescriptionCmd creates the command that writes the description of a
+// pull request
+func newPRDescriptionCmd() *cobra.Command {
+	var options workflow.PRDescriptionOptions
+	var outputFile string
+	var jsonOutput bool
+
+	prDescriptionCmd := &cobra.Command{
+		Use:   "pr-description [branch]",
+		Short: "Write the title and description of a pull request for a branch",
+		Long: `Write the title and description of a pull request for a branch, the current
+branch by default, from its diff against the base branch and its commit
+messages.
+
+The base is the default branch unless --base is given. The description
+follows the repository's pull request template, such as
+.github/pull_request_template.md, filled in section by section, or the one
+given with --template. Without a template it has a summary, the motivation,
+the notable changes per area, testing notes and risk and rollback notes.
+
+The title, an empty line and the body are printed to stdout, or written to the
+file given with --file. --json prints them as a JSON object instead.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reviewWorkflow, err := workflow.NewReviewWorkflow(workflowOptions())
+			if err != nil {
+				return fmt.Errorf("failed to create review workflow: %w", err)
+			}
+			if len(args) > 0 {
+				options.Branch = args[0]
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			description, err := reviewWorkflow.DescribePullRequest(ctx, options)
+			if err != nil {
+				return err
+			}
+
+			output := description.String()
+			if jsonOutput {
+				data, err := json.MarshalIndent(description, "", "  ")
+				if err != nil {
+					return fmt.Errorf("failed to encode description: %w", err)
+				}
+				output = string(data) + "\n"
+			}
+
+			if outputFile == "" || outputFile == "-" {
+				fmt.Print(output)
+				return nil
+			}
+			if err := os.WriteFile(outputFile, []byte(output), 0644); err != nil {
+				return fmt.Errorf("failed to write description: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "Description written to %s\n", outputFile)
+			return nil
+		},
+	}
+	prDescriptionCmd.Flags().StringVar(&options.Base, "base", "", "Branch the pull request is merged into (default: the default branch)")
+	prDescriptionCmd.Flags().StringVar(&options.TemplatePath, "template", "", "Pull request template to fill in (default: the repository's template)")
+	prDescriptionCmd.Flags().BoolVar(&options.NoTemplate, "no-template", false, "Do not use a pull request template")
+	prDescriptionCmd.Flags().StringVarP(&outputFile, "file", "f", "", "Write the description to a file instead of stdout")
+	prDescriptionCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the title and body as a JSON object")
+
+	return prDescriptionCmd
+}
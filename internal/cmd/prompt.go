@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/niels/git-llm-review/pkg/workflow"
+	"github.com/spf13/cobra"
+)
+
+// newPromptCmd creates the command that shows the prompts built from the
+// templates in the repository
+func newPromptCmd() *cobra.Command {
+	promptCmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Inspect the prompts sent to the LLM",
+	}
+
+	promptCmd.AddCommand(&cobra.Command{
+		Use:   "render <file>",
+		Short: "Show the system and review prompts for a changed file without sending them",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rendered, err := workflow.RenderPrompt(workflowOptions(), args[0])
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			for i, pass := range rendered {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				fmt.Fprintf(out, "# Provider: %s\n", pass.Provider)
+				if pass.Focus != "" {
+					fmt.Fprintf(out, "# Focus pass: %s\n", pass.Focus)
+				}
+				fmt.Fprintf(out, "# Prompt templates: %s\n\n", pass.TemplateVersion)
+				fmt.Fprintln(out, "=== System prompt ===")
+				fmt.Fprintln(out, pass.System)
+				fmt.Fprintln(out, "\n=== Review prompt ===")
+				fmt.Fprintln(out, pass.User)
+			}
+			return nil
+		},
+	})
+
+	return promptCmd
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/niels/git-llm-review/pkg/config"
 	"github.com/niels/git-llm-review/pkg/git"
 	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/prompt"
 	"github.com/niels/git-llm-review/pkg/version"
 	"github.com/niels/git-llm-review/pkg/workflow"
 	"github.com/spf13/cobra"
@@ -20,9 +24,19 @@ var (
 	all             bool
 	showVersion     bool
 	providerName    string
+	profile         string
 	verbose         bool
 	logPrompts      bool
 	logFullExchange bool
+	recordPath      string
+	replayPath      string
+	batchMode       bool
+	changesetMode   bool
+	changeSummary   bool
+	intent          bool
+	intentMessage   string
+	focus           []string
+	focusMode       string
 	cfg             *config.Config
 	repoDetector    git.RepositoryDetector
 )
@@ -46,7 +60,7 @@ A tool that uses LLMs to review code changes in Git repositories.
 `, version.AppName, version.Description),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// Initialize the logger with default logging config
-			cfg := config.Default()
+			cfg = config.Default()
 			
 			// If config file is specified, try to load it first for logging settings
 			if configPath != "" {
@@ -86,16 +100,10 @@ A tool that uses LLMs to review code changes in Git repositories.
 			}
 			
 			// Create workflow options
-			options := workflow.Options{
-				ConfigPath:      configPath,
-				All:             all,
-				OutputFormat:    "terminal",
-				OutputPath:      outputDir,
-				ProviderName:    providerName,
-				VerboseOutput:   verbose,
-				LogPrompts:      logPrompts,
-				LogFullExchange: logFullExchange,
-			}
+			options := workflowOptions()
+			options.Batch = batchMode
+			options.Changeset = changesetMode
+			options.ChangeSummary = changeSummary
 			
 			// Create and run workflow
 			reviewWorkflow, err := workflow.NewReviewWorkflow(options)
@@ -106,8 +114,9 @@ A tool that uses LLMs to review code changes in Git repositories.
 				return fmt.Errorf("failed to create review workflow: %w", err)
 			}
 			
-			// Run the workflow
-			ctx := context.Background()
+			// Run the workflow, stopping in-flight requests on Ctrl-C
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
 			stats, err := reviewWorkflow.Run(ctx)
 			if err != nil {
 				logging.ErrorWith("Review workflow failed", map[string]interface{}{
@@ -116,6 +125,11 @@ A tool that uses LLMs to review code changes in Git repositories.
 				return fmt.Errorf("review workflow failed: %w", err)
 			}
 			
+			// A batch job is collected by a later run
+			if batchMode {
+				return nil
+			}
+			
 			// Log completion
 			logging.InfoWith("Review workflow completed", map[string]interface{}{
 				"files_processed":   stats.FilesProcessed,
@@ -135,13 +149,59 @@ A tool that uses LLMs to review code changes in Git repositories.
 	rootCmd.PersistentFlags().BoolVarP(&all, "all", "a", false, "Review all changed files (both staged and unstaged)")
 	rootCmd.PersistentFlags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
 	rootCmd.PersistentFlags().StringVarP(&providerName, "provider", "p", "", "LLM provider to use (overrides config)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Configuration profile to use (overrides default_profile)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "", false, "Enable verbose output")
 	rootCmd.PersistentFlags().BoolVarP(&logPrompts, "log-prompts", "x", false, "Log prompts to prompt.log for debugging")
 	rootCmd.PersistentFlags().BoolVar(&logFullExchange, "log-full-exchange", false, "Log both prompts and raw LLM responses to exchange.log")
+	rootCmd.PersistentFlags().StringVar(&recordPath, "record", "", "Record all provider traffic to a cassette file")
+	rootCmd.PersistentFlags().StringVar(&replayPath, "replay", "", "Replay provider traffic from a cassette file without network access")
+	rootCmd.PersistentFlags().StringSliceVar(&focus, "focus", nil, "Concentrate the review on "+strings.Join(focusNames(), ", ")+" (repeat or separate with commas)")
+	rootCmd.PersistentFlags().StringVar(&focusMode, "focus-mode", "", "Review several foci in one pass per file (combined) or in one pass per focus (separate)")
+	rootCmd.PersistentFlags().BoolVar(&intent, "intent", false, "Check that the changes do what the commit message, branch and referenced tickets say")
+	rootCmd.PersistentFlags().StringVar(&intentMessage, "intent-message", "", "State what the change is meant to do and check the changes against it (implies --intent)")
+	rootCmd.Flags().BoolVar(&batchMode, "batch", false, "Submit the reviews as a discounted batch job and collect them later with 'batch collect'")
+	rootCmd.Flags().BoolVar(&changesetMode, "changeset", false, "Review related files together in one prompt to find issues between them")
+	rootCmd.Flags().BoolVar(&changeSummary, "change-summary", false, "Summarize the whole change first and give every file review the summary as context")
+	
+	rootCmd.AddCommand(newBatchCmd())
+	rootCmd.AddCommand(newPromptCmd())
+	rootCmd.AddCommand(newExplainCmd())
+	rootCmd.AddCommand(newPRDescriptionCmd())
+	rootCmd.AddCommand(newSuggestTestsCmd())
 	
 	return rootCmd
 }
 
+// workflowOptions returns the workflow options set by the command line flags
+func workflowOptions() workflow.Options {
+	return workflow.Options{
+		ConfigPath:      configPath,
+		All:             all,
+		OutputFormat:    "terminal",
+		OutputPath:      outputDir,
+		ProviderName:    providerName,
+		Profile:         profile,
+		VerboseOutput:   verbose,
+		LogPrompts:      logPrompts,
+		LogFullExchange: logFullExchange,
+		RecordPath:      recordPath,
+		ReplayPath:      replayPath,
+		Focus:           focus,
+		FocusMode:       focusMode,
+		Intent:          intent,
+		IntentMessage:   intentMessage,
+	}
+}
+
+// focusNames returns the names of the review foci
+func focusNames() []string {
+	var names []string
+	for _, focusType := range prompt.FocusTypes() {
+		names = append(names, string(focusType))
+	}
+	return names
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
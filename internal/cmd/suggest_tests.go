@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/niels/git-llm-review/pkg/workflow"
+	"github.com/spf13/cobra"
+)
+
+// newSuggestTestsCmd creates the command that suggests tests for changed code
+func newSuggestTestsCmd() *cobra.Command {
+	var dir string
+
+	suggestTestsCmd := &cobra.Command{
+		Use:   "suggest-tests [path...]",
+		Short: "Suggest the missing test cases for the changed functions",
+		Long: `Suggest the missing test cases for the functions touched by the staged changes,
+or by all uncommitted changes with --all, limited to the given paths.
+
+For each changed Go, JavaScript or Python file, the model writes a runnable
+test file in the style of the project's tests next to it. The suggestions are
+printed, or written to the scratch directory given with --dir under their
+path in the repository. Existing files are never overwritten.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reviewWorkflow, err := workflow.NewReviewWorkflow(workflowOptions())
+			if err != nil {
+				return fmt.Errorf("failed to create review workflow: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			_, err = reviewWorkflow.SuggestTests(ctx, dir, args...)
+			return err
+		},
+	}
+	suggestTestsCmd.Flags().StringVar(&dir, "dir", "", "Scratch directory to write the suggested test files to instead of printing them")
+
+	return suggestTestsCmd
+}
@@ -0,0 +1,144 @@
+// Package batch stores the batch review jobs submitted to a provider, so that
+// their results can be collected by a later run.
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNoJob is returned when no submitted batch job is found
+var ErrNoJob = errors.New("no batch job found")
+
+// Job is a batch of reviews submitted to a provider
+type Job struct {
+	// ID is the provider's ID of the batch
+	ID string `json:"id"`
+	// Provider and Model are the provider and model the batch was sent to
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Profile is the configuration profile used to submit the batch
+	Profile string `json:"profile,omitempty"`
+	// TemplateVersion identifies the prompt templates the batch was built with
+	TemplateVersion string `json:"template_version,omitempty"`
+	// SubmittedAt is the time the batch was submitted
+	SubmittedAt time.Time `json:"submitted_at"`
+	// Files are the files reviewed in the batch
+	Files []File `json:"files"`
+}
+
+// File is a file reviewed in a batch job
+type File struct {
+	// RequestID identifies the review of the file within the batch
+	RequestID string `json:"request_id"`
+	// Path is the path of the file in the repository
+	Path string `json:"path"`
+	// Focus is the focus of the review, when the file was reviewed once per
+	// focus or with a single focus
+	Focus string `json:"focus,omitempty"`
+}
+
+// Dir returns the directory that holds the batch jobs of the repository at
+// repoRoot. The jobs are kept in the Git directory, out of the working tree.
+func Dir(repoRoot string) string {
+	gitDir := filepath.Join(repoRoot, ".git")
+
+	// In a worktree, .git is a file that points to the Git directory
+	if data, err := os.ReadFile(gitDir); err == nil {
+		if dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:"); ok {
+			gitDir = strings.TrimSpace(dir)
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(repoRoot, gitDir)
+			}
+		}
+	}
+
+	return filepath.Join(gitDir, "git-llm-review", "batches")
+}
+
+// Save writes the job to dir
+func Save(dir string, job *Job) error {
+	path, err := jobPath(dir, job.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create batch directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode batch job: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write batch job: %w", err)
+	}
+	return nil
+}
+
+// Load reads the job with the given ID from dir, or the most recently
+// submitted job if id is empty
+func Load(dir, id string) (*Job, error) {
+	if id == "" {
+		return latest(dir)
+	}
+
+	path, err := jobPath(dir, id)
+	if err != nil {
+		return nil, err
+	}
+	job, err := read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w with ID %s", ErrNoJob, id)
+	}
+	return job, err
+}
+
+// latest returns the most recently submitted job in dir
+func latest(dir string) (*Job, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch jobs: %w", err)
+	}
+
+	var newest *Job
+	for _, path := range paths {
+		job, err := read(path)
+		if err != nil {
+			return nil, err
+		}
+		if newest == nil || job.SubmittedAt.After(newest.SubmittedAt) {
+			newest = job
+		}
+	}
+	if newest == nil {
+		return nil, ErrNoJob
+	}
+	return newest, nil
+}
+
+// read reads the job stored at path
+func read(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("failed to parse batch job %s: %w", path, err)
+	}
+	return &job, nil
+}
+
+// jobPath returns the path of the file that stores the job with the given ID
+func jobPath(dir, id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid batch ID %q", id)
+	}
+	return filepath.Join(dir, id+".json"), nil
+}
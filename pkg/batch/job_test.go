@@ -0,0 +1,70 @@
+package batch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "batches")
+
+	if _, err := Load(dir, ""); !errors.Is(err, ErrNoJob) {
+		t.Errorf("Expected ErrNoJob without jobs, got: %v", err)
+	}
+
+	submitted := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	older := &Job{ID: "batch_old", Provider: "OpenAI", Model: "gpt-4o", SubmittedAt: submitted}
+	newer := &Job{
+		ID:          "batch_new",
+		Provider:    "OpenAI",
+		Model:       "gpt-4o",
+		SubmittedAt: submitted.Add(time.Hour),
+		Files:       []File{{RequestID: "file-1", Path: "main.go"}},
+	}
+	for _, job := range []*Job{newer, older} {
+		if err := Save(dir, job); err != nil {
+			t.Fatalf("Failed to save job: %v", err)
+		}
+	}
+
+	// The most recently submitted job is loaded without an ID
+	job, err := Load(dir, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if job.ID != "batch_new" || len(job.Files) != 1 || job.Files[0].Path != "main.go" {
+		t.Errorf("Expected the newer job, got %+v", job)
+	}
+
+	job, err = Load(dir, "batch_old")
+	if err != nil || job.ID != "batch_old" {
+		t.Errorf("Expected the older job, got %+v, %v", job, err)
+	}
+
+	if _, err := Load(dir, "batch_missing"); !errors.Is(err, ErrNoJob) {
+		t.Errorf("Expected ErrNoJob for an unknown ID, got: %v", err)
+	}
+	if _, err := Load(dir, "../config"); err == nil {
+		t.Error("Expected an error for an ID with a path separator")
+	}
+}
+
+func TestDir(t *testing.T) {
+	repoRoot := t.TempDir()
+	if got, want := Dir(repoRoot), filepath.Join(repoRoot, ".git", "git-llm-review", "batches"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	// A worktree points to its Git directory from a .git file
+	worktree := t.TempDir()
+	gitDir := filepath.Join(repoRoot, ".git", "worktrees", "feature")
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .git file: %v", err)
+	}
+	if got, want := Dir(worktree), filepath.Join(gitDir, "git-llm-review", "batches"); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
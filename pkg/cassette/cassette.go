@@ -0,0 +1,327 @@
+package cassette
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Modes for a cassette
+const (
+	// ModeRecord stores every exchange in the cassette file
+	ModeRecord = "record"
+	// ModeReplay serves responses from the cassette file without network access
+	ModeReplay = "replay"
+)
+
+// ErrNoMatch is returned in replay mode for a request that was not recorded
+var ErrNoMatch = errors.New("no recorded response for request")
+
+// Entry is a single recorded HTTP exchange, stored as one line of JSON
+type Entry struct {
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	BodyHash   string      `json:"body_sha256"`
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// key identifies the request an entry answers
+func (e *Entry) key() string {
+	return e.Method + " " + e.Path + " " + e.BodyHash
+}
+
+// skippedHeaders are response headers that are not recorded
+var skippedHeaders = []string{"Date", "Set-Cookie"}
+
+// Recorder is an http.RoundTripper that sends requests to the network and
+// appends every exchange to a cassette file. Request headers, which carry
+// API keys, are never recorded.
+type Recorder struct {
+	base http.RoundTripper
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	closed bool
+}
+
+// NewRecorder creates a recorder that writes a new cassette to path. The
+// file is created when the first exchange is recorded, so an existing
+// cassette is kept until then.
+func NewRecorder(base http.RoundTripper, path string) (*Recorder, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("failed to create cassette: directory %s not found", filepath.Dir(path))
+	}
+
+	return &Recorder{
+		base: base,
+		path: path,
+	}, nil
+}
+
+// RoundTrip sends the request and records the exchange
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the response so it can be recorded, then hand out a copy
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for cassette: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	for _, name := range skippedHeaders {
+		header.Del(name)
+	}
+
+	entry := Entry{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		BodyHash:   hashBody(body),
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       string(respBody),
+	}
+	if err := r.write(&entry); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// write appends an entry to the cassette file
+func (r *Recorder) write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette entry: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return fmt.Errorf("cassette %s is closed", r.path)
+	}
+	if r.file == nil {
+		file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create cassette: %w", err)
+		}
+		r.file = file
+	}
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write cassette %s: %w", r.path, err)
+	}
+	return nil
+}
+
+// Close closes the cassette file. Nothing is recorded after it.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Player is an http.RoundTripper that answers requests from a cassette file
+// without network access. Identical requests are answered with their
+// recorded responses in order.
+type Player struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string][]*Entry
+}
+
+// NewPlayer loads the cassette at path
+func NewPlayer(path string) (*Player, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer file.Close()
+
+	entries := make(map[string][]*Entry)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s line %d: %w", path, lineNumber, err)
+		}
+		entries[entry.key()] = append(entries[entry.key()], &entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette %s: %w", path, err)
+	}
+
+	return &Player{
+		path:    path,
+		entries: entries,
+	}, nil
+}
+
+// RoundTrip answers the request with the next matching recorded response
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := (&Entry{Method: req.Method, Path: req.URL.Path, BodyHash: hashBody(body)}).key()
+
+	p.mu.Lock()
+	queue := p.entries[key]
+	var entry *Entry
+	if len(queue) > 0 {
+		entry = queue[0]
+		p.entries[key] = queue[1:]
+	}
+	p.mu.Unlock()
+
+	if entry == nil {
+		return nil, fmt.Errorf("%w: %s %s (body sha256 %s) in cassette %s",
+			ErrNoMatch, req.Method, req.URL.Path, hashBody(body), p.path)
+	}
+
+	header := entry.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(entry.Body))),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the number of recorded responses that have not been served
+func (p *Player) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, queue := range p.entries {
+		count += len(queue)
+	}
+	return count
+}
+
+// readRequestBody returns the request body, restoring it so it can be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// hashBody returns the hex encoded SHA-256 of a request body
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	sharedMu    sync.Mutex
+	sharedTrips = make(map[string]http.RoundTripper)
+)
+
+// Open returns the recorder or player for the cassette at path. A recorder
+// sends requests through network. All providers in a run share one cassette
+// per path, so a recording captures every provider's traffic and a replay
+// serves each response once.
+func Open(mode, path string, network http.RoundTripper) (http.RoundTripper, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	key := mode + ":" + path
+	if rt, ok := sharedTrips[key]; ok {
+		return rt, nil
+	}
+
+	var (
+		rt  http.RoundTripper
+		err error
+	)
+	switch mode {
+	case ModeRecord:
+		rt, err = NewRecorder(network, path)
+	case ModeReplay:
+		rt, err = NewPlayer(path)
+	default:
+		return nil, fmt.Errorf("unknown cassette mode: %q", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sharedTrips[key] = rt
+	return rt, nil
+}
+
+// Close closes the cassette at path that Open returned for mode, once every
+// provider of the run is done with it. A later Open starts a new cassette.
+func Close(mode, path string) error {
+	sharedMu.Lock()
+	key := mode + ":" + path
+	rt := sharedTrips[key]
+	delete(sharedTrips, key)
+	sharedMu.Unlock()
+
+	if recorder, ok := rt.(*Recorder); ok {
+		return recorder.Close()
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package cassette
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestRecordAndReplay verifies that recorded responses are replayed in order
+// for identical requests, and that requests are never recorded
+func TestRecordAndReplay(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Request-Number", strconv.Itoa(int(n)))
+		if n == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("slow down"))
+			return
+		}
+		w.Write([]byte("answer"))
+	}))
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recorder, err := NewRecorder(nil, path)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	send := func(client *http.Client, body string) (*http.Response, string, error) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/v1/messages", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret-key")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data), nil
+	}
+
+	// Record the same request twice, as a retry would
+	client := &http.Client{Transport: recorder}
+	for i := 0; i < 2; i++ {
+		if _, _, err := send(client, `{"prompt":"review"}`); err != nil {
+			t.Fatalf("Failed to record request: %v", err)
+		}
+	}
+	recorder.Close()
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read cassette: %v", err)
+	}
+	if strings.Contains(string(data), "secret-key") {
+		t.Errorf("Expected request headers not to be recorded")
+	}
+
+	// Replay both responses in order
+	player, err := NewPlayer(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+	client = &http.Client{Transport: player}
+
+	resp, body, err := send(client, `{"prompt":"review"}`)
+	if err != nil {
+		t.Fatalf("Expected a replayed response, got: %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || body != "slow down" {
+		t.Errorf("Expected the first recorded response, got %d %q", resp.StatusCode, body)
+	}
+
+	resp, body, err = send(client, `{"prompt":"review"}`)
+	if err != nil {
+		t.Fatalf("Expected a replayed response, got: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || body != "answer" || resp.Header.Get("X-Request-Number") != "2" {
+		t.Errorf("Expected the second recorded response, got %d %q", resp.StatusCode, body)
+	}
+
+	if player.Remaining() != 0 {
+		t.Errorf("Expected all responses to be served, %d left", player.Remaining())
+	}
+
+	// Once the recorded responses are used up the request fails
+	if _, _, err := send(client, `{"prompt":"review"}`); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Expected ErrNoMatch, got: %v", err)
+	}
+}
+
+// TestReplayUnmatchedRequest verifies that a request with a different body
+// is not answered with another request's response
+func TestReplayUnmatchedRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	entry := `{"method":"POST","path":"/chat/completions","body_sha256":"` + hashBody([]byte("recorded")) + `","status":200,"body":"ok"}`
+	if err := os.WriteFile(path, []byte(entry+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write cassette: %v", err)
+	}
+
+	player, err := NewPlayer(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.invalid/chat/completions", strings.NewReader("different"))
+	_, err = player.RoundTrip(req)
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("Expected ErrNoMatch, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "/chat/completions") {
+		t.Errorf("Expected the error to name the request, got: %v", err)
+	}
+}
+
+// TestNewPlayerRejectsCorruptCassette verifies that a corrupt line is reported
+func TestNewPlayerRejectsCorruptCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	if err := os.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatalf("Failed to write cassette: %v", err)
+	}
+
+	if _, err := NewPlayer(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected an error naming line 1, got: %v", err)
+	}
+}
+
+// TestOpenAndClose verifies that the providers of a run share a cassette
+// until it is closed
+func TestOpenAndClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	first, err := Open(ModeRecord, path, nil)
+	if err != nil {
+		t.Fatalf("Failed to open cassette: %v", err)
+	}
+	if shared, _ := Open(ModeRecord, path, nil); shared != first {
+		t.Errorf("Expected the open cassette to be shared")
+	}
+
+	if err := Close(ModeRecord, path); err != nil {
+		t.Fatalf("Failed to close cassette: %v", err)
+	}
+	if err := first.(*Recorder).write(&Entry{Method: http.MethodPost}); err == nil {
+		t.Errorf("Expected writing to a closed cassette to fail")
+	}
+
+	second, err := Open(ModeRecord, path, nil)
+	if err != nil {
+		t.Fatalf("Failed to reopen cassette: %v", err)
+	}
+	defer Close(ModeRecord, path)
+	if second == first {
+		t.Errorf("Expected a new cassette after closing")
+	}
+}
+
+// TestRecorderKeepsCassetteUntilRecording verifies that an existing cassette
+// is only replaced once an exchange is recorded
+func TestRecorderKeepsCassetteUntilRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0600); err != nil {
+		t.Fatalf("Failed to write cassette: %v", err)
+	}
+
+	recorder, err := NewRecorder(nil, path)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Failed to close recorder: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{}\n" {
+		t.Errorf("Expected the cassette to be kept, got %q", data)
+	}
+
+	if _, err := NewRecorder(nil, filepath.Join(path, "missing", "cassette.jsonl")); err == nil {
+		t.Errorf("Expected an error for a missing directory")
+	}
+}
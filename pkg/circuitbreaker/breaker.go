@@ -0,0 +1,201 @@
+package circuitbreaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets all requests through
+	StateClosed State = iota
+	// StateOpen rejects all requests until the cool-down has passed
+	StateOpen
+	// StateHalfOpen lets a single probe request through to test the endpoint
+	StateHalfOpen
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("State(%d)", int(s))
+	}
+}
+
+// Result is the outcome of a request as seen by a circuit breaker
+type Result int
+
+const (
+	// Success shows the endpoint is up
+	Success Result = iota
+	// Failure shows the endpoint is failing
+	Failure
+	// Ignored says nothing about the endpoint, such as a cancelled request
+	Ignored
+)
+
+// Options configures a Breaker
+type Options struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+
+	// Cooldown is how long the circuit stays open before a probe request is allowed
+	Cooldown time.Duration
+}
+
+// Breaker is a circuit breaker for one endpoint. Once the endpoint has failed
+// FailureThreshold times in a row, requests are rejected immediately until
+// the cool-down has passed; then a single probe request decides whether the
+// circuit closes again. A Breaker is safe for concurrent use.
+type Breaker struct {
+	mu sync.Mutex
+
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	now func() time.Time
+}
+
+// New creates a new closed Breaker for the named endpoint
+func New(name string, opts Options) *Breaker {
+	threshold := opts.FailureThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  opts.Cooldown,
+		state:     StateClosed,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state of the breaker
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+// currentState returns the state, moving from open to half-open once the
+// cool-down has passed. The caller must hold b.mu.
+func (b *Breaker) currentState() State {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = StateHalfOpen
+		b.probing = false
+	}
+	return b.state
+}
+
+// Allow reports whether a request may be sent. If it may, the returned
+// function must be called with the result of the request. Otherwise the
+// error wraps llm.ErrProviderUnavailable.
+func (b *Breaker) Allow() (func(Result), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case StateOpen:
+		return nil, b.unavailableError()
+	case StateHalfOpen:
+		// Only one probe at a time
+		if b.probing {
+			return nil, b.unavailableError()
+		}
+		b.probing = true
+	}
+
+	return b.record, nil
+}
+
+// unavailableError returns the error for a rejected request. The caller must hold b.mu.
+func (b *Breaker) unavailableError() error {
+	retryIn := b.cooldown - b.now().Sub(b.openedAt)
+	if retryIn < 0 {
+		retryIn = 0
+	}
+	return fmt.Errorf("%w: %s failed %d times in a row, not sending requests for %s",
+		llm.ErrProviderUnavailable, b.name, b.threshold, retryIn.Round(time.Second))
+}
+
+// record updates the breaker with the result of a request
+func (b *Breaker) record(result Result) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch result {
+	case Ignored:
+		// Let another request probe the endpoint
+		if b.state == StateHalfOpen {
+			b.probing = false
+		}
+		return
+	case Success:
+		if b.state != StateClosed {
+			logging.InfoWith("Provider recovered, closing circuit breaker", map[string]interface{}{
+				"endpoint": b.name,
+			})
+		}
+		b.state = StateClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	// Failures of requests sent before the circuit opened do not extend the cool-down
+	b.failures++
+	if b.state == StateOpen {
+		return
+	}
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		logging.WarnWith("Provider unavailable, opening circuit breaker", map[string]interface{}{
+			"endpoint": b.name,
+			"failures": b.failures,
+			"cooldown": b.cooldown.String(),
+		})
+		b.state = StateOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
+
+var (
+	sharedMu       sync.Mutex
+	sharedBreakers = make(map[string]*Breaker)
+)
+
+// Shared returns the breaker for the named endpoint, creating it with opts if
+// it does not exist yet, so that all workers see the same circuit state
+func Shared(name string, opts Options) *Breaker {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if breaker, ok := sharedBreakers[name]; ok {
+		return breaker
+	}
+
+	breaker := New(name, opts)
+	sharedBreakers[name] = breaker
+	return breaker
+}
@@ -0,0 +1,188 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+// newTestBreaker creates a breaker with a manually advanced clock
+func newTestBreaker(opts Options) (*Breaker, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	b := New("test-endpoint", opts)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+// send simulates a request with the given result through the breaker
+func send(b *Breaker, result Result) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	done(result)
+	return nil
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(Options{FailureThreshold: 3, Cooldown: time.Minute})
+
+	// Failures below the threshold keep the circuit closed
+	for i := 0; i < 2; i++ {
+		if err := send(b, Failure); err != nil {
+			t.Fatalf("Expected request %d to be allowed, got: %v", i+1, err)
+		}
+	}
+	if b.State() != StateClosed {
+		t.Fatalf("Expected closed circuit, got %s", b.State())
+	}
+
+	// A success resets the count of consecutive failures
+	send(b, Success)
+	send(b, Failure)
+	send(b, Failure)
+	if b.State() != StateClosed {
+		t.Fatalf("Expected closed circuit after reset, got %s", b.State())
+	}
+
+	// The third consecutive failure opens the circuit
+	send(b, Failure)
+	if b.State() != StateOpen {
+		t.Fatalf("Expected open circuit, got %s", b.State())
+	}
+
+	// Requests now fail fast
+	err := send(b, Success)
+	if !errors.Is(err, llm.ErrProviderUnavailable) {
+		t.Errorf("Expected ErrProviderUnavailable, got: %v", err)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b, now := newTestBreaker(Options{FailureThreshold: 1, Cooldown: time.Minute})
+
+	send(b, Failure)
+	if b.State() != StateOpen {
+		t.Fatalf("Expected open circuit, got %s", b.State())
+	}
+
+	// After the cool-down a single probe is allowed
+	*now = now.Add(time.Minute)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("Expected half-open circuit, got %s", b.State())
+	}
+
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("Expected probe to be allowed, got: %v", err)
+	}
+	if _, err := b.Allow(); !errors.Is(err, llm.ErrProviderUnavailable) {
+		t.Errorf("Expected a second concurrent probe to be rejected, got: %v", err)
+	}
+
+	// A failed probe opens the circuit again
+	done(Failure)
+	if b.State() != StateOpen {
+		t.Fatalf("Expected open circuit after failed probe, got %s", b.State())
+	}
+
+	// A successful probe closes it
+	*now = now.Add(time.Minute)
+	if err := send(b, Success); err != nil {
+		t.Fatalf("Expected probe to be allowed, got: %v", err)
+	}
+	if b.State() != StateClosed {
+		t.Errorf("Expected closed circuit after successful probe, got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoredProbe(t *testing.T) {
+	b, now := newTestBreaker(Options{FailureThreshold: 1, Cooldown: time.Second})
+
+	send(b, Failure)
+	*now = now.Add(time.Second)
+
+	// A cancelled probe lets another request probe the endpoint
+	send(b, Ignored)
+	if b.State() != StateHalfOpen {
+		t.Fatalf("Expected half-open circuit, got %s", b.State())
+	}
+	if err := send(b, Success); err != nil {
+		t.Errorf("Expected another probe to be allowed, got: %v", err)
+	}
+}
+
+// TestTransportFailsFastForConcurrentWorkers verifies that once the endpoint
+// is down, concurrent workers stop sending requests to it
+func TestTransportFailsFastForConcurrentWorkers(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	breaker := New(server.URL, Options{FailureThreshold: 3, Cooldown: time.Minute})
+	client := &http.Client{Transport: NewTransport(nil, breaker)}
+
+	// Trip the breaker
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected request %d to reach the server, got: %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+
+	// Every worker now fails fast without reaching the server
+	var wg sync.WaitGroup
+	var unavailable int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.Get(server.URL)
+			if errors.Is(err, llm.ErrProviderUnavailable) {
+				atomic.AddInt32(&unavailable, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if unavailable != 10 {
+		t.Errorf("Expected 10 requests to fail fast, got %d", unavailable)
+	}
+	if calls != 3 {
+		t.Errorf("Expected the server to see 3 requests, got %d", calls)
+	}
+}
+
+// TestTransportClientErrorsKeepCircuitClosed verifies that rate limiting and
+// client errors do not count as the endpoint being down
+func TestTransportClientErrorsKeepCircuitClosed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	breaker := New(server.URL, Options{FailureThreshold: 1, Cooldown: time.Minute})
+	client := &http.Client{Transport: NewTransport(nil, breaker)}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected closed circuit, got %s", breaker.State())
+	}
+}
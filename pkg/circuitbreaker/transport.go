@@ -0,0 +1,66 @@
+package circuitbreaker
+
+import (
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that sends requests through a Breaker.
+// Connection failures and server errors count as failures; any other
+// response, including 429, shows the endpoint is up.
+type Transport struct {
+	// Base is the underlying transport (http.DefaultTransport if nil)
+	Base http.RoundTripper
+
+	// Breaker guards the endpoint
+	Breaker *Breaker
+}
+
+// NewTransport creates a transport guarded by breaker on top of base
+func NewTransport(base http.RoundTripper, breaker *Breaker) *Transport {
+	return &Transport{
+		Base:    base,
+		Breaker: breaker,
+	}
+}
+
+// RoundTrip sends the request unless the circuit is open
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Breaker == nil {
+		return base.RoundTrip(req)
+	}
+
+	done, err := t.Breaker.Allow()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		// A cancelled request says nothing about the endpoint
+		if req.Context().Err() != nil {
+			done(Ignored)
+		} else {
+			done(Failure)
+		}
+		return nil, err
+	}
+
+	if isServerFailure(resp.StatusCode) {
+		done(Failure)
+	} else {
+		done(Success)
+	}
+	return resp, nil
+}
+
+// isServerFailure reports whether a status code indicates that the endpoint is down
+func isServerFailure(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout
+}
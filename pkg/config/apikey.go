@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Sources of an API key, reported in APIKeySource
+const (
+	KeySourceEnv     = "LLM_API_KEY"
+	KeySourceCommand = "api_key_command"
+	KeySourceFile    = "api_key_file"
+	KeySourceConfig  = "api_key"
+)
+
+// providerKeyEnvVars lists the environment variables that hold the API key
+// of each provider, used when no key is configured
+var providerKeyEnvVars = map[string][]string{
+	"openai":    {"OPENAI_API_KEY"},
+	"anthropic": {"ANTHROPIC_API_KEY"},
+}
+
+// keylessProviders lists the providers that need no API key, so their key
+// sources are never read
+var keylessProviders = map[string]bool{
+	"fake": true,
+}
+
+// keyCommandCache holds the output of each api_key_command, so a command is
+// only run once per run even when several providers share it
+var keyCommandCache = struct {
+	sync.Mutex
+	keys map[string]string
+}{keys: make(map[string]string)}
+
+// ResolveAPIKeys sets the API key of the provider and of each fallback from
+// the first source that provides one, in this order:
+//
+//  1. the output of api_key_command
+//  2. the contents of api_key_file
+//  3. the LLM_API_KEY environment variable (primary provider only)
+//  4. api_key in the configuration file
+//  5. the provider's own environment variable, such as OPENAI_API_KEY
+//
+// A warning is printed when a key is read from a file tracked by Git, and
+// when LLM_API_KEY is ignored for a configured command or file. Keys that
+// are already resolved are kept, so ResolveAPIKeys can be called again.
+func (c *Config) ResolveAPIKeys() error {
+	if err := c.resolveAPIKey(&c.LLM, true); err != nil {
+		return err
+	}
+	for i := range c.LLM.Fallbacks {
+		if err := c.resolveAPIKey(&c.LLM.Fallbacks[i], false); err != nil {
+			return fmt.Errorf("fallback provider %d (%s): %w", i+1, c.LLM.Fallbacks[i].Provider, err)
+		}
+	}
+	return nil
+}
+
+// resolveAPIKey sets the API key of one provider
+func (c *Config) resolveAPIKey(llm *LLMConfig, useGlobalEnv bool) error {
+	if llm.APIKeySource != "" || keylessProviders[strings.ToLower(llm.Provider)] {
+		return nil
+	}
+
+	envKey := os.Getenv("LLM_API_KEY")
+	if !useGlobalEnv {
+		envKey = ""
+	}
+	if envKey != "" && (llm.APIKeyCommand != "" || llm.APIKeyFile != "") {
+		fmt.Fprintf(os.Stderr, "Warning: LLM_API_KEY is set but ignored, since %s is configured.\n", configuredKeySource(llm))
+	}
+
+	if llm.APIKeyCommand != "" {
+		key, err := runKeyCommand(llm.APIKeyCommand)
+		if err != nil {
+			return err
+		}
+		llm.APIKey = key
+		llm.APIKeySource = KeySourceCommand
+		return nil
+	}
+
+	if llm.APIKeyFile != "" {
+		key, err := readKeyFile(llm.APIKeyFile)
+		if err != nil {
+			return err
+		}
+		warnIfTracked(llm.APIKeyFile)
+		llm.APIKey = key
+		llm.APIKeySource = KeySourceFile
+		return nil
+	}
+
+	if envKey != "" {
+		llm.APIKey = envKey
+		llm.APIKeySource = KeySourceEnv
+		return nil
+	}
+
+	if llm.APIKey != "" {
+		if c.sourcePath != "" {
+			warnIfTracked(c.sourcePath)
+		}
+		llm.APIKeySource = KeySourceConfig
+		return nil
+	}
+
+	for _, name := range providerKeyEnvVars[strings.ToLower(llm.Provider)] {
+		if key := os.Getenv(name); key != "" {
+			llm.APIKey = key
+			llm.APIKeySource = name
+			return nil
+		}
+	}
+	return nil
+}
+
+// configuredKeySource names the key source configured for llm that takes
+// precedence over LLM_API_KEY
+func configuredKeySource(llm *LLMConfig) string {
+	if llm.APIKeyCommand != "" {
+		return KeySourceCommand
+	}
+	return KeySourceFile
+}
+
+// runKeyCommand runs an api_key_command and returns its output. The command
+// can prompt for a passphrase, since it shares the terminal.
+func runKeyCommand(command string) (string, error) {
+	keyCommandCache.Lock()
+	defer keyCommandCache.Unlock()
+
+	if key, ok := keyCommandCache.keys[command]; ok {
+		return key, nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run api_key_command: %w", err)
+	}
+
+	// Tools such as pass print the secret on the first line
+	key, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("api_key_command printed no key")
+	}
+
+	keyCommandCache.keys[command] = key
+	return key, nil
+}
+
+// readKeyFile returns the key stored in an api_key_file
+func readKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read api_key_file: %w", err)
+	}
+
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("api_key_file %s is empty", path)
+	}
+	return key, nil
+}
+
+// expandHome replaces a leading ~/ in path with the home directory
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// warnIfTracked prints a warning when the file at path is tracked by Git,
+// since the key it contains would end up in the repository history
+func warnIfTracked(path string) {
+	path = expandHome(path)
+	if !isTrackedByGit(path) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: API key read from %s, which is tracked by Git. "+
+		"Move the key to api_key_file or api_key_command outside the repository, or to an environment variable.\n", path)
+}
+
+// isTrackedByGit reports whether the file at path is tracked by Git
+func isTrackedByGit(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	cmd := exec.Command("git", "-C", filepath.Dir(absPath), "ls-files", "--error-unmatch", "--", filepath.Base(absPath))
+	return cmd.Run() == nil
+}
+
+// MaskSecret returns a version of secret that is safe to print, showing at
+// most the last four characters of long secrets
+func MaskSecret(secret string) string {
+	switch {
+	case secret == "":
+		return "(not set)"
+	case len(secret) < 16:
+		return "****"
+	default:
+		return "****" + secret[len(secret)-4:]
+	}
+}
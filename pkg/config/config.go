@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,7 +15,24 @@ type Config struct {
 	LLM         LLMConfig        `yaml:"llm"`
 	Concurrency ConcurrencyConfig `yaml:"concurrency"`
 	Retry       RetryConfig      `yaml:"retry"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	Cassette    CassetteConfig   `yaml:"cassette"`
+	HTTP        HTTPConfig       `yaml:"http"`
+	Output      OutputConfig     `yaml:"output"`
+	Prompt      PromptConfig     `yaml:"prompt"`
+	Changeset   ChangesetConfig  `yaml:"changeset"`
 	Logging     LogConfig        `yaml:"logging"`
+
+	// Profiles are named sets of provider and prompt settings that can be
+	// selected with --profile, or by default with DefaultProfile
+	Profiles       map[string]ProfileConfig `yaml:"profiles"`
+	DefaultProfile string                   `yaml:"default_profile"`
+
+	// ActiveProfile is the name of the profile applied by ApplyProfile
+	ActiveProfile string `yaml:"-"`
+
+	// sourcePath is the file the configuration was loaded from
+	sourcePath string
 }
 
 // LLMConfig contains settings for the LLM provider
@@ -23,6 +42,204 @@ type LLMConfig struct {
 	APIKey   string `yaml:"api_key"`
 	Model    string `yaml:"model"`
 	Timeout  int    `yaml:"timeout"` // in seconds
+
+	// APIKeyFile and APIKeyCommand read the API key from a file or from the
+	// output of a command, such as "pass show llm/openai"
+	APIKeyFile    string `yaml:"api_key_file"`
+	APIKeyCommand string `yaml:"api_key_command"`
+
+	// APIKeySource describes where ResolveAPIKeys found the API key
+	APIKeySource string `yaml:"-"`
+
+	// Generation settings apply to every model unless overridden in Models
+	GenerationConfig `yaml:",inline"`
+
+	// Models overrides the generation settings for individual models, keyed
+	// by model name
+	Models map[string]GenerationConfig `yaml:"models"`
+
+	// Tools selects how the model may call tools, such as looking up type
+	// definitions: "auto" (the default) uses native tool calls and switches
+	// to "text" for servers that reject them, "native", "text" describes the
+	// tools in the prompt, and "none" disables tools
+	Tools string `yaml:"tools"`
+
+	// RateLimit limits the requests sent to this provider
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+
+	// Fake contains the settings of the fake provider
+	Fake FakeConfig `yaml:"fake"`
+
+	// Fallbacks lists providers that are tried, in order, when this provider
+	// fails after exhausting its retries
+	Fallbacks []LLMConfig `yaml:"fallbacks"`
+}
+
+// GenerationConfig contains settings that control how a model generates a
+// response. Unset fields use the provider defaults.
+type GenerationConfig struct {
+	Temperature     *float64 `yaml:"temperature"`
+	MaxOutputTokens int      `yaml:"max_output_tokens"`
+	TopP            *float64 `yaml:"top_p"`
+	Seed            *int64   `yaml:"seed"`
+	Stop            []string `yaml:"stop"`
+	RequestTimeout  int      `yaml:"request_timeout"` // in seconds, defaults to the LLM timeout
+
+	// Reasoning overrides the detection of reasoning models from the model name
+	Reasoning       *bool    `yaml:"reasoning"`
+	ReasoningEffort string   `yaml:"reasoning_effort"` // "minimal", "low", "medium" or "high"
+	ThinkingBudget  int      `yaml:"thinking_budget"`  // tokens of extended thinking, 0 = disabled
+}
+
+// Merge returns the settings with every field that is set in override
+// replaced by the value from override
+func (g GenerationConfig) Merge(override GenerationConfig) GenerationConfig {
+	if override.Temperature != nil {
+		g.Temperature = override.Temperature
+	}
+	if override.MaxOutputTokens > 0 {
+		g.MaxOutputTokens = override.MaxOutputTokens
+	}
+	if override.TopP != nil {
+		g.TopP = override.TopP
+	}
+	if override.Seed != nil {
+		g.Seed = override.Seed
+	}
+	if len(override.Stop) > 0 {
+		g.Stop = override.Stop
+	}
+	if override.RequestTimeout > 0 {
+		g.RequestTimeout = override.RequestTimeout
+	}
+	if override.Reasoning != nil {
+		g.Reasoning = override.Reasoning
+	}
+	if override.ReasoningEffort != "" {
+		g.ReasoningEffort = override.ReasoningEffort
+	}
+	if override.ThinkingBudget > 0 {
+		g.ThinkingBudget = override.ThinkingBudget
+	}
+	return g
+}
+
+// Merge returns the settings with every field that is set in override
+// replaced by the value from override
+func (c LLMConfig) Merge(override LLMConfig) LLMConfig {
+	if override.Provider != "" {
+		c.Provider = override.Provider
+	}
+	if override.APIURL != "" {
+		c.APIURL = override.APIURL
+	}
+	// A key source in override replaces all configured key sources
+	if override.APIKey != "" || override.APIKeyFile != "" || override.APIKeyCommand != "" {
+		c.APIKey = override.APIKey
+		c.APIKeyFile = override.APIKeyFile
+		c.APIKeyCommand = override.APIKeyCommand
+	}
+	if override.Model != "" {
+		c.Model = override.Model
+	}
+	if override.Timeout > 0 {
+		c.Timeout = override.Timeout
+	}
+	c.GenerationConfig = c.GenerationConfig.Merge(override.GenerationConfig)
+	if len(override.Models) > 0 {
+		c.Models = override.Models
+	}
+	if override.RateLimit.RequestsPerMinute > 0 {
+		c.RateLimit.RequestsPerMinute = override.RateLimit.RequestsPerMinute
+	}
+	if override.RateLimit.TokensPerMinute > 0 {
+		c.RateLimit.TokensPerMinute = override.RateLimit.TokensPerMinute
+	}
+	if override.RateLimit.Adaptive != nil {
+		c.RateLimit.Adaptive = override.RateLimit.Adaptive
+	}
+	if override.RateLimit.MaxPause > 0 {
+		c.RateLimit.MaxPause = override.RateLimit.MaxPause
+	}
+	if override.Tools != "" {
+		c.Tools = override.Tools
+	}
+	if override.Fake.isSet() {
+		c.Fake = override.Fake
+	}
+	if len(override.Fallbacks) > 0 {
+		c.Fallbacks = override.Fallbacks
+	}
+	return c
+}
+
+// withoutEndpoint returns the settings without those that belong to one
+// provider or server: its URL, key sources, model and rate limits
+func (c LLMConfig) withoutEndpoint() LLMConfig {
+	c.APIURL = ""
+	c.APIKey = ""
+	c.APIKeyFile = ""
+	c.APIKeyCommand = ""
+	c.Model = ""
+	c.RateLimit = RateLimitConfig{}
+	return c
+}
+
+// Generation returns the generation settings for the configured model,
+// including any overrides for that model
+func (c LLMConfig) Generation() GenerationConfig {
+	if override, ok := c.Models[c.Model]; ok {
+		return c.GenerationConfig.Merge(override)
+	}
+	return c.GenerationConfig
+}
+
+// FakeConfig contains settings for the fake provider, which returns
+// deterministic reviews without network access
+type FakeConfig struct {
+	// Fixtures is a directory of reviews. The review of a file is read from
+	// the fixture that matches it in Responses, or else from the file with
+	// the same path and a .json extension, such as pkg/main.go.json.
+	Fixtures  string            `yaml:"fixtures"`
+	Responses []FakeResponse    `yaml:"responses"`
+
+	// Rules generate an issue for every added line that matches them. A
+	// rule that flags TODO and FIXME comments is used when no fixtures or
+	// rules are configured.
+	Rules []FakeRule `yaml:"rules"`
+
+	// Latency delays every response, in milliseconds
+	Latency int `yaml:"latency"`
+
+	// Errors injects failures for matching files
+	Errors []FakeError `yaml:"errors"`
+}
+
+// FakeResponse selects a fixture for the files matching a path or glob. A
+// glob without a slash matches file names, and an empty one every file.
+type FakeResponse struct {
+	Match   string `yaml:"match"`
+	Fixture string `yaml:"fixture"` // relative to the fixtures directory
+}
+
+// FakeRule reports an issue for every added line matching a regular expression
+type FakeRule struct {
+	Pattern     string `yaml:"pattern"`
+	Title       string `yaml:"title"`
+	Explanation string `yaml:"explanation"`
+}
+
+// FakeError injects a failure for the files matching a path or glob, which
+// is matched like FakeResponse.Match
+type FakeError struct {
+	Match string `yaml:"match"`
+	Kind  string `yaml:"kind"`  // "timeout", "rate_limit", "server_error" or "malformed"
+	Times int    `yaml:"times"` // fail only the first requests for each file, 0 = always
+}
+
+// isSet reports whether any fake provider setting is set
+func (f FakeConfig) isSet() bool {
+	return f.Fixtures != "" || len(f.Responses) > 0 || len(f.Rules) > 0 || f.Latency > 0 || len(f.Errors) > 0
 }
 
 // ConcurrencyConfig contains settings for concurrency control
@@ -30,6 +247,15 @@ type ConcurrencyConfig struct {
 	MaxTasks int `yaml:"max_tasks"`
 }
 
+// RateLimitConfig contains settings for client-side rate limiting. The limits
+// are shared by all workers that use the same endpoint and API key.
+type RateLimitConfig struct {
+	RequestsPerMinute int   `yaml:"requests_per_minute"` // 0 = unlimited
+	TokensPerMinute   int   `yaml:"tokens_per_minute"`   // estimated prompt tokens, 0 = unlimited
+	Adaptive          *bool `yaml:"adaptive"`            // reduce concurrency when rate limited
+	MaxPause          int   `yaml:"max_pause"`           // longest pause requested by the server to honour, in seconds, 0 = 300
+}
+
 // RetryConfig contains settings for retry behavior
 type RetryConfig struct {
 	Enabled       bool     `yaml:"enabled"`
@@ -41,6 +267,219 @@ type RetryConfig struct {
 	RetryableErrors []string `yaml:"retryable_errors"`
 }
 
+// CircuitBreakerConfig contains settings for the circuit breaker that stops
+// sending requests to an endpoint that keeps failing
+type CircuitBreakerConfig struct {
+	Enabled          bool `yaml:"enabled"`
+	FailureThreshold int  `yaml:"failure_threshold"` // consecutive failures that open the circuit
+	Cooldown         int  `yaml:"cooldown"`          // in seconds
+}
+
+// CassetteConfig contains settings for recording provider traffic to a
+// cassette file, or replaying it from one without network access
+type CassetteConfig struct {
+	Mode string `yaml:"mode"` // "record", "replay" or empty to disable
+	Path string `yaml:"path"` // path to the cassette file
+}
+
+// HTTPConfig contains settings for the HTTP connections to the LLM
+// providers, such as a proxy and the certificates it needs
+type HTTPConfig struct {
+	// Proxy is the URL of the proxy to use. The HTTPS_PROXY and HTTP_PROXY
+	// environment variables are used when it is empty.
+	Proxy string `yaml:"proxy"`
+
+	// CABundle is a PEM file with certificate authorities to trust in
+	// addition to the system ones
+	CABundle string `yaml:"ca_bundle"`
+
+	// ClientCert and ClientKey are PEM files with the certificate and key
+	// presented to servers that require client authentication
+	ClientCert string `yaml:"client_cert"`
+	ClientKey  string `yaml:"client_key"`
+
+	// InsecureSkipVerify disables certificate verification, for local
+	// servers with self-signed certificates only
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+
+	// Headers are added to every request
+	Headers map[string]string `yaml:"headers"`
+
+	// RequestIDHeader names a header that is set to a unique ID for every
+	// request. Retries of a request keep its ID.
+	RequestIDHeader string `yaml:"request_id_header"`
+}
+
+// Merge returns the settings with every field that is set in override
+// replaced by the value from override. Headers are merged by name.
+func (h HTTPConfig) Merge(override HTTPConfig) HTTPConfig {
+	if override.Proxy != "" {
+		h.Proxy = override.Proxy
+	}
+	if override.CABundle != "" {
+		h.CABundle = override.CABundle
+	}
+	if override.ClientCert != "" {
+		h.ClientCert = override.ClientCert
+	}
+	if override.ClientKey != "" {
+		h.ClientKey = override.ClientKey
+	}
+	if override.InsecureSkipVerify {
+		h.InsecureSkipVerify = override.InsecureSkipVerify
+	}
+	if len(override.Headers) > 0 {
+		headers := make(map[string]string, len(h.Headers)+len(override.Headers))
+		for name, value := range h.Headers {
+			headers[name] = value
+		}
+		for name, value := range override.Headers {
+			headers[name] = value
+		}
+		h.Headers = headers
+	}
+	if override.RequestIDHeader != "" {
+		h.RequestIDHeader = override.RequestIDHeader
+	}
+	return h
+}
+
+// PromptConfig contains settings for the review prompt
+type PromptConfig struct {
+	// AdditionalInstructions are added to the prompt of every review
+	AdditionalInstructions string `yaml:"additional_instructions"`
+
+	// TemplatesDir holds templates that override the built-in prompts, such
+	// as review.tmpl and system.tmpl. Relative paths are resolved against the
+	// repository root; defaults to .git-llm-reviewer/prompts.
+	TemplatesDir string `yaml:"templates_dir"`
+
+	// Guidelines lists files or glob patterns, relative to the repository
+	// root, whose content the review should follow, such as CONTRIBUTING.md
+	Guidelines []string `yaml:"guidelines"`
+
+	// GuidelineFile is the name of the guideline files that apply to the
+	// files in their directory and below; defaults to .review-guidelines.md
+	GuidelineFile string `yaml:"guideline_file"`
+
+	// GuidelinesMaxChars limits the size of the guidelines added to a
+	// prompt; longer guidelines are condensed or truncated
+	GuidelinesMaxChars int `yaml:"guidelines_max_chars"`
+
+	// Focus lists the kinds of issues reviews concentrate on by default,
+	// such as security or performance; empty for a general review
+	Focus []string `yaml:"focus"`
+
+	// FocusMode is "combined" to review each file once for all foci, or
+	// "separate" to review it once per focus; defaults to combined
+	FocusMode string `yaml:"focus_mode"`
+
+	// Checklists change the built-in review checklists, keyed by language
+	// name, such as python, or file extension, such as py
+	Checklists map[string]ChecklistConfig `yaml:"checklists"`
+
+	// ChangeSummary summarizes the whole change with one request before the
+	// files are reviewed, and adds the summary to the prompt of every file
+	ChangeSummary *bool `yaml:"change_summary"`
+
+	// Intent checks that the changes do what the commit message, the
+	// branch and the tickets it references say, like --intent
+	Intent *bool `yaml:"intent"`
+
+	// IntentMaxCommits limits the earlier commits on the branch whose
+	// messages describe the intent
+	IntentMaxCommits int `yaml:"intent_max_commits"`
+}
+
+// ChecklistConfig changes the review checklist of a language
+type ChecklistConfig struct {
+	// Items are the pitfalls to check for in addition to the built-in ones
+	Items []string `yaml:"items"`
+
+	// Replace drops the built-in checklist so that only Items are checked;
+	// without items, the language has no checklist
+	Replace bool `yaml:"replace"`
+}
+
+// Enabled reports whether a switch that may be left unset is turned on
+func Enabled(flag *bool) bool {
+	return flag != nil && *flag
+}
+
+// Merge returns the settings with every field that is set in override
+// replaced by the value from override. Checklists are merged by language.
+func (p PromptConfig) Merge(override PromptConfig) PromptConfig {
+	if override.AdditionalInstructions != "" {
+		p.AdditionalInstructions = override.AdditionalInstructions
+	}
+	if override.TemplatesDir != "" {
+		p.TemplatesDir = override.TemplatesDir
+	}
+	if len(override.Guidelines) > 0 {
+		p.Guidelines = override.Guidelines
+	}
+	if override.GuidelineFile != "" {
+		p.GuidelineFile = override.GuidelineFile
+	}
+	if override.GuidelinesMaxChars > 0 {
+		p.GuidelinesMaxChars = override.GuidelinesMaxChars
+	}
+	if len(override.Focus) > 0 {
+		p.Focus = override.Focus
+	}
+	if override.FocusMode != "" {
+		p.FocusMode = override.FocusMode
+	}
+	if len(override.Checklists) > 0 {
+		checklists := make(map[string]ChecklistConfig, len(p.Checklists)+len(override.Checklists))
+		for language, checklist := range p.Checklists {
+			checklists[language] = checklist
+		}
+		for language, checklist := range override.Checklists {
+			checklists[language] = checklist
+		}
+		p.Checklists = checklists
+	}
+	if override.ChangeSummary != nil {
+		p.ChangeSummary = override.ChangeSummary
+	}
+	if override.Intent != nil {
+		p.Intent = override.Intent
+	}
+	if override.IntentMaxCommits > 0 {
+		p.IntentMaxCommits = override.IntentMaxCommits
+	}
+	return p
+}
+
+// ProfileConfig contains the settings of a named profile. The provider
+// settings are written at the top level of the profile, like the llm section.
+type ProfileConfig struct {
+	LLMConfig `yaml:",inline"`
+	Prompt    PromptConfig `yaml:"prompt"`
+}
+
+// OutputConfig contains settings for the review reports
+type OutputConfig struct {
+	// IncludeRationale adds a condensed version of the model's reasoning to
+	// the markdown reports
+	IncludeRationale bool `yaml:"include_rationale"`
+}
+
+// ChangesetConfig contains settings for reviewing related files together
+type ChangesetConfig struct {
+	// Enabled reviews related files of a change in one prompt, so that
+	// issues between the files are found
+	Enabled bool `yaml:"enabled"`
+
+	// MaxTokens limits the estimated tokens of the content and diffs of the
+	// files in one prompt
+	MaxTokens int `yaml:"max_tokens"`
+
+	// MaxFiles limits the number of files in one prompt
+	MaxFiles int `yaml:"max_files"`
+}
+
 // LogConfig contains settings for logging
 type LogConfig struct {
 	LogToFile   bool   `yaml:"log_to_file"`
@@ -66,6 +505,15 @@ func LoadDefault() *Config {
 		Concurrency: ConcurrencyConfig{
 			MaxTasks: 5,
 		},
+		Prompt: PromptConfig{
+			GuidelineFile:      ".review-guidelines.md",
+			GuidelinesMaxChars: 12000, // about 3000 tokens
+			IntentMaxCommits:   10,
+		},
+		Changeset: ChangesetConfig{
+			MaxTokens: 24000,
+			MaxFiles:  8,
+		},
 		Retry: RetryConfig{
 			Enabled:        true,
 			MaxRetries:     3,
@@ -82,6 +530,11 @@ func LoadDefault() *Config {
 				"internal server error",
 			},
 		},
+		CircuitBreaker: CircuitBreakerConfig{
+			Enabled:          false,
+			FailureThreshold: 5,
+			Cooldown:         30,
+		},
 		Logging: LogConfig{
 			LogToFile:     false,
 			LogFilePath:   "git-llm-review.log",
@@ -118,26 +571,20 @@ func Load(configPath string) (*Config, error) {
 	}
 
 	// Merge file configuration with defaults
+	cfg.sourcePath = configPath
 	if len(fileCfg.Extensions) > 0 {
 		cfg.Extensions = fileCfg.Extensions
 	}
 
 	// Merge LLM configuration
-	if fileCfg.LLM.Provider != "" {
-		cfg.LLM.Provider = fileCfg.LLM.Provider
-	}
-	if fileCfg.LLM.APIURL != "" {
-		cfg.LLM.APIURL = fileCfg.LLM.APIURL
-	}
-	if fileCfg.LLM.APIKey != "" {
-		cfg.LLM.APIKey = fileCfg.LLM.APIKey
-	}
-	if fileCfg.LLM.Model != "" {
-		cfg.LLM.Model = fileCfg.LLM.Model
-	}
-	if fileCfg.LLM.Timeout > 0 {
-		cfg.LLM.Timeout = fileCfg.LLM.Timeout
-	}
+	cfg.LLM = cfg.LLM.Merge(fileCfg.LLM)
+
+	// Merge prompt configuration
+	cfg.Prompt = cfg.Prompt.Merge(fileCfg.Prompt)
+
+	// Keep the profiles; they are applied by ApplyProfile
+	cfg.Profiles = fileCfg.Profiles
+	cfg.DefaultProfile = fileCfg.DefaultProfile
 	
 	// Check for LLM_API_KEY environment variable to override the API key
 	if envKey := os.Getenv("LLM_API_KEY"); envKey != "" {
@@ -172,6 +619,44 @@ func Load(configPath string) (*Config, error) {
 		cfg.Retry.RetryableErrors = fileCfg.Retry.RetryableErrors
 	}
 
+	// Merge circuit breaker configuration
+	if fileCfg.CircuitBreaker.Enabled {
+		cfg.CircuitBreaker.Enabled = fileCfg.CircuitBreaker.Enabled
+	}
+	if fileCfg.CircuitBreaker.FailureThreshold > 0 {
+		cfg.CircuitBreaker.FailureThreshold = fileCfg.CircuitBreaker.FailureThreshold
+	}
+	if fileCfg.CircuitBreaker.Cooldown > 0 {
+		cfg.CircuitBreaker.Cooldown = fileCfg.CircuitBreaker.Cooldown
+	}
+
+	// Merge cassette configuration
+	if fileCfg.Cassette.Mode != "" {
+		cfg.Cassette.Mode = fileCfg.Cassette.Mode
+	}
+	if fileCfg.Cassette.Path != "" {
+		cfg.Cassette.Path = fileCfg.Cassette.Path
+	}
+
+	// Merge HTTP configuration
+	cfg.HTTP = cfg.HTTP.Merge(fileCfg.HTTP)
+
+	// Merge output configuration
+	if fileCfg.Output.IncludeRationale {
+		cfg.Output.IncludeRationale = fileCfg.Output.IncludeRationale
+	}
+
+	// Merge changeset configuration
+	if fileCfg.Changeset.Enabled {
+		cfg.Changeset.Enabled = fileCfg.Changeset.Enabled
+	}
+	if fileCfg.Changeset.MaxTokens > 0 {
+		cfg.Changeset.MaxTokens = fileCfg.Changeset.MaxTokens
+	}
+	if fileCfg.Changeset.MaxFiles > 0 {
+		cfg.Changeset.MaxFiles = fileCfg.Changeset.MaxFiles
+	}
+
 	// Merge logging configuration
 	if fileCfg.Logging.LogToFile {
 		cfg.Logging.LogToFile = fileCfg.Logging.LogToFile
@@ -198,6 +683,80 @@ func Load(configPath string) (*Config, error) {
 	return cfg, nil
 }
 
+// ApplyProfile applies the settings of the named profile on top of the
+// configuration. An empty name selects the default profile, if one is set.
+// Settings that the profile leaves out keep their configured values, except
+// that a profile setting a provider or API URL is a full LLM configuration:
+// its URL, key sources, model and rate limits come only from the profile.
+func (c *Config) ApplyProfile(name string) error {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	// A profile that points at another provider or server brings its own
+	// endpoint and key, so that the key of the configured provider is never
+	// sent elsewhere; only neutral settings such as the timeout are kept
+	base := c.LLM
+	if profile.Provider != "" || profile.APIURL != "" {
+		base = base.withoutEndpoint()
+	}
+	c.LLM = base.Merge(profile.LLMConfig)
+	c.Prompt = c.Prompt.Merge(profile.Prompt)
+	c.ActiveProfile = name
+	return nil
+}
+
+// OverrideProvider switches to the provider with the given name, as the
+// --provider flag does. Like a profile for another provider, it drops the
+// URL, key sources, model and rate limits of the configured provider when
+// the provider changes.
+func (c *Config) OverrideProvider(name string) {
+	if name == "" || strings.EqualFold(name, c.LLM.Provider) {
+		return
+	}
+	c.LLM = c.LLM.withoutEndpoint()
+	c.LLM.Provider = name
+}
+
+// ProfileNames returns the names of the configured profiles in sorted order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FallbackConfigs returns a configuration for each fallback provider, in order.
+// Each copy shares the non-LLM settings of the primary configuration, and
+// inherits the primary timeout and per-model settings when the fallback does
+// not set its own.
+func (c *Config) FallbackConfigs() []*Config {
+	configs := make([]*Config, 0, len(c.LLM.Fallbacks))
+	for _, fallback := range c.LLM.Fallbacks {
+		fallbackCfg := *c
+		fallbackCfg.LLM = fallback
+		fallbackCfg.LLM.Fallbacks = nil
+		if fallbackCfg.LLM.Timeout <= 0 {
+			fallbackCfg.LLM.Timeout = c.LLM.Timeout
+		}
+		if len(fallbackCfg.LLM.Models) == 0 {
+			fallbackCfg.LLM.Models = c.LLM.Models
+		}
+		configs = append(configs, &fallbackCfg)
+	}
+	return configs
+}
+
 // LoadOrDefault attempts to load configuration from a file
 // If the file doesn't exist or can't be parsed, it returns default configuration
 func LoadOrDefault(configPath string) *Config {
@@ -2,8 +2,10 @@ package config
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -183,4 +185,396 @@ func TestLoadDefaultConfig(t *testing.T) {
 	if cfg.Concurrency.MaxTasks != 5 {
 		t.Errorf("Expected default max tasks 5, got %d", cfg.Concurrency.MaxTasks)
 	}
+
+	// Check default changeset limits
+	if cfg.Changeset.Enabled || cfg.Changeset.MaxTokens != 24000 || cfg.Changeset.MaxFiles != 8 {
+		t.Errorf("Expected changeset reviews to be off with default limits, got %+v", cfg.Changeset)
+	}
+
+	// Check the default intent settings
+	if cfg.Prompt.Intent != nil || cfg.Prompt.IntentMaxCommits != 10 {
+		t.Errorf("Expected intent checks to be off with 10 commits, got %v and %d", cfg.Prompt.Intent, cfg.Prompt.IntentMaxCommits)
+	}
+}
+
+func TestFallbackConfigs(t *testing.T) {
+	// Create a temporary directory for test files
+	tempDir, err := os.MkdirTemp("", "git-llm-review-test")
+	if err != nil {
+		t.Fatalf("Failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	configPath := filepath.Join(tempDir, "fallback-config.yaml")
+	configContent := `
+llm:
+  provider: openai
+  api_key: primary-key
+  model: gpt-4
+  timeout: 120
+  fallbacks:
+    - provider: anthropic
+      api_key: fallback-key
+      model: claude-3-5-sonnet-latest
+    - provider: openai
+      api_url: http://localhost:8080/v1
+      api_key: local-key
+      model: local-model
+      timeout: 600
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	fallbacks := cfg.FallbackConfigs()
+	if len(fallbacks) != 2 {
+		t.Fatalf("Expected 2 fallback configs, got %d", len(fallbacks))
+	}
+
+	// The first fallback inherits the primary timeout
+	if fallbacks[0].LLM.Provider != "anthropic" || fallbacks[0].LLM.APIKey != "fallback-key" {
+		t.Errorf("Unexpected first fallback: %+v", fallbacks[0].LLM)
+	}
+	if fallbacks[0].LLM.Timeout != 120 {
+		t.Errorf("Expected inherited timeout 120, got %d", fallbacks[0].LLM.Timeout)
+	}
+
+	// The second fallback keeps its own timeout
+	if fallbacks[1].LLM.Timeout != 600 {
+		t.Errorf("Expected timeout 600, got %d", fallbacks[1].LLM.Timeout)
+	}
+
+	// Other settings are shared with the primary configuration
+	if !reflect.DeepEqual(fallbacks[1].Extensions, cfg.Extensions) {
+		t.Errorf("Expected fallback to share extensions with the primary config")
+	}
+}
+
+// TestGenerationSettings verifies that generation settings are loaded and that
+// per-model overrides apply to the configured model only
+func TestGenerationSettings(t *testing.T) {
+	tempDir := t.TempDir()
+
+	configPath := filepath.Join(tempDir, "generation-config.yaml")
+	configContent := `
+llm:
+  provider: openai
+  api_key: test-key
+  model: o3-mini
+  temperature: 0
+  max_output_tokens: 8000
+  seed: 42
+  stop: ["<END>"]
+  models:
+    o3-mini:
+      temperature: 1
+      request_timeout: 900
+  fallbacks:
+    - provider: openai
+      api_key: test-key
+      model: gpt-4o
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// A temperature of 0 is kept rather than treated as unset
+	if cfg.LLM.Temperature == nil || *cfg.LLM.Temperature != 0 {
+		t.Errorf("Expected temperature 0, got %v", cfg.LLM.Temperature)
+	}
+
+	// The model override replaces only the settings it sets
+	generation := cfg.LLM.Generation()
+	if generation.Temperature == nil || *generation.Temperature != 1 {
+		t.Errorf("Expected overridden temperature 1, got %v", generation.Temperature)
+	}
+	if generation.RequestTimeout != 900 {
+		t.Errorf("Expected request timeout 900, got %d", generation.RequestTimeout)
+	}
+	if generation.MaxOutputTokens != 8000 || generation.Seed == nil || *generation.Seed != 42 {
+		t.Errorf("Expected shared settings to be kept, got %+v", generation)
+	}
+	if !reflect.DeepEqual(generation.Stop, []string{"<END>"}) {
+		t.Errorf("Expected stop sequences to be kept, got %v", generation.Stop)
+	}
+
+	// The fallback uses a different model, so the override does not apply,
+	// but it still knows about the per-model settings
+	fallback := cfg.FallbackConfigs()[0]
+	if fallback.LLM.Generation().Temperature != nil {
+		t.Errorf("Expected no temperature for the fallback, got %v", *fallback.LLM.Generation().Temperature)
+	}
+	if _, ok := fallback.LLM.Models["o3-mini"]; !ok {
+		t.Errorf("Expected the fallback to inherit the per-model settings")
+	}
+}
+
+// TestHTTPSettings verifies that the http section is loaded
+func TestHTTPSettings(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "http-config.yaml")
+	configContent := `
+http:
+  proxy: http://proxy.example.com:3128
+  ca_bundle: /etc/ssl/corp-ca.pem
+  headers:
+    X-Gateway-Team: reviews
+  request_id_header: X-Request-ID
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.HTTP.Proxy != "http://proxy.example.com:3128" || cfg.HTTP.CABundle != "/etc/ssl/corp-ca.pem" {
+		t.Errorf("Expected the proxy and CA bundle to be loaded, got %+v", cfg.HTTP)
+	}
+	if cfg.HTTP.Headers["X-Gateway-Team"] != "reviews" || cfg.HTTP.RequestIDHeader != "X-Request-ID" {
+		t.Errorf("Expected the headers to be loaded, got %+v", cfg.HTTP)
+	}
+	if cfg.HTTP.InsecureSkipVerify {
+		t.Error("Expected certificate verification to stay enabled")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	configPath := filepath.Join(tempDir, "profiles-config.yaml")
+	configContent := `
+llm:
+  provider: openai
+  api_url: https://openai.example.com/v1
+  api_key: test-key
+  model: gpt-4o
+  timeout: 120
+prompt:
+  additional_instructions: Follow the style guide
+  intent: true
+  checklists:
+    go:
+      items: ["Handlers check permissions"]
+default_profile: quick
+profiles:
+  quick:
+    api_url: http://localhost:11434/v1
+    model: qwen2.5-coder
+    prompt:
+      additional_instructions: Only report bugs
+      change_summary: true
+      intent: false
+      checklists:
+        python:
+          replace: true
+  thorough:
+    provider: anthropic
+    api_key: anthropic-key
+    model: claude-sonnet-4-20250514
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	t.Run("Default profile", func(t *testing.T) {
+		cfg, err := Load(configPath)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if err := cfg.ApplyProfile(""); err != nil {
+			t.Fatalf("Failed to apply profile: %v", err)
+		}
+
+		if cfg.ActiveProfile != "quick" {
+			t.Errorf("Expected active profile quick, got %q", cfg.ActiveProfile)
+		}
+		if cfg.LLM.Model != "qwen2.5-coder" || cfg.LLM.APIURL != "http://localhost:11434/v1" {
+			t.Errorf("Expected the profile's model and URL, got %s at %s", cfg.LLM.Model, cfg.LLM.APIURL)
+		}
+		// Neutral settings the profile leaves out keep their configured values
+		if cfg.LLM.Provider != "openai" || cfg.LLM.Timeout != 120 {
+			t.Errorf("Expected unset profile settings to be kept, got %+v", cfg.LLM)
+		}
+		// The key of the configured server is not sent to the profile's server
+		if cfg.LLM.APIKey != "" || cfg.LLM.APIKeyFile != "" || cfg.LLM.APIKeyCommand != "" {
+			t.Errorf("Expected no inherited key, got %+v", cfg.LLM)
+		}
+		if cfg.Prompt.AdditionalInstructions != "Only report bugs" {
+			t.Errorf("Expected the profile's instructions, got %q", cfg.Prompt.AdditionalInstructions)
+		}
+		// Checklists are merged by language
+		if len(cfg.Prompt.Checklists["go"].Items) != 1 || !cfg.Prompt.Checklists["python"].Replace {
+			t.Errorf("Expected the configured and the profile's checklists, got %+v", cfg.Prompt.Checklists)
+		}
+		if !Enabled(cfg.Prompt.ChangeSummary) {
+			t.Error("Expected the profile to enable change summaries")
+		}
+		// A profile can also turn off what the configuration turns on
+		if Enabled(cfg.Prompt.Intent) {
+			t.Error("Expected the profile to disable intent checks")
+		}
+	})
+
+	t.Run("Named profile", func(t *testing.T) {
+		cfg, err := Load(configPath)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if err := cfg.ApplyProfile("thorough"); err != nil {
+			t.Fatalf("Failed to apply profile: %v", err)
+		}
+
+		if cfg.LLM.Provider != "anthropic" || cfg.LLM.APIKey != "anthropic-key" {
+			t.Errorf("Expected the anthropic profile, got %+v", cfg.LLM)
+		}
+		if cfg.LLM.Timeout != 120 || cfg.LLM.APIURL != "" {
+			t.Errorf("Expected the configured timeout and no configured URL, got %+v", cfg.LLM)
+		}
+		if cfg.Prompt.AdditionalInstructions != "Follow the style guide" {
+			t.Errorf("Expected the configured instructions, got %q", cfg.Prompt.AdditionalInstructions)
+		}
+		if !Enabled(cfg.Prompt.Intent) || cfg.Prompt.ChangeSummary != nil {
+			t.Errorf("Expected the configured intent checks and no change summary, got %v and %v", cfg.Prompt.Intent, cfg.Prompt.ChangeSummary)
+		}
+	})
+
+	t.Run("Unknown profile", func(t *testing.T) {
+		cfg, err := Load(configPath)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		err = cfg.ApplyProfile("missing")
+		if err == nil || !strings.Contains(err.Error(), "quick, thorough") {
+			t.Errorf("Expected an error listing the available profiles, got: %v", err)
+		}
+	})
+}
+
+func TestResolveAPIKeys(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	keyFile := filepath.Join(tempDir, "openai.key")
+	if err := os.WriteFile(keyFile, []byte("sk-from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	t.Run("Precedence", func(t *testing.T) {
+		cfg := &Config{LLM: LLMConfig{Provider: "openai", APIKey: "sk-from-config", APIKeyFile: keyFile}}
+		if err := cfg.ResolveAPIKeys(); err != nil {
+			t.Fatalf("Failed to resolve API keys: %v", err)
+		}
+		if cfg.LLM.APIKey != "sk-from-file" || cfg.LLM.APIKeySource != KeySourceFile {
+			t.Errorf("Expected the key from the file, got %q from %q", cfg.LLM.APIKey, cfg.LLM.APIKeySource)
+		}
+
+		// An explicitly configured file takes precedence over LLM_API_KEY
+		t.Setenv("LLM_API_KEY", "sk-from-env")
+		cfg = &Config{LLM: LLMConfig{Provider: "openai", APIKeyFile: keyFile}}
+		if err := cfg.ResolveAPIKeys(); err != nil {
+			t.Fatalf("Failed to resolve API keys: %v", err)
+		}
+		if cfg.LLM.APIKey != "sk-from-file" || cfg.LLM.APIKeySource != KeySourceFile {
+			t.Errorf("Expected the key from the file, got %q from %q", cfg.LLM.APIKey, cfg.LLM.APIKeySource)
+		}
+
+		// LLM_API_KEY overrides the key in the configuration file
+		cfg = &Config{LLM: LLMConfig{Provider: "openai", APIKey: "sk-from-config", Fallbacks: []LLMConfig{
+			{Provider: "anthropic"},
+		}}}
+		t.Setenv("ANTHROPIC_API_KEY", "sk-ant-from-env")
+		if err := cfg.ResolveAPIKeys(); err != nil {
+			t.Fatalf("Failed to resolve API keys: %v", err)
+		}
+		if cfg.LLM.APIKey != "sk-from-env" || cfg.LLM.APIKeySource != KeySourceEnv {
+			t.Errorf("Expected the key from LLM_API_KEY, got %q from %q", cfg.LLM.APIKey, cfg.LLM.APIKeySource)
+		}
+		// Fallbacks use their provider's variable
+		if cfg.LLM.Fallbacks[0].APIKey != "sk-ant-from-env" || cfg.LLM.Fallbacks[0].APIKeySource != "ANTHROPIC_API_KEY" {
+			t.Errorf("Expected the fallback key from ANTHROPIC_API_KEY, got %q", cfg.LLM.Fallbacks[0].APIKey)
+		}
+	})
+
+	t.Run("Command is run once", func(t *testing.T) {
+		counter := filepath.Join(tempDir, "runs")
+		command := "echo run >> " + counter + "; echo sk-from-command"
+		cfg := &Config{LLM: LLMConfig{Provider: "openai", APIKeyCommand: command, Fallbacks: []LLMConfig{
+			{Provider: "openai", APIKeyCommand: command},
+		}}}
+		if err := cfg.ResolveAPIKeys(); err != nil {
+			t.Fatalf("Failed to resolve API keys: %v", err)
+		}
+		if cfg.LLM.APIKey != "sk-from-command" || cfg.LLM.Fallbacks[0].APIKey != "sk-from-command" {
+			t.Errorf("Expected the key from the command, got %q and %q", cfg.LLM.APIKey, cfg.LLM.Fallbacks[0].APIKey)
+		}
+		runs, err := os.ReadFile(counter)
+		if err != nil {
+			t.Fatalf("Failed to read counter: %v", err)
+		}
+		if count := strings.Count(string(runs), "run"); count != 1 {
+			t.Errorf("Expected the command to run once, ran %d times", count)
+		}
+	})
+
+	t.Run("Keyless provider", func(t *testing.T) {
+		cfg := &Config{LLM: LLMConfig{Provider: "fake", APIKeyCommand: "exit 3"}}
+		if err := cfg.ResolveAPIKeys(); err != nil {
+			t.Errorf("Expected the command of a keyless provider not to run, got: %v", err)
+		}
+	})
+
+	t.Run("Failing command", func(t *testing.T) {
+		cfg := &Config{LLM: LLMConfig{Provider: "openai", APIKeyCommand: "exit 3"}}
+		if err := cfg.ResolveAPIKeys(); err == nil {
+			t.Error("Expected an error for a failing command")
+		}
+	})
+
+	t.Run("Masking", func(t *testing.T) {
+		if masked := MaskSecret("sk-test-secret-key-1234567890"); masked != "****7890" {
+			t.Errorf("Expected ****7890, got %s", masked)
+		}
+		if masked := MaskSecret("short"); masked != "****" {
+			t.Errorf("Expected short secrets to be hidden completely, got %s", masked)
+		}
+	})
+}
+
+func TestIsTrackedByGit(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := exec.Command("git", "-C", repoDir, "init", "-q").Run(); err != nil {
+		t.Skipf("git is not available: %v", err)
+	}
+
+	tracked := filepath.Join(repoDir, "config.yaml")
+	untracked := filepath.Join(repoDir, "local.key")
+	for _, path := range []string{tracked, untracked} {
+		if err := os.WriteFile(path, []byte("api_key: sk-test\n"), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	if err := exec.Command("git", "-C", repoDir, "add", "config.yaml").Run(); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+
+	if !isTrackedByGit(tracked) {
+		t.Error("Expected config.yaml to be tracked")
+	}
+	if isTrackedByGit(untracked) {
+		t.Error("Expected local.key not to be tracked")
+	}
 }
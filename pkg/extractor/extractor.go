@@ -8,7 +8,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/niels/git-llm-review/pkg/logging"
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/smacker/go-tree-sitter/golang"
 	"github.com/smacker/go-tree-sitter/javascript"
@@ -43,6 +45,9 @@ type CodeExtractor struct {
 	Language Language
 	parser   *sitter.Parser
 	dirPath  string
+
+	// mu guards the parser, which cannot be used concurrently
+	mu sync.Mutex
 }
 
 // NewCodeExtractor creates a new code extractor for the specified language
@@ -76,6 +81,13 @@ func (ce *CodeExtractor) ExtractFunctionAtLine(filePath string, lineNumber int)
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
+	return ce.ExtractFunctionAtLineIn(content, lineNumber)
+}
+
+// ExtractFunctionAtLineIn extracts a function containing the specified line
+// number from the given code, such as a version of a file that is not in the
+// working tree
+func (ce *CodeExtractor) ExtractFunctionAtLineIn(content []byte, lineNumber int) (string, error) {
 	// Count lines in the file
 	lines := bytes.Count(content, []byte{'\n'}) + 1
 
@@ -85,7 +97,9 @@ func (ce *CodeExtractor) ExtractFunctionAtLine(filePath string, lineNumber int)
 	}
 
 	// Parse the code into an AST
+	ce.mu.Lock()
 	tree := ce.parser.Parse(nil, content)
+	ce.mu.Unlock()
 	rootNode := tree.RootNode()
 
 	// Convert 1-indexed line number to 0-indexed for internal processing
@@ -147,6 +161,59 @@ func (ce *CodeExtractor) ExtractFunctionAtLine(filePath string, lineNumber int)
 	return "", fmt.Errorf("no function found containing line %d", lineNumber)
 }
 
+// LanguageOf returns the language of the file at filePath, and false if the
+// language is not supported
+func LanguageOf(filePath string) (Language, bool) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".go":
+		return Go, true
+	case ".js", ".jsx", ".ts", ".tsx":
+		return JavaScript, true
+	case ".py":
+		return Python, true
+	default:
+		return "", false
+	}
+}
+
+// Set holds one CodeExtractor per language, all searching the same
+// directory, so that the reviews of a run share them
+type Set struct {
+	dirPath    string
+	mu         sync.Mutex
+	extractors map[Language]*CodeExtractor
+}
+
+// NewSet creates a set of extractors that search dirPath
+func NewSet(dirPath string) *Set {
+	return &Set{
+		dirPath:    dirPath,
+		extractors: make(map[Language]*CodeExtractor),
+	}
+}
+
+// ForFile returns the extractor for the language of the file at filePath,
+// or nil if the language is not supported
+func (s *Set) ForFile(filePath string) *CodeExtractor {
+	lang, ok := LanguageOf(filePath)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ce, ok := s.extractors[lang]; ok {
+		return ce
+	}
+	ce, err := NewCodeExtractor(lang, s.dirPath)
+	if err != nil {
+		return nil
+	}
+	s.extractors[lang] = ce
+	return ce
+}
+
 // DetectLanguage attempts to detect the language from the file extension
 func DetectLanguage(filePath string) Language {
 	ext := strings.ToLower(filepath.Ext(filePath))
@@ -167,7 +234,9 @@ func DetectLanguage(filePath string) Language {
 // ExtractTypeByName extracts a type definition by its name
 func (ce *CodeExtractor) ExtractTypeByName(content []byte, typeName string) (string, error) {
 	// Parse the code into an AST
+	ce.mu.Lock()
 	tree := ce.parser.Parse(nil, content)
+	ce.mu.Unlock()
 	rootNode := tree.RootNode()
 
 	// Find a type node with the specified name
@@ -268,7 +337,10 @@ func (ce *CodeExtractor) FindDefinitionForType(typeName string) (string, string,
 	var result string
 	var foundFilePath string
 
-	fmt.Println("Searching for type definition:", typeName)
+	logging.DebugWith("Searching for type definition", map[string]interface{}{
+		"type": typeName,
+		"dir":  ce.dirPath,
+	})
 
 	err := filepath.WalkDir(ce.dirPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -0,0 +1,122 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// testDirs are the directories, next to the code, that tests are kept in
+var testDirs = []string{"tests", "test", "__tests__"}
+
+// IsTestFile reports whether the file at filePath holds tests, by the naming
+// conventions of its language
+func IsTestFile(filePath string) bool {
+	lang, ok := LanguageOf(filePath)
+	if !ok {
+		return false
+	}
+
+	base := strings.ToLower(filepath.Base(filePath))
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	switch lang {
+	case Go:
+		return strings.HasSuffix(name, "_test")
+	case Python:
+		return strings.HasPrefix(name, "test_") || strings.HasSuffix(name, "_test")
+	default:
+		return strings.HasSuffix(name, ".test") || strings.HasSuffix(name, ".spec")
+	}
+}
+
+// TestFilePath returns the path of the test file for the source file at
+// filePath, named like example, the path of a test file whose style is
+// followed, if it is not empty. Python and JavaScript tests go in the
+// directory of example; Go tests stay in the package of the code.
+func TestFilePath(filePath, example string) string {
+	dir := filepath.Dir(filePath)
+	ext := filepath.Ext(filePath)
+	name := strings.TrimSuffix(filepath.Base(filePath), ext)
+	exampleBase := strings.ToLower(filepath.Base(example))
+
+	lang, _ := LanguageOf(filePath)
+	if lang != Go && example != "" {
+		dir = filepath.Dir(example)
+	}
+	switch lang {
+	case Go:
+		return filepath.Join(dir, name+"_test"+ext)
+	case Python:
+		if strings.HasSuffix(exampleBase, "_test.py") && !strings.HasPrefix(exampleBase, "test_") {
+			return filepath.Join(dir, name+"_test"+ext)
+		}
+		return filepath.Join(dir, "test_"+name+ext)
+	default:
+		if strings.Contains(exampleBase, ".spec.") {
+			return filepath.Join(dir, name+".spec"+ext)
+		}
+		return filepath.Join(dir, name+".test"+ext)
+	}
+}
+
+// NearestTestFile returns the test file closest to the source file at
+// filePath, in the language of the file, to show the style of the tests of
+// the project: its own test file, or else one in its directory, in a test
+// directory next to it, or in its parent directory. It returns an empty
+// string if there is none.
+func NearestTestFile(filePath string) string {
+	lang, ok := LanguageOf(filePath)
+	if !ok {
+		return ""
+	}
+
+	dir := filepath.Dir(filePath)
+	var dirs []string
+	for _, base := range []string{dir, filepath.Dir(dir)} {
+		dirs = append(dirs, base)
+		for _, testDir := range testDirs {
+			dirs = append(dirs, filepath.Join(base, testDir))
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	for _, testDir := range dirs {
+		testFiles := FindTestFiles(testDir, lang)
+		if len(testFiles) == 0 {
+			continue
+		}
+		own := TestFilePath(filePath, testFiles[0])
+		for _, testFile := range testFiles {
+			if testFile == own {
+				return testFile
+			}
+		}
+		return testFiles[0]
+	}
+	return ""
+}
+
+// FindTestFiles returns the test files in dirPath in the language lang,
+// sorted by name
+func FindTestFiles(dirPath string, lang Language) []string {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil
+	}
+
+	var testFiles []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dirPath, entry.Name())
+		if fileLang, ok := LanguageOf(path); ok && fileLang == lang && IsTestFile(path) {
+			testFiles = append(testFiles, path)
+		}
+	}
+	sort.Strings(testFiles)
+	return testFiles
+}
@@ -0,0 +1,90 @@
+package extractor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"pkg/client_test.go":       true,
+		"pkg/client.go":            false,
+		"tests/test_client.py":     true,
+		"client_test.py":           true,
+		"client.py":                false,
+		"src/client.test.ts":       true,
+		"src/client.spec.js":       true,
+		"src/client.js":            false,
+		"docs/client_test.md":      false,
+		"pkg/testdata/fixtures.go": false,
+	}
+
+	for filePath, expected := range tests {
+		if got := IsTestFile(filePath); got != expected {
+			t.Errorf("IsTestFile(%q) = %v, expected %v", filePath, got, expected)
+		}
+	}
+}
+
+func TestTestFilePath(t *testing.T) {
+	tests := []struct {
+		filePath string
+		example  string
+		expected string
+	}{
+		{filePath: "pkg/client.go", expected: "pkg/client_test.go"},
+		{filePath: "pkg/client.go", example: "other/server_test.go", expected: "pkg/client_test.go"},
+		{filePath: "app/client.py", expected: "app/test_client.py"},
+		{filePath: "app/client.py", example: "tests/test_server.py", expected: "tests/test_client.py"},
+		{filePath: "app/client.py", example: "app/server_test.py", expected: "app/client_test.py"},
+		{filePath: "src/client.ts", expected: "src/client.test.ts"},
+		{filePath: "src/client.ts", example: "src/__tests__/server.spec.ts", expected: "src/__tests__/client.spec.ts"},
+	}
+
+	for _, tt := range tests {
+		if got := TestFilePath(tt.filePath, tt.example); got != tt.expected {
+			t.Errorf("TestFilePath(%q, %q) = %q, expected %q", tt.filePath, tt.example, got, tt.expected)
+		}
+	}
+}
+
+func TestNearestTestFile(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{
+		"app/client.py",
+		"app/server.py",
+		"tests/test_a.py",
+		"tests/test_server.py",
+		"pkg/client.go",
+		"pkg/cache_test.go",
+		"pkg/client_test.go",
+		"lib/util.js",
+	} {
+		fullPath := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte("\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string]string{
+		// The file's own tests come first
+		"pkg/client.go": "pkg/client_test.go",
+		"app/server.py": "tests/test_server.py",
+		// Otherwise the first test file nearby
+		"app/client.py": "tests/test_a.py",
+		"lib/util.js":   "",
+	}
+	for filePath, expected := range tests {
+		got := NearestTestFile(filepath.Join(root, filePath))
+		if expected != "" {
+			expected = filepath.Join(root, expected)
+		}
+		if got != expected {
+			t.Errorf("NearestTestFile(%q) = %q, expected %q", filePath, got, expected)
+		}
+	}
+}
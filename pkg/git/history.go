@@ -0,0 +1,247 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Change is a set of changes read from the repository, such as the staged
+// changes, a commit or a range of commits
+type Change struct {
+	// Description names the change, such as "commit 1a2b3c4 Fix retries"
+	Description string
+	// Messages are the messages of the commits in the change, newest first
+	Messages []string
+	// Stat lists the changed files, like git diff --stat
+	Stat string
+	// Diff is the patch of the change
+	Diff string
+}
+
+// HistoryReader is implemented by repository detectors that can read changes
+// from the history of the repository
+type HistoryReader interface {
+	// GetWorkingChange returns the staged changes, or with all the changes
+	// to tracked files, limited to paths if any are given
+	GetWorkingChange(dir string, all bool, paths ...string) (*Change, error)
+	// GetCommitChange returns the changes made by a commit
+	GetCommitChange(dir, rev string) (*Change, error)
+	// GetRangeChange returns the changes made by a range of commits, such as
+	// main..feature
+	GetRangeChange(dir, revRange string) (*Change, error)
+	// GetLineCommit returns the commit that last changed a line of a file, or
+	// an empty string if the line has not been committed
+	GetLineCommit(dir, path string, line int) (string, error)
+	// GetBranchBase returns the base branch that branch is compared to and
+	// the commit where branch left it. The base is the default branch if
+	// base is empty.
+	GetBranchBase(dir, branch, base string) (string, string, error)
+}
+
+// uncommittedHash is the hash git blame reports for uncommitted lines
+const uncommittedHash = "0000000000000000000000000000000000000000"
+
+// hunkPattern matches the header of a hunk and the first line it covers in
+// the new version of the file
+var hunkPattern = regexp.MustCompile(`^@@ -[0-9,]+ \+([0-9]+)`)
+
+// GetWorkingChange returns the staged changes, or with all the changes to
+// tracked files, limited to paths if any are given
+func (d *RepositoryDetectorImpl) GetWorkingChange(dir string, all bool, paths ...string) (*Change, error) {
+	args := []string{"-C", dir, "diff", "--cached"}
+	description := "staged changes"
+	if all {
+		args = []string{"-C", dir, "diff", "HEAD"}
+		description = "uncommitted changes"
+	}
+	if len(paths) > 0 {
+		description += " to " + strings.Join(paths, ", ")
+	}
+	paths = append([]string{"--"}, paths...)
+
+	stat, err := d.runGit("failed to get changed files", append(append(args, "--stat"), paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := d.runGit("failed to get diff", append(args, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &Change{Description: description, Stat: stat, Diff: diff}, nil
+}
+
+// GetCommitChange returns the changes made by a commit
+func (d *RepositoryDetectorImpl) GetCommitChange(dir, rev string) (*Change, error) {
+	header, err := d.runGit(fmt.Sprintf("unknown commit %s", rev),
+		"-C", dir, "log", "-1", "--date=short", "--format=%h %s (%an, %ad)", rev+"^{commit}", "--")
+	if err != nil {
+		return nil, err
+	}
+	message, err := d.runGit("failed to get commit message", "-C", dir, "log", "-1", "--format=%B", rev+"^{commit}", "--")
+	if err != nil {
+		return nil, err
+	}
+	stat, err := d.runGit("failed to get changed files", "-C", dir, "show", "--format=", "--stat", rev)
+	if err != nil {
+		return nil, err
+	}
+	diff, err := d.runGit("failed to get diff", "-C", dir, "show", "--format=", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Change{
+		Description: "commit " + strings.TrimSpace(header),
+		Messages:    []string{strings.TrimSpace(message)},
+		Stat:        stat,
+		Diff:        diff,
+	}, nil
+}
+
+// GetRangeChange returns the changes made by a range of commits, such as
+// main..feature or main...feature
+func (d *RepositoryDetectorImpl) GetRangeChange(dir, revRange string) (*Change, error) {
+	output, err := d.runGit(fmt.Sprintf("unknown range %s", revRange), "-C", dir, "log", "--format=%B%x00", revRange, "--")
+	if err != nil {
+		return nil, err
+	}
+	var messages []string
+	for _, message := range strings.Split(output, commitSeparator) {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+
+	stat, err := d.runGit("failed to get changed files", "-C", dir, "diff", "--stat", revRange, "--")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := d.runGit("failed to get diff", "-C", dir, "diff", revRange, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Change{
+		Description: fmt.Sprintf("range %s (%d commits)", revRange, len(messages)),
+		Messages:    messages,
+		Stat:        stat,
+		Diff:        diff,
+	}, nil
+}
+
+// GetLineCommit returns the commit that last changed a line of a file, or an
+// empty string if the line has not been committed
+func (d *RepositoryDetectorImpl) GetLineCommit(dir, path string, line int) (string, error) {
+	lineRange := strconv.Itoa(line) + "," + strconv.Itoa(line)
+	output, err := d.runGit(fmt.Sprintf("failed to find line %d of %s", line, path),
+		"-C", dir, "blame", "--porcelain", "-L", lineRange, "--", path)
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("failed to find line %d of %s", line, path)
+	}
+	if fields[0] == uncommittedHash {
+		return "", nil
+	}
+	return fields[0], nil
+}
+
+// GetBranchBase returns the base branch that branch is compared to and the
+// commit where branch left it, as the base of a pull request. The base is the
+// default branch if base is empty; the upstream of branch is not used, since
+// it is usually the same branch on the remote.
+func (d *RepositoryDetectorImpl) GetBranchBase(dir, branch, base string) (string, string, error) {
+	if base != "" {
+		mergeBase, err := d.runGit(fmt.Sprintf("failed to compare %s with %s", branch, base),
+			"-C", dir, "merge-base", base, branch)
+		if err != nil {
+			return "", "", err
+		}
+		return base, strings.TrimSpace(mergeBase), nil
+	}
+
+	for _, candidate := range defaultBranches {
+		mergeBase, err := d.runGit("no common commit", "-C", dir, "merge-base", candidate, branch)
+		if errors.Is(err, ErrGitNotInstalled) {
+			return "", "", err
+		}
+		if err == nil {
+			return candidate, strings.TrimSpace(mergeBase), nil
+		}
+	}
+	return "", "", fmt.Errorf("failed to find the base branch of %s, give it as the base", branch)
+}
+
+// runGit runs git with args and returns its output, or an error starting with
+// msg and holding what git reported
+func (d *RepositoryDetectorImpl) runGit(msg string, args ...string) (string, error) {
+	output, err := d.cmdRunner.runCommand("git", args...)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", ErrGitNotInstalled
+		}
+		if detail := strings.TrimSpace(string(output)); detail != "" {
+			return "", fmt.Errorf("%s: %s", msg, firstLine(detail))
+		}
+		return "", fmt.Errorf("%s: %w", msg, err)
+	}
+	return string(output), nil
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	if i := strings.Index(text, "\n"); i >= 0 {
+		return text[:i]
+	}
+	return text
+}
+
+// ChangedLines returns the lines each file of a diff adds or changes,
+// numbered as in the new version of the file. Where lines are only removed,
+// the line after the removal is included, so that the code around it counts
+// as changed.
+func ChangedLines(diff string) map[string][]int {
+	changed := make(map[string][]int)
+	var file string
+	line := 0
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			file, line = "", 0
+		case line == 0 && strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			}
+		case strings.HasPrefix(text, "@@ "):
+			line = 0
+			if match := hunkPattern.FindStringSubmatch(text); match != nil {
+				line, _ = strconv.Atoi(match[1])
+			}
+		case file == "" || line == 0:
+		case strings.HasPrefix(text, "+"):
+			changed[file] = appendLine(changed[file], line)
+			line++
+		case strings.HasPrefix(text, "-"):
+			changed[file] = appendLine(changed[file], line)
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return changed
+}
+
+// appendLine adds line to the ascending lines, unless it is the last one
+func appendLine(lines []int, line int) []int {
+	if len(lines) > 0 && lines[len(lines)-1] == line {
+		return lines
+	}
+	return append(lines, line)
+}
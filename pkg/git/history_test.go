@@ -0,0 +1,162 @@
+package git
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetCommitChange(t *testing.T) {
+	detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+		outputFunc: func(name string, args ...string) ([]byte, error) {
+			if args[len(args)-1] == "--" && strings.HasPrefix(args[len(args)-2], "nope") {
+				return []byte("fatal: bad revision 'nope^{commit}'\n"), errors.New("exit status 128")
+			}
+			switch joined := strings.Join(args[2:], " "); {
+			case strings.Contains(joined, "--format=%h"):
+				return []byte("1a2b3c4 Add retries (Jane, 2024-01-02)\n"), nil
+			case strings.Contains(joined, "--format=%B"):
+				return []byte("Add retries\n\nRetry failed requests.\n\n"), nil
+			case strings.Contains(joined, "--stat"):
+				return []byte(" client.go | 2 ++\n"), nil
+			case strings.HasPrefix(joined, "show"):
+				return []byte("diff --git a/client.go b/client.go\n+retries := 3\n"), nil
+			}
+			return nil, errors.New("unexpected command")
+		},
+	}}
+
+	change, err := detector.GetCommitChange(".", "1a2b3c4")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if change.Description != "commit 1a2b3c4 Add retries (Jane, 2024-01-02)" {
+		t.Errorf("Unexpected description %q", change.Description)
+	}
+	if len(change.Messages) != 1 || change.Messages[0] != "Add retries\n\nRetry failed requests." {
+		t.Errorf("Unexpected messages %q", change.Messages)
+	}
+	if !strings.Contains(change.Diff, "+retries := 3") || !strings.Contains(change.Stat, "client.go") {
+		t.Errorf("Expected the stat and diff of the commit, got %+v", change)
+	}
+
+	// What git reports about an unknown commit is passed on
+	if _, err := detector.GetCommitChange(".", "nope"); err == nil || !strings.Contains(err.Error(), "bad revision") {
+		t.Errorf("Expected an error about the revision, got: %v", err)
+	}
+}
+
+func TestGetLineCommit(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name:     "Committed line",
+			output:   "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432 4 3 1\nauthor Jane\n",
+			expected: "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432",
+		},
+		{
+			name:     "Uncommitted line",
+			output:   uncommittedHash + " 3 3 1\nauthor Not Committed Yet\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var blameArgs []string
+			detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+				outputFunc: func(name string, args ...string) ([]byte, error) {
+					blameArgs = args
+					return []byte(tt.output), nil
+				},
+			}}
+
+			commit, err := detector.GetLineCommit(".", "client.go", 3)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if commit != tt.expected {
+				t.Errorf("Expected commit %q, got %q", tt.expected, commit)
+			}
+			if !strings.Contains(strings.Join(blameArgs, " "), "blame --porcelain -L 3,3 -- client.go") {
+				t.Errorf("Unexpected git arguments %v", blameArgs)
+			}
+		})
+	}
+}
+
+func TestGetBranchBase(t *testing.T) {
+	var tried []string
+	detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+		outputFunc: func(name string, args ...string) ([]byte, error) {
+			tried = append(tried, args[3])
+			if args[3] == "main" || args[3] == "release" {
+				return []byte("a93c012\n"), nil
+			}
+			return []byte("fatal: Not a valid object name " + args[3] + "\n"), errors.New("exit status 128")
+		},
+	}}
+
+	base, mergeBase, err := detector.GetBranchBase(".", "feature/retry", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if base != "main" || mergeBase != "a93c012" {
+		t.Errorf("Expected main at a93c012, got %s at %s", base, mergeBase)
+	}
+	if strings.Join(tried, " ") != "origin/HEAD origin/main origin/master main" {
+		t.Errorf("Expected the default branches to be tried in order without the upstream, got %v", tried)
+	}
+
+	if base, _, err := detector.GetBranchBase(".", "feature/retry", "release"); err != nil || base != "release" {
+		t.Errorf("Expected the given base, got %q, %v", base, err)
+	}
+	if _, _, err := detector.GetBranchBase(".", "feature/retry", "nope"); err == nil || !strings.Contains(err.Error(), "Not a valid object name") {
+		t.Errorf("Expected an error about the base, got: %v", err)
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	diff := `diff --git a/client.go b/client.go
+index 1111111..2222222 100644
+--- a/client.go
++++ b/client.go
+@@ -3,4 +3,5 @@ import "fmt"
+ func retry() {
+-	tries := 1
++	tries := 3
++	// --- keep trying
+ 	fmt.Println(tries)
+@@ -20,3 +21,2 @@ func other() {
+ 	a()
+-	b()
+ 	c()
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package old
+-
+diff --git a/new.py b/new.py
+new file mode 100644
+--- /dev/null
++++ b/new.py
+@@ -0,0 +1,2 @@
++def f():
+++++ not a header
+`
+	changed := ChangedLines(diff)
+
+	expected := map[string][]int{
+		"client.go": {4, 5, 22},
+		"new.py":    {1, 2},
+	}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Expected %v, got %v", expected, changed)
+	}
+}
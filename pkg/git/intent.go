@@ -0,0 +1,151 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IntentReader is implemented by repository detectors that can read what a
+// change is meant to do from the repository
+type IntentReader interface {
+	// GetBranchName returns the name of the current branch, or an empty
+	// string if HEAD is detached
+	GetBranchName(dir string) (string, error)
+	// GetCommitMessageDraft returns the commit message being written for the
+	// staged changes, or an empty string if there is none
+	GetCommitMessageDraft(dir string) (string, error)
+	// GetBranchCommitMessages returns the messages of at most limit commits
+	// on the current branch that are not on its base branch, newest first
+	GetBranchCommitMessages(dir string, limit int) ([]string, error)
+}
+
+// defaultBranches are the refs tried, in order, as the default branch of the
+// repository
+var defaultBranches = []string{"origin/HEAD", "origin/main", "origin/master", "main", "master"}
+
+// baseCandidates are the refs tried, in order, as the branch that the
+// current branch was started from
+var baseCandidates = append([]string{"@{upstream}"}, defaultBranches...)
+
+// draftFiles are the files in the Git directory that hold a commit message
+// being written: by git commit, a merge or a squash
+var draftFiles = []string{"COMMIT_EDITMSG", "MERGE_MSG", "SQUASH_MSG"}
+
+// commitSeparator ends each message in the output of git log, written by
+// the %x00 placeholder of its format
+const commitSeparator = "\x00"
+
+// GetBranchName returns the name of the current branch, or an empty string if
+// HEAD is detached
+func (d *RepositoryDetectorImpl) GetBranchName(dir string) (string, error) {
+	output, err := d.cmdRunner.runCommand("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", ErrGitNotInstalled
+		}
+		return "", fmt.Errorf("failed to get branch name: %w", err)
+	}
+
+	branch := strings.TrimSpace(string(output))
+	if branch == "HEAD" {
+		return "", nil
+	}
+	return branch, nil
+}
+
+// GetCommitMessageDraft returns the commit message being written for the
+// staged changes, such as the message of a commit-msg hook or of a pending
+// merge. A message file left over from the last commit is not a draft, so it
+// is ignored when it holds the message of HEAD.
+func (d *RepositoryDetectorImpl) GetCommitMessageDraft(dir string) (string, error) {
+	var lastMessage string
+	if output, err := d.cmdRunner.runCommand("git", "-C", dir, "log", "-1", "--format=%B"); err == nil {
+		lastMessage = strings.TrimSpace(string(output))
+	}
+
+	for _, name := range draftFiles {
+		output, err := d.cmdRunner.runCommand("git", "-C", dir, "rev-parse", "--git-path", name)
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return "", ErrGitNotInstalled
+			}
+			return "", fmt.Errorf("failed to find %s: %w", name, err)
+		}
+
+		path := strings.TrimSpace(string(output))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		message := stripCommitComments(string(data))
+		if message != "" && message != lastMessage {
+			return message, nil
+		}
+	}
+	return "", nil
+}
+
+// GetBranchCommitMessages returns the messages of at most limit commits on
+// the current branch that are not on its base branch, newest first. The base
+// is the upstream of the branch, or else the default branch. Without a base
+// no commits are returned, to leave out the history of the whole repository.
+func (d *RepositoryDetectorImpl) GetBranchCommitMessages(dir string, limit int) ([]string, error) {
+	var base string
+	for _, candidate := range baseCandidates {
+		output, err := d.cmdRunner.runCommand("git", "-C", dir, "merge-base", "HEAD", candidate)
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return nil, ErrGitNotInstalled
+			}
+			continue
+		}
+		base = strings.TrimSpace(string(output))
+		break
+	}
+	if base == "" {
+		return nil, nil
+	}
+
+	args := []string{"-C", dir, "log", "--format=%B%x00"}
+	if limit > 0 {
+		args = append(args, "-n", strconv.Itoa(limit))
+	}
+	args = append(args, base+"..HEAD")
+	output, err := d.cmdRunner.runCommand("git", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit messages: %w", err)
+	}
+
+	var messages []string
+	for _, message := range strings.Split(string(output), commitSeparator) {
+		if message = strings.TrimSpace(message); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// stripCommitComments removes the comment lines that Git adds to a commit
+// message, and everything below the scissors line of git commit --verbose
+func stripCommitComments(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if strings.HasPrefix(line, "# ") && strings.Contains(line, ">8") {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetBranchName(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{name: "Branch", output: "feature/PROJ-12-retry\n", expected: "feature/PROJ-12-retry"},
+		{name: "Detached HEAD", output: "HEAD\n", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+				outputFunc: func(name string, args ...string) ([]byte, error) {
+					return []byte(tt.output), nil
+				},
+			}}
+			branch, err := detector.GetBranchName(".")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if branch != tt.expected {
+				t.Errorf("Expected branch %q, got %q", tt.expected, branch)
+			}
+		})
+	}
+}
+
+func TestGetCommitMessageDraft(t *testing.T) {
+	gitDir := t.TempDir()
+	writeMessage := func(name, message string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(gitDir, name), []byte(message), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+		outputFunc: func(name string, args ...string) ([]byte, error) {
+			switch args[2] {
+			case "log":
+				return []byte("Add retries\n\nRetry failed requests.\n"), nil
+			case "rev-parse":
+				return []byte(filepath.Join(gitDir, args[4]) + "\n"), nil
+			}
+			return nil, errors.New("unexpected command")
+		},
+	}}
+
+	t.Run("Message of the last commit", func(t *testing.T) {
+		writeMessage("COMMIT_EDITMSG", "Add retries\n\nRetry failed requests.\n")
+		draft, err := detector.GetCommitMessageDraft(".")
+		if err != nil || draft != "" {
+			t.Errorf("Expected no draft, got %q, %v", draft, err)
+		}
+	})
+
+	t.Run("Message being written", func(t *testing.T) {
+		writeMessage("COMMIT_EDITMSG", "Limit retries to 3\n\n# Please enter the commit message\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n")
+		draft, err := detector.GetCommitMessageDraft(".")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if draft != "Limit retries to 3" {
+			t.Errorf("Expected the draft without comments and diff, got %q", draft)
+		}
+	})
+}
+
+func TestGetBranchCommitMessages(t *testing.T) {
+	t.Run("Commits since the base", func(t *testing.T) {
+		var logArgs []string
+		detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+			outputFunc: func(name string, args ...string) ([]byte, error) {
+				switch args[2] {
+				case "merge-base":
+					if args[4] == "@{upstream}" {
+						return nil, errors.New("no upstream configured")
+					}
+					return []byte("abc123\n"), nil
+				case "log":
+					logArgs = args
+					return []byte("Second commit\n\nPROJ-12\n\x00\nFirst commit\n\x00\n"), nil
+				}
+				return nil, errors.New("unexpected command")
+			},
+		}}
+
+		messages, err := detector.GetBranchCommitMessages(".", 5)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		expected := []string{"Second commit\n\nPROJ-12", "First commit"}
+		if !reflect.DeepEqual(messages, expected) {
+			t.Errorf("Expected messages %q, got %q", expected, messages)
+		}
+		if !strings.Contains(strings.Join(logArgs, " "), "-n 5 abc123..HEAD") {
+			t.Errorf("Expected the commits since the merge base, got git %v", logArgs)
+		}
+	})
+
+	t.Run("No base branch", func(t *testing.T) {
+		detector := &RepositoryDetectorImpl{cmdRunner: &mockCommandRunner{
+			outputFunc: func(name string, args ...string) ([]byte, error) {
+				return nil, errors.New("unknown revision")
+			},
+		}}
+
+		messages, err := detector.GetBranchCommitMessages(".", 5)
+		if err != nil || len(messages) != 0 {
+			t.Errorf("Expected no messages, got %q, %v", messages, err)
+		}
+	})
+}
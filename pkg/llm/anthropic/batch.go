@@ -0,0 +1,199 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/llm/promptlog"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/retry"
+)
+
+// messageBatch is a message batch as returned by the API
+type messageBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"`
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+	ResultsURL string `json:"results_url"`
+}
+
+// batchResultLine is a line of the results of a message batch
+type batchResultLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string                 `json:"type"`
+		Message map[string]interface{} `json:"message"`
+		Error   struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+// SubmitBatch creates a message batch with the review requests
+func (p *Provider) SubmitBatch(ctx context.Context, requests []llm.BatchRequest) (string, error) {
+	if len(requests) == 0 {
+		return "", llm.NewInvalidRequestError("no requests to submit")
+	}
+
+	batchRequests := make([]map[string]interface{}, 0, len(requests))
+	for _, batchRequest := range requests {
+		options := batchRequest.Request.Options.WithDefaults(llm.ReviewOptionsFromConfig(p.config))
+		if err := options.Validate(); err != nil {
+			return "", err
+		}
+
+		apiRequest, userPrompt := p.reviewRequest(batchRequest.Request, options)
+		if err := promptlog.LogPrompt(p.Name(), batchRequest.Request.FilePath, userPrompt); err != nil {
+			logging.WarnWith("Failed to log prompt", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		batchRequests = append(batchRequests, map[string]interface{}{
+			"custom_id": batchRequest.ID,
+			"params":    apiRequest,
+		})
+	}
+
+	requestJSON, err := json.Marshal(map[string]interface{}{"requests": batchRequests})
+	if err != nil {
+		return "", llm.NewInvalidRequestError(fmt.Sprintf("failed to marshal batch: %v", err))
+	}
+
+	var batch messageBatch
+	// A retried creation would be billed as a second batch
+	if err := p.getJSON(retry.SendOnce(ctx), http.MethodPost, p.baseURL+"/v1/messages/batches", requestJSON, &batch); err != nil {
+		return "", err
+	}
+
+	logging.InfoWith("Submitted batch", map[string]interface{}{
+		"batch":    batch.ID,
+		"requests": len(requests),
+		"model":    p.model,
+	})
+	return batch.ID, nil
+}
+
+// BatchStatus returns the progress of a message batch
+func (p *Provider) BatchStatus(ctx context.Context, id string) (*llm.BatchInfo, error) {
+	batch, err := p.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return batchInfo(batch), nil
+}
+
+// BatchResults downloads the results of a message batch that has ended
+func (p *Provider) BatchResults(ctx context.Context, id string) (map[string]llm.BatchResult, error) {
+	batch, err := p.getBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if info := batchInfo(batch); !info.Done {
+		return nil, llm.NewProviderError(fmt.Sprintf("batch %s is still %s", id, info.Status), nil)
+	}
+
+	resultsURL := batch.ResultsURL
+	if resultsURL == "" {
+		resultsURL = p.baseURL + "/v1/messages/batches/" + url.PathEscape(id) + "/results"
+	}
+	resp, err := p.do(ctx, http.MethodGet, resultsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	results := make(map[string]llm.BatchResult)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, llm.NewProviderError(fmt.Sprintf("failed to parse batch results: %v", err), err)
+		}
+		results[line.CustomID] = p.batchResult(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, llm.NewProviderError(fmt.Sprintf("failed to read batch results: %v", err), err)
+	}
+	return results, nil
+}
+
+// batchResult converts a line of the results of a message batch into a result
+func (p *Provider) batchResult(line batchResultLine) llm.BatchResult {
+	switch line.Result.Type {
+	case "succeeded":
+		review, err := extractReviewText(line.Result.Message)
+		if err != nil {
+			return llm.BatchResult{Err: err}
+		}
+		return llm.BatchResult{Response: &llm.ReviewResponse{
+			Review:   review,
+			Metadata: p.metadata(line.Result.Message),
+		}}
+	case "errored":
+		return llm.BatchResult{Err: llm.NewProviderError(fmt.Sprintf("API error: %s", line.Result.Error.Error.Message), nil)}
+	default:
+		// The request was canceled or expired before it ran
+		return llm.BatchResult{Err: llm.NewProviderError(fmt.Sprintf("request %s", line.Result.Type), nil)}
+	}
+}
+
+// getBatch returns a message batch
+func (p *Provider) getBatch(ctx context.Context, id string) (*messageBatch, error) {
+	var batch messageBatch
+	if err := p.getJSON(ctx, http.MethodGet, p.baseURL+"/v1/messages/batches/"+url.PathEscape(id), nil, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// getJSON sends a request to the API and decodes the JSON response into v
+func (p *Provider) getJSON(ctx context.Context, method, apiURL string, requestJSON []byte, v interface{}) error {
+	resp, err := p.do(ctx, method, apiURL, requestJSON)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return llm.NewProviderError(fmt.Sprintf("failed to read response body: %v", err), err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return llm.NewProviderError(fmt.Sprintf("failed to parse response: %v", err), err)
+	}
+	return nil
+}
+
+// batchInfo converts a message batch into its progress
+func batchInfo(batch *messageBatch) *llm.BatchInfo {
+	counts := batch.RequestCounts
+	return &llm.BatchInfo{
+		ID:        batch.ID,
+		Status:    batch.ProcessingStatus,
+		Done:      batch.ProcessingStatus == "ended",
+		Total:     counts.Processing + counts.Succeeded + counts.Errored + counts.Canceled + counts.Expired,
+		Succeeded: counts.Succeeded,
+		Failed:    counts.Errored + counts.Canceled + counts.Expired,
+	}
+}
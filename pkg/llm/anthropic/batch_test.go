@@ -0,0 +1,135 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+// batchServer is a stand-in for the Anthropic message batches endpoints. It
+// answers every request of a batch with a review naming the request, except
+// for requests that review fail.go, which error.
+type batchServer struct {
+	mu       sync.Mutex
+	requests []stubRequest
+	ended    bool
+}
+
+// stubRequest is a request of a message batch
+type stubRequest struct {
+	CustomID string                 `json:"custom_id"`
+	Params   map[string]interface{} `json:"params"`
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches":
+		var body struct {
+			Requests []stubRequest `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, `{"type":"error","error":{"type":"invalid_request_error","message":"invalid batch"}}`, http.StatusBadRequest)
+			return
+		}
+		s.requests = body.Requests
+		fmt.Fprint(w, s.batch())
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_1":
+		fmt.Fprint(w, s.batch())
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_1/results":
+		for _, request := range s.requests {
+			prompt, _ := json.Marshal(request.Params["messages"])
+			if strings.Contains(string(prompt), "fail.go") {
+				fmt.Fprintf(w, `{"custom_id":%q,"result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"prompt rejected"}}}}`+"\n", request.CustomID)
+				continue
+			}
+			text, _ := json.Marshal(fmt.Sprintf(`{"issues": [{"title": "Issue in %s"}]}`, request.CustomID))
+			fmt.Fprintf(w, `{"custom_id":%q,"result":{"type":"succeeded","message":{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":%s}],"usage":{"input_tokens":10,"output_tokens":5}}}}`+"\n", request.CustomID, text)
+		}
+	default:
+		http.Error(w, `{"type":"error","error":{"type":"not_found_error","message":"not found"}}`, http.StatusNotFound)
+	}
+}
+
+// batch returns the message batch, with the results URL once it has ended
+func (s *batchServer) batch() string {
+	if !s.ended {
+		return fmt.Sprintf(`{"id":"msgbatch_1","type":"message_batch","processing_status":"in_progress","request_counts":{"processing":%d,"succeeded":0,"errored":0,"canceled":0,"expired":0},"results_url":null}`, len(s.requests))
+	}
+	return fmt.Sprintf(`{"id":"msgbatch_1","type":"message_batch","processing_status":"ended","request_counts":{"processing":0,"succeeded":%d,"errored":1,"canceled":0,"expired":0},"results_url":null}`, len(s.requests)-1)
+}
+
+// TestBatch verifies that reviews are submitted as a message batch and that
+// the results are matched to their requests once the batch has ended
+func TestBatch(t *testing.T) {
+	stub := &batchServer{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, err := NewProvider(&config.Config{
+		LLM: config.LLMConfig{
+			Provider: "anthropic",
+			APIURL:   server.URL,
+			APIKey:   "test-api-key",
+			Model:    "claude-3-5-sonnet-latest",
+			Timeout:  5,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	batcher := provider.(llm.Batcher)
+	ctx := context.Background()
+
+	id, err := batcher.SubmitBatch(ctx, []llm.BatchRequest{
+		{ID: "file-1", Request: &llm.ReviewRequest{FilePath: "main.go", FileDiff: "+func main() {}"}},
+		{ID: "file-2", Request: &llm.ReviewRequest{FilePath: "fail.go", FileDiff: "+package fail"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if id != "msgbatch_1" {
+		t.Errorf("Expected batch ID msgbatch_1, got %q", id)
+	}
+	if len(stub.requests) != 2 || stub.requests[0].CustomID != "file-1" {
+		t.Fatalf("Unexpected batch requests: %+v", stub.requests)
+	}
+	if stub.requests[0].Params["model"] != "claude-3-5-sonnet-latest" || stub.requests[0].Params["system"] == nil {
+		t.Errorf("Expected the messages request in the batch, got %v", stub.requests[0].Params)
+	}
+
+	// Results cannot be collected before the batch has ended
+	info, err := batcher.BatchStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if info.Done || info.Total != 2 {
+		t.Errorf("Expected the batch to be in progress, got %+v", info)
+	}
+	if _, err := batcher.BatchResults(ctx, id); err == nil {
+		t.Error("Expected an error for a batch in progress")
+	}
+
+	stub.ended = true
+	results, err := batcher.BatchResults(ctx, id)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result := results["file-1"]; result.Err != nil || !strings.Contains(result.Response.Review, "Issue in file-1") {
+		t.Errorf("Unexpected result for file-1: %+v", result)
+	}
+	if result := results["file-2"]; result.Err == nil || !strings.Contains(result.Err.Error(), "prompt rejected") {
+		t.Errorf("Expected the error for file-2, got %+v", result)
+	}
+}
@@ -1,6 +1,7 @@
 package anthropic
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,15 +10,13 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/niels/git-llm-review/pkg/config"
-	"github.com/niels/git-llm-review/pkg/extractor"
 	"github.com/niels/git-llm-review/pkg/llm"
 	"github.com/niels/git-llm-review/pkg/llm/promptlog"
+	"github.com/niels/git-llm-review/pkg/llm/transport"
 	"github.com/niels/git-llm-review/pkg/logging"
 	"github.com/niels/git-llm-review/pkg/prompt"
 	"github.com/niels/git-llm-review/pkg/retry"
@@ -46,7 +45,7 @@ func NewProvider(cfg *config.Config) (llm.Provider, error) {
 		apiKey:  cfg.LLM.APIKey,
 		model:   cfg.LLM.Model,
 		baseURL: cfg.LLM.APIURL,
-		client:  &http.Client{},
+		client:  transport.NewClient(cfg),
 		config:  cfg,
 	}
 
@@ -55,11 +54,6 @@ func NewProvider(cfg *config.Config) (llm.Provider, error) {
 		provider.baseURL = defaultAPIURL
 	}
 
-	// Set timeout for the HTTP client
-	if cfg.LLM.Timeout > 0 {
-		provider.client.Timeout = time.Duration(cfg.LLM.Timeout) * time.Second
-	}
-
 	// Validate configuration
 	if err := provider.ValidateConfig(); err != nil {
 		return nil, err
@@ -88,6 +82,11 @@ func (p *Provider) Name() string {
 	return "Anthropic"
 }
 
+// Model returns the name of the model used by the provider
+func (p *Provider) Model() string {
+	return p.model
+}
+
 // ReviewCode sends a code review request to Anthropic and returns the response
 func (p *Provider) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (*llm.ReviewResponse, error) {
 	// Validate request
@@ -95,317 +94,260 @@ func (p *Provider) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (
 		return nil, errors.New("request is nil")
 	}
 
-	// Create the messages for the API request
-	// Get system message from centralized system prompts
-	systemMessage := prompt.GetSystemPrompt(prompt.ProviderAnthropic, prompt.SystemPromptReview)
-	
-	// Create user message with prompt
+	// Use the configured generation settings for anything the request leaves unset
+	options := request.Options.WithDefaults(llm.ReviewOptionsFromConfig(p.config))
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Limit the request to its timeout, if one is set
+	ctx, cancel := withTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	apiRequest, userPrompt := p.reviewRequest(request, options)
+
+	// Log the prompt if enabled
+	if err := promptlog.LogPrompt(p.Name(), request.FilePath, userPrompt); err != nil {
+		logging.ErrorWith("Failed to log prompt", map[string]interface{}{
+			"error": err.Error(),
+		})
+		// Continue without prompt logging, but log the error
+	}
+
+	// Let the model look up type definitions, unless tools are disabled or
+	// not available for the language of the file
+	var apiResponse map[string]interface{}
+	var err error
+	mode := llm.ToolModeNone
+	if llm.UseTools(request, options) {
+		mode = llm.ToolModeFor(p.endpoint(), options.ToolMode)
+	}
+	switch mode {
+	case llm.ToolModeNone:
+		apiResponse, err = p.sendMessages(ctx, apiRequest)
+	case llm.ToolModeText:
+		apiResponse, err = p.reviewWithTextTools(ctx, request, apiRequest)
+	default:
+		apiResponse, err = p.reviewWithNativeTools(ctx, request, apiRequest)
+
+		// Fall back to the text protocol when the server rejects tools, and
+		// remember which protocol works for the next files
+		if mode == llm.ToolModeAuto {
+			if err != nil && toolsRejected(err) {
+				logging.WarnWith("Server does not support tool calls, describing the tools in the prompt instead", map[string]interface{}{
+					"model": p.model,
+					"error": err.Error(),
+				})
+				apiResponse, err = p.reviewWithTextTools(ctx, request, apiRequest)
+				mode = llm.ToolModeText
+			}
+			if err == nil {
+				llm.RememberToolMode(p.endpoint(), mode)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the review from the final response
+	review, err := extractReviewText(apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	return &llm.ReviewResponse{
+		Review:   review,
+		Metadata: p.metadata(apiResponse),
+	}, nil
+}
+
+// reviewRequest returns the messages API request of a review request,
+// together with its user prompt
+func (p *Provider) reviewRequest(request *llm.ReviewRequest, options llm.ReviewOptions) (map[string]interface{}, string) {
+	// The system prompt is sent apart from the messages
 	userPrompt := prompt.CreatePrompt(request, prompt.ProviderAnthropic)
-	
-	// Create messages
-	messages := []map[string]interface{}{
-		{
-			"role":    "system",
-			"content": systemMessage,
-		},
-		{
-			"role":    "user",
-			"content": userPrompt,
+	apiRequest := map[string]interface{}{
+		"model":  p.model,
+		"system": prompt.CreateSystemPrompt(request, prompt.ProviderAnthropic),
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": userPrompt,
+			},
 		},
 	}
+	applyOptions(apiRequest, options)
+	return apiRequest, userPrompt
+}
+
+// endpoint identifies the server and model, to remember which tool protocol
+// works for them
+func (p *Provider) endpoint() string {
+	return "anthropic " + p.baseURL + " " + p.model
+}
 
-	// Define the FindDefinitionForType tool for the LLM to call
-	tools := []map[string]interface{}{
+// reviewWithNativeTools sends a review request that offers the tools through
+// the API, and answers the model's tool calls until it returns the review
+func (p *Provider) reviewWithNativeTools(ctx context.Context, request *llm.ReviewRequest, apiRequest map[string]interface{}) (map[string]interface{}, error) {
+	apiRequest = copyRequest(apiRequest)
+	apiRequest["tools"] = []map[string]interface{}{
 		{
-			"name": "FindDefinitionForType",
-			"description": "Searches for and extracts a type definition by name in the codebase",
-			"input_schema": map[string]interface{}{
-				"type": "object",
-				"properties": map[string]interface{}{
-					"typeName": map[string]interface{}{
-						"type": "string",
-						"description": "The name of the type to find definition for",
-					},
-				},
-				"required": []string{"typeName"},
-			},
+			"name":         llm.ToolFindDefinition,
+			"description":  llm.ToolFindDefinitionDescription,
+			"input_schema": llm.ToolFindDefinitionSchema(),
 		},
 	}
 
-	// Create the API request
-	apiRequest := map[string]interface{}{
-		"model":       p.model,
-		"messages":    messages,
-		"tools":       tools,
-		"max_tokens":  request.Options.MaxTokens,
-		"temperature": request.Options.Temperature,
+	apiResponse, err := p.sendMessages(ctx, apiRequest)
+	if err != nil {
+		return nil, err
 	}
 
-	// Log the prompt if enabled
-	userMessageContent := ""
-	for _, msg := range messages {
-		if role, ok := msg["role"].(string); ok && role == "user" {
-			if content, ok := msg["content"].(string); ok {
-				userMessageContent = content
-				break
-			}
+	messages := apiRequest["messages"].([]map[string]interface{})
+	for round := 1; round <= llm.MaxToolRounds; round++ {
+		toolUses := toolUseBlocks(apiResponse)
+		if len(toolUses) == 0 {
+			break
 		}
-	}
-	if userMessageContent != "" {
-		if err := promptlog.LogPrompt(p.Name(), request.FilePath, userMessageContent); err != nil {
-			logging.ErrorWith("Failed to log prompt", map[string]interface{}{
-				"error": err.Error(),
+
+		// Every tool use is answered in one user message, after the
+		// assistant message with all its content, including any thinking
+		results := make([]map[string]interface{}, 0, len(toolUses))
+		for _, toolUse := range toolUses {
+			name, _ := toolUse["name"].(string)
+			logging.DebugWith("Running tool", map[string]interface{}{
+				"file": request.FilePath,
+				"tool": name,
+			})
+			input, err := json.Marshal(toolUse["input"])
+			if err != nil {
+				return nil, llm.NewProviderError("failed to read tool input", err)
+			}
+			results = append(results, map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": toolUse["id"],
+				"content":     llm.RunTool(request, name, input),
 			})
-			// Continue without prompt logging, but log the error
+		}
+		messages = append(messages,
+			map[string]interface{}{"role": "assistant", "content": apiResponse["content"]},
+			map[string]interface{}{"role": "user", "content": results},
+		)
+		apiRequest["messages"] = messages
+
+		// Make the model answer once it has used up its tool calls
+		if round == llm.MaxToolRounds {
+			apiRequest["tool_choice"] = map[string]interface{}{"type": "none"}
+		}
+
+		apiResponse, err = p.sendMessages(ctx, apiRequest)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	// Create a variable to store the API response
-	var apiResponse map[string]interface{}
+	return apiResponse, nil
+}
 
-	// Convert the request to JSON
-	requestBody, err := json.Marshal(apiRequest)
+// reviewWithTextTools sends a review request that describes the tools in the
+// system prompt, and answers the tool_call blocks in the model's replies
+// until it returns the review
+func (p *Provider) reviewWithTextTools(ctx context.Context, request *llm.ReviewRequest, apiRequest map[string]interface{}) (map[string]interface{}, error) {
+	apiRequest = copyRequest(apiRequest)
+	apiRequest["system"] = fmt.Sprintf("%s\n\n%s", apiRequest["system"], llm.TextToolInstructions())
+
+	apiResponse, err := p.sendMessages(ctx, apiRequest)
 	if err != nil {
-		return nil, llm.NewInvalidRequestError(fmt.Sprintf("failed to marshal request: %v", err))
+		return nil, err
 	}
 
-	// Function to create a new request with the same body
-	createRequest := func() (*http.Request, error) {
-		req, err := http.NewRequestWithContext(
-			ctx,
-			http.MethodPost,
-			fmt.Sprintf("%s/v1/messages", p.baseURL),
-			bytes.NewBuffer(requestBody),
-		)
+	messages := apiRequest["messages"].([]map[string]interface{})
+	for round := 1; round <= llm.MaxToolRounds; round++ {
+		reply, err := extractReviewText(apiResponse)
 		if err != nil {
 			return nil, err
 		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Api-Key", p.apiKey)
-		req.Header.Set("Anthropic-Version", apiVersion)
-		return req, nil
-	}
+		calls := llm.ParseTextToolCalls(reply)
+		if len(calls) == 0 {
+			break
+		}
+		logging.DebugWith("Running tools", map[string]interface{}{
+			"file":  request.FilePath,
+			"calls": len(calls),
+		})
+
+		messages = append(messages,
+			map[string]interface{}{"role": "assistant", "content": apiResponse["content"]},
+			map[string]interface{}{"role": "user", "content": llm.RunTextToolCalls(request, calls, round == llm.MaxToolRounds)},
+		)
+		apiRequest["messages"] = messages
 
-	// Function to make the API call with retry
-	makeAPICall := func() (*http.Response, error) {
-		req, err := createRequest()
+		apiResponse, err = p.sendMessages(ctx, apiRequest)
 		if err != nil {
 			return nil, err
 		}
-		return p.client.Do(req)
 	}
 
-	// Make the API call with retry
-	var resp *http.Response
+	return apiResponse, nil
+}
 
-	// Check if retry is enabled in the config
-	if p.config != nil && p.config.Retry.Enabled {
-		// Use the retry utility
-		resp, err = retry.DoLLMRequest(makeAPICall, p.config)
-	} else {
-		// Make a single API call without retry
-		resp, err = makeAPICall()
+// copyRequest returns a copy of a messages API request that can be changed
+// without affecting the original
+func copyRequest(apiRequest map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(apiRequest))
+	for key, value := range apiRequest {
+		copied[key] = value
 	}
+	messages, _ := apiRequest["messages"].([]map[string]interface{})
+	copied["messages"] = append([]map[string]interface{}(nil), messages...)
+	return copied
+}
 
+// toolsRejected reports whether a failed request shows that the server does
+// not support native tool calls
+func toolsRejected(err error) bool {
+	var statusErr *retry.StatusError
+	return errors.As(err, &statusErr) && llm.ToolsRejected(statusErr.StatusCode, statusErr.Message)
+}
+
+// sendMessages sends a messages API request and returns the decoded response
+func (p *Provider) sendMessages(ctx context.Context, apiRequest map[string]interface{}) (map[string]interface{}, error) {
+	requestBody, err := json.Marshal(apiRequest)
 	if err != nil {
-		return nil, llm.NewProviderError(fmt.Sprintf("failed to send request: %v", err), err)
+		return nil, llm.NewInvalidRequestError(fmt.Sprintf("failed to marshal request: %v", err))
 	}
-	defer resp.Body.Close()
 
-	// Check for error status code
-	if resp.StatusCode != http.StatusOK {
-		// Try to parse error response
-		var errorResp map[string]interface{}
-		body, _ := io.ReadAll(resp.Body)
-		if err := json.Unmarshal(body, &errorResp); err == nil {
-			// Extract error message
-			if errObj, ok := errorResp["error"].(map[string]interface{}); ok {
-				if msg, ok := errObj["message"].(string); ok {
-					return nil, llm.NewProviderError(fmt.Sprintf("API error: %s", msg), nil)
-				}
-			}
-		}
-		
-		// Fallback error message
-		return nil, llm.NewProviderError(fmt.Sprintf("API error: %d", resp.StatusCode), nil)
+	// Transient failures are retried by the HTTP transport
+	resp, err := p.send(ctx, requestBody)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Parse the response
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, llm.NewProviderError(fmt.Sprintf("failed to read response body: %v", err), err)
 	}
-	
+
+	var apiResponse map[string]interface{}
 	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return nil, llm.NewInvalidRequestError(fmt.Sprintf("failed to parse response: %v", err))
-	}
-
-	// Handle tool calls and recursively process responses
-	var handleToolCalls func() (*llm.ReviewResponse, error)
-	handleToolCalls = func() (*llm.ReviewResponse, error) {
-		// Check if the response contains a tool call
-		var toolCalls []map[string]interface{}
-		if content, exists := apiResponse["content"].([]interface{}); exists {
-			for _, contentBlock := range content {
-				if block, ok := contentBlock.(map[string]interface{}); ok {
-					if block["type"] == "tool_call" {
-						if toolCall, ok := block["tool_call"].(map[string]interface{}); ok {
-							toolCalls = append(toolCalls, toolCall)
-						}
-					}
-				}
-			}
-		}
-		
-		// If no tool calls, extract the text and return it
-		if len(toolCalls) == 0 {
-			// Get the completion from the API response
-			completionContent, err := extractReviewText(apiResponse)
-			if err != nil {
-				return nil, err
-			}
-			
-			// Process the response and return the result
-			return &llm.ReviewResponse{
-				Review:   completionContent,
-				Metadata: map[string]interface{}{
-					"model": p.model,
-				},
-			}, nil
-		}
-		
-		// Process the first tool call
-		toolCall := toolCalls[0]
-		toolName, ok := toolCall["name"].(string)
-		if !ok {
-			return nil, llm.NewProviderError("invalid tool call format", nil)
-		}
-		
-		// Currently we only support FindDefinitionForType
-		if toolName == "FindDefinitionForType" {
-			// Extract the parameters
-			params, ok := toolCall["parameters"].(map[string]interface{})
-			if !ok {
-				return nil, llm.NewProviderError("invalid tool parameters", nil)
-			}
-			
-			typeName, ok := params["typeName"].(string)
-			if !ok {
-				return nil, llm.NewProviderError("missing typeName parameter", nil)
-			}
-			
-			// Get the extractor
-			var codeExtractor *extractor.CodeExtractor
-			var language extractor.Language
-
-			if request.Extractor != nil {
-				codeExtractor = request.Extractor
-			} else {
-				// Infer language from file extension
-				ext := filepath.Ext(request.FilePath)
-				switch strings.ToLower(ext) {
-				case ".go":
-					language = extractor.Go
-				case ".js":
-					language = extractor.JavaScript
-				case ".py":
-					language = extractor.Python
-				default:
-					return nil, llm.NewProviderError(fmt.Sprintf("unsupported file extension: %s", ext), nil)
-				}
-
-				// Get the working directory
-				workDir, err := os.Getwd()
-				if err != nil {
-					return nil, llm.NewProviderError(fmt.Sprintf("failed to get working directory: %v", err), err)
-				}
-
-				// Create a new code extractor
-				codeExtractor, err = extractor.NewCodeExtractor(language, workDir)
-				if err != nil {
-					return nil, llm.NewProviderError(fmt.Sprintf("failed to create code extractor: %v", err), err)
-				}
-			}
-			
-			// Call the function
-			typeDefinition, filePath, err := codeExtractor.FindDefinitionForType(typeName)
-			var toolResponse map[string]interface{}
-			if err != nil {
-				toolResponse = map[string]interface{}{
-					"error": err.Error(),
-				}
-			} else {
-				toolResponse = map[string]interface{}{
-					"typeDefinition": typeDefinition,
-					"filePath":       filePath,
-				}
-			}
-			
-			// Convert tool response to JSON
-			toolResponseJSON, err := json.Marshal(toolResponse)
-			if err != nil {
-				return nil, llm.NewProviderError("failed to marshal tool response", err)
-			}
-			
-			// Add the assistant's tool call message
-			messages = append(messages, map[string]interface{}{
-				"role": "assistant",
-				"content": []map[string]interface{}{
-					{
-						"type": "tool_call",
-						"tool_call": map[string]interface{}{
-							"id": toolCall["id"],
-							"name": "FindDefinitionForType",
-							"parameters": params,
-						},
-					},
-				},
-			})
-			
-			// Add the tool response message
-			messages = append(messages, map[string]interface{}{
-				"role": "tool",
-				"tool_call_id": toolCall["id"],
-				"name": "FindDefinitionForType",
-				"content": string(toolResponseJSON),
-			})
-			
-			// Make another API call with the updated messages
-			apiRequest["messages"] = messages
-			
-			// Convert the request to JSON
-			requestBody, err = json.Marshal(apiRequest)
-			if err != nil {
-				return nil, llm.NewInvalidRequestError(fmt.Sprintf("failed to marshal request: %v", err))
-			}
-			
-			// Make another API call with retry
-			resp, err = retry.DoLLMRequest(makeAPICall, p.config)
-			if err != nil {
-				return nil, llm.NewProviderError(fmt.Sprintf("failed to send request: %v", err), err)
-			}
-			defer resp.Body.Close()
-			
-			// Read the response body
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return nil, llm.NewProviderError(fmt.Sprintf("failed to read response body: %v", err), err)
-			}
-			
-			// Parse the response
-			if err := json.Unmarshal(body, &apiResponse); err != nil {
-				return nil, llm.NewProviderError(fmt.Sprintf("failed to parse response: %v", err), err)
-			}
-			
-			// Recursively handle more tool calls if needed
-			return handleToolCalls()
-		}
-		
-		return nil, llm.NewProviderError(fmt.Sprintf("unsupported tool: %s", toolName), nil)
+		return nil, llm.NewProviderError(fmt.Sprintf("failed to parse response: %v", err), err)
 	}
+	return apiResponse, nil
+}
+
+// toolUseBlocks returns the tool_use content blocks of a messages API response
+func toolUseBlocks(response map[string]interface{}) []map[string]interface{} {
+	content, _ := response["content"].([]interface{})
 
-	// Process the response and return the result
-	return handleToolCalls()
+	var toolUses []map[string]interface{}
+	for _, contentBlock := range content {
+		if block, ok := contentBlock.(map[string]interface{}); ok && block["type"] == "tool_use" {
+			toolUses = append(toolUses, block)
+		}
+	}
+	return toolUses
 }
 
 // extractReviewText extracts the review text from the API response
@@ -460,11 +402,130 @@ func extractReviewText(response map[string]interface{}) (string, error) {
 }
 
 // GetCompletion sends a prompt to the Anthropic API and returns the completion
-func (p *Provider) GetCompletion(prompt string) (string, error) {
+func (p *Provider) GetCompletion(ctx context.Context, prompt string) (string, error) {
+	response, err := p.GetCompletionWithMetadata(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return response.Review, nil
+}
+
+// GetCompletionWithMetadata sends a prompt to the Anthropic API and returns the
+// completion together with the model's thinking, if extended thinking is enabled
+func (p *Provider) GetCompletionWithMetadata(ctx context.Context, prompt string) (*llm.ReviewResponse, error) {
+	// Use the configured generation settings
+	options := llm.ReviewOptionsFromConfig(p.config)
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Limit the request to the configured timeout
+	ctx, cancel := withTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	// Create request body
+	requestBody := map[string]interface{}{
+		"model": p.model,
+		"messages": []map[string]interface{}{
+			{
+				"role":    "user",
+				"content": prompt,
+			},
+		},
+	}
+	applyOptions(requestBody, options)
+
+	// Convert request to JSON
+	requestJSON, err := json.Marshal(requestBody)
+	if err != nil {
+		return nil, llm.NewProviderError("failed to marshal request", err)
+	}
+
+	// Send request; transient failures are retried by the HTTP transport
+	resp, err := p.send(ctx, requestJSON)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, llm.NewProviderError("failed to read response body", err)
+	}
+
+	// Parse response
+	var responseData map[string]interface{}
+	if err := json.Unmarshal(responseBody, &responseData); err != nil {
+		return nil, llm.NewProviderError("failed to parse response", err)
+	}
+
+	// Extract content from response
+	content, err := extractReviewText(responseData)
+	if err != nil {
+		return nil, llm.NewProviderError("failed to extract content from response", err)
+	}
+
+	return &llm.ReviewResponse{
+		Review:   content,
+		Metadata: p.metadata(responseData),
+	}, nil
+}
+
+// metadata returns the metadata of a messages API response, including the
+// token usage and the model's thinking when extended thinking was enabled
+func (p *Provider) metadata(response map[string]interface{}) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"model": p.model,
+	}
+	if usage, ok := response["usage"].(map[string]interface{}); ok {
+		inputTokens, _ := usage["input_tokens"].(float64)
+		outputTokens, _ := usage["output_tokens"].(float64)
+		metadata["prompt_tokens"] = int64(inputTokens)
+		metadata["completion_tokens"] = int64(outputTokens)
+		metadata["token_count"] = int64(inputTokens + outputTokens)
+	}
+	if thinking := extractThinking(response); thinking != "" {
+		metadata[llm.MetadataReasoning] = thinking
+	}
+	return metadata
+}
+
+// extractThinking returns the text of the thinking blocks in the API response
+func extractThinking(response map[string]interface{}) string {
+	content, _ := response["content"].([]interface{})
+
+	var thinking []string
+	for _, contentBlock := range content {
+		block, ok := contentBlock.(map[string]interface{})
+		if !ok || block["type"] != "thinking" {
+			continue
+		}
+		if text, ok := block["thinking"].(string); ok && text != "" {
+			thinking = append(thinking, text)
+		}
+	}
+
+	return strings.Join(thinking, "\n\n")
+}
+
+// StreamCompletion sends a prompt to the Anthropic API and calls onChunk with
+// the completion as it is generated
+func (p *Provider) StreamCompletion(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	// Use the configured generation settings
+	options := llm.ReviewOptionsFromConfig(p.config)
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+
+	// Limit the request to the configured timeout
+	ctx, cancel := withTimeout(ctx, options.Timeout)
+	defer cancel()
+
 	// Create request body
 	requestBody := map[string]interface{}{
-		"model":      p.model,
-		"max_tokens": 4096,
+		"model":  p.model,
+		"stream": true,
 		"messages": []map[string]interface{}{
 			{
 				"role":    "user",
@@ -472,6 +533,7 @@ func (p *Provider) GetCompletion(prompt string) (string, error) {
 			},
 		},
 	}
+	applyOptions(requestBody, options)
 
 	// Convert request to JSON
 	requestJSON, err := json.Marshal(requestBody)
@@ -479,10 +541,72 @@ func (p *Provider) GetCompletion(prompt string) (string, error) {
 		return "", llm.NewProviderError("failed to marshal request", err)
 	}
 
+	resp, err := p.send(ctx, requestJSON)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Read the server-sent events, delivering each text delta as it arrives
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return "", llm.NewProviderError("failed to parse stream event", err)
+		}
+
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				onChunk(event.Delta.Text)
+			}
+		case "error":
+			return "", llm.NewProviderError(fmt.Sprintf("API error: %s", event.Error.Message), nil)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", llm.RequestError(ctx, "failed to read stream", err)
+	}
+
+	return content.String(), nil
+}
+
+// send posts a request body to the messages endpoint and returns the
+// response, or an error if the request failed or the API returned an error
+func (p *Provider) send(ctx context.Context, requestJSON []byte) (*http.Response, error) {
+	return p.do(ctx, http.MethodPost, p.baseURL+"/v1/messages", requestJSON)
+}
+
+// do sends a request with the given body, if any, to an API URL and returns
+// the response, or an error if the request failed or the API returned an error
+func (p *Provider) do(ctx context.Context, method, url string, requestJSON []byte) (*http.Response, error) {
+	var body io.Reader
+	if requestJSON != nil {
+		body = bytes.NewReader(requestJSON)
+	}
+
 	// Create HTTP request
-	req, err := http.NewRequest("POST", p.baseURL+"/v1/messages", bytes.NewBuffer(requestJSON))
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return "", llm.NewProviderError("failed to create request", err)
+		return nil, llm.NewProviderError("failed to create request", err)
 	}
 
 	// Set headers
@@ -490,69 +614,91 @@ func (p *Provider) GetCompletion(prompt string) (string, error) {
 	req.Header.Set("x-api-key", p.apiKey)
 	req.Header.Set("anthropic-version", apiVersion)
 
-	// Create retry options
-	retryOpts := retry.DefaultOptions()
-	retryOpts.MaxRetries = 3
-	retryOpts.InitialDelay = 1 * time.Second
-	retryOpts.MaxDelay = 10 * time.Second
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, llm.RequestError(ctx, "failed to send request", err)
+	}
 
-	// Send request with retry
-	var responseBody []byte
-	result, err := retry.Do(func() (interface{}, error) {
-		resp, err := p.client.Do(req)
-		if err != nil {
-			return nil, err
-		}
+	// Check for error response
+	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
-		
-		// Read response body
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		
-		// Check for error response
-		if resp.StatusCode != http.StatusOK {
-			var errorResponse struct {
-				Error struct {
-					Message string `json:"message"`
-					Type    string `json:"type"`
-				} `json:"error"`
-			}
-			if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Error.Message != "" {
-				return nil, fmt.Errorf("Anthropic API error: %s", errorResponse.Error.Message)
-			}
-			return nil, fmt.Errorf("Anthropic API error: %d", resp.StatusCode)
+		return nil, apiError(resp)
+	}
+
+	return resp, nil
+}
+
+// applyOptions sets the generation parameters of a messages request. The
+// messages API does not support a seed, so it is not sent.
+func applyOptions(apiRequest map[string]interface{}, options llm.ReviewOptions) {
+	// max_tokens is required by the messages API
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = llm.DefaultMaxTokens
+	}
+	if len(options.Stop) > 0 {
+		apiRequest["stop_sequences"] = options.Stop
+	}
+
+	// Extended thinking counts against max_tokens, so the budget is added to
+	// keep the configured room for the answer. It cannot be combined with a
+	// temperature or top_p.
+	if options.UsesReasoning() && options.ThinkingBudget > 0 {
+		apiRequest["thinking"] = map[string]interface{}{
+			"type":          "enabled",
+			"budget_tokens": options.ThinkingBudget,
 		}
-		
-		return body, nil
-	}, retryOpts)
+		apiRequest["max_tokens"] = maxTokens + options.ThinkingBudget
+		return
+	}
 
-	if err != nil {
-		return "", llm.NewProviderError("failed to send request", err)
+	apiRequest["max_tokens"] = maxTokens
+	if options.Temperature != nil {
+		apiRequest["temperature"] = *options.Temperature
 	}
-	
-	responseBody = result.([]byte)
+	if options.TopP != nil {
+		apiRequest["top_p"] = *options.TopP
+	}
+}
 
-	// Parse response
-	var responseData map[string]interface{}
-	if err := json.Unmarshal(responseBody, &responseData); err != nil {
-		return "", llm.NewProviderError("failed to parse response", err)
+// withTimeout limits ctx to timeout, if one is set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
 	}
+	return context.WithCancel(ctx)
+}
 
-	// Extract content from response
-	content, err := extractReviewText(responseData)
-	if err != nil {
-		return "", llm.NewProviderError("failed to extract content from response", err)
+// apiError converts an error response from the Anthropic API into an error
+func apiError(resp *http.Response) error {
+	// Try to extract the error message from the response
+	message := http.StatusText(resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	var errorResponse struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Error.Message != "" {
+		message = errorResponse.Error.Message
 	}
 
-	return content, nil
+	return llm.NewAPIError(fmt.Sprintf("API error: %s", message), resp.StatusCode, &retry.StatusError{
+		StatusCode: resp.StatusCode,
+		Message:    message,
+	})
 }
 
 // init registers the Anthropic provider
 func init() {
 	// Register the Anthropic provider factory
 	llm.RegisterProviderFactory("anthropic", func(cfg map[string]interface{}) (llm.Provider, error) {
+		// Use the full configuration, with its transport settings, if provided
+		if fullCfg, ok := cfg["config"].(*config.Config); ok && fullCfg != nil {
+			return NewProvider(fullCfg)
+		}
+
 		// Convert the generic config map to our specific config structure
 		apiKey, ok := cfg["api_key"].(string)
 		if !ok || apiKey == "" {
@@ -12,6 +12,7 @@ import (
 
 	"github.com/niels/git-llm-review/pkg/config"
 	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/llm/transport"
 )
 
 // TestNewProvider tests the creation of a new Anthropic provider
@@ -143,10 +144,14 @@ func MockAnthropicServer(t *testing.T) *httptest.Server {
 			return
 		}
 
-		// Check that the request contains the expected content
+		// Check that the request contains the expected content. The system
+		// prompt is a top-level field of the messages API.
 		messages, ok := requestBody["messages"].([]interface{})
-		if !ok || len(messages) < 2 {
-			t.Errorf("Expected at least 2 messages, got: %v", messages)
+		if !ok || len(messages) < 1 {
+			t.Errorf("Expected at least 1 message, got: %v", messages)
+		}
+		if system, ok := requestBody["system"].(string); !ok || system == "" {
+			t.Errorf("Expected a system prompt, got: %v", requestBody["system"])
 		}
 
 		// Check model
@@ -200,7 +205,7 @@ func TestReviewCode(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     30 * time.Second,
 		},
 	}
@@ -286,7 +291,7 @@ func TestReviewCodeTimeout(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     100 * time.Millisecond, // Very short timeout
 		},
 	}
@@ -338,7 +343,7 @@ func TestReviewCodeAPIError(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     30 * time.Second,
 		},
 	}
@@ -425,7 +430,8 @@ func TestReviewCodeWithRetry(t *testing.T) {
 		apiKey:  "test-api-key",
 		model:   "claude-3-opus-20240229",
 		baseURL: server.URL,
-		client:  &http.Client{Timeout: 5 * time.Second},
+		// Retries are performed by the provider's HTTP transport
+		client:  &http.Client{Timeout: 5 * time.Second, Transport: transport.New(cfg)},
 		config:  cfg,
 	}
 
@@ -436,7 +442,7 @@ func TestReviewCodeWithRetry(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     10 * time.Second,
 		},
 	}
@@ -0,0 +1,55 @@
+package llm
+
+import "context"
+
+// BatchRequest is a review request submitted as part of a batch job
+type BatchRequest struct {
+	// ID identifies the request within the batch. It may only contain
+	// letters, digits, underscores and dashes.
+	ID string
+
+	// Request is the review request. Tools are not offered in a batch, since
+	// the model cannot call them without a round trip.
+	Request *ReviewRequest
+}
+
+// BatchInfo describes the progress of a batch job
+type BatchInfo struct {
+	// ID is the provider's ID of the batch job
+	ID string
+
+	// Status is the status reported by the provider
+	Status string
+
+	// Done is true when the provider has stopped processing the batch, and
+	// its results can be collected
+	Done bool
+
+	// Total, Succeeded and Failed count the requests in the batch
+	Total     int
+	Succeeded int
+	Failed    int
+}
+
+// BatchResult is the outcome of one request of a batch job
+type BatchResult struct {
+	// Response is the review, if the request succeeded
+	Response *ReviewResponse
+
+	// Err is the reason the request failed
+	Err error
+}
+
+// Batcher is implemented by providers that can review files in an
+// asynchronous batch job, which is cheaper but may take hours to complete
+type Batcher interface {
+	// SubmitBatch submits the requests as one batch job and returns its ID
+	SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error)
+
+	// BatchStatus returns the progress of a batch job
+	BatchStatus(ctx context.Context, id string) (*BatchInfo, error)
+
+	// BatchResults returns the results of a finished batch job by request ID.
+	// Requests that expired or were cancelled before they ran may be missing.
+	BatchResults(ctx context.Context, id string) (map[string]BatchResult, error)
+}
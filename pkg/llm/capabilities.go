@@ -0,0 +1,48 @@
+package llm
+
+import "strings"
+
+// MetadataReasoning records the reasoning or thinking text a model produced
+// before its answer, when the provider returns it
+const MetadataReasoning = "reasoning"
+
+// MetadataReasoningTokens records the number of tokens a model spent on
+// reasoning, when the provider reports it
+const MetadataReasoningTokens = "reasoning_tokens"
+
+// ModelCapabilities describes the request parameters a model accepts
+type ModelCapabilities struct {
+	// Reasoning is true for models that reason before they answer. OpenAI
+	// reasoning models reject temperature and max_tokens and take
+	// max_completion_tokens and reasoning_effort instead, and Anthropic
+	// models with extended thinking accept a thinking budget.
+	Reasoning bool
+}
+
+// reasoningModelPrefixes lists the prefixes of model names that are known to
+// be reasoning models
+var reasoningModelPrefixes = []string{
+	// OpenAI o-series and GPT-5
+	"o1", "o3", "o4", "gpt-5",
+	// Anthropic models with extended thinking
+	"claude-3-7-", "claude-sonnet-4", "claude-opus-4", "claude-haiku-4",
+}
+
+// DetectCapabilities returns the capabilities of a model based on its name.
+// Models served under a different name can be marked as reasoning models in
+// the configuration. Local models that write their reasoning in <think> tags
+// accept the usual parameters and are not reasoning models in this sense.
+func DetectCapabilities(model string) ModelCapabilities {
+	name := strings.ToLower(model)
+	// Ignore an organisation or provider prefix, as in "openai/o3-mini"
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	for _, prefix := range reasoningModelPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return ModelCapabilities{Reasoning: true}
+		}
+	}
+	return ModelCapabilities{}
+}
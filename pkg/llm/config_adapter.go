@@ -1,11 +1,56 @@
 package llm
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/logging"
 )
 
-// CreateProviderFromConfig creates a provider from the configuration
+// CreateProviderFromConfig creates a provider from the configuration. When
+// fallback providers are configured, the result is a FallbackProvider that
+// tries the configured provider first. The API keys are read from their
+// configured sources here, so commands that build no provider never run
+// api_key_command.
 func CreateProviderFromConfig(cfg *config.Config) (Provider, error) {
+	if err := cfg.ResolveAPIKeys(); err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	logging.DebugWith("API key resolved", map[string]interface{}{
+		"source": cfg.LLM.APIKeySource,
+		"key":    config.MaskSecret(cfg.LLM.APIKey),
+	})
+
+	provider, err := createSingleProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.LLM.Fallbacks) == 0 {
+		return provider, nil
+	}
+
+	providers := []Provider{provider}
+	for i, fallbackCfg := range cfg.FallbackConfigs() {
+		fallback, err := createSingleProvider(fallbackCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create fallback provider %d (%s): %w", i+1, fallbackCfg.LLM.Provider, err)
+		}
+		providers = append(providers, fallback)
+	}
+
+	return NewFallbackProvider(providers...), nil
+}
+
+// createSingleProvider creates the provider described by cfg.LLM, ignoring fallbacks
+func createSingleProvider(cfg *config.Config) (Provider, error) {
+	// Reject invalid generation settings before any review starts
+	if err := ReviewOptionsFromConfig(cfg).Validate(); err != nil {
+		return nil, fmt.Errorf("invalid generation settings for model %s: %w", cfg.LLM.Model, err)
+	}
+
 	// Convert config to map for provider factory
 	configMap := map[string]interface{}{
 		"api_key":  cfg.LLM.APIKey,
@@ -16,5 +61,15 @@ func CreateProviderFromConfig(cfg *config.Config) (Provider, error) {
 	}
 
 	// Create provider using the factory
-	return CreateProvider(cfg.LLM.Provider, configMap)
+	provider, err := CreateProvider(strings.ToLower(cfg.LLM.Provider), configMap)
+	if errors.Is(err, ErrUnsupportedProvider) {
+		return nil, err
+	}
+	if err == nil {
+		err = provider.ValidateConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s provider: %w", cfg.LLM.Provider, err)
+	}
+	return provider, nil
 }
@@ -0,0 +1,585 @@
+// Package fake implements an LLM provider that returns deterministic reviews
+// from fixtures or rules, for demos and for testing integrations without
+// network access.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/parse"
+	"github.com/niels/git-llm-review/pkg/prompt"
+	"github.com/niels/git-llm-review/pkg/retry"
+)
+
+// Kinds of injected errors
+const (
+	ErrorTimeout     = "timeout"
+	ErrorRateLimit   = "rate_limit"
+	ErrorServerError = "server_error"
+	ErrorMalformed   = "malformed"
+)
+
+// DefaultModel is the model name reported when none is configured
+const DefaultModel = "fake"
+
+// malformedResponse is returned for injected malformed JSON
+const malformedResponse = `{"issues": [{"title": "Truncated response", "explanation": "`
+
+// defaultRules flag unresolved TODO and FIXME comments
+var defaultRules = []config.FakeRule{
+	{
+		Pattern:     `\b(TODO|FIXME)\b`,
+		Title:       "Maintainability: Unresolved TODO",
+		Explanation: "The change adds a TODO or FIXME comment. Resolve it or track it in an issue before merging.",
+	},
+}
+
+var (
+	// filePattern finds the path of the reviewed file in a prompt
+	filePattern = regexp.MustCompile(`(?m)^File: (.+)$`)
+	// diffFilePattern finds the files of a git diff in a prompt
+	diffFilePattern = regexp.MustCompile(`(?m)^diff --git a/(\S+) b/`)
+	// testFilePattern finds the file a test prompt asks for tests of
+	testFilePattern = regexp.MustCompile(`^` + regexp.QuoteMeta(prompt.TestSuggestionHeading) + ` in (.+)\.\n`)
+	// functionNamePattern finds the names of Go, Python and JavaScript functions
+	functionNamePattern = regexp.MustCompile(`(?m)^(?:func (?:\([^)]*\) )?|\s*def |\s*(?:async )?function )(\w+)`)
+	// packagePattern finds the package clause of Go code
+	packagePattern = regexp.MustCompile(`(?m)^package (\w+)`)
+	// templatePattern finds the pull request template in a prompt
+	templatePattern = regexp.MustCompile("(?s)TEMPLATE:\n```markdown\n(.*?)\n```")
+	// diffPattern finds the diff of the reviewed file in a prompt
+	diffPattern = regexp.MustCompile("(?s)```diff\n(.*?)\n```")
+)
+
+// Provider implements the llm.Provider interface with canned reviews
+type Provider struct {
+	model  string
+	config config.FakeConfig
+	rules  []rule
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// rule is a compiled config.FakeRule
+type rule struct {
+	pattern     *regexp.Regexp
+	title       string
+	explanation string
+}
+
+// NewProvider creates a new fake provider with the given configuration
+func NewProvider(cfg *config.Config) (llm.Provider, error) {
+	fakeCfg := cfg.LLM.Fake
+
+	for _, injected := range fakeCfg.Errors {
+		switch injected.Kind {
+		case ErrorTimeout, ErrorRateLimit, ErrorServerError, ErrorMalformed:
+		default:
+			return nil, llm.NewProviderError(fmt.Sprintf("unknown fake error kind %q", injected.Kind), llm.ErrConfigurationError)
+		}
+	}
+
+	ruleCfgs := fakeCfg.Rules
+	if len(ruleCfgs) == 0 && fakeCfg.Fixtures == "" {
+		ruleCfgs = defaultRules
+	}
+	rules := make([]rule, 0, len(ruleCfgs))
+	for _, ruleCfg := range ruleCfgs {
+		pattern, err := regexp.Compile(ruleCfg.Pattern)
+		if err != nil {
+			return nil, llm.NewProviderError(fmt.Sprintf("invalid fake rule pattern %q: %v", ruleCfg.Pattern, err), llm.ErrConfigurationError)
+		}
+		title := ruleCfg.Title
+		if title == "" {
+			title = fmt.Sprintf("Line matches %s", ruleCfg.Pattern)
+		}
+		rules = append(rules, rule{pattern: pattern, title: title, explanation: ruleCfg.Explanation})
+	}
+
+	model := cfg.LLM.Model
+	if model == "" {
+		model = DefaultModel
+	}
+
+	return &Provider{
+		model:    model,
+		config:   fakeCfg,
+		rules:    rules,
+		attempts: make(map[string]int),
+	}, nil
+}
+
+// Name returns the name of the provider
+func (p *Provider) Name() string {
+	return "Fake"
+}
+
+// Model returns the name of the model used by the provider
+func (p *Provider) Model() string {
+	return p.model
+}
+
+// ValidateConfig validates the provider configuration
+func (p *Provider) ValidateConfig() error {
+	if p.config.Fixtures == "" {
+		return nil
+	}
+	if info, err := os.Stat(p.config.Fixtures); err != nil || !info.IsDir() {
+		return llm.NewProviderError(fmt.Sprintf("fixtures directory %s not found", p.config.Fixtures), llm.ErrConfigurationError)
+	}
+	return nil
+}
+
+// ReviewCode returns the review of the requested file, or of every file of
+// a changeset review
+func (p *Provider) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (*llm.ReviewResponse, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	if len(request.Files) > 0 {
+		return p.reviewChangeset(ctx, request.Files)
+	}
+
+	diff := request.FileDiff
+	if diff == "" {
+		diff = request.FileContent
+	}
+	return p.review(ctx, request.FilePath, diff)
+}
+
+// GetCompletion returns the review of the file named in the prompt
+func (p *Provider) GetCompletion(ctx context.Context, prompt string) (string, error) {
+	response, err := p.GetCompletionWithMetadata(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return response.Review, nil
+}
+
+// GetCompletionWithMetadata returns the review of the file named in the
+// prompt, or the summary of a change, together with its metadata
+func (p *Provider) GetCompletionWithMetadata(ctx context.Context, prompt string) (*llm.ReviewResponse, error) {
+	if isSummaryPrompt(prompt) {
+		return p.summarize(ctx, prompt)
+	}
+	if isExplainPrompt(prompt) {
+		return p.explain(ctx, prompt)
+	}
+	if isPRDescriptionPrompt(prompt) {
+		return p.describePullRequest(ctx, prompt)
+	}
+	if isTestSuggestionPrompt(prompt) {
+		return p.suggestTests(ctx, prompt)
+	}
+
+	var filePath string
+	if match := filePattern.FindStringSubmatch(prompt); match != nil {
+		filePath = strings.TrimSpace(match[1])
+	}
+
+	// Rules look at the changed lines, or at the whole prompt without a diff
+	diff := prompt
+	if match := diffPattern.FindStringSubmatch(prompt); match != nil {
+		diff = match[1]
+	}
+
+	return p.review(ctx, filePath, diff)
+}
+
+// review returns the review of the file at filePath with the given changes
+func (p *Provider) review(ctx context.Context, filePath, diff string) (*llm.ReviewResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	injected, err := p.injectedError(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	review := malformedResponse
+	if injected != ErrorMalformed {
+		review, err = p.reviewFor(filePath, diff)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return p.response(review), nil
+}
+
+// reviewChangeset returns one review holding the reviews of all files, with
+// every issue naming its file. An error injected for any of the files fails
+// the whole review.
+func (p *Provider) reviewChangeset(ctx context.Context, files []llm.ReviewFile) (*llm.ReviewResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	combined := parse.JSONReviewResult{Issues: []parse.Issue{}}
+	for _, file := range files {
+		injected, err := p.injectedError(file.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		if injected == ErrorMalformed {
+			return p.response(malformedResponse), nil
+		}
+
+		diff := file.FileDiff
+		if diff == "" {
+			diff = file.FileContent
+		}
+		review, err := p.reviewFor(file.FilePath, diff)
+		if err != nil {
+			return nil, err
+		}
+
+		var result parse.JSONReviewResult
+		if err := json.Unmarshal([]byte(review), &result); err != nil {
+			return nil, llm.NewProviderError(fmt.Sprintf("invalid fixture for %s: %v", file.FilePath, err), err)
+		}
+		for _, issue := range result.Issues {
+			if issue.File == "" {
+				issue.File = file.FilePath
+			}
+			combined.Issues = append(combined.Issues, issue)
+		}
+		for _, diff := range result.Diffs {
+			if diff.File == "" {
+				diff.File = file.FilePath
+			}
+			combined.Diffs = append(combined.Diffs, diff)
+		}
+	}
+
+	review, err := json.MarshalIndent(combined, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode review: %w", err)
+	}
+	return p.response(string(review)), nil
+}
+
+// summarize returns a summary of the change in the prompt that lists the
+// files it names
+func (p *Provider) summarize(ctx context.Context, prompt string) (*llm.ReviewResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("The change touches the following files:\n")
+	for _, match := range filePattern.FindAllStringSubmatch(prompt, -1) {
+		sb.WriteString("- " + strings.TrimSpace(match[1]) + "\n")
+	}
+	return p.response(sb.String()), nil
+}
+
+// explain returns an explanation of the change in the prompt that lists the
+// files of its diff, with a diagram if one is asked for
+func (p *Provider) explain(ctx context.Context, prompt string) (*llm.ReviewResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range diffFilePattern.FindAllStringSubmatch(prompt, -1) {
+		files = append(files, match[1])
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Summary\n\nThe change touches " + strings.Join(files, ", ") + ".\n\n")
+	sb.WriteString("## Walkthrough\n\n")
+	for _, file := range files {
+		sb.WriteString("- " + file + " is changed.\n")
+	}
+	sb.WriteString("\n## Why it matters\n\nThe fake provider does not know.\n")
+	if strings.Contains(prompt, "## Diagram") {
+		sb.WriteString("\n## Diagram\n\n```mermaid\nsequenceDiagram\n")
+		for _, file := range files {
+			sb.WriteString("    Caller->>" + path.Base(file) + ": call\n")
+		}
+		sb.WriteString("```\n")
+	}
+	return p.response(sb.String()), nil
+}
+
+// describePullRequest returns the title and body of a pull request for the
+// files of the diff in the prompt, with the headings of its template if it
+// has one
+func (p *Provider) describePullRequest(ctx context.Context, prompt string) (*llm.ReviewResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, match := range diffFilePattern.FindAllStringSubmatch(prompt, -1) {
+		files = append(files, match[1])
+	}
+
+	headings := []string{"## Summary", "## Motivation", "## Notable changes", "## Testing", "## Risks and rollback"}
+	if match := templatePattern.FindStringSubmatch(prompt); match != nil {
+		headings = nil
+		for _, line := range strings.Split(match[1], "\n") {
+			if strings.HasPrefix(line, "#") {
+				headings = append(headings, strings.TrimSpace(line))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("TITLE: Update " + strings.Join(files, ", ") + "\n")
+	for _, heading := range headings {
+		sb.WriteString("\n" + heading + "\n\nThe change touches " + strings.Join(files, ", ") + ".\n")
+	}
+	return p.response(sb.String()), nil
+}
+
+// suggestTests returns a test file with an empty test for each changed
+// function in the prompt, in the language of the changed file
+func (p *Provider) suggestTests(ctx context.Context, text string) (*llm.ReviewResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var filePath string
+	if match := testFilePattern.FindStringSubmatch(text); match != nil {
+		filePath = match[1]
+	}
+	functions := text
+	if start := strings.Index(text, "CHANGED FUNCTIONS:"); start >= 0 {
+		functions = text[start:]
+	}
+	if end := strings.Index(functions, "\nDIFF:"); end >= 0 {
+		functions = functions[:end]
+	}
+
+	var sb strings.Builder
+	language := prompt.GetLanguageFromFilePath(filePath)
+	sb.WriteString("```" + language + "\n")
+	switch language {
+	case "go":
+		packageName := "main"
+		if match := packagePattern.FindStringSubmatch(text); match != nil {
+			packageName = match[1]
+		}
+		sb.WriteString("package " + packageName + "\n\nimport \"testing\"\n")
+	}
+	for _, match := range functionNamePattern.FindAllStringSubmatch(functions, -1) {
+		name := match[1]
+		switch language {
+		case "go":
+			sb.WriteString("\nfunc Test" + strings.ToUpper(name[:1]) + name[1:] + "(t *testing.T) {\n\t// TODO: cover the changes to " + name + "\n}\n")
+		case "python":
+			sb.WriteString("\ndef test_" + name + "():\n    # TODO: cover the changes to " + name + "\n    pass\n")
+		default:
+			sb.WriteString("\ntest(\"" + name + "\", () => {\n  // TODO: cover the changes to " + name + "\n});\n")
+		}
+	}
+	sb.WriteString("```\n")
+	return p.response(sb.String()), nil
+}
+
+// isTestSuggestionPrompt reports whether the prompt asks for tests of
+// changed code
+func isTestSuggestionPrompt(text string) bool {
+	return strings.HasPrefix(text, prompt.TestSuggestionHeading)
+}
+
+// isPRDescriptionPrompt reports whether the prompt asks for the description
+// of a pull request
+func isPRDescriptionPrompt(text string) bool {
+	return strings.HasPrefix(text, prompt.PRDescriptionHeading)
+}
+
+// isExplainPrompt reports whether the prompt asks for the explanation of a
+// change
+func isExplainPrompt(text string) bool {
+	return strings.HasPrefix(text, prompt.ExplainHeading)
+}
+
+// isSummaryPrompt reports whether the prompt asks for the summary of a change
+func isSummaryPrompt(text string) bool {
+	return strings.HasPrefix(text, prompt.SummaryHeading)
+}
+
+// response wraps a review in a response with the provider's metadata
+func (p *Provider) response(review string) *llm.ReviewResponse {
+	return &llm.ReviewResponse{
+		Review: review,
+		Metadata: map[string]interface{}{
+			llm.MetadataProvider: llm.DescribeProvider(p),
+			llm.MetadataModel:    p.model,
+		},
+	}
+}
+
+// wait delays the response by the configured latency
+func (p *Provider) wait(ctx context.Context) error {
+	if p.config.Latency <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(p.config.Latency) * time.Millisecond)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return llm.RequestError(ctx, "fake request failed", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
+
+// injectedError returns the error injected for filePath, if any. A malformed
+// response is not an error, so it is returned as the kind with a nil error.
+func (p *Provider) injectedError(filePath string) (string, error) {
+	for i, injected := range p.config.Errors {
+		if !matchPath(injected.Match, filePath) {
+			continue
+		}
+
+		// Count the attempts for each file, so that an error injected only
+		// for the first attempts lets a retry succeed
+		if injected.Times > 0 {
+			p.mu.Lock()
+			key := fmt.Sprintf("%d\x00%s", i, filePath)
+			p.attempts[key]++
+			attempt := p.attempts[key]
+			p.mu.Unlock()
+			if attempt > injected.Times {
+				continue
+			}
+		}
+
+		switch injected.Kind {
+		case ErrorTimeout:
+			return "", llm.NewTimeoutError(fmt.Sprintf("fake request for %s timed out", filePath))
+		case ErrorRateLimit:
+			return "", llm.NewAPIError("API error: rate limit exceeded", 429, &retry.StatusError{
+				StatusCode: 429,
+				Message:    "rate limit exceeded",
+			})
+		case ErrorServerError:
+			return "", llm.NewAPIError("API error: internal server error", 500, &retry.StatusError{
+				StatusCode: 500,
+				Message:    "internal server error",
+			})
+		case ErrorMalformed:
+			return ErrorMalformed, nil
+		}
+	}
+	return "", nil
+}
+
+// reviewFor returns the review from the fixture for filePath, or else the
+// review generated by the rules
+func (p *Provider) reviewFor(filePath, diff string) (string, error) {
+	if p.config.Fixtures != "" {
+		fixture := ""
+		for _, response := range p.config.Responses {
+			if matchPath(response.Match, filePath) {
+				fixture = response.Fixture
+				break
+			}
+		}
+		if fixture == "" && filePath != "" {
+			fixture = filePath + ".json"
+		}
+
+		if fixture != "" {
+			data, err := os.ReadFile(filepath.Join(p.config.Fixtures, filepath.FromSlash(fixture)))
+			if err == nil {
+				return string(data), nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return "", llm.NewProviderError(fmt.Sprintf("failed to read fixture %s: %v", fixture, err), err)
+			}
+		}
+	}
+
+	return p.applyRules(filePath, diff)
+}
+
+// applyRules returns a review with an issue for every added line that
+// matches a rule. Every line is checked when diff is not a unified diff.
+func (p *Provider) applyRules(filePath, diff string) (string, error) {
+	result := parse.JSONReviewResult{Issues: []parse.Issue{}}
+	isDiff := strings.HasPrefix(diff, "@@") || strings.Contains(diff, "\n@@")
+
+	for _, line := range strings.Split(diff, "\n") {
+		if isDiff {
+			if !strings.HasPrefix(line, "+") || strings.HasPrefix(line, "+++") {
+				continue
+			}
+			line = line[1:]
+		}
+
+		for _, r := range p.rules {
+			if !r.pattern.MatchString(line) {
+				continue
+			}
+			explanation := r.explanation
+			if explanation != "" {
+				explanation += "\n\n"
+			}
+			result.Issues = append(result.Issues, parse.Issue{
+				Title:       r.title,
+				Explanation: explanation + "Line: " + strings.TrimSpace(line),
+				File:        filePath,
+			})
+		}
+	}
+
+	review, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode review: %w", err)
+	}
+	return string(review), nil
+}
+
+// matchPath reports whether filePath matches pattern, which is a path or a
+// glob. A glob without a slash is matched against the file name.
+func matchPath(pattern, filePath string) bool {
+	if pattern == "" || pattern == filePath {
+		return true
+	}
+	if ok, _ := path.Match(pattern, filePath); ok {
+		return true
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(filePath))
+		return ok
+	}
+	return false
+}
+
+// init registers the fake provider with the provider registry
+func init() {
+	llm.RegisterProviderFactory("fake", func(cfg map[string]interface{}) (llm.Provider, error) {
+		if fullCfg, ok := cfg["config"].(*config.Config); ok && fullCfg != nil {
+			return NewProvider(fullCfg)
+		}
+
+		model, _ := cfg["model"].(string)
+		return NewProvider(&config.Config{
+			LLM: config.LLMConfig{
+				Provider: "fake",
+				Model:    model,
+			},
+		})
+	})
+}
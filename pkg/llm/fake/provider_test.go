@@ -0,0 +1,279 @@
+package fake_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/llm/fake"
+	"github.com/niels/git-llm-review/pkg/parse"
+	"github.com/niels/git-llm-review/pkg/prompt"
+)
+
+// todoDiff adds a line with a TODO next to an unchanged one
+const todoDiff = `@@ -1,2 +1,3 @@
+ // TODO: existing comment
++// TODO: handle errors
+ func main() {}`
+
+// newProvider creates a fake provider with the given settings
+func newProvider(t *testing.T, fakeCfg config.FakeConfig) llm.Provider {
+	t.Helper()
+	provider, err := fake.NewProvider(&config.Config{
+		LLM: config.LLMConfig{Provider: "fake", Fake: fakeCfg},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	return provider
+}
+
+// reviewPrompt returns the review prompt for a file with the given diff
+func reviewPrompt(filePath, diff string) string {
+	return prompt.CreatePrompt(&llm.ReviewRequest{
+		FilePath:    filePath,
+		FileContent: "package main",
+		FileDiff:    diff,
+	}, prompt.ProviderDefault)
+}
+
+func TestDefaultRules(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{})
+
+	completion, err := provider.GetCompletion(context.Background(), reviewPrompt("main.go", todoDiff))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Only the added TODO is reported
+	result := parse.ParseReview(completion)
+	if result.GetIssueCount() != 1 {
+		t.Fatalf("Expected 1 issue, got %d: %s", result.GetIssueCount(), completion)
+	}
+	if result.Issues[0].File != "main.go" {
+		t.Errorf("Expected the issue to be reported for main.go, got %q", result.Issues[0].File)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, filepath.Join(dir, "pkg", "main.go.json"), `{"issues": [{"title": "From path"}]}`)
+	writeFixture(t, filepath.Join(dir, "proto.json"), `{"issues": [{"title": "From glob"}]}`)
+
+	provider := newProvider(t, config.FakeConfig{
+		Fixtures:  dir,
+		Responses: []config.FakeResponse{{Match: "*.proto", Fixture: "proto.json"}},
+	})
+
+	tests := []struct {
+		filePath string
+		want     string
+	}{
+		{"pkg/main.go", "From path"},
+		{"api/service.proto", "From glob"},
+	}
+	for _, tt := range tests {
+		response, err := provider.ReviewCode(context.Background(), &llm.ReviewRequest{FilePath: tt.filePath})
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got: %v", tt.filePath, err)
+		}
+		result := parse.ParseReview(response.Review)
+		if result.GetIssueCount() != 1 || result.Issues[0].Title != tt.want {
+			t.Errorf("Expected the %q fixture for %s, got %s", tt.want, tt.filePath, response.Review)
+		}
+	}
+
+	// Files without a fixture get an empty review, since no rules are configured
+	response, err := provider.ReviewCode(context.Background(), &llm.ReviewRequest{FilePath: "other.go", FileDiff: todoDiff})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if count := parse.ParseReview(response.Review).GetIssueCount(); count != 0 {
+		t.Errorf("Expected no issues, got %d", count)
+	}
+}
+
+func TestErrorInjection(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{
+		Errors: []config.FakeError{
+			{Match: "flaky.go", Kind: fake.ErrorRateLimit, Times: 1},
+			{Match: "slow.go", Kind: fake.ErrorTimeout},
+			{Match: "*.proto", Kind: fake.ErrorMalformed},
+		},
+	})
+	ctx := context.Background()
+
+	// A rate limit injected once lets the second attempt succeed
+	_, err := provider.GetCompletion(ctx, reviewPrompt("flaky.go", todoDiff))
+	if !errors.Is(err, llm.ErrProviderFailure) {
+		t.Errorf("Expected a provider error, got: %v", err)
+	}
+	if _, err := provider.GetCompletion(ctx, reviewPrompt("flaky.go", todoDiff)); err != nil {
+		t.Errorf("Expected the second attempt to succeed, got: %v", err)
+	}
+
+	if _, err := provider.GetCompletion(ctx, reviewPrompt("slow.go", todoDiff)); !errors.Is(err, llm.ErrTimeout) {
+		t.Errorf("Expected a timeout error, got: %v", err)
+	}
+
+	completion, err := provider.GetCompletion(ctx, reviewPrompt("api/service.proto", todoDiff))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := parse.ParseJSONReview(completion); err == nil {
+		t.Errorf("Expected malformed JSON, got %s", completion)
+	}
+}
+
+func TestChangesetReview(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{
+		Errors: []config.FakeError{{Match: "slow.go", Kind: fake.ErrorTimeout}},
+	})
+	request := &llm.ReviewRequest{
+		FilePath: "a.go and 1 more files",
+		Files: []llm.ReviewFile{
+			{FilePath: "a.go", FileDiff: todoDiff},
+			{FilePath: "lib/b.go", FileDiff: todoDiff},
+		},
+	}
+
+	response, err := provider.ReviewCode(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	result := parse.ParseReview(response.Review)
+	if result.GetIssueCount() != 2 || result.Issues[0].File != "a.go" || result.Issues[1].File != "lib/b.go" {
+		t.Errorf("Expected an issue naming each file, got %+v", result.Issues)
+	}
+
+	// An error injected for one file fails the whole review
+	request.Files = append(request.Files, llm.ReviewFile{FilePath: "slow.go", FileDiff: todoDiff})
+	if _, err := provider.ReviewCode(context.Background(), request); !errors.Is(err, llm.ErrTimeout) {
+		t.Errorf("Expected a timeout error, got: %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{Latency: 5000})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := provider.GetCompletion(ctx, reviewPrompt("main.go", todoDiff))
+	if !errors.Is(err, llm.ErrTimeout) {
+		t.Errorf("Expected a timeout error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request to stop with its context, took %v", elapsed)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for name, fakeCfg := range map[string]config.FakeConfig{
+		"unknown error kind": {Errors: []config.FakeError{{Kind: "explode"}}},
+		"invalid rule":       {Rules: []config.FakeRule{{Pattern: "("}}},
+	} {
+		_, err := fake.NewProvider(&config.Config{LLM: config.LLMConfig{Provider: "fake", Fake: fakeCfg}})
+		if !errors.Is(err, llm.ErrConfigurationError) {
+			t.Errorf("%s: expected a configuration error, got: %v", name, err)
+		}
+	}
+}
+
+// writeFixture writes a fixture file, creating its directory
+func writeFixture(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create fixture directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+}
+
+func TestChangeSummary(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{})
+	summaryPrompt := prompt.CreateSummaryPrompt([]llm.ReviewFile{
+		{FilePath: "a.go", FileDiff: todoDiff},
+		{FilePath: "lib/b.go", FileDiff: todoDiff},
+	})
+
+	summary, err := provider.GetCompletion(context.Background(), summaryPrompt)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !strings.Contains(summary, "- a.go\n") || !strings.Contains(summary, "- lib/b.go\n") {
+		t.Errorf("Expected a summary listing both files, got %q", summary)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{})
+	explainPrompt := prompt.CreateExplainPrompt(prompt.ExplainRequest{
+		Subject: "staged changes",
+		Diff:    "diff --git a/a.go b/a.go\n" + todoDiff + "\ndiff --git a/lib/b.go b/lib/b.go\n" + todoDiff,
+		Diagram: true,
+	}, prompt.ProviderDefault)
+
+	explanation, err := provider.GetCompletion(context.Background(), explainPrompt)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, expected := range []string{"## Summary", "a.go, lib/b.go", "```mermaid"} {
+		if !strings.Contains(explanation, expected) {
+			t.Errorf("Expected the explanation to contain %q, got %q", expected, explanation)
+		}
+	}
+}
+
+func TestPRDescription(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{})
+	descriptionPrompt := prompt.CreatePRDescriptionPrompt(prompt.PRDescriptionRequest{
+		Branch:   "feature",
+		Base:     "main",
+		Diff:     "diff --git a/a.go b/a.go\n" + todoDiff,
+		Template: "## What\n\n<!-- describe -->\n\n## Checklist\n",
+	})
+
+	description, err := provider.GetCompletion(context.Background(), descriptionPrompt)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	title, body := prompt.ParsePRDescription(description)
+	if title != "Update a.go" {
+		t.Errorf("Expected a title for a.go, got %q", title)
+	}
+	if !strings.HasPrefix(body, "## What\n") || !strings.Contains(body, "## Checklist\n") {
+		t.Errorf("Expected the headings of the template, got %q", body)
+	}
+}
+
+func TestSuggestTests(t *testing.T) {
+	provider := newProvider(t, config.FakeConfig{})
+	testPrompt := prompt.CreateTestSuggestionPrompt(prompt.TestSuggestionRequest{
+		FilePath:     "calc/calc.go",
+		TestPath:     "calc/calc_test.go",
+		Functions:    []string{"func Sub(a, b int) int {\n\treturn a - b\n}", "func (c *Calc) reset() {\n}"},
+		Diff:         todoDiff,
+		StylePath:    "calc/add_test.go",
+		StyleExample: "package calc\n",
+	})
+
+	answer, err := provider.GetCompletion(context.Background(), testPrompt)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	code := prompt.ExtractTestCode(answer)
+	for _, expected := range []string{"package calc\n", "func TestSub(t *testing.T) {", "func TestReset(t *testing.T) {"} {
+		if !strings.Contains(code, expected) {
+			t.Errorf("Expected the tests to contain %q, got %q", expected, code)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/logging"
+)
+
+// FallbackProvider sends requests to an ordered chain of providers. When a
+// provider fails with an error that another provider might not have, such as
+// an outage, exhausted retries or an authentication or quota problem, the
+// request is re-sent to the next provider in the chain.
+type FallbackProvider struct {
+	providers []Provider
+}
+
+// NewFallbackProvider creates a provider that tries the given providers in order
+func NewFallbackProvider(providers ...Provider) *FallbackProvider {
+	return &FallbackProvider{
+		providers: providers,
+	}
+}
+
+// Providers returns the providers in the chain, in the order they are tried
+func (p *FallbackProvider) Providers() []Provider {
+	return p.providers
+}
+
+// Name returns the name of the primary provider
+func (p *FallbackProvider) Name() string {
+	if len(p.providers) == 0 {
+		return "Fallback"
+	}
+	return p.providers[0].Name()
+}
+
+// ValidateConfig validates the configuration of every provider in the chain
+func (p *FallbackProvider) ValidateConfig() error {
+	if len(p.providers) == 0 {
+		return NewProviderError("no providers configured", ErrConfigurationError)
+	}
+
+	for _, provider := range p.providers {
+		if err := provider.ValidateConfig(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReviewCode performs a code review with the first provider that succeeds
+func (p *FallbackProvider) ReviewCode(ctx context.Context, request *ReviewRequest) (*ReviewResponse, error) {
+	return p.try(ctx, func(provider Provider) (*ReviewResponse, error) {
+		return provider.ReviewCode(ctx, request)
+	})
+}
+
+// GetCompletion sends a prompt to the first provider that succeeds
+func (p *FallbackProvider) GetCompletion(ctx context.Context, prompt string) (string, error) {
+	response, err := p.GetCompletionWithMetadata(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return response.Review, nil
+}
+
+// GetCompletionWithMetadata sends a prompt to the first provider that succeeds
+// and records which provider produced the completion
+func (p *FallbackProvider) GetCompletionWithMetadata(ctx context.Context, prompt string) (*ReviewResponse, error) {
+	return p.try(ctx, func(provider Provider) (*ReviewResponse, error) {
+		return CompleteWithMetadata(ctx, provider, prompt)
+	})
+}
+
+// StreamCompletion streams the completion from the first provider that
+// succeeds. Once part of a completion has been delivered, a failure is
+// returned instead of starting over with the next provider.
+func (p *FallbackProvider) StreamCompletion(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	streamed := false
+	response, err := p.try(ctx, func(provider Provider) (*ReviewResponse, error) {
+		completion, err := StreamCompletion(ctx, provider, prompt, func(chunk string) {
+			streamed = true
+			onChunk(chunk)
+		})
+		if err != nil {
+			if streamed {
+				return nil, fmt.Errorf("completion stopped after streaming started: %v", err)
+			}
+			return nil, err
+		}
+		return &ReviewResponse{Review: completion}, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return response.Review, nil
+}
+
+// try calls fn for each provider in turn until one succeeds or fails with an
+// error that should not be retried elsewhere
+func (p *FallbackProvider) try(ctx context.Context, fn func(Provider) (*ReviewResponse, error)) (*ReviewResponse, error) {
+	if len(p.providers) == 0 {
+		return nil, NewProviderError("no providers configured", ErrConfigurationError)
+	}
+
+	var errs []error
+	for i, provider := range p.providers {
+		response, err := fn(provider)
+		if err == nil {
+			if response.Metadata == nil {
+				response.Metadata = make(map[string]interface{})
+			}
+			response.Metadata[MetadataProvider] = DescribeProvider(provider)
+			if i > 0 {
+				logging.InfoWith("Request served by fallback provider", map[string]interface{}{
+					"provider": DescribeProvider(provider),
+					"position": i,
+				})
+			}
+			return response, nil
+		}
+
+		// Do not move on when the caller gave up or the error is not one
+		// that a different provider could avoid
+		if ctx.Err() != nil || !ShouldFallback(err) {
+			return nil, err
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", DescribeProvider(provider), err))
+		if i < len(p.providers)-1 {
+			logging.WarnWith("Provider failed, trying next fallback provider", map[string]interface{}{
+				"provider": DescribeProvider(provider),
+				"next":     DescribeProvider(p.providers[i+1]),
+				"error":    err.Error(),
+			})
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, errors.Unwrap(errs[0])
+	}
+
+	reasons := make([]string, len(errs))
+	for i, err := range errs {
+		reasons[i] = err.Error()
+	}
+	return nil, NewProviderError(fmt.Sprintf("all %d providers failed: %s", len(errs), strings.Join(reasons, "; ")), errors.Join(errs...))
+}
+
+// ShouldFallback reports whether a failed request should be re-sent to the
+// next provider in a fallback chain: when the provider stayed unavailable
+// after all retries, is out of quota, rejected the key or timed out. Other
+// errors, such as a rejected request or an answer that cannot be parsed,
+// would most likely fail again and be paid for twice.
+func ShouldFallback(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrRetriesExhausted) ||
+		errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrProviderUnavailable) ||
+		errors.Is(err, ErrAuthenticationFailure) ||
+		errors.Is(err, ErrTimeout)
+}
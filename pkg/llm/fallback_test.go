@@ -0,0 +1,360 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/llm/anthropic"
+	"github.com/niels/git-llm-review/pkg/llm/openai"
+)
+
+// anthropicSuccess is a minimal successful Anthropic messages response
+const anthropicSuccess = `{"content":[{"type":"text","text":"anthropic review"}]}`
+
+// openAISuccess is a minimal successful OpenAI chat completion response
+const openAISuccess = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"created": 1700000000,
+	"model": "gpt-4",
+	"choices": [{
+		"index": 0,
+		"message": {"role": "assistant", "content": "openai review"},
+		"finish_reason": "stop"
+	}],
+	"usage": {"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2}
+}`
+
+// newStandIn starts a test server that answers with the given status and body
+// and counts the requests it receives
+func newStandIn(t *testing.T, status int, body string, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestProvider creates a real provider pointed at a stand-in server
+func newTestProvider(t *testing.T, cfg *config.Config) llm.Provider {
+	t.Helper()
+	var (
+		provider llm.Provider
+		err      error
+	)
+	switch cfg.LLM.Provider {
+	case "openai":
+		provider, err = openai.NewProvider(cfg)
+	case "anthropic":
+		provider, err = anthropic.NewProvider(cfg)
+	}
+	if err != nil {
+		t.Fatalf("Failed to create %s provider: %v", cfg.LLM.Provider, err)
+	}
+	return provider
+}
+
+// testConfig returns a configuration for the given provider and server
+func testConfig(provider, model string, server *httptest.Server) *config.Config {
+	return &config.Config{
+		LLM: config.LLMConfig{
+			Provider: provider,
+			APIURL:   server.URL,
+			APIKey:   "test-api-key",
+			Model:    model,
+			Timeout:  5,
+		},
+	}
+}
+
+// TestFallbackOnServerError verifies that a 500 from the primary is served by the fallback
+func TestFallbackOnServerError(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	primaryServer := newStandIn(t, http.StatusInternalServerError, `{"error":{"message":"overloaded"}}`, &primaryCalls)
+	fallbackServer := newStandIn(t, http.StatusOK, anthropicSuccess, &fallbackCalls)
+
+	chain := llm.NewFallbackProvider(
+		newTestProvider(t, testConfig("anthropic", "claude-primary", primaryServer)),
+		newTestProvider(t, testConfig("anthropic", "claude-fallback", fallbackServer)),
+	)
+
+	response, err := chain.GetCompletionWithMetadata(context.Background(), "review this")
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got: %v", err)
+	}
+
+	if response.Review != "anthropic review" {
+		t.Errorf("Expected fallback review, got %q", response.Review)
+	}
+
+	// The report should name the provider that actually produced the review
+	if used := response.Metadata[llm.MetadataProvider]; used != "Anthropic (claude-fallback)" {
+		t.Errorf("Expected provider 'Anthropic (claude-fallback)', got %v", used)
+	}
+
+	if primaryCalls == 0 || fallbackCalls != 1 {
+		t.Errorf("Expected both servers to be called, got primary=%d fallback=%d", primaryCalls, fallbackCalls)
+	}
+}
+
+// TestFallbackOnAuthenticationError verifies that a rejected key on one
+// provider falls back to a different provider type
+func TestFallbackOnAuthenticationError(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	primaryServer := newStandIn(t, http.StatusUnauthorized, `{"error":{"message":"invalid api key","type":"invalid_request_error"}}`, &primaryCalls)
+	fallbackServer := newStandIn(t, http.StatusOK, anthropicSuccess, &fallbackCalls)
+
+	chain := llm.NewFallbackProvider(
+		newTestProvider(t, testConfig("openai", "gpt-4", primaryServer)),
+		newTestProvider(t, testConfig("anthropic", "claude-fallback", fallbackServer)),
+	)
+
+	// Use the generic helper, as the processor does
+	response, err := llm.CompleteWithMetadata(context.Background(), chain, "review this")
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got: %v", err)
+	}
+
+	if response.Review != "anthropic review" {
+		t.Errorf("Expected fallback review, got %q", response.Review)
+	}
+
+	if used := response.Metadata[llm.MetadataProvider]; used != "Anthropic (claude-fallback)" {
+		t.Errorf("Expected provider 'Anthropic (claude-fallback)', got %v", used)
+	}
+}
+
+// TestFallbackAfterRetriesExhausted verifies that a rate-limited primary is
+// retried before the request moves to the fallback
+func TestFallbackAfterRetriesExhausted(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	primaryServer := newStandIn(t, http.StatusTooManyRequests, `{"error":{"message":"rate limited"}}`, &primaryCalls)
+	fallbackServer := newStandIn(t, http.StatusOK, openAISuccess, &fallbackCalls)
+
+	// Enable fast retries on the primary
+	primaryCfg := testConfig("anthropic", "claude-primary", primaryServer)
+	primaryCfg.Retry = config.RetryConfig{
+		Enabled:       true,
+		MaxRetries:    2,
+		InitialDelay:  1,
+		MaxDelay:      5,
+		BackoffFactor: 2,
+	}
+
+	chain := llm.NewFallbackProvider(
+		newTestProvider(t, primaryCfg),
+		newTestProvider(t, testConfig("openai", "gpt-4", fallbackServer)),
+	)
+
+	request := &llm.ReviewRequest{
+		FilePath:    "main.go",
+		FileContent: "package main\n",
+		FileDiff:    "+package main\n",
+		Options: llm.ReviewOptions{
+			MaxTokens:   100,
+			Temperature: llm.Float(0.1),
+			Timeout:     5 * time.Second,
+		},
+	}
+
+	response, err := chain.ReviewCode(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got: %v", err)
+	}
+
+	if response.Review != "openai review" {
+		t.Errorf("Expected fallback review, got %q", response.Review)
+	}
+
+	// The primary should have been tried once plus every retry
+	if primaryCalls != 3 {
+		t.Errorf("Expected 3 attempts on the primary, got %d", primaryCalls)
+	}
+
+	if fallbackCalls != 1 {
+		t.Errorf("Expected 1 call to the fallback, got %d", fallbackCalls)
+	}
+}
+
+// TestFallbackSkippedForBadRequest verifies that a request the provider
+// rejects as invalid is not sent, and paid for, again on the next provider
+func TestFallbackSkippedForBadRequest(t *testing.T) {
+	var primaryCalls, fallbackCalls int32
+	primaryServer := newStandIn(t, http.StatusBadRequest, `{"error":{"message":"max_tokens is too large","type":"invalid_request_error"}}`, &primaryCalls)
+	fallbackServer := newStandIn(t, http.StatusOK, anthropicSuccess, &fallbackCalls)
+
+	for _, primary := range []string{"openai", "anthropic"} {
+		chain := llm.NewFallbackProvider(
+			newTestProvider(t, testConfig(primary, "primary-model", primaryServer)),
+			newTestProvider(t, testConfig("anthropic", "claude-fallback", fallbackServer)),
+		)
+
+		if _, err := chain.GetCompletion(context.Background(), "review this"); err == nil || llm.ShouldFallback(err) {
+			t.Errorf("%s: expected an error that does not fall back, got: %v", primary, err)
+		}
+	}
+	if primaryCalls != 2 || fallbackCalls != 0 {
+		t.Errorf("Expected only the primary to be called, got primary=%d fallback=%d", primaryCalls, fallbackCalls)
+	}
+}
+
+// TestFallbackAllProvidersFail verifies that the errors of every provider are reported
+func TestFallbackAllProvidersFail(t *testing.T) {
+	var firstCalls, secondCalls int32
+	firstServer := newStandIn(t, http.StatusServiceUnavailable, `{"error":{"message":"unavailable"}}`, &firstCalls)
+	secondServer := newStandIn(t, http.StatusForbidden, `{"error":{"message":"quota exceeded"}}`, &secondCalls)
+
+	chain := llm.NewFallbackProvider(
+		newTestProvider(t, testConfig("anthropic", "claude-first", firstServer)),
+		newTestProvider(t, testConfig("anthropic", "claude-second", secondServer)),
+	)
+
+	_, err := chain.GetCompletion(context.Background(), "review this")
+	if err == nil {
+		t.Fatal("Expected an error when every provider fails")
+	}
+
+	if !errors.Is(err, llm.ErrProviderFailure) {
+		t.Errorf("Expected ErrProviderFailure, got: %v", err)
+	}
+
+	if firstCalls == 0 || secondCalls == 0 {
+		t.Errorf("Expected every provider to be tried, got first=%d second=%d", firstCalls, secondCalls)
+	}
+}
+
+// stubProvider is a provider whose result is fixed by the test
+type stubProvider struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (s *stubProvider) Name() string          { return s.name }
+func (s *stubProvider) ValidateConfig() error { return nil }
+
+func (s *stubProvider) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (*llm.ReviewResponse, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &llm.ReviewResponse{Review: s.name + " review"}, nil
+}
+
+func (s *stubProvider) GetCompletion(ctx context.Context, prompt string) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.name + " review", nil
+}
+
+// TestFallbackSkippedForNonProviderErrors verifies that cancellation and
+// invalid requests are not re-sent to the next provider
+func TestFallbackSkippedForNonProviderErrors(t *testing.T) {
+	// Invalid requests would fail the same way on every provider
+	primary := &stubProvider{name: "primary", err: llm.NewInvalidRequestError("bad request")}
+	fallback := &stubProvider{name: "fallback"}
+
+	chain := llm.NewFallbackProvider(primary, fallback)
+	if _, err := chain.GetCompletion(context.Background(), "review this"); !errors.Is(err, llm.ErrInvalidRequest) {
+		t.Errorf("Expected ErrInvalidRequest, got: %v", err)
+	}
+	if fallback.calls != 0 {
+		t.Errorf("Expected fallback not to be called, got %d calls", fallback.calls)
+	}
+
+	// An answer that cannot be parsed is not the fault of the provider
+	primary = &stubProvider{name: "primary", err: llm.NewProviderError("failed to parse response", errors.New("unexpected end of JSON input"))}
+	fallback = &stubProvider{name: "fallback"}
+
+	chain = llm.NewFallbackProvider(primary, fallback)
+	if _, err := chain.GetCompletion(context.Background(), "review this"); !errors.Is(err, llm.ErrProviderFailure) {
+		t.Errorf("Expected ErrProviderFailure, got: %v", err)
+	}
+	if fallback.calls != 0 {
+		t.Errorf("Expected fallback not to be called, got %d calls", fallback.calls)
+	}
+
+	// A cancelled run should not move on to the next provider
+	primary = &stubProvider{name: "primary", err: llm.NewProviderError("request cancelled", context.Canceled)}
+	fallback = &stubProvider{name: "fallback"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	chain = llm.NewFallbackProvider(primary, fallback)
+	if _, err := chain.ReviewCode(ctx, &llm.ReviewRequest{FilePath: "main.go"}); err == nil {
+		t.Error("Expected an error for a cancelled context")
+	}
+	if fallback.calls != 0 {
+		t.Errorf("Expected fallback not to be called, got %d calls", fallback.calls)
+	}
+}
+
+// TestCreateProviderFromConfigResolvesKeys verifies that the API keys of the
+// provider and its fallbacks are read when the provider is built
+func TestCreateProviderFromConfigResolvesKeys(t *testing.T) {
+	t.Setenv("LLM_API_KEY", "")
+	keyFile := filepath.Join(t.TempDir(), "anthropic.key")
+	if err := os.WriteFile(keyFile, []byte("sk-ant-from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	var keys []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("x-api-key"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":{"message":"overloaded"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := testConfig("anthropic", "claude-primary", server)
+	cfg.LLM.APIKey = ""
+	cfg.LLM.APIKeyFile = keyFile
+	cfg.LLM.Fallbacks = []config.LLMConfig{{
+		Provider:   "anthropic",
+		APIURL:     server.URL,
+		APIKeyFile: keyFile,
+		Model:      "claude-fallback",
+		Timeout:    5,
+	}}
+
+	provider, err := llm.CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	if cfg.LLM.APIKeySource != config.KeySourceFile {
+		t.Errorf("Expected the key to be read from the file, got %q", cfg.LLM.APIKeySource)
+	}
+
+	provider.GetCompletion(context.Background(), "review this")
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) < 2 {
+		t.Fatalf("Expected both providers to be called, got %d requests", len(keys))
+	}
+	for _, key := range keys {
+		if key != "sk-ant-from-file" {
+			t.Errorf("Expected the key from the file, got %q", key)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/llm/promptlog"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/retry"
+	"github.com/openai/openai-go"
+)
+
+// batchLine is a request in the input file of a batch
+type batchLine struct {
+	CustomID string                         `json:"custom_id"`
+	Method   string                         `json:"method"`
+	URL      string                         `json:"url"`
+	Body     openai.ChatCompletionNewParams `json:"body"`
+}
+
+// batchOutputLine is a result in the output or error file of a batch
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SubmitBatch uploads the review requests as a JSONL file and creates a
+// batch job for the chat completions endpoint
+func (p *Provider) SubmitBatch(ctx context.Context, requests []llm.BatchRequest) (string, error) {
+	if len(requests) == 0 {
+		return "", llm.NewInvalidRequestError("no requests to submit")
+	}
+
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for _, batchRequest := range requests {
+		options := batchRequest.Request.Options.WithDefaults(llm.ReviewOptionsFromConfig(p.config))
+		if err := options.Validate(); err != nil {
+			return "", err
+		}
+
+		params, userPrompt := p.reviewParams(batchRequest.Request, options)
+		if err := promptlog.LogPrompt(p.Name(), batchRequest.Request.FilePath, userPrompt); err != nil {
+			logging.WarnWith("Failed to log prompt", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		line := batchLine{
+			CustomID: batchRequest.ID,
+			Method:   "POST",
+			URL:      string(openai.BatchNewParamsEndpointV1ChatCompletions),
+			Body:     params,
+		}
+		if err := encoder.Encode(line); err != nil {
+			return "", llm.NewInvalidRequestError(fmt.Sprintf("failed to encode batch request: %v", err))
+		}
+	}
+
+	// A retried upload or creation would be billed as a second batch
+	sendOnce := retry.SendOnce(ctx)
+	file, err := p.client.Files.New(sendOnce, openai.FileNewParams{
+		File:    openai.File(&input, "review-batch.jsonl", "application/jsonl"),
+		Purpose: openai.FilePurposeBatch,
+	})
+	if err != nil {
+		return "", requestError(ctx, "failed to upload batch input", err)
+	}
+
+	batch, err := p.client.Batches.New(sendOnce, openai.BatchNewParams{
+		CompletionWindow: openai.BatchNewParamsCompletionWindow24h,
+		Endpoint:         openai.BatchNewParamsEndpointV1ChatCompletions,
+		InputFileID:      file.ID,
+	})
+	if err != nil {
+		return "", requestError(ctx, "failed to create batch", err)
+	}
+
+	logging.InfoWith("Submitted batch", map[string]interface{}{
+		"batch":    batch.ID,
+		"requests": len(requests),
+		"model":    p.model,
+	})
+	return batch.ID, nil
+}
+
+// BatchStatus returns the progress of a batch job
+func (p *Provider) BatchStatus(ctx context.Context, id string) (*llm.BatchInfo, error) {
+	batch, err := p.client.Batches.Get(ctx, id)
+	if err != nil {
+		return nil, requestError(ctx, "failed to get batch", err)
+	}
+	return batchInfo(batch), nil
+}
+
+// BatchResults downloads the output and error files of a finished batch job
+func (p *Provider) BatchResults(ctx context.Context, id string) (map[string]llm.BatchResult, error) {
+	batch, err := p.client.Batches.Get(ctx, id)
+	if err != nil {
+		return nil, requestError(ctx, "failed to get batch", err)
+	}
+	if info := batchInfo(batch); !info.Done {
+		return nil, llm.NewProviderError(fmt.Sprintf("batch %s is still %s", id, info.Status), nil)
+	}
+
+	results := make(map[string]llm.BatchResult)
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		if err := p.readBatchFile(ctx, fileID, results); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// readBatchFile adds the results in an output or error file to results
+func (p *Provider) readBatchFile(ctx context.Context, fileID string, results map[string]llm.BatchResult) error {
+	resp, err := p.client.Files.Content(ctx, fileID)
+	if err != nil {
+		return requestError(ctx, "failed to download batch results", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchOutputLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return llm.NewProviderError(fmt.Sprintf("failed to parse batch results: %v", err), err)
+		}
+		results[line.CustomID] = p.batchResult(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return llm.NewProviderError(fmt.Sprintf("failed to read batch results: %v", err), err)
+	}
+	return nil
+}
+
+// batchResult converts a line of a batch output file into a result
+func (p *Provider) batchResult(line batchOutputLine) llm.BatchResult {
+	if line.Error != nil {
+		return llm.BatchResult{Err: llm.NewProviderError(fmt.Sprintf("API error: %s", line.Error.Message), nil)}
+	}
+	if line.Response == nil {
+		return llm.BatchResult{Err: llm.NewProviderError("batch result has no response", nil)}
+	}
+	if line.Response.StatusCode != 200 {
+		var body struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(line.Response.Body, &body)
+		return llm.BatchResult{Err: llm.NewProviderError(fmt.Sprintf("API error (status %d): %s", line.Response.StatusCode, body.Error.Message), nil)}
+	}
+
+	var completion openai.ChatCompletion
+	if err := json.Unmarshal(line.Response.Body, &completion); err != nil {
+		return llm.BatchResult{Err: llm.NewProviderError("failed to parse batch response", err)}
+	}
+	if len(completion.Choices) == 0 {
+		return llm.BatchResult{Err: llm.NewProviderError("no completion choices returned", nil)}
+	}
+	return llm.BatchResult{Response: p.response(&completion)}
+}
+
+// batchInfo converts a batch into its progress
+func batchInfo(batch *openai.Batch) *llm.BatchInfo {
+	info := &llm.BatchInfo{
+		ID:        batch.ID,
+		Status:    string(batch.Status),
+		Total:     int(batch.RequestCounts.Total),
+		Succeeded: int(batch.RequestCounts.Completed),
+		Failed:    int(batch.RequestCounts.Failed),
+	}
+	switch batch.Status {
+	case openai.BatchStatusCompleted, openai.BatchStatusFailed, openai.BatchStatusExpired, openai.BatchStatusCancelled:
+		info.Done = true
+	}
+	return info
+}
@@ -0,0 +1,164 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+// batchServer is a stand-in for the OpenAI files and batches endpoints. It
+// answers every request of a batch with a review naming the request, except
+// for requests that review fail.go.
+type batchServer struct {
+	mu       sync.Mutex
+	requests []stubRequest
+	done     bool
+}
+
+// stubRequest is a request read from the input file of a batch
+type stubRequest struct {
+	CustomID string          `json:"custom_id"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/files":
+		file, _, err := r.FormFile("file")
+		if err != nil || r.FormValue("purpose") != "batch" {
+			http.Error(w, `{"error":{"message":"invalid upload"}}`, http.StatusBadRequest)
+			return
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var request stubRequest
+			if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+				http.Error(w, `{"error":{"message":"invalid JSONL"}}`, http.StatusBadRequest)
+				return
+			}
+			s.requests = append(s.requests, request)
+		}
+		fmt.Fprint(w, `{"id":"file-input","object":"file","bytes":1,"created_at":1700000000,"filename":"review-batch.jsonl","purpose":"batch","status":"processed"}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/batches":
+		fmt.Fprint(w, s.batch("validating"))
+	case r.Method == http.MethodGet && r.URL.Path == "/batches/batch_1":
+		if s.done {
+			fmt.Fprint(w, s.batch("completed"))
+		} else {
+			fmt.Fprint(w, s.batch("in_progress"))
+		}
+	case r.Method == http.MethodGet && (r.URL.Path == "/files/file-output/content" || r.URL.Path == "/files/file-errors/content"):
+		errorFile := r.URL.Path == "/files/file-errors/content"
+		for _, line := range s.requests {
+			failed := strings.Contains(string(line.Body), "fail.go")
+			if failed != errorFile {
+				continue
+			}
+			if failed {
+				fmt.Fprintf(w, `{"id":"req","custom_id":%q,"response":{"status_code":400,"body":{"error":{"message":"prompt rejected"}}},"error":null}`+"\n", line.CustomID)
+				continue
+			}
+			content, _ := json.Marshal(fmt.Sprintf(`{"issues": [{"title": "Issue in %s"}]}`, line.CustomID))
+			fmt.Fprintf(w, `{"id":"req","custom_id":%q,"response":{"status_code":200,"body":{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}},"error":null}`+"\n", line.CustomID, content)
+		}
+	default:
+		http.Error(w, `{"error":{"message":"not found"}}`, http.StatusNotFound)
+	}
+}
+
+// batch returns the batch object with the given status
+func (s *batchServer) batch(status string) string {
+	output := ""
+	if status == "completed" {
+		output = `"output_file_id":"file-output","error_file_id":"file-errors",`
+	}
+	return fmt.Sprintf(`{"id":"batch_1","object":"batch","endpoint":"/v1/chat/completions","input_file_id":"file-input","completion_window":"24h","created_at":1700000000,"status":%q,%s"request_counts":{"total":%d,"completed":%d,"failed":%d}}`,
+		status, output, len(s.requests), len(s.requests)-1, 1)
+}
+
+// TestBatch verifies that reviews are submitted as a batch and that the
+// results are matched to their requests once the batch has completed
+func TestBatch(t *testing.T) {
+	stub := &batchServer{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, err := NewProvider(&config.Config{
+		LLM: config.LLMConfig{
+			Provider: "openai",
+			APIURL:   server.URL,
+			APIKey:   "test-api-key",
+			Model:    "test-model",
+			Timeout:  5,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create provider: %v", err)
+	}
+	batcher := provider.(llm.Batcher)
+	ctx := context.Background()
+
+	id, err := batcher.SubmitBatch(ctx, []llm.BatchRequest{
+		{ID: "file-1", Request: &llm.ReviewRequest{FilePath: "main.go", FileDiff: "+func main() {}"}},
+		{ID: "file-2", Request: &llm.ReviewRequest{FilePath: "fail.go", FileDiff: "+package fail"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if id != "batch_1" {
+		t.Errorf("Expected batch ID batch_1, got %q", id)
+	}
+	if len(stub.requests) != 2 || stub.requests[0].CustomID != "file-1" || stub.requests[0].URL != "/v1/chat/completions" {
+		t.Fatalf("Unexpected batch input: %+v", stub.requests)
+	}
+	if !strings.Contains(string(stub.requests[0].Body), `"model":"test-model"`) {
+		t.Errorf("Expected the chat completion parameters in the batch input, got %s", stub.requests[0].Body)
+	}
+
+	// Results cannot be collected before the batch has completed
+	info, err := batcher.BatchStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if info.Done || info.Status != "in_progress" {
+		t.Errorf("Expected the batch to be in progress, got %+v", info)
+	}
+	if _, err := batcher.BatchResults(ctx, id); err == nil {
+		t.Error("Expected an error for a batch in progress")
+	}
+
+	stub.done = true
+	info, err = batcher.BatchStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !info.Done || info.Total != 2 || info.Succeeded != 1 || info.Failed != 1 {
+		t.Errorf("Unexpected batch progress: %+v", info)
+	}
+
+	results, err := batcher.BatchResults(ctx, id)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result := results["file-1"]; result.Err != nil || !strings.Contains(result.Response.Review, "Issue in file-1") {
+		t.Errorf("Unexpected result for file-1: %+v", result)
+	}
+	if result := results["file-2"]; result.Err == nil || !strings.Contains(result.Err.Error(), "prompt rejected") {
+		t.Errorf("Expected the error for file-2, got %+v", result)
+	}
+}
@@ -5,16 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/niels/git-llm-review/pkg/config"
-	"github.com/niels/git-llm-review/pkg/extractor"
 	"github.com/niels/git-llm-review/pkg/llm"
 	"github.com/niels/git-llm-review/pkg/llm/exchangelog"
 	"github.com/niels/git-llm-review/pkg/llm/promptlog"
+	"github.com/niels/git-llm-review/pkg/llm/transport"
 	"github.com/niels/git-llm-review/pkg/logging"
 	"github.com/niels/git-llm-review/pkg/prompt"
 	"github.com/niels/git-llm-review/pkg/util"
@@ -27,6 +25,10 @@ type Provider struct {
 	client openai.Client
 	model  string
 	config *config.Config
+
+	// endpoint identifies the server and model, to remember which tool
+	// protocol works for them
+	endpoint string
 }
 
 // NewProvider creates a new OpenAI provider with the given configuration
@@ -44,6 +46,9 @@ func NewProvider(cfg *config.Config) (llm.Provider, error) {
 	// Set the OpenAI API options
 	options := []option.RequestOption{
 		option.WithAPIKey(cfg.LLM.APIKey),
+		option.WithHTTPClient(transport.NewClient(cfg)),
+		// Retries are handled by our transport, which honours the retry config
+		option.WithMaxRetries(0),
 	}
 
 	// Set custom API URL if provided
@@ -55,9 +60,10 @@ func NewProvider(cfg *config.Config) (llm.Provider, error) {
 	client := openai.NewClient(options...)
 
 	return &Provider{
-		client: client,
-		model:  cfg.LLM.Model,
-		config: cfg,
+		client:   client,
+		model:    cfg.LLM.Model,
+		config:   cfg,
+		endpoint: "openai " + cfg.LLM.APIURL + " " + cfg.LLM.Model,
 	}, nil
 }
 
@@ -66,6 +72,11 @@ func (p *Provider) Name() string {
 	return "OpenAI"
 }
 
+// Model returns the name of the model used by the provider
+func (p *Provider) Model() string {
+	return p.model
+}
+
 // ValidateConfig validates the provider configuration
 func (p *Provider) ValidateConfig() error {
 	if p.model == "" {
@@ -82,183 +93,216 @@ func (p *Provider) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (
 		return nil, errors.New("request is nil")
 	}
 
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(ctx, request.Options.Timeout)
+	// Use the configured generation settings for anything the request leaves unset
+	options := request.Options.WithDefaults(llm.ReviewOptionsFromConfig(p.config))
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Limit the request to its timeout, if one is set
+	ctx, cancel := withTimeout(ctx, options.Timeout)
 	defer cancel()
 
-	// Get the user prompt from the prompt package
+	// Prepare the chat completion parameters
+	params, userPrompt := p.reviewParams(request, options)
+
+	// Log the prompt if enabled
+	if err := promptlog.LogPrompt(p.Name(), request.FilePath, userPrompt); err != nil {
+		logging.ErrorWith("Failed to log prompt", map[string]interface{}{
+			"error": err.Error(),
+		})
+		// Continue without prompt logging, but log the error
+	}
+
+	// Let the model look up type definitions, unless tools are disabled or
+	// not available for the language of the file
+	var completion *openai.ChatCompletion
+	var err error
+	mode := llm.ToolModeNone
+	if llm.UseTools(request, options) {
+		mode = llm.ToolModeFor(p.endpoint, options.ToolMode)
+	}
+	switch mode {
+	case llm.ToolModeNone:
+		completion, err = p.complete(ctx, params)
+	case llm.ToolModeText:
+		completion, err = p.reviewWithTextTools(ctx, request, params)
+	default:
+		completion, err = p.reviewWithNativeTools(ctx, request, params)
+
+		// Fall back to the text protocol when the server rejects tools, and
+		// remember which protocol works for the next files
+		if mode == llm.ToolModeAuto {
+			if err != nil && toolsRejected(err) {
+				logging.WarnWith("Server does not support tool calls, describing the tools in the prompt instead", map[string]interface{}{
+					"model": p.model,
+					"error": err.Error(),
+				})
+				completion, err = p.reviewWithTextTools(ctx, request, params)
+				mode = llm.ToolModeText
+			}
+			if err == nil {
+				llm.RememberToolMode(p.endpoint, mode)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if we have any choices
+	if len(completion.Choices) == 0 {
+		return nil, llm.NewProviderError("no completion choices returned", nil)
+	}
+
+	// Log the full exchange if exchange logging is enabled
+	if err := exchangelog.LogExchange(p.Name(), request.FilePath, userPrompt, completion.Choices[0].Message.Content); err != nil {
+		logging.WarnWith("Failed to log exchange", map[string]interface{}{
+			"error": err.Error(),
+		})
+		// Continue without exchange logging, but log the error
+	}
+
+	// Create the response with metadata, keeping the reasoning apart
+	return p.response(completion), nil
+}
+
+// reviewParams returns the chat completion parameters of a review request,
+// together with its user prompt
+func (p *Provider) reviewParams(request *llm.ReviewRequest, options llm.ReviewOptions) (openai.ChatCompletionNewParams, string) {
 	userPrompt := prompt.CreatePrompt(request, prompt.ProviderOpenAI)
+	params := openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(prompt.CreateSystemPrompt(request, prompt.ProviderOpenAI)),
+			openai.UserMessage(userPrompt),
+		},
+		Model: p.model,
+	}
+
+	// Set the generation parameters
+	applyOptions(&params, options)
+	return params, userPrompt
+}
 
-	// Create the messages for the chat completion
-	messages := []openai.ChatCompletionMessageParamUnion{
-		openai.SystemMessage(prompt.GetSystemPrompt(prompt.ProviderOpenAI, prompt.SystemPromptReview)),
-		openai.UserMessage(userPrompt),
+// complete sends a chat completion request
+func (p *Provider) complete(ctx context.Context, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	completion, err := p.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return nil, requestError(ctx, "failed to get chat completion", err)
 	}
+	return completion, nil
+}
 
-	// Define the FindDefinitionForType function as a tool
-	tools := []openai.ChatCompletionToolParam{
+// reviewWithNativeTools sends a review request that offers the tools through
+// the API, and answers the model's tool calls until it returns the review
+func (p *Provider) reviewWithNativeTools(ctx context.Context, request *llm.ReviewRequest, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	params.Tools = []openai.ChatCompletionToolParam{
 		{
 			Function: openai.FunctionDefinitionParam{
-				Name:        "FindDefinitionForType",
-				Description: openai.String("Searches for and extracts a type definition by name in the codebase"),
-				Parameters: openai.FunctionParameters{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"typeName": map[string]interface{}{
-							"type":        "string",
-							"description": "The name of the type to find definition for",
-						},
-					},
-					"required": []string{"typeName"},
-				},
+				Name:        llm.ToolFindDefinition,
+				Description: openai.String(llm.ToolFindDefinitionDescription),
+				Parameters:  openai.FunctionParameters(llm.ToolFindDefinitionSchema()),
 			},
 		},
 	}
 
-	// Prepare the chat completion parameters
-	params := openai.ChatCompletionNewParams{
-		Messages: messages,
-		Model:    p.model,
-		Tools:    tools,
+	completion, err := p.complete(ctx, params)
+	if err != nil {
+		return nil, err
 	}
 
-	// Set temperature using the openai helper function
-	params.Temperature = openai.Opt(request.Options.Temperature)
+	for round := 1; round <= llm.MaxToolRounds && len(completion.Choices) > 0 && len(completion.Choices[0].Message.ToolCalls) > 0; round++ {
+		message := completion.Choices[0].Message
+		params.Messages = append(params.Messages, message.ToParam())
+		for _, toolCall := range message.ToolCalls {
+			logging.DebugWith("Running tool", map[string]interface{}{
+				"file": request.FilePath,
+				"tool": toolCall.Function.Name,
+			})
+			result := llm.RunTool(request, toolCall.Function.Name, []byte(toolCall.Function.Arguments))
+			params.Messages = append(params.Messages, openai.ToolMessage(result, toolCall.ID))
+		}
 
-	// Set max tokens if specified
-	if request.Options.MaxTokens > 0 {
-		// Use the Int helper function from the SDK
-		params.MaxTokens = openai.Int(int64(request.Options.MaxTokens))
-	}
+		// Make the model answer once it has used up its tool calls
+		if round == llm.MaxToolRounds {
+			params.ToolChoice = openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("none")}
+		}
 
-	// Log the prompt if enabled
-	if err := promptlog.LogPrompt(p.Name(), request.FilePath, userPrompt); err != nil {
-		logging.ErrorWith("Failed to log prompt", map[string]interface{}{
-			"error": err.Error(),
-		})
-		// Continue without prompt logging, but log the error
+		completion, err = p.complete(ctx, params)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Make the initial API call
-	completion, err := p.client.Chat.Completions.New(ctx, params)
+	return completion, nil
+}
+
+// reviewWithTextTools sends a review request that describes the tools in the
+// system prompt, and answers the tool_call blocks in the model's replies
+// until it returns the review
+func (p *Provider) reviewWithTextTools(ctx context.Context, request *llm.ReviewRequest, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, error) {
+	systemPrompt := prompt.CreateSystemPrompt(request, prompt.ProviderOpenAI) + "\n\n" + llm.TextToolInstructions()
+	params.Messages = append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage(systemPrompt)}, params.Messages[1:]...)
+
+	completion, err := p.complete(ctx, params)
 	if err != nil {
-		return nil, llm.NewProviderError(fmt.Sprintf("failed to get chat completion: %v", err), err)
-	}
-
-	// Process the completion and check for tool calls
-	for len(completion.Choices) > 0 && len(completion.Choices[0].Message.ToolCalls) > 0 {
-		// Extract and process the tool calls
-		hasToolCalls := false
-
-		for _, toolCall := range completion.Choices[0].Message.ToolCalls {
-			if toolCall.Function.Name == "FindDefinitionForType" {
-				hasToolCalls = true
-
-				// Extract the typeName parameter
-				var args map[string]string
-				if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
-					return nil, llm.NewProviderError(fmt.Sprintf("failed to parse function arguments: %v", err), err)
-				}
-
-				typeName, ok := args["typeName"]
-				if !ok {
-					return nil, llm.NewProviderError("typeName parameter is required", nil)
-				}
-
-				// Create a code extractor if not available in the request
-				var codeExtractor *extractor.CodeExtractor
-				var language extractor.Language
-
-				if request.Extractor != nil {
-					codeExtractor = request.Extractor
-				} else {
-					// Infer language from file extension
-					ext := filepath.Ext(request.FilePath)
-					switch strings.ToLower(ext) {
-					case ".go":
-						language = extractor.Go
-					case ".js":
-						language = extractor.JavaScript
-					case ".py":
-						language = extractor.Python
-					default:
-						return nil, llm.NewProviderError(fmt.Sprintf("unsupported file extension: %s", ext), nil)
-					}
-
-					// Get the working directory
-					workDir, err := os.Getwd()
-					if err != nil {
-						return nil, llm.NewProviderError(fmt.Sprintf("failed to get working directory: %v", err), err)
-					}
-
-					// Create a new code extractor
-					codeExtractor, err = extractor.NewCodeExtractor(language, workDir)
-					if err != nil {
-						return nil, llm.NewProviderError(fmt.Sprintf("failed to create code extractor: %v", err), err)
-					}
-				}
-
-				// Call the function
-				typeDefinition, filePath, err := codeExtractor.FindDefinitionForType(typeName)
-				var toolResult string
-				if err != nil {
-					toolResult = fmt.Sprintf("Error finding type definition: %s", err.Error())
-				} else {
-					resultMap := map[string]string{
-						"typeDefinition": typeDefinition,
-						"filePath":       filePath,
-					}
-					resultJSON, err := json.Marshal(resultMap)
-					if err != nil {
-						return nil, llm.NewProviderError(fmt.Sprintf("failed to marshal function result: %v", err), err)
-					}
-					toolResult = string(resultJSON)
-				}
-
-				// Add the assistant's message with the tool call to the messages
-				params.Messages = append(params.Messages, completion.Choices[0].Message.ToParam())
-
-				// Add the tool response to the messages
-				params.Messages = append(params.Messages, openai.ToolMessage(toolResult, toolCall.ID))
-			}
-		}
+		return nil, err
+	}
 
-		// If no tool calls were processed, break the loop
-		if !hasToolCalls {
+	for round := 1; round <= llm.MaxToolRounds && len(completion.Choices) > 0; round++ {
+		reply := completion.Choices[0].Message.Content
+		calls := llm.ParseTextToolCalls(util.RemoveThinkTags(reply))
+		if len(calls) == 0 {
 			break
 		}
+		logging.DebugWith("Running tools", map[string]interface{}{
+			"file":  request.FilePath,
+			"calls": len(calls),
+		})
 
-		// Make another API call with the updated messages
-		completion, err = p.client.Chat.Completions.New(ctx, params)
+		params.Messages = append(params.Messages,
+			openai.AssistantMessage(reply),
+			openai.UserMessage(llm.RunTextToolCalls(request, calls, round == llm.MaxToolRounds)),
+		)
+		completion, err = p.complete(ctx, params)
 		if err != nil {
-			return nil, llm.NewProviderError(fmt.Sprintf("failed to get chat completion: %v", err), err)
+			return nil, err
 		}
 	}
 
-	// Extract the final content from the completion
-	content := completion.Choices[0].Message.Content
-
-	// Remove <think>...</think> tags if present
-	content = util.RemoveThinkTags(content)
+	return completion, nil
+}
 
-	// Create the response with metadata
-	return &llm.ReviewResponse{
-		Review:     content,
-		Confidence: 1.0, // OpenAI doesn't provide confidence scores
-		Metadata: map[string]interface{}{
-			"model":             p.model,
-			"token_count":       completion.Usage.TotalTokens,
-			"prompt_tokens":     completion.Usage.PromptTokens,
-			"completion_tokens": completion.Usage.CompletionTokens,
-			"finish_reason":     completion.Choices[0].FinishReason,
-		},
-	}, nil
+// toolsRejected reports whether a failed request shows that the server does
+// not support native tool calls
+func toolsRejected(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return llm.ToolsRejected(apiErr.StatusCode, apiErr.Message) || llm.ToolsRejected(apiErr.StatusCode, apiErr.RawJSON())
 }
 
 // GetCompletion sends a prompt to the OpenAI API and returns the completion
-func (p *Provider) GetCompletion(prompt string) (string, error) {
-	// Create context
-	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Second)
+func (p *Provider) GetCompletion(ctx context.Context, prompt string) (string, error) {
+	response, err := p.GetCompletionWithMetadata(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	return response.Review, nil
+}
+
+// GetCompletionWithMetadata sends a prompt to the OpenAI API and returns the
+// completion together with the model's reasoning and token usage
+func (p *Provider) GetCompletionWithMetadata(ctx context.Context, prompt string) (*llm.ReviewResponse, error) {
+	// Use the configured generation settings
+	options := llm.ReviewOptionsFromConfig(p.config)
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Limit the request to the configured timeout
+	ctx, cancel := withTimeout(ctx, options.Timeout)
 	defer cancel()
 
 	// Log the prompt if prompt logging is enabled
@@ -277,42 +321,182 @@ func (p *Provider) GetCompletion(prompt string) (string, error) {
 		Model: p.model,
 	}
 
-	// Set temperature
-	params.Temperature = openai.Opt(0.7)
+	// Set the generation parameters
+	applyOptions(&params, options)
 
 	// Make the API request
 	completion, err := p.client.Chat.Completions.New(ctx, params)
 	if err != nil {
-		return "", llm.NewProviderError(fmt.Sprintf("failed to get chat completion: %v", err), err)
+		return nil, requestError(ctx, "failed to get chat completion", err)
 	}
 
 	// Check if we have any choices
 	if len(completion.Choices) == 0 {
-		return "", llm.NewProviderError("no completion choices returned", nil)
+		return nil, llm.NewProviderError("no completion choices returned", nil)
+	}
+
+	// Log the full exchange if exchange logging is enabled
+	if err := exchangelog.LogExchange(p.Name(), "prompt", prompt, completion.Choices[0].Message.Content); err != nil {
+		logging.WarnWith("Failed to log exchange", map[string]interface{}{
+			"error": err.Error(),
+		})
+		// Continue without exchange logging, but log the error
+	}
+
+	// Return the processed content
+	return p.response(completion), nil
+}
+
+// response converts a chat completion into a review response. Reasoning
+// that the model wrote in <think> tags, or that an OpenAI compatible server
+// returned in a reasoning_content field, is moved to the metadata.
+func (p *Provider) response(completion *openai.ChatCompletion) *llm.ReviewResponse {
+	message := completion.Choices[0].Message
+	reasoning, content := util.ExtractThinkTags(message.Content)
+	if reasoning == "" {
+		if field, ok := message.JSON.ExtraFields["reasoning_content"]; ok {
+			// The field is only present on servers that support it
+			_ = json.Unmarshal([]byte(field.Raw()), &reasoning)
+		}
+	}
+
+	metadata := map[string]interface{}{
+		"model":             p.model,
+		"token_count":       completion.Usage.TotalTokens,
+		"prompt_tokens":     completion.Usage.PromptTokens,
+		"completion_tokens": completion.Usage.CompletionTokens,
+		"finish_reason":     completion.Choices[0].FinishReason,
+	}
+	if reasoning != "" {
+		metadata[llm.MetadataReasoning] = reasoning
+	}
+	if tokens := completion.Usage.CompletionTokensDetails.ReasoningTokens; tokens > 0 {
+		metadata[llm.MetadataReasoningTokens] = tokens
+	}
+
+	return &llm.ReviewResponse{
+		Review:     content,
+		Confidence: 1.0, // OpenAI doesn't provide confidence scores
+		Metadata:   metadata,
+	}
+}
+
+// StreamCompletion sends a prompt to the OpenAI API and calls onChunk with
+// the completion as it is generated
+func (p *Provider) StreamCompletion(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error) {
+	// Use the configured generation settings
+	options := llm.ReviewOptionsFromConfig(p.config)
+	if err := options.Validate(); err != nil {
+		return "", err
+	}
+
+	// Limit the request to the configured timeout
+	ctx, cancel := withTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	// Log the prompt if prompt logging is enabled
+	if err := promptlog.LogPrompt(p.Name(), "prompt", prompt); err != nil {
+		logging.WarnWith("Failed to log prompt", map[string]interface{}{
+			"error": err.Error(),
+		})
+		// Continue without prompt logging, but log the error
 	}
 
-	// Extract content
-	content := completion.Choices[0].Message.Content
+	params := openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.UserMessage(prompt),
+		},
+		Model: p.model,
+	}
+	applyOptions(&params, options)
 
-	// Remove <think>...</think> tags if present
-	content = util.RemoveThinkTags(content)
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	// Deliver each piece of the completion as it arrives
+	var content strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		onChunk(chunk.Choices[0].Delta.Content)
+	}
+	if err := stream.Err(); err != nil {
+		return "", requestError(ctx, "failed to stream chat completion", err)
+	}
 
 	// Log the full exchange if exchange logging is enabled
-	if err := exchangelog.LogExchange(p.Name(), "prompt", prompt, content); err != nil {
+	if err := exchangelog.LogExchange(p.Name(), "prompt", prompt, content.String()); err != nil {
 		logging.WarnWith("Failed to log exchange", map[string]interface{}{
 			"error": err.Error(),
 		})
 		// Continue without exchange logging, but log the error
 	}
 
-	// Return the processed content
-	return content, nil
+	return util.RemoveThinkTags(content.String()), nil
+}
+
+// applyOptions sets the generation parameters of a chat completion request
+func applyOptions(params *openai.ChatCompletionNewParams, options llm.ReviewOptions) {
+	if options.Seed != nil {
+		params.Seed = openai.Int(*options.Seed)
+	}
+
+	// Reasoning models reject the sampling parameters and count their
+	// reasoning against max_completion_tokens instead of max_tokens
+	if options.UsesReasoning() {
+		if options.MaxTokens > 0 {
+			params.MaxCompletionTokens = openai.Int(int64(options.MaxTokens))
+		}
+		if options.ReasoningEffort != "" {
+			params.ReasoningEffort = openai.ReasoningEffort(options.ReasoningEffort)
+		}
+		return
+	}
+
+	if options.Temperature != nil {
+		params.Temperature = openai.Float(*options.Temperature)
+	}
+	if options.MaxTokens > 0 {
+		params.MaxTokens = openai.Int(int64(options.MaxTokens))
+	}
+	if options.TopP != nil {
+		params.TopP = openai.Float(*options.TopP)
+	}
+	if len(options.Stop) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfChatCompletionNewsStopArray: options.Stop}
+	}
+}
+
+// requestError returns the error for a failed request, classifying error
+// responses of the API by their status code
+func requestError(ctx context.Context, msg string, err error) error {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && ctx.Err() == nil {
+		return llm.NewAPIError(fmt.Sprintf("%s: %v", msg, err), apiErr.StatusCode, err)
+	}
+	return llm.RequestError(ctx, msg, err)
+}
+
+// withTimeout limits ctx to timeout, if one is set
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
 }
 
 // init registers the OpenAI provider with the provider registry
 func init() {
 	// Register the OpenAI provider factory
 	llm.RegisterProviderFactory("openai", func(cfg map[string]interface{}) (llm.Provider, error) {
+		// Use the full configuration, with its transport settings, if provided
+		if fullCfg, ok := cfg["config"].(*config.Config); ok && fullCfg != nil {
+			return NewProvider(fullCfg)
+		}
+
 		// Convert the generic config map to our specific config structure
 		apiKey, ok := cfg["api_key"].(string)
 		if !ok || apiKey == "" {
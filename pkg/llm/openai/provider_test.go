@@ -192,7 +192,7 @@ func TestReviewCode(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     30 * time.Second,
 		},
 	}
@@ -286,7 +286,7 @@ func TestReviewCodeTimeout(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     100 * time.Millisecond, // Very short timeout
 		},
 	}
@@ -340,7 +340,7 @@ func TestReviewCodeAPIError(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     30 * time.Second,
 		},
 	}
@@ -445,7 +445,7 @@ func TestReviewCodeWithRetry(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: llm.ReviewOptions{
 			MaxTokens:   1000,
-			Temperature: 0.7,
+			Temperature: llm.Float(0.7),
 			Timeout:     10 * time.Second,
 		},
 	}
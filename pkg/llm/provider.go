@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"time"
 	
+	"github.com/niels/git-llm-review/pkg/config"
 	"github.com/niels/git-llm-review/pkg/extractor"
 )
 
@@ -17,6 +21,9 @@ var (
 	ErrTimeout              = errors.New("timeout error")
 	ErrUnsupportedProvider  = errors.New("unsupported provider")
 	ErrConfigurationError   = errors.New("configuration error")
+	ErrProviderUnavailable  = errors.New("provider unavailable")
+	ErrRetriesExhausted     = errors.New("retries exhausted")
+	ErrQuotaExceeded        = errors.New("quota exceeded")
 )
 
 // Provider defines the interface that all LLM providers must implement
@@ -31,7 +38,141 @@ type Provider interface {
 	ReviewCode(ctx context.Context, request *ReviewRequest) (*ReviewResponse, error)
 	
 	// GetCompletion sends a prompt to the LLM and returns the completion
-	GetCompletion(prompt string) (string, error)
+	GetCompletion(ctx context.Context, prompt string) (string, error)
+}
+
+// Metadata keys shared by providers in ReviewResponse.Metadata
+const (
+	// MetadataProvider records which provider produced the response
+	MetadataProvider = "provider"
+	// MetadataModel records which model produced the response
+	MetadataModel = "model"
+)
+
+// MetadataCompleter is implemented by providers that can report details about
+// how a completion was produced, such as which provider in a chain served it
+type MetadataCompleter interface {
+	// GetCompletionWithMetadata sends a prompt to the LLM and returns the
+	// completion in the Review field of the response
+	GetCompletionWithMetadata(ctx context.Context, prompt string) (*ReviewResponse, error)
+}
+
+// Streamer is implemented by providers that can deliver a completion while it
+// is being generated
+type Streamer interface {
+	// StreamCompletion sends a prompt to the LLM, calls onChunk with each part
+	// of the completion as it arrives and returns the full completion
+	StreamCompletion(ctx context.Context, prompt string, onChunk func(chunk string)) (string, error)
+}
+
+// ModelNamer is implemented by providers that can report the model they use
+type ModelNamer interface {
+	// Model returns the name of the model used by the provider
+	Model() string
+}
+
+// DescribeProvider returns a human readable label for the provider, including
+// the model when the provider reports it
+func DescribeProvider(provider Provider) string {
+	if namer, ok := provider.(ModelNamer); ok && namer.Model() != "" {
+		return fmt.Sprintf("%s (%s)", provider.Name(), namer.Model())
+	}
+	return provider.Name()
+}
+
+// CompleteWithMetadata sends a prompt to the provider and returns the completion
+// together with its metadata. Providers that do not implement MetadataCompleter
+// are described by DescribeProvider.
+func CompleteWithMetadata(ctx context.Context, provider Provider, prompt string) (*ReviewResponse, error) {
+	if completer, ok := provider.(MetadataCompleter); ok {
+		return completer.GetCompletionWithMetadata(ctx, prompt)
+	}
+
+	completion, err := provider.GetCompletion(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReviewResponse{
+		Review: completion,
+		Metadata: map[string]interface{}{
+			MetadataProvider: DescribeProvider(provider),
+		},
+	}, nil
+}
+
+// StreamCompletion sends a prompt to the provider and calls onChunk with the
+// completion as it arrives. Providers that do not implement Streamer deliver
+// the whole completion in a single chunk.
+func StreamCompletion(ctx context.Context, provider Provider, prompt string, onChunk func(chunk string)) (string, error) {
+	if streamer, ok := provider.(Streamer); ok {
+		return streamer.StreamCompletion(ctx, prompt, onChunk)
+	}
+
+	completion, err := provider.GetCompletion(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	onChunk(completion)
+	return completion, nil
+}
+
+// RequestError returns the error a provider reports when sending a request
+// failed. A request stopped because the caller cancelled ctx returns an error
+// wrapping context.Canceled, and one that ran out of time returns a timeout
+// error. Other failures are returned as provider errors, which wrap
+// ErrRetriesExhausted if the provider could not be reached.
+func RequestError(ctx context.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("%s: %w", msg, context.Canceled)
+	case isTimeout(err) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return NewTimeoutError(fmt.Sprintf("%s: %v", msg, err))
+	case isConnectionError(err):
+		return NewProviderError(fmt.Sprintf("%s: %v", msg, err), errors.Join(ErrRetriesExhausted, err))
+	default:
+		return NewProviderError(fmt.Sprintf("%s: %v", msg, err), err)
+	}
+}
+
+// NewAPIError returns the error for an error response of the API with the
+// given status code. Rejected keys are authentication errors. A response
+// that the retry transport gives up on, after retrying it as configured, is
+// a provider error wrapping ErrRetriesExhausted, and one that reports an
+// exhausted account wraps ErrQuotaExceeded.
+func NewAPIError(msg string, statusCode int, err error) error {
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return NewAuthenticationError(msg)
+	case http.StatusPaymentRequired:
+		return NewProviderError(msg, errors.Join(ErrQuotaExceeded, err))
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic: overloaded
+		return NewProviderError(msg, errors.Join(ErrRetriesExhausted, err))
+	default:
+		return NewProviderError(msg, err)
+	}
+}
+
+// isConnectionError reports whether err is a failure to reach the provider
+// or to read its answer
+func isConnectionError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isTimeout reports whether err was caused by a deadline or network timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
 }
 
 // ReviewRequest represents a request to review code
@@ -50,6 +191,75 @@ type ReviewRequest struct {
 	
 	// Extractor provides access to code extraction functionality
 	Extractor *extractor.CodeExtractor
+
+	// Files are the files of a changeset review, which are reviewed together
+	// in one prompt; FilePath then names the group. Empty when one file is
+	// reviewed.
+	Files []ReviewFile
+}
+
+// ReviewFile is one of the files of a changeset review
+type ReviewFile struct {
+	// FilePath is the path to the file, relative to the repository root
+	FilePath string
+
+	// FileContent is the full content of the file
+	FileContent string
+
+	// FileDiff is the diff of the changes made to the file
+	FileDiff string
+}
+
+// Paths returns the paths of the reviewed files: those of a changeset review,
+// or else the reviewed file
+func (r *ReviewRequest) Paths() []string {
+	if len(r.Files) == 0 {
+		return []string{r.FilePath}
+	}
+	paths := make([]string, len(r.Files))
+	for i, file := range r.Files {
+		paths[i] = file.FilePath
+	}
+	return paths
+}
+
+// Guideline is a project guideline, such as a style guide, that the review
+// should follow
+type Guideline struct {
+	// Path is the file the guideline was read from
+	Path string
+
+	// Scope is the directory, relative to the repository root, whose files
+	// the guideline applies to; empty for the whole repository
+	Scope string
+
+	// Content is the text of the guideline
+	Content string
+}
+
+// Intent describes what a change is meant to do, so that the review can check
+// that the code does it
+type Intent struct {
+	// Stated is the intent given on the command line
+	Stated string
+
+	// Draft is the commit message being written for the change
+	Draft string
+
+	// Commits are the messages of the earlier commits on the branch, newest first
+	Commits []string
+
+	// Branch is the name of the current branch
+	Branch string
+
+	// Tickets are the tickets referenced by the branch name and the messages,
+	// such as PROJ-123 or #42
+	Tickets []string
+}
+
+// IsEmpty reports whether nothing is known about the intent
+func (i *Intent) IsEmpty() bool {
+	return i == nil || (i.Stated == "" && i.Draft == "" && len(i.Commits) == 0 && i.Branch == "" && len(i.Tickets) == 0)
 }
 
 // ReviewOptions contains options for the code review
@@ -57,19 +267,210 @@ type ReviewOptions struct {
 	// MaxTokens is the maximum number of tokens to generate
 	MaxTokens int
 	
-	// Temperature controls the randomness of the output (0.0-1.0)
-	Temperature float64
+	// Temperature controls the randomness of the output (0.0-2.0, nil = unset)
+	Temperature *float64
+	
+	// TopP limits sampling to the most likely tokens (0.0-1.0, nil = provider default)
+	TopP *float64
+	
+	// Seed requests deterministic sampling from providers that support it
+	Seed *int64
+	
+	// Stop lists sequences that end the output
+	Stop []string
 	
 	// Timeout is the maximum time to wait for a response
 	Timeout time.Duration
 	
+	// Reasoning is true when the model reasons before it answers (nil = unset)
+	Reasoning *bool
+	
+	// ReasoningEffort sets how much OpenAI reasoning models reason ("minimal", "low", "medium" or "high")
+	ReasoningEffort string
+	
+	// ThinkingBudget is the number of tokens Anthropic models may spend on extended thinking (0 = disabled)
+	ThinkingBudget int
+	
+	// ToolMode selects how the model may call tools (ToolModeAuto, ToolModeNative, ToolModeText or ToolModeNone)
+	ToolMode string
+	
 	// AdditionalInstructions provides extra guidance to the LLM
 	AdditionalInstructions string
-	
+
+	// PromptTemplates override the built-in prompts, keyed by template name such as "review" or "system.openai"
+	PromptTemplates map[string]string
+
+	// Focus lists the kinds of issues the review concentrates on, such as "security" (empty = general review)
+	Focus []string
+
+	// Guidelines are the project guidelines added to the system prompt of the files they apply to
+	Guidelines []Guideline
+
+	// GuidelinesMaxChars limits the size of the guidelines in a prompt (0 = no limit)
+	GuidelinesMaxChars int
+
+	// Checklists map languages, such as "go", to the pitfalls checked in their files
+	Checklists map[string][]string
+
+	// ChangeSummary summarizes the whole change the reviewed file is part of (empty = no summary)
+	ChangeSummary string
+
+	// Intent describes what the change is meant to do; mismatches are reported as issues (nil = not checked)
+	Intent *Intent
+
 	// IncludeExplanations indicates whether to include explanations in the review
 	IncludeExplanations bool
 }
 
+// Defaults for generation settings that are not configured
+const (
+	// DefaultTemperature keeps reviews focused and repeatable
+	DefaultTemperature = 0.1
+	// DefaultMaxTokens leaves room for the JSON of a review of a large file
+	DefaultMaxTokens = 4096
+	// MinThinkingBudget is the smallest extended thinking budget accepted
+	MinThinkingBudget = 1024
+)
+
+// ReviewOptionsFromConfig returns the generation options configured for the
+// model in cfg, including any per-model overrides
+func ReviewOptionsFromConfig(cfg *config.Config) ReviewOptions {
+	options := ReviewOptions{
+		MaxTokens:   DefaultMaxTokens,
+		Temperature: Float(DefaultTemperature),
+	}
+	if cfg == nil {
+		return options
+	}
+
+	generation := cfg.LLM.Generation()
+	if generation.Temperature != nil {
+		options.Temperature = generation.Temperature
+	}
+	if generation.MaxOutputTokens > 0 {
+		options.MaxTokens = generation.MaxOutputTokens
+	}
+	options.TopP = generation.TopP
+	options.Seed = generation.Seed
+	options.Stop = generation.Stop
+
+	// Reasoning models are recognised by name unless configured explicitly
+	options.Reasoning = Bool(DetectCapabilities(cfg.LLM.Model).Reasoning)
+	if generation.Reasoning != nil {
+		options.Reasoning = generation.Reasoning
+	}
+	options.ReasoningEffort = generation.ReasoningEffort
+	options.ThinkingBudget = generation.ThinkingBudget
+	options.ToolMode = cfg.LLM.Tools
+
+	// Each request may take as long as the provider timeout unless a
+	// request timeout is configured
+	if generation.RequestTimeout > 0 {
+		options.Timeout = time.Duration(generation.RequestTimeout) * time.Second
+	} else if cfg.LLM.Timeout > 0 {
+		options.Timeout = time.Duration(cfg.LLM.Timeout) * time.Second
+	}
+
+	return options
+}
+
+// Float returns a pointer to v, for the optional settings of ReviewOptions
+func Float(v float64) *float64 {
+	return &v
+}
+
+// Bool returns a pointer to v, for the optional settings of ReviewOptions
+func Bool(v bool) *bool {
+	return &v
+}
+
+// UsesReasoning reports whether the model reasons before it answers
+func (o ReviewOptions) UsesReasoning() bool {
+	return o.Reasoning != nil && *o.Reasoning
+}
+
+// WithDefaults returns the options with every unset field taken from defaults.
+// Generation settings of a request that are left unset, or at their zero
+// value for counts and names, use the settings configured for the provider.
+// A temperature of 0 or reasoning turned off is a setting of its own.
+func (o ReviewOptions) WithDefaults(defaults ReviewOptions) ReviewOptions {
+	if o.MaxTokens == 0 {
+		o.MaxTokens = defaults.MaxTokens
+	}
+	if o.Temperature == nil {
+		o.Temperature = defaults.Temperature
+	}
+	if o.TopP == nil {
+		o.TopP = defaults.TopP
+	}
+	if o.Seed == nil {
+		o.Seed = defaults.Seed
+	}
+	if len(o.Stop) == 0 {
+		o.Stop = defaults.Stop
+	}
+	if o.Timeout == 0 {
+		o.Timeout = defaults.Timeout
+	}
+	if o.Reasoning == nil {
+		o.Reasoning = defaults.Reasoning
+	}
+	if o.ReasoningEffort == "" {
+		o.ReasoningEffort = defaults.ReasoningEffort
+	}
+	if o.ThinkingBudget == 0 {
+		o.ThinkingBudget = defaults.ThinkingBudget
+	}
+	if o.ToolMode == "" {
+		o.ToolMode = defaults.ToolMode
+	}
+	return o
+}
+
+// Validate validates the generation options
+func (o ReviewOptions) Validate() error {
+	if o.MaxTokens < 0 {
+		return NewInvalidRequestError("max tokens must be non-negative")
+	}
+	
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2.0) {
+		return NewInvalidRequestError("temperature must be between 0.0 and 2.0")
+	}
+	
+	if o.TopP != nil && (*o.TopP < 0 || *o.TopP > 1.0) {
+		return NewInvalidRequestError("top_p must be between 0.0 and 1.0")
+	}
+	
+	for _, stop := range o.Stop {
+		if stop == "" {
+			return NewInvalidRequestError("stop sequences must not be empty")
+		}
+	}
+	
+	if o.Timeout < 0 {
+		return NewInvalidRequestError("timeout must be non-negative")
+	}
+	
+	switch o.ReasoningEffort {
+	case "", "minimal", "low", "medium", "high":
+	default:
+		return NewInvalidRequestError(fmt.Sprintf("unknown reasoning effort %q", o.ReasoningEffort))
+	}
+	
+	switch o.ToolMode {
+	case "", ToolModeAuto, ToolModeNative, ToolModeText, ToolModeNone:
+	default:
+		return NewInvalidRequestError(fmt.Sprintf("unknown tool mode %q", o.ToolMode))
+	}
+	
+	// Anthropic requires a thinking budget of at least 1024 tokens
+	if o.ThinkingBudget != 0 && o.ThinkingBudget < MinThinkingBudget {
+		return NewInvalidRequestError(fmt.Sprintf("thinking budget must be at least %d tokens", MinThinkingBudget))
+	}
+	
+	return nil
+}
+
 // ReviewResponse represents the response from a code review
 type ReviewResponse struct {
 	// Review is the text of the code review
@@ -98,15 +499,7 @@ func (r *ReviewRequest) Validate() error {
 	// FileDiff can be empty for new files or when only reviewing content
 	
 	// Validate options
-	if r.Options.MaxTokens < 0 {
-		return NewInvalidRequestError("max tokens must be non-negative")
-	}
-	
-	if r.Options.Temperature < 0 || r.Options.Temperature > 1.0 {
-		return NewInvalidRequestError("temperature must be between 0.0 and 1.0")
-	}
-	
-	return nil
+	return r.Options.Validate()
 }
 
 // ProviderError represents an error from an LLM provider
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"github.com/niels/git-llm-review/pkg/config"
 )
 
 // TestProviderInterface verifies that a mock provider can implement the Provider interface
@@ -47,7 +49,7 @@ func TestProviderInterface(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: ReviewOptions{
 			MaxTokens:  1000,
-			Temperature: 0.7,
+			Temperature: Float(0.7),
 			Timeout:    30 * time.Second,
 		},
 	}
@@ -81,7 +83,7 @@ func TestReviewRequest(t *testing.T) {
 		FileDiff:    "diff --git a/test.go b/test.go\nnew file mode 100644\nindex 0000000..1234567\n--- /dev/null\n+++ b/test.go\n@@ -0,0 +1,5 @@\n+package main\n+\n+func main() {\n+\tprintln(\"Hello, World!\")\n+}\n",
 		Options: ReviewOptions{
 			MaxTokens:  1000,
-			Temperature: 0.7,
+			Temperature: Float(0.7),
 			Timeout:    30 * time.Second,
 		},
 	}
@@ -237,7 +239,7 @@ func (m *MockProvider) ReviewCode(ctx context.Context, request *ReviewRequest) (
 }
 
 // GetCompletion sends a prompt to the mock provider and returns a completion
-func (p *MockProvider) GetCompletion(prompt string) (string, error) {
+func (p *MockProvider) GetCompletion(ctx context.Context, prompt string) (string, error) {
 	if p.shouldError {
 		return "", NewProviderError("mock provider error", errors.New("mock error"))
 	}
@@ -250,3 +252,160 @@ func (p *MockProvider) GetCompletion(prompt string) (string, error) {
 func (m *MockProvider) SetAPIKey(apiKey string) {
 	m.apiKey = apiKey
 }
+
+// TestReviewOptionsFromConfig verifies that generation settings come from the
+// configuration and that requests can override them
+func TestReviewOptionsFromConfig(t *testing.T) {
+	// Without any settings the defaults are used
+	options := ReviewOptionsFromConfig(nil)
+	if options.Temperature == nil || *options.Temperature != DefaultTemperature || options.MaxTokens != DefaultMaxTokens {
+		t.Errorf("Expected default options, got %+v", options)
+	}
+
+	temperature := 0.0
+	topP := 0.9
+	seed := int64(7)
+	cfg := &config.Config{
+		LLM: config.LLMConfig{
+			Model:   "gpt-4o",
+			Timeout: 60,
+			GenerationConfig: config.GenerationConfig{
+				Temperature: &temperature,
+				TopP:        &topP,
+				Seed:        &seed,
+			},
+			Models: map[string]config.GenerationConfig{
+				"gpt-4o": {MaxOutputTokens: 16000, RequestTimeout: 120},
+			},
+		},
+	}
+
+	options = ReviewOptionsFromConfig(cfg)
+	if options.Temperature == nil || *options.Temperature != 0 || options.TopP == nil || *options.TopP != 0.9 || options.Seed == nil || *options.Seed != 7 {
+		t.Errorf("Expected the configured settings, got %+v", options)
+	}
+	if options.MaxTokens != 16000 {
+		t.Errorf("Expected the model's max tokens 16000, got %d", options.MaxTokens)
+	}
+	if options.Timeout != 120*time.Second {
+		t.Errorf("Expected the request timeout of 120s, got %v", options.Timeout)
+	}
+
+	// Settings set on a request take precedence
+	request := ReviewOptions{MaxTokens: 500}.WithDefaults(options)
+	if request.MaxTokens != 500 || *request.TopP != 0.9 {
+		t.Errorf("Expected request settings to override the configuration, got %+v", request)
+	}
+
+	// A temperature of 0 and reasoning turned off are settings of their own
+	defaults := ReviewOptions{Temperature: Float(0.7), Reasoning: Bool(true)}
+	request = ReviewOptions{Temperature: Float(0), Reasoning: Bool(false)}.WithDefaults(defaults)
+	if *request.Temperature != 0 || request.UsesReasoning() {
+		t.Errorf("Expected temperature 0 without reasoning, got temperature %v and reasoning %v", *request.Temperature, *request.Reasoning)
+	}
+	request = ReviewOptions{}.WithDefaults(defaults)
+	if *request.Temperature != 0.7 || !request.UsesReasoning() {
+		t.Errorf("Expected the default temperature 0.7 with reasoning, got temperature %v and reasoning %v", *request.Temperature, *request.Reasoning)
+	}
+}
+
+// TestReviewOptionsValidate verifies that out of range settings are rejected
+func TestReviewOptionsValidate(t *testing.T) {
+	invalid := map[string]ReviewOptions{
+		"negative max tokens": {MaxTokens: -1},
+		"temperature too high": {Temperature: Float(2.5)},
+		"top_p too high":       {TopP: Float(1.5)},
+		"empty stop sequence":  {Stop: []string{""}},
+		"negative timeout":     {Timeout: -time.Second},
+	}
+	for name, options := range invalid {
+		request := &ReviewRequest{FilePath: "main.go", Options: options}
+		if err := request.Validate(); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%s: expected ErrInvalidRequest, got: %v", name, err)
+		}
+	}
+
+	valid := ReviewOptions{MaxTokens: 100, Temperature: Float(1.5), TopP: Float(1), Stop: []string{"<END>"}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid options, got: %v", err)
+	}
+}
+
+// TestDetectCapabilities verifies that reasoning models are recognised by name
+// and that the configuration can override the detection
+func TestDetectCapabilities(t *testing.T) {
+	models := map[string]bool{
+		"o3-mini":                  true,
+		"openai/o4-mini":           true,
+		"claude-3-7-sonnet-latest": true,
+		"claude-sonnet-4-20250514": true,
+		"gpt-4o":                   false,
+		"claude-3-5-sonnet-latest": false,
+		"deepseek-r1:14b":          false,
+	}
+	for model, reasoning := range models {
+		if got := DetectCapabilities(model).Reasoning; got != reasoning {
+			t.Errorf("%s: expected reasoning %v, got %v", model, reasoning, got)
+		}
+	}
+
+	// A model served under another name can be marked as a reasoning model
+	enabled := true
+	cfg := &config.Config{
+		LLM: config.LLMConfig{
+			Model: "my-deployment",
+			GenerationConfig: config.GenerationConfig{
+				Reasoning:       &enabled,
+				ReasoningEffort: "low",
+			},
+		},
+	}
+	options := ReviewOptionsFromConfig(cfg)
+	if !options.UsesReasoning() || options.ReasoningEffort != "low" {
+		t.Errorf("Expected reasoning with low effort, got %+v", options)
+	}
+
+	// Unknown efforts and too small thinking budgets are rejected
+	for _, options := range []ReviewOptions{{ReasoningEffort: "extreme"}, {ThinkingBudget: 100}} {
+		if err := options.Validate(); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("Expected ErrInvalidRequest for %+v, got: %v", options, err)
+		}
+	}
+}
+
+// TestParseTextToolCalls verifies that tool calls are read from tool_call blocks
+func TestParseTextToolCalls(t *testing.T) {
+	reply := "Let me check.\n```tool_call\n{\"name\": \"FindDefinitionForType\", \"arguments\": {\"typeName\": \"Config\"}}\n```\n" +
+		"```tool_call\nnot json\n```"
+
+	calls := ParseTextToolCalls(reply)
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %d", len(calls))
+	}
+	if calls[0].Name != ToolFindDefinition || string(calls[0].Arguments) != `{"typeName": "Config"}` {
+		t.Errorf("Unexpected tool call: %+v", calls[0])
+	}
+	if calls[1].Name != "" {
+		t.Errorf("Expected an invalid block to have no name, got %q", calls[1].Name)
+	}
+
+	if calls := ParseTextToolCalls("The code looks good."); len(calls) != 0 {
+		t.Errorf("Expected no tool calls, got %d", len(calls))
+	}
+}
+
+// TestToolModeFor verifies that the remembered mode is only used in auto mode
+func TestToolModeFor(t *testing.T) {
+	endpoint := "test http://localhost test-model"
+	if mode := ToolModeFor(endpoint, ""); mode != ToolModeAuto {
+		t.Errorf("Expected %q before detection, got %q", ToolModeAuto, mode)
+	}
+
+	RememberToolMode(endpoint, ToolModeText)
+	if mode := ToolModeFor(endpoint, ToolModeAuto); mode != ToolModeText {
+		t.Errorf("Expected the remembered mode, got %q", mode)
+	}
+	if mode := ToolModeFor(endpoint, ToolModeNative); mode != ToolModeNative {
+		t.Errorf("Expected the configured mode, got %q", mode)
+	}
+}
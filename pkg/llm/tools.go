@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Tool modes, selected with the tools setting of a provider
+const (
+	// ToolModeAuto uses native tool calls, and switches to the text protocol
+	// for endpoints that reject them. It is the default.
+	ToolModeAuto = "auto"
+	// ToolModeNative lets the model call tools through the provider's API
+	ToolModeNative = "native"
+	// ToolModeText describes the tools in the system prompt and reads tool
+	// calls from fenced tool_call blocks in the model's reply
+	ToolModeText = "text"
+	// ToolModeNone sends a single request without tools, for servers that
+	// do not support them
+	ToolModeNone = "none"
+)
+
+// ToolFindDefinition is the name of the tool that looks up a type definition
+const ToolFindDefinition = "FindDefinitionForType"
+
+// ToolFindDefinitionDescription describes ToolFindDefinition to the model
+const ToolFindDefinitionDescription = "Searches for and extracts a type definition by name in the codebase"
+
+// MaxToolRounds is the number of times a model may call tools during one
+// review. The model must answer without tools after that.
+const MaxToolRounds = 5
+
+// ToolFindDefinitionSchema returns the JSON schema of the arguments of
+// ToolFindDefinition
+func ToolFindDefinitionSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"typeName": map[string]interface{}{
+				"type":        "string",
+				"description": "The name of the type to find definition for",
+			},
+		},
+		"required": []string{"typeName"},
+	}
+}
+
+// UseTools reports whether a review request should offer tools to the model
+func UseTools(request *ReviewRequest, options ReviewOptions) bool {
+	return request.Extractor != nil && options.ToolMode != ToolModeNone
+}
+
+// toolModes remembers the tool mode that works for each endpoint in auto mode
+var toolModes sync.Map
+
+// ToolModeFor returns the tool mode to use for endpoint, which identifies a
+// server and model. In auto mode it is the mode remembered for the endpoint,
+// or ToolModeAuto if the endpoint has not been used yet.
+func ToolModeFor(endpoint, configured string) string {
+	if configured != "" && configured != ToolModeAuto {
+		return configured
+	}
+	if mode, ok := toolModes.Load(endpoint); ok {
+		return mode.(string)
+	}
+	return ToolModeAuto
+}
+
+// RememberToolMode records the tool mode that works for endpoint
+func RememberToolMode(endpoint, mode string) {
+	toolModes.Store(endpoint, mode)
+}
+
+// ToolsRejected reports whether an API error with the given status code and
+// message shows that the server does not support native tool calls
+func ToolsRejected(statusCode int, message string) bool {
+	switch statusCode {
+	case 400, 422, 500, 501:
+		return strings.Contains(strings.ToLower(message), "tool")
+	default:
+		return false
+	}
+}
+
+// TextToolCall is a tool call that the model wrote in a tool_call block
+type TextToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// textToolCallPattern finds the fenced tool_call blocks in a reply
+var textToolCallPattern = regexp.MustCompile("(?s)```tool_call[ \t]*\n(.*?)```")
+
+// TextToolInstructions returns the description of the tools that is added to
+// the system prompt when the text protocol is used
+func TextToolInstructions() string {
+	schema, _ := json.Marshal(ToolFindDefinitionSchema())
+	return fmt.Sprintf(`You can use the following tool before you answer.
+
+%s: %s
+Arguments (JSON schema): %s
+
+To call the tool, reply with nothing but a fenced tool_call block, for example:
+`+"```tool_call"+`
+{"name": "%s", "arguments": {"typeName": "Config"}}
+`+"```"+`
+The result is sent in the next message. Call the tool only when you need a
+definition that is not in the code you were given. When you have what you
+need, reply with your review and no tool_call block.`,
+		ToolFindDefinition, ToolFindDefinitionDescription, schema, ToolFindDefinition)
+}
+
+// ParseTextToolCalls returns the tool calls in the tool_call blocks of a reply.
+// Blocks that are not valid JSON are returned without a name, so that the
+// model is told about the mistake.
+func ParseTextToolCalls(reply string) []TextToolCall {
+	var calls []TextToolCall
+	for _, match := range textToolCallPattern.FindAllStringSubmatch(reply, -1) {
+		var call TextToolCall
+		if err := json.Unmarshal([]byte(strings.TrimSpace(match[1])), &call); err != nil {
+			call = TextToolCall{}
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// RunTextToolCalls runs the tool calls of a reply and returns the message
+// that answers them. The last message tells the model to answer without
+// calling more tools.
+func RunTextToolCalls(request *ReviewRequest, calls []TextToolCall, last bool) string {
+	var message strings.Builder
+	for _, call := range calls {
+		name, result := call.Name, ""
+		if name == "" {
+			name = "tool_call"
+			result = toolError(`the tool_call block must contain {"name": ..., "arguments": {...}}`)
+		} else {
+			result = RunTool(request, call.Name, call.Arguments)
+		}
+		fmt.Fprintf(&message, "Result of %s:\n```json\n%s\n```\n\n", name, result)
+	}
+	if last {
+		message.WriteString("Do not call any more tools. Reply with your review now.")
+	} else {
+		message.WriteString("Call another tool if you need to, or reply with your review.")
+	}
+	return message.String()
+}
+
+// RunTool runs the tool with the given name and JSON arguments for a review
+// request and returns its result for the model. Failures are reported to the
+// model in the result rather than ending the review.
+func RunTool(request *ReviewRequest, name string, arguments []byte) string {
+	if name != ToolFindDefinition {
+		return toolError(fmt.Sprintf("unknown tool: %s", name))
+	}
+
+	var args struct {
+		TypeName string `json:"typeName"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil || args.TypeName == "" {
+		return toolError("typeName parameter is required")
+	}
+	if request.Extractor == nil {
+		return toolError(fmt.Sprintf("type definitions are not available for %s", request.FilePath))
+	}
+
+	definition, filePath, err := request.Extractor.FindDefinitionForType(args.TypeName)
+	if err != nil {
+		return toolError(err.Error())
+	}
+	return toolResult(map[string]string{
+		"typeDefinition": definition,
+		"filePath":       filePath,
+	})
+}
+
+// toolError returns a tool result that reports an error to the model
+func toolError(msg string) string {
+	return toolResult(map[string]string{"error": msg})
+}
+
+// toolResult encodes a tool result as JSON
+func toolResult(result map[string]string) string {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return `{"error": "failed to encode tool result"}`
+	}
+	return string(data)
+}
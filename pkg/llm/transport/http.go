@@ -0,0 +1,119 @@
+package transport
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/niels/git-llm-review/pkg/config"
+)
+
+// Network returns the transport that sends requests over the network with
+// the proxy and TLS settings in cfg.HTTP
+func Network(cfg *config.Config) (http.RoundTripper, error) {
+	h := cfg.HTTP
+	if h.Proxy == "" && h.CABundle == "" && h.ClientCert == "" && h.ClientKey == "" && !h.InsecureSkipVerify {
+		return http.DefaultTransport, nil
+	}
+
+	rt := http.DefaultTransport.(*http.Transport).Clone()
+
+	if h.Proxy != "" {
+		proxyURL, err := url.Parse(h.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", h.Proxy)
+		}
+		rt.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := tlsConfigFor(h)
+	if err != nil {
+		return nil, err
+	}
+	rt.TLSClientConfig = tlsConfig
+
+	return rt, nil
+}
+
+// tlsConfigFor returns the TLS settings for the certificates in h
+func tlsConfigFor(h config.HTTPConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: h.InsecureSkipVerify,
+	}
+
+	if h.CABundle != "" {
+		pem, err := os.ReadFile(h.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		// Trust the bundle in addition to the system certificates
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", h.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if h.ClientCert != "" || h.ClientKey != "" {
+		if h.ClientCert == "" || h.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(h.ClientCert, h.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// headerTransport adds the configured headers to every request
+type headerTransport struct {
+	base            http.RoundTripper
+	headers         map[string]string
+	requestIDHeader string
+}
+
+// newHeaderTransport returns a transport that adds the headers in h to
+// every request, or nil if h adds none
+func newHeaderTransport(base http.RoundTripper, h config.HTTPConfig) http.RoundTripper {
+	if len(h.Headers) == 0 && h.RequestIDHeader == "" {
+		return nil
+	}
+	return &headerTransport{
+		base:            base,
+		headers:         h.Headers,
+		requestIDHeader: h.RequestIDHeader,
+	}
+}
+
+// RoundTrip sends req with the configured headers added
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	if t.requestIDHeader != "" {
+		req.Header.Set(t.requestIDHeader, newRequestID())
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newRequestID returns a random ID for a request
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/cassette"
+	"github.com/niels/git-llm-review/pkg/circuitbreaker"
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/ratelimit"
+	"github.com/niels/git-llm-review/pkg/retry"
+)
+
+// New returns the http.RoundTripper that providers use to talk to the LLM
+// endpoint described by cfg.LLM
+func New(cfg *config.Config) http.RoundTripper {
+	rt := baseTransport(cfg)
+	replaying := cfg.Cassette.Mode == cassette.ModeReplay
+
+	// Apply client-side rate limiting if configured. It sits below the retry
+	// layer so that every attempt is counted against the limits. Replayed
+	// responses do not reach the provider, so they are not limited.
+	if limiter := limiterFor(cfg); limiter != nil && !replaying {
+		rt = ratelimit.NewTransport(rt, limiter)
+	}
+
+	// Stop sending requests to an endpoint that keeps failing. It sits below
+	// the retry layer so that open circuits also cut retries short. A replay
+	// must report unmatched requests rather than an open circuit.
+	if breaker := breakerFor(cfg); breaker != nil && !replaying {
+		rt = circuitbreaker.NewTransport(rt, breaker)
+	}
+
+	// Retry transient failures as configured
+	if cfg.Retry.Enabled {
+		rt = retry.NewTransport(rt, retry.FromConfig(cfg))
+	}
+
+	// Add the configured headers above the retry layer, so that every
+	// attempt of a request carries the same request ID
+	if headers := newHeaderTransport(rt, cfg.HTTP); headers != nil {
+		rt = headers
+	}
+
+	return rt
+}
+
+// NewClient returns an http.Client that uses the transport returned by New
+func NewClient(cfg *config.Config) *http.Client {
+	return &http.Client{
+		Transport: New(cfg),
+	}
+}
+
+// baseTransport returns the transport that sends requests: the network, or
+// the cassette when recording or replaying
+func baseTransport(cfg *config.Config) http.RoundTripper {
+	network, err := Network(cfg)
+	if err != nil {
+		// Fail every request rather than ignoring the HTTP settings
+		return failingTransport{err: fmt.Errorf("invalid http settings: %w", err)}
+	}
+	if cfg.Cassette.Mode == "" {
+		return network
+	}
+
+	rt, err := cassette.Open(cfg.Cassette.Mode, cfg.Cassette.Path, network)
+	if err != nil {
+		// Fail every request rather than silently using the network
+		return failingTransport{err: err}
+	}
+	return rt
+}
+
+// failingTransport returns the same error for every request
+type failingTransport struct {
+	err error
+}
+
+// RoundTrip returns the transport's error
+func (t failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, t.err
+}
+
+// limiterFor returns the limiter shared by every client for the endpoint and
+// API key in cfg, or nil if rate limiting is not configured
+func limiterFor(cfg *config.Config) *ratelimit.Limiter {
+	rl := cfg.LLM.RateLimit
+	if rl.RequestsPerMinute <= 0 && rl.TokensPerMinute <= 0 && !config.Enabled(rl.Adaptive) {
+		return nil
+	}
+
+	opts := ratelimit.Options{
+		RequestsPerMinute: rl.RequestsPerMinute,
+		TokensPerMinute:   rl.TokensPerMinute,
+		Adaptive:          config.Enabled(rl.Adaptive),
+		MaxConcurrency:    cfg.Concurrency.MaxTasks,
+		MaxPause:          time.Duration(rl.MaxPause) * time.Second,
+	}
+
+	return ratelimit.Shared(endpointKey(cfg), opts)
+}
+
+// breakerFor returns the circuit breaker shared by every client for the
+// endpoint in cfg, or nil if the circuit breaker is disabled
+func breakerFor(cfg *config.Config) *circuitbreaker.Breaker {
+	if !cfg.CircuitBreaker.Enabled {
+		return nil
+	}
+
+	// Name the endpoint by URL, or by provider when the default URL is used
+	name := cfg.LLM.APIURL
+	if name == "" {
+		name = cfg.LLM.Provider
+	}
+
+	return circuitbreaker.Shared(name, circuitbreaker.Options{
+		FailureThreshold: cfg.CircuitBreaker.FailureThreshold,
+		Cooldown:         time.Duration(cfg.CircuitBreaker.Cooldown) * time.Second,
+	})
+}
+
+// endpointKey identifies an endpoint and API key without keeping the key itself
+func endpointKey(cfg *config.Config) string {
+	sum := sha256.Sum256([]byte(cfg.LLM.Provider + "\x00" + cfg.LLM.APIURL + "\x00" + cfg.LLM.APIKey))
+	return hex.EncodeToString(sum[:])
+}
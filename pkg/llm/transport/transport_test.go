@@ -0,0 +1,660 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/extractor"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/llm/anthropic"
+	"github.com/niels/git-llm-review/pkg/llm/openai"
+	"github.com/niels/git-llm-review/pkg/llm/transport"
+)
+
+// TestCircuitBreakerCutsRetriesShort verifies that providers stop retrying and
+// fail fast with ErrProviderUnavailable once the endpoint's circuit is open
+func TestCircuitBreakerCutsRetriesShort(t *testing.T) {
+	factories := map[string]func(*config.Config) (llm.Provider, error){
+		"anthropic": anthropic.NewProvider,
+		"openai":    openai.NewProvider,
+	}
+
+	for name, newProvider := range factories {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{
+				LLM: config.LLMConfig{
+					Provider: name,
+					APIURL:   server.URL,
+					APIKey:   "test-api-key",
+					Model:    "test-model",
+					Timeout:  5,
+				},
+				Retry: config.RetryConfig{
+					Enabled:       true,
+					MaxRetries:    5,
+					InitialDelay:  1,
+					MaxDelay:      5,
+					BackoffFactor: 2,
+				},
+				CircuitBreaker: config.CircuitBreakerConfig{
+					Enabled:          true,
+					FailureThreshold: 2,
+					Cooldown:         60,
+				},
+			}
+
+			provider, err := newProvider(cfg)
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			// The first request trips the breaker before its retries run out
+			_, err = provider.GetCompletion(context.Background(), "review this")
+			if !errors.Is(err, llm.ErrProviderUnavailable) {
+				t.Fatalf("Expected ErrProviderUnavailable, got: %v", err)
+			}
+			if !errors.Is(err, llm.ErrProviderFailure) {
+				t.Errorf("Expected the error to remain a provider failure, got: %v", err)
+			}
+			if calls != 2 {
+				t.Errorf("Expected 2 requests before the circuit opened, got %d", calls)
+			}
+
+			// A second worker fails fast without reaching the server
+			_, err = provider.GetCompletion(context.Background(), "review this too")
+			if !errors.Is(err, llm.ErrProviderUnavailable) {
+				t.Errorf("Expected ErrProviderUnavailable, got: %v", err)
+			}
+			if calls != 2 {
+				t.Errorf("Expected no further requests, got %d", calls)
+			}
+		})
+	}
+}
+
+// TestProvidersStopWhenCancelled verifies that cancelling the context ends an
+// in-flight request at once, without retries and without falling back
+func TestProvidersStopWhenCancelled(t *testing.T) {
+	factories := map[string]func(*config.Config) (llm.Provider, error){
+		"anthropic": anthropic.NewProvider,
+		"openai":    openai.NewProvider,
+	}
+
+	for name, newProvider := range factories {
+		t.Run(name, func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				// Hold the request until the client goes away, which the
+				// server only notices once the body has been read
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{
+				LLM: config.LLMConfig{
+					Provider: name,
+					APIURL:   server.URL,
+					APIKey:   "test-api-key",
+					Model:    "test-model",
+					Timeout:  300,
+				},
+				Retry: config.RetryConfig{
+					Enabled:       true,
+					MaxRetries:    3,
+					InitialDelay:  1,
+					MaxDelay:      5,
+					BackoffFactor: 2,
+				},
+			}
+
+			provider, err := newProvider(cfg)
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+
+			start := time.Now()
+			_, err = provider.GetCompletion(ctx, "review this")
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("Expected context.Canceled, got: %v", err)
+			}
+			if llm.ShouldFallback(err) {
+				t.Errorf("Expected a cancelled request not to fall back, got: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Expected cancellation to end the request, took %v", elapsed)
+			}
+			if calls != 1 {
+				t.Errorf("Expected 1 request, got %d", calls)
+			}
+		})
+	}
+}
+
+// TestProvidersStreamCompletion verifies that both providers deliver a
+// streamed completion piece by piece
+func TestProvidersStreamCompletion(t *testing.T) {
+	events := map[string][]string{
+		"anthropic": {
+			`{"type":"message_start","message":{"id":"msg_1"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Looks "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"good"}}`,
+			`{"type":"message_stop"}`,
+		},
+		"openai": {
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"test-model","choices":[{"index":0,"delta":{"content":"Looks "}}]}`,
+			`{"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"test-model","choices":[{"index":0,"delta":{"content":"good"}}]}`,
+			`[DONE]`,
+		},
+	}
+	factories := map[string]func(*config.Config) (llm.Provider, error){
+		"anthropic": anthropic.NewProvider,
+		"openai":    openai.NewProvider,
+	}
+
+	for name, newProvider := range factories {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, event := range events[name] {
+					fmt.Fprintf(w, "data: %s\n\n", event)
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer server.Close()
+
+			provider, err := newProvider(&config.Config{
+				LLM: config.LLMConfig{
+					Provider: name,
+					APIURL:   server.URL,
+					APIKey:   "test-api-key",
+					Model:    "test-model",
+					Timeout:  5,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			var chunks []string
+			completion, err := llm.StreamCompletion(context.Background(), provider, "review this", func(chunk string) {
+				chunks = append(chunks, chunk)
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if completion != "Looks good" {
+				t.Errorf("Expected completion %q, got %q", "Looks good", completion)
+			}
+			if len(chunks) != 2 {
+				t.Errorf("Expected 2 chunks, got %d: %q", len(chunks), chunks)
+			}
+		})
+	}
+}
+
+// TestProvidersReasoningModels verifies that reasoning models receive their
+// own parameters and that their reasoning is returned in the metadata
+func TestProvidersReasoningModels(t *testing.T) {
+	tests := map[string]struct {
+		newProvider func(*config.Config) (llm.Provider, error)
+		model       string
+		generation  config.GenerationConfig
+		response    string
+		// Request fields that must be sent, and ones that must be left out
+		want    map[string]interface{}
+		without []string
+	}{
+		"openai": {
+			newProvider: openai.NewProvider,
+			model:       "o3-mini",
+			generation:  config.GenerationConfig{MaxOutputTokens: 8000, ReasoningEffort: "high"},
+			response:    `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"o3-mini","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"<think>The loop is off by one.</think>Looks good"}}],"usage":{"prompt_tokens":10,"completion_tokens":20,"total_tokens":30,"completion_tokens_details":{"reasoning_tokens":12}}}`,
+			want:        map[string]interface{}{"max_completion_tokens": 8000.0, "reasoning_effort": "high"},
+			without:     []string{"temperature", "max_tokens"},
+		},
+		"anthropic": {
+			newProvider: anthropic.NewProvider,
+			model:       "claude-3-7-sonnet-latest",
+			generation:  config.GenerationConfig{MaxOutputTokens: 4000, ThinkingBudget: 2000},
+			response:    `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"thinking","thinking":"The loop is off by one.","signature":"sig"},{"type":"text","text":"Looks good"}],"usage":{"input_tokens":10,"output_tokens":20}}`,
+			want:        map[string]interface{}{"max_tokens": 6000.0},
+			without:     []string{"temperature"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var request map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			provider, err := tt.newProvider(&config.Config{
+				LLM: config.LLMConfig{
+					Provider:         name,
+					APIURL:           server.URL,
+					APIKey:           "test-api-key",
+					Model:            tt.model,
+					Timeout:          5,
+					GenerationConfig: tt.generation,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			response, err := llm.CompleteWithMetadata(context.Background(), provider, "review this")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			// Check the parameters sent for a reasoning model
+			for field, value := range tt.want {
+				if request[field] != value {
+					t.Errorf("Expected %s to be %v, got %v", field, value, request[field])
+				}
+			}
+			for _, field := range tt.without {
+				if _, ok := request[field]; ok {
+					t.Errorf("Expected %s not to be sent to a reasoning model", field)
+				}
+			}
+			if name == "anthropic" {
+				thinking, _ := request["thinking"].(map[string]interface{})
+				if thinking["type"] != "enabled" || thinking["budget_tokens"] != 2000.0 {
+					t.Errorf("Expected extended thinking with a budget of 2000 tokens, got %v", request["thinking"])
+				}
+			}
+
+			// Check that the reasoning is kept apart from the answer
+			if response.Review != "Looks good" {
+				t.Errorf("Expected the answer without reasoning, got %q", response.Review)
+			}
+			if response.Metadata[llm.MetadataReasoning] != "The loop is off by one." {
+				t.Errorf("Expected the reasoning in the metadata, got %v", response.Metadata[llm.MetadataReasoning])
+			}
+		})
+	}
+}
+
+// TestProvidersHTTPSettings verifies that both providers trust the configured
+// CA bundle and send the configured headers
+func TestProvidersHTTPSettings(t *testing.T) {
+	responses := map[string]string{
+		"anthropic": `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Looks good"}],"usage":{"input_tokens":10,"output_tokens":20}}`,
+		"openai":    `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Looks good"}}]}`,
+	}
+	factories := map[string]func(*config.Config) (llm.Provider, error){
+		"anthropic": anthropic.NewProvider,
+		"openai":    openai.NewProvider,
+	}
+
+	for name, newProvider := range factories {
+		t.Run(name, func(t *testing.T) {
+			var gateway, requestID string
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gateway = r.Header.Get("X-Gateway-Team")
+				requestID = r.Header.Get("X-Request-ID")
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprint(w, responses[name])
+			}))
+			defer server.Close()
+
+			// Trust the test server's certificate through a CA bundle
+			caBundle := filepath.Join(t.TempDir(), "ca.pem")
+			certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+			if err := os.WriteFile(caBundle, certPEM, 0600); err != nil {
+				t.Fatalf("Failed to write CA bundle: %v", err)
+			}
+
+			provider, err := newProvider(&config.Config{
+				LLM: config.LLMConfig{
+					Provider: name,
+					APIURL:   server.URL,
+					APIKey:   "test-api-key",
+					Model:    "test-model",
+					Timeout:  5,
+				},
+				HTTP: config.HTTPConfig{
+					CABundle:        caBundle,
+					Headers:         map[string]string{"X-Gateway-Team": "reviews"},
+					RequestIDHeader: "X-Request-ID",
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			completion, err := provider.GetCompletion(context.Background(), "review this")
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if completion != "Looks good" {
+				t.Errorf("Expected completion %q, got %q", "Looks good", completion)
+			}
+			if gateway != "reviews" {
+				t.Errorf("Expected the extra header to be sent, got %q", gateway)
+			}
+			if len(requestID) != 32 {
+				t.Errorf("Expected a request ID, got %q", requestID)
+			}
+		})
+	}
+}
+
+// TestNetwork verifies the proxy and TLS settings of the network transport
+func TestNetwork(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	get := func(h config.HTTPConfig) error {
+		rt, err := transport.Network(&config.Config{HTTP: h})
+		if err != nil {
+			return err
+		}
+		resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		if err := get(config.HTTPConfig{}); err == nil {
+			t.Error("Expected the test server's certificate to be rejected")
+		}
+	})
+
+	t.Run("insecure skip verify", func(t *testing.T) {
+		if err := get(config.HTTPConfig{InsecureSkipVerify: true}); err != nil {
+			t.Errorf("Expected no error, got: %v", err)
+		}
+	})
+
+	t.Run("proxy", func(t *testing.T) {
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+			fmt.Fprint(w, "ok")
+		}))
+		defer proxy.Close()
+
+		rt, err := transport.Network(&config.Config{HTTP: config.HTTPConfig{Proxy: proxy.URL}})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp, err := (&http.Client{Transport: rt}).Get("http://llm.example.com/v1/models")
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+		if proxied != "http://llm.example.com/v1/models" {
+			t.Errorf("Expected the request to go through the proxy, got %q", proxied)
+		}
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		for _, h := range []config.HTTPConfig{
+			{Proxy: "not a url"},
+			{CABundle: "missing.pem"},
+			{ClientKey: "key.pem"},
+		} {
+			if _, err := transport.Network(&config.Config{HTTP: h}); err == nil {
+				t.Errorf("Expected an error for %+v", h)
+			}
+		}
+	})
+}
+
+// TestProvidersToolCalls verifies that both providers answer the model's tool
+// calls with type definitions from the repository, and send no tools when
+// tools are disabled
+func TestProvidersToolCalls(t *testing.T) {
+	tests := map[string]struct {
+		newProvider func(*config.Config) (llm.Provider, error)
+		toolCall    string
+		answer      string
+		// toolResult returns the tool result sent in the second request
+		toolResult func(request map[string]interface{}) string
+	}{
+		"openai": {
+			newProvider: openai.NewProvider,
+			toolCall:    `{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"test-model","choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"FindDefinitionForType","arguments":"{\"typeName\":\"Widget\"}"}}]}}]}`,
+			answer:      `{"id":"chatcmpl-2","object":"chat.completion","created":1700000000,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"Looks good"}}]}`,
+			toolResult: func(request map[string]interface{}) string {
+				messages, _ := request["messages"].([]interface{})
+				last, _ := messages[len(messages)-1].(map[string]interface{})
+				if last["role"] != "tool" || last["tool_call_id"] != "call_1" {
+					return ""
+				}
+				content, _ := last["content"].(string)
+				return content
+			},
+		},
+		"anthropic": {
+			newProvider: anthropic.NewProvider,
+			toolCall:    `{"id":"msg_1","type":"message","role":"assistant","stop_reason":"tool_use","content":[{"type":"tool_use","id":"toolu_1","name":"FindDefinitionForType","input":{"typeName":"Widget"}}]}`,
+			answer:      `{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"Looks good"}]}`,
+			toolResult: func(request map[string]interface{}) string {
+				messages, _ := request["messages"].([]interface{})
+				last, _ := messages[len(messages)-1].(map[string]interface{})
+				blocks, _ := last["content"].([]interface{})
+				if last["role"] != "user" || len(blocks) != 1 {
+					return ""
+				}
+				block, _ := blocks[0].(map[string]interface{})
+				if block["type"] != "tool_result" || block["tool_use_id"] != "toolu_1" {
+					return ""
+				}
+				content, _ := block["content"].(string)
+				return content
+			},
+		},
+	}
+
+	// A repository with the type the model asks for
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "widget.go"), []byte("package widget\n\ntype Widget struct {\n\tName string\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	codeExtractor, err := extractor.NewCodeExtractor(extractor.Go, repo)
+	if err != nil {
+		t.Fatalf("Failed to create extractor: %v", err)
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				requests = append(requests, request)
+				w.Header().Set("Content-Type", "application/json")
+				if _, hasTools := request["tools"]; hasTools && len(requests) == 1 {
+					fmt.Fprint(w, tt.toolCall)
+					return
+				}
+				fmt.Fprint(w, tt.answer)
+			}))
+			defer server.Close()
+
+			for _, mode := range []string{llm.ToolModeNative, llm.ToolModeNone} {
+				requests = nil
+				provider, err := tt.newProvider(&config.Config{
+					LLM: config.LLMConfig{
+						Provider: name,
+						APIURL:   server.URL,
+						APIKey:   "test-api-key",
+						Model:    "test-model",
+						Timeout:  5,
+						Tools:    mode,
+					},
+				})
+				if err != nil {
+					t.Fatalf("Failed to create provider: %v", err)
+				}
+
+				response, err := provider.ReviewCode(context.Background(), &llm.ReviewRequest{
+					FilePath:  "main.go",
+					FileDiff:  "+var w Widget",
+					Extractor: codeExtractor,
+				})
+				if err != nil {
+					t.Fatalf("%s: expected no error, got: %v", mode, err)
+				}
+				if response.Review != "Looks good" {
+					t.Errorf("%s: expected the review, got %q", mode, response.Review)
+				}
+
+				if mode == llm.ToolModeNone {
+					if len(requests) != 1 {
+						t.Errorf("Expected a single request without tools, got %d", len(requests))
+					}
+					continue
+				}
+				if len(requests) != 2 {
+					t.Fatalf("Expected 2 requests, got %d", len(requests))
+				}
+				if result := tt.toolResult(requests[1]); !strings.Contains(result, "type Widget struct") {
+					t.Errorf("Expected the type definition in the tool result, got %q", result)
+				}
+			}
+		})
+	}
+}
+
+// TestProvidersTextToolCalls verifies that both providers switch to the text
+// tool protocol when the server rejects native tools, and remember the switch
+func TestProvidersTextToolCalls(t *testing.T) {
+	tests := map[string]struct {
+		newProvider func(*config.Config) (llm.Provider, error)
+		// reply returns a response with the given text
+		reply func(text string) string
+	}{
+		"openai": {
+			newProvider: openai.NewProvider,
+			reply: func(text string) string {
+				content, _ := json.Marshal(text)
+				return fmt.Sprintf(`{"id":"chatcmpl-1","object":"chat.completion","created":1700000000,"model":"test-model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":%s}}]}`, content)
+			},
+		},
+		"anthropic": {
+			newProvider: anthropic.NewProvider,
+			reply: func(text string) string {
+				content, _ := json.Marshal(text)
+				return fmt.Sprintf(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":%s}]}`, content)
+			},
+		},
+	}
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "widget.go"), []byte("package widget\n\ntype Widget struct {\n\tName string\n}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	codeExtractor, err := extractor.NewCodeExtractor(extractor.Go, repo)
+	if err != nil {
+		t.Fatalf("Failed to create extractor: %v", err)
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var toolRequests int32
+			var toolResult string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request map[string]interface{}
+				if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+					t.Errorf("Failed to decode request: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+
+				// Reject native tools like a server started without tool support
+				if _, ok := request["tools"]; ok {
+					atomic.AddInt32(&toolRequests, 1)
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"error":{"type":"invalid_request_error","message":"tools are not supported by this server"}}`)
+					return
+				}
+
+				// Call the tool in the first reply, then answer
+				messages, _ := request["messages"].([]interface{})
+				last, _ := messages[len(messages)-1].(map[string]interface{})
+				if strings.Contains(fmt.Sprint(last["content"]), "File: main.go") {
+					fmt.Fprint(w, tt.reply("```tool_call\n{\"name\": \"FindDefinitionForType\", \"arguments\": {\"typeName\": \"Widget\"}}\n```"))
+					return
+				}
+				toolResult, _ = last["content"].(string)
+				fmt.Fprint(w, tt.reply("Looks good"))
+			}))
+			defer server.Close()
+
+			provider, err := tt.newProvider(&config.Config{
+				LLM: config.LLMConfig{
+					Provider: name,
+					APIURL:   server.URL,
+					APIKey:   "test-api-key",
+					Model:    "test-model",
+					Timeout:  5,
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed to create provider: %v", err)
+			}
+
+			// The second review uses the text protocol straight away
+			for i := 0; i < 2; i++ {
+				response, err := provider.ReviewCode(context.Background(), &llm.ReviewRequest{
+					FilePath:  "main.go",
+					FileDiff:  "+var w Widget",
+					Extractor: codeExtractor,
+				})
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if response.Review != "Looks good" {
+					t.Errorf("Expected the review, got %q", response.Review)
+				}
+				if !strings.Contains(toolResult, "type Widget struct") {
+					t.Errorf("Expected the type definition in the tool result, got %q", toolResult)
+				}
+			}
+			if toolRequests != 1 {
+				t.Errorf("Expected native tools to be tried once, got %d", toolRequests)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ const (
 	IssueTypeMaintenance   = "maintenance"
 	IssueTypeMaintain      = "maintain"
 	IssueTypeRefactor      = "refactor"
+	IssueTypeIntent        = "intent"
 	
 	// Issue type display names (capitalized for presentation)
 	IssueTypeBugDisplay           = "Bug"
@@ -25,4 +26,5 @@ const (
 	IssueTypeDocumentationDisplay = "Documentation"
 	IssueTypeMaintainabilityDisplay = "Maintainability"
 	IssueTypeRefactorDisplay      = "Refactor"
+	IssueTypeIntentDisplay        = "Intent"
 )
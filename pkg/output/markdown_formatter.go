@@ -10,16 +10,45 @@ import (
 	"time"
 
 	"github.com/niels/git-llm-review/pkg/parse"
+	"github.com/niels/git-llm-review/pkg/util"
 )
 
+// maxRationaleLength is the number of characters of the model's reasoning
+// shown in a report
+const maxRationaleLength = 800
+
 // MarkdownFormatter formats review results as markdown
-type MarkdownFormatter struct{}
+type MarkdownFormatter struct {
+	includeRationale bool
+	profile          string
+	templateVersion  string
+}
 
 // NewMarkdownFormatter creates a new markdown formatter
 func NewMarkdownFormatter() *MarkdownFormatter {
 	return &MarkdownFormatter{}
 }
 
+// WithProfile sets the name of the profile shown in the reports
+func (f *MarkdownFormatter) WithProfile(profile string) *MarkdownFormatter {
+	f.profile = profile
+	return f
+}
+
+// WithTemplateVersion sets the version of the prompt templates shown in the
+// reports
+func (f *MarkdownFormatter) WithTemplateVersion(version string) *MarkdownFormatter {
+	f.templateVersion = version
+	return f
+}
+
+// WithRationale sets whether a condensed version of the model's reasoning is
+// included in the reports
+func (f *MarkdownFormatter) WithRationale(include bool) *MarkdownFormatter {
+	f.includeRationale = include
+	return f
+}
+
 // FormatReview formats a review result as markdown
 func (f *MarkdownFormatter) FormatReview(result *parse.ReviewResult, filePath, repoName string) string {
 	var sb strings.Builder
@@ -29,6 +58,21 @@ func (f *MarkdownFormatter) FormatReview(result *parse.ReviewResult, filePath, r
 	sb.WriteString(fmt.Sprintf("## File: %s\n\n", filePath))
 	sb.WriteString(fmt.Sprintf("Repository: %s\n\n", repoName))
 	sb.WriteString(fmt.Sprintf("Generated on: %s\n\n", time.Now().Format(time.RFC1123)))
+	if result != nil && result.Provider != "" {
+		sb.WriteString(fmt.Sprintf("Reviewed by: %s\n\n", result.Provider))
+	}
+	if f.profile != "" {
+		sb.WriteString(fmt.Sprintf("Profile: %s\n\n", f.profile))
+	}
+	if f.templateVersion != "" {
+		sb.WriteString(fmt.Sprintf("Prompt templates: %s\n\n", f.templateVersion))
+	}
+
+	// Add the model's reasoning if requested
+	if f.includeRationale && result != nil && result.Reasoning != "" {
+		sb.WriteString("## Rationale\n\n")
+		sb.WriteString(fmt.Sprintf("> %s\n\n", condenseRationale(result.Reasoning)))
+	}
 
 	// Handle empty result
 	if result == nil || len(result.Issues) == 0 {
@@ -36,8 +80,39 @@ func (f *MarkdownFormatter) FormatReview(result *parse.ReviewResult, filePath, r
 		return sb.String()
 	}
 
+	// Group the issues by the focus that found them, if the review had one
+	if hasFocus(result.Issues) {
+		issuesByFocus := make(map[string][]parse.Issue)
+		for _, issue := range result.Issues {
+			focus := issue.Focus
+			if focus == "" {
+				focus = "general"
+			}
+			issuesByFocus[focus] = append(issuesByFocus[focus], issue)
+		}
+
+		var foci []string
+		for focus := range issuesByFocus {
+			foci = append(foci, focus)
+		}
+		sort.Strings(foci)
+
+		for _, focus := range foci {
+			sb.WriteString(fmt.Sprintf("## Focus: %s\n\n", focus))
+			f.writeIssuesByType(&sb, issuesByFocus[focus], "###")
+		}
+		return sb.String()
+	}
+
+	f.writeIssuesByType(&sb, result.Issues, "##")
+	return sb.String()
+}
+
+// writeIssuesByType writes issues grouped by type, with the type headings at
+// the given heading level and the issue titles one level below
+func (f *MarkdownFormatter) writeIssuesByType(sb *strings.Builder, issues []parse.Issue, heading string) {
 	// Group issues by type
-	issuesByType := f.groupIssuesByType(result.Issues)
+	issuesByType := f.groupIssuesByType(issues)
 
 	// Sort issue types for consistent output
 	var types []string
@@ -49,11 +124,11 @@ func (f *MarkdownFormatter) FormatReview(result *parse.ReviewResult, filePath, r
 	// Process each issue type
 	for _, issueType := range types {
 		issues := issuesByType[issueType]
-		sb.WriteString(fmt.Sprintf("## %s Issues\n\n", issueType))
+		sb.WriteString(fmt.Sprintf("%s %s Issues\n\n", heading, issueType))
 
 		for _, issue := range issues {
 			// Add issue title as header
-			sb.WriteString(fmt.Sprintf("### %s\n\n", issue.Title))
+			sb.WriteString(fmt.Sprintf("%s# %s\n\n", heading, issue.Title))
 			
 			// Add explanation
 			sb.WriteString(fmt.Sprintf("%s\n\n", issue.Explanation))
@@ -66,8 +141,16 @@ func (f *MarkdownFormatter) FormatReview(result *parse.ReviewResult, filePath, r
 			}
 		}
 	}
+}
 
-	return sb.String()
+// hasFocus reports whether any of the issues was found by a focused review
+func hasFocus(issues []parse.Issue) bool {
+	for _, issue := range issues {
+		if issue.Focus != "" {
+			return true
+		}
+	}
+	return false
 }
 
 // WriteToFile writes the formatted review to a file
@@ -89,6 +172,35 @@ func (f *MarkdownFormatter) WriteToFile(result *parse.ReviewResult, filePath, re
 	return nil
 }
 
+// FormatExplanation formats the explanation of a change as markdown
+func (f *MarkdownFormatter) FormatExplanation(subject, explanation, provider, repoName string) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Explanation of %s\n\n", subject))
+	sb.WriteString(fmt.Sprintf("Repository: %s\n\n", repoName))
+	sb.WriteString(fmt.Sprintf("Generated on: %s\n\n", time.Now().Format(time.RFC1123)))
+	if provider != "" {
+		sb.WriteString(fmt.Sprintf("Explained by: %s\n\n", provider))
+	}
+	if f.profile != "" {
+		sb.WriteString(fmt.Sprintf("Profile: %s\n\n", f.profile))
+	}
+
+	sb.WriteString(strings.TrimSpace(explanation) + "\n")
+	return sb.String()
+}
+
+// WriteExplanation writes the formatted explanation of a change to a file
+func (f *MarkdownFormatter) WriteExplanation(subject, explanation, provider, repoName, outputPath string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(outputPath, []byte(f.FormatExplanation(subject, explanation, provider, repoName)), 0644); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	return nil
+}
+
 // groupIssuesByType groups issues by their type (Bug, Style, etc.)
 func (f *MarkdownFormatter) groupIssuesByType(issues []parse.Issue) map[string][]parse.Issue {
 	result := make(map[string][]parse.Issue)
@@ -130,6 +242,8 @@ func (f *MarkdownFormatter) extractIssueType(title string) string {
 		return IssueTypeSecurityDisplay
 	case IssueTypeMaintainability, IssueTypeMaintenance, IssueTypeMaintain, "readability":
 		return IssueTypeMaintainabilityDisplay
+	case IssueTypeIntent:
+		return IssueTypeIntentDisplay
 	default:
 		// If it's capitalized, it's probably a valid type
 		if len(issueType) > 0 && issueType[0] >= 'A' && issueType[0] <= 'Z' {
@@ -139,6 +253,24 @@ func (f *MarkdownFormatter) extractIssueType(title string) string {
 	}
 }
 
+// condenseRationale joins the reasoning into a single paragraph and shortens
+// it to at most maxRationaleLength characters, ending at a sentence if possible
+func condenseRationale(reasoning string) string {
+	condensed := strings.Join(strings.Fields(reasoning), " ")
+	if len(condensed) <= maxRationaleLength {
+		return condensed
+	}
+
+	condensed = util.TruncateUTF8(condensed, maxRationaleLength)
+	if end := strings.LastIndex(condensed, ". "); end > maxRationaleLength/2 {
+		return condensed[:end+1] + " …"
+	}
+	if end := strings.LastIndex(condensed, " "); end > 0 {
+		condensed = condensed[:end]
+	}
+	return condensed + " …"
+}
+
 // formatDiff formats a diff for markdown
 func (f *MarkdownFormatter) formatDiff(diff string) string {
 	// Clean up diff format
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/niels/git-llm-review/pkg/parse"
 )
@@ -214,3 +215,64 @@ func TestMarkdownFormatter_GroupIssuesByType(t *testing.T) {
 		}
 	})
 }
+
+func TestMarkdownFormatter_GroupIssuesByFocus(t *testing.T) {
+	result := &parse.ReviewResult{
+		Issues: []parse.Issue{
+			{Title: "Bug: Missing test", Explanation: "Add a test", Focus: "tests"},
+			{Title: "Security: SQL injection", Explanation: "Use parameters", Focus: "security"},
+		},
+	}
+
+	markdown := NewMarkdownFormatter().FormatReview(result, "test.go", "example-repo")
+	security := strings.Index(markdown, "## Focus: security")
+	tests := strings.Index(markdown, "## Focus: tests")
+	if security < 0 || tests < 0 || security > tests {
+		t.Fatalf("Expected sections for each focus in order, got:\n%s", markdown)
+	}
+	if !strings.Contains(markdown, "### Security Issues\n\n#### Security: SQL injection") {
+		t.Errorf("Expected the issues grouped by type within each focus, got:\n%s", markdown)
+	}
+}
+
+func TestMarkdownFormatter_Rationale(t *testing.T) {
+	result := &parse.ReviewResult{
+		Issues: []parse.Issue{
+			{Title: "Bug: Off by one", Explanation: "The loop skips the last element."},
+		},
+		Reasoning: "The loop runs while i < len(items) - 1.\n\nSo the last item is never visited.",
+	}
+
+	t.Run("Omitted by default", func(t *testing.T) {
+		markdown := NewMarkdownFormatter().FormatReview(result, "test.go", "example-repo")
+		if strings.Contains(markdown, "## Rationale") {
+			t.Error("Markdown should not contain the rationale unless requested")
+		}
+	})
+
+	t.Run("Included when requested", func(t *testing.T) {
+		markdown := NewMarkdownFormatter().WithRationale(true).FormatReview(result, "test.go", "example-repo")
+		if !strings.Contains(markdown, "## Rationale\n\n> The loop runs while i < len(items) - 1. So the last item is never visited.") {
+			t.Errorf("Markdown should contain the condensed rationale, got:\n%s", markdown)
+		}
+	})
+
+	t.Run("Long reasoning is shortened", func(t *testing.T) {
+		long := &parse.ReviewResult{Reasoning: strings.Repeat("This is one sentence of reasoning. ", 100)}
+		markdown := NewMarkdownFormatter().WithRationale(true).FormatReview(long, "test.go", "example-repo")
+		if !strings.Contains(markdown, "reasoning. …") {
+			t.Error("Long reasoning should be cut at the end of a sentence")
+		}
+		if len(markdown) > 1500 {
+			t.Errorf("Expected a condensed rationale, got %d characters", len(markdown))
+		}
+	})
+
+	t.Run("Long reasoning stays valid UTF-8", func(t *testing.T) {
+		long := &parse.ReviewResult{Reasoning: "a" + strings.Repeat("é", 1000)}
+		markdown := NewMarkdownFormatter().WithRationale(true).FormatReview(long, "test.go", "example-repo")
+		if !utf8.ValidString(markdown) {
+			t.Error("Shortened reasoning should not split a character")
+		}
+	})
+}
@@ -100,6 +100,9 @@ func (f *TerminalFormatter) FormatReview(result *parse.ReviewResult) string {
 		for i, issue := range issues {
 			// Issue title
 			issueTitle := fmt.Sprintf("Issue %d: %s", i+1, issue.Title)
+			if issue.Focus != "" {
+				issueTitle = fmt.Sprintf("Issue %d [%s]: %s", i+1, issue.Focus, issue.Title)
+			}
 			sb.WriteString(f.colorizeText(issueTitle, ColorBoldCyan))
 			sb.WriteString("\n\n")
 
@@ -214,6 +217,7 @@ func (f *TerminalFormatter) formatIssueType(title string) string {
 		IssueTypeMaintain:      ColorBoldBlue,
 		IssueTypeMaintenance:   ColorBoldBlue,
 		IssueTypeRefactor:      ColorBoldMagenta,
+		IssueTypeIntent:        ColorBoldYellow,
 	}
 
 	titleLower := strings.ToLower(title)
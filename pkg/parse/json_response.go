@@ -22,6 +22,7 @@ type Issue struct {
 	Explanation string `json:"explanation"`
 	Diff        string `json:"diff,omitempty"` // Kept for backward compatibility
 	File        string `json:"file,omitempty"` // New field to track which file the issue belongs to
+	Focus       string `json:"focus,omitempty"` // Focus of the review that found the issue, such as "security"
 }
 
 // FileDiff represents a consolidated diff for a single file
@@ -9,6 +9,10 @@ import (
 type ReviewResult struct {
 	Issues []Issue
 	Diffs  []FileDiff
+	// Provider describes the provider that produced the review, if known
+	Provider string
+	// Reasoning is the reasoning the model reported before its review, if any
+	Reasoning string
 }
 
 // ParseReview parses a review response and returns a ReviewResult
@@ -108,7 +112,119 @@ func cleanupIssues(issues []Issue) []Issue {
 			issues[i].File = "General"
 		}
 		
+		// Focus names are compared in lower case
+		issues[i].Focus = strings.ToLower(strings.TrimSpace(issues[i].Focus))
+
 		// Diff can be empty, no need to modify
 	}
 	return issues
 }
+
+// SetFocus records focus as the focus of every issue that has none
+func (r *ReviewResult) SetFocus(focus string) {
+	if r == nil {
+		return
+	}
+	for i := range r.Issues {
+		if r.Issues[i].Focus == "" {
+			r.Issues[i].Focus = focus
+		}
+	}
+}
+
+// Merge adds the issues and diffs of other to the result. Only the first diff
+// for each file is kept, so that the suggested fixes do not conflict.
+func (r *ReviewResult) Merge(other *ReviewResult) {
+	if other == nil {
+		return
+	}
+	r.Issues = append(r.Issues, other.Issues...)
+	for _, diff := range other.Diffs {
+		duplicate := false
+		for _, existing := range r.Diffs {
+			if existing.File == diff.File {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			r.Diffs = append(r.Diffs, diff)
+		}
+	}
+	if r.Provider == "" {
+		r.Provider = other.Provider
+	}
+	if other.Reasoning != "" {
+		if r.Reasoning != "" {
+			r.Reasoning += "\n\n"
+		}
+		r.Reasoning += other.Reasoning
+	}
+}
+
+// SplitByFile splits the review of several files into a result for each of
+// paths, using the file the model named for each issue and diff. Issues that
+// name no file, or one that is not reviewed, are attributed to the first
+// path; such diffs cannot be applied and are dropped. Every result keeps the
+// provider and reasoning of the review.
+func (r *ReviewResult) SplitByFile(paths []string) map[string]*ReviewResult {
+	results := make(map[string]*ReviewResult, len(paths))
+	for _, path := range paths {
+		results[path] = &ReviewResult{
+			Issues:    []Issue{},
+			Diffs:     []FileDiff{},
+			Provider:  r.Provider,
+			Reasoning: r.Reasoning,
+		}
+	}
+	if len(paths) == 0 {
+		return results
+	}
+
+	for _, issue := range r.Issues {
+		path := MatchFile(issue.File, paths)
+		if path == "" {
+			path = paths[0]
+		} else {
+			issue.File = path
+		}
+		results[path].Issues = append(results[path].Issues, issue)
+	}
+	for _, diff := range r.Diffs {
+		if path := MatchFile(diff.File, paths); path != "" {
+			diff.File = path
+			results[path].Diffs = append(results[path].Diffs, diff)
+		}
+	}
+	return results
+}
+
+// MatchFile returns the path among paths that a model meant by name, which
+// may be written relative to another directory, with a diff prefix such as
+// "b/", or as the file name only. It returns an empty string if name matches
+// none or more than one of paths.
+func MatchFile(name string, paths []string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimPrefix(name, "./")
+	if name == "" {
+		return ""
+	}
+	for _, path := range paths {
+		if path == name {
+			return path
+		}
+	}
+
+	// Accept names with a prefix, such as a/ and b/ of diffs or an absolute
+	// path, and paths given relative to a subdirectory
+	match := ""
+	for _, path := range paths {
+		if strings.HasSuffix(name, "/"+path) || strings.HasSuffix(path, "/"+name) {
+			if match != "" {
+				return ""
+			}
+			match = path
+		}
+	}
+	return match
+}
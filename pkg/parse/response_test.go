@@ -275,3 +275,74 @@ func TestReviewResult_String(t *testing.T) {
 		})
 	}
 }
+
+func TestReviewResult_Focus(t *testing.T) {
+	result := ParseReview(`{"issues":[{"title":"A","explanation":"a","focus":" Security "},{"title":"B","explanation":"b"}]}`)
+	if result.Issues[0].Focus != "security" {
+		t.Errorf("Expected the focus given by the model, got %q", result.Issues[0].Focus)
+	}
+
+	result.SetFocus("tests")
+	if result.Issues[0].Focus != "security" || result.Issues[1].Focus != "tests" {
+		t.Errorf("Expected only issues without a focus to be tagged, got %+v", result.Issues)
+	}
+
+	result.Merge(&ReviewResult{
+		Issues:    []Issue{{Title: "C", Focus: "performance"}},
+		Diffs:     []FileDiff{{File: "main.go", Diff: "+fix"}},
+		Reasoning: "more",
+	})
+	if len(result.Issues) != 3 || len(result.Diffs) != 1 || result.Reasoning != "more" {
+		t.Errorf("Unexpected merged result: %+v", result)
+	}
+}
+
+func TestReviewResult_SplitByFile(t *testing.T) {
+	paths := []string{"pkg/lib/lib.go", "cmd/app/main.go"}
+	result := &ReviewResult{
+		Issues: []Issue{
+			{Title: "A", File: "pkg/lib/lib.go"},
+			{Title: "B", File: "./cmd/app/main.go"},
+			{Title: "C", File: "main.go"},
+			{Title: "D", File: "General"},
+		},
+		Diffs: []FileDiff{
+			{File: "b/pkg/lib/lib.go", Diff: "+fix"},
+			{File: "other.go", Diff: "+fix"},
+		},
+		Provider: "Fake",
+	}
+
+	split := result.SplitByFile(paths)
+	lib, app := split["pkg/lib/lib.go"], split["cmd/app/main.go"]
+	if len(lib.Issues) != 2 || lib.Issues[0].Title != "A" || lib.Issues[1].Title != "D" {
+		t.Errorf("Expected issues A and D for the first file, got %+v", lib.Issues)
+	}
+	if len(app.Issues) != 2 || app.Issues[0].File != "cmd/app/main.go" || app.Issues[1].File != "cmd/app/main.go" {
+		t.Errorf("Expected issues B and C with the full path, got %+v", app.Issues)
+	}
+	if len(lib.Diffs) != 1 || lib.Diffs[0].File != "pkg/lib/lib.go" || len(app.Diffs) != 0 {
+		t.Errorf("Expected only the diff of a reviewed file, got %+v and %+v", lib.Diffs, app.Diffs)
+	}
+	if app.Provider != "Fake" {
+		t.Errorf("Expected the provider to be kept, got %q", app.Provider)
+	}
+}
+
+func TestMatchFile(t *testing.T) {
+	paths := []string{"pkg/a/util.go", "pkg/b/util.go", "pkg/b/server.go"}
+	tests := map[string]string{
+		"pkg/a/util.go":           "pkg/a/util.go",
+		"/home/dev/pkg/b/util.go": "pkg/b/util.go",
+		"server.go":               "pkg/b/server.go",
+		"b/server.go":             "pkg/b/server.go",
+		"util.go":                 "",
+		"missing.go":              "",
+		"":                        "",
+	}
+	for name, expected := range tests {
+		if got := MatchFile(name, paths); got != expected {
+			t.Errorf("MatchFile(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
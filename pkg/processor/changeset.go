@@ -0,0 +1,265 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/niels/git-llm-review/pkg/extractor"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/parse"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// ChangesetGroup is a set of files of a changeset that are reviewed together
+type ChangesetGroup struct {
+	// Requests are the review requests of the files in the group
+	Requests []*llm.ReviewRequest
+}
+
+// Paths returns the paths of the files in the group
+func (g ChangesetGroup) Paths() []string {
+	paths := make([]string, len(g.Requests))
+	for i, request := range g.Requests {
+		paths[i] = request.FilePath
+	}
+	return paths
+}
+
+// Name describes the group in logs and prompts
+func (g ChangesetGroup) Name() string {
+	switch len(g.Requests) {
+	case 0:
+		return ""
+	case 1:
+		return g.Requests[0].FilePath
+	default:
+		return fmt.Sprintf("%s and %d more files", g.Requests[0].FilePath, len(g.Requests)-1)
+	}
+}
+
+// Request returns the request that reviews the files of the group with the
+// given options. A group of one file is reviewed like any other file.
+func (g ChangesetGroup) Request(options llm.ReviewOptions) *llm.ReviewRequest {
+	var request *llm.ReviewRequest
+	if len(g.Requests) == 1 {
+		single := *g.Requests[0]
+		request = &single
+	} else {
+		request = &llm.ReviewRequest{FilePath: g.Name()}
+		for _, fileRequest := range g.Requests {
+			request.Files = append(request.Files, llm.ReviewFile{
+				FilePath:    fileRequest.FilePath,
+				FileContent: fileRequest.FileContent,
+				FileDiff:    fileRequest.FileDiff,
+			})
+		}
+	}
+	request.Options = options
+	request.Options.IncludeExplanations = true
+	return request
+}
+
+// ChangesetLimits bound the size of the groups of a changeset review
+type ChangesetLimits struct {
+	// MaxTokens is the estimated number of tokens of the content and diffs
+	// of the files in a group (0 = no limit)
+	MaxTokens int
+
+	// MaxFiles is the number of files in a group (0 = no limit)
+	MaxFiles int
+}
+
+// GroupChangeset groups the review requests of the files of a changeset, so
+// that related files are reviewed together within the limits. Files are
+// related when they are in the same directory or one imports the other.
+// Files without related files that fit in a group are batched together to
+// save requests, and files too large for any group are reviewed alone.
+func GroupChangeset(repoRoot string, requests []*llm.ReviewRequest, limits ChangesetLimits) []ChangesetGroup {
+	// Join related files into components
+	component := make([]int, len(requests))
+	for i := range component {
+		component[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if component[i] != i {
+			component[i] = find(component[i])
+		}
+		return component[i]
+	}
+
+	modulePath := goModulePath(repoRoot)
+	for i, request := range requests {
+		imported := importedPaths(request.FilePath, request.FileContent, modulePath)
+		for j, other := range requests {
+			if i == j {
+				continue
+			}
+			if path.Dir(request.FilePath) == path.Dir(other.FilePath) || importsFile(imported, other.FilePath) {
+				component[find(i)] = find(j)
+			}
+		}
+	}
+
+	// Keep the files of each component in the order they were given
+	var order []int
+	members := make(map[int][]*llm.ReviewRequest)
+	for i, request := range requests {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			order = append(order, root)
+		}
+		members[root] = append(members[root], request)
+	}
+
+	var groups []ChangesetGroup
+	var unrelated []*llm.ReviewRequest
+	for _, root := range order {
+		for _, chunk := range packRequests(members[root], limits) {
+			if len(chunk) == 1 {
+				unrelated = append(unrelated, chunk[0])
+				continue
+			}
+			groups = append(groups, ChangesetGroup{Requests: chunk})
+		}
+	}
+
+	// Batch the files that are left on their own
+	for _, chunk := range packRequests(unrelated, limits) {
+		groups = append(groups, ChangesetGroup{Requests: chunk})
+	}
+	return groups
+}
+
+// packRequests splits requests into chunks within the limits, adding each
+// request to the first chunk it fits in
+func packRequests(requests []*llm.ReviewRequest, limits ChangesetLimits) [][]*llm.ReviewRequest {
+	var chunks [][]*llm.ReviewRequest
+	var sizes []int
+	for _, request := range requests {
+		size := requestTokens(request)
+		placed := false
+		for i := range chunks {
+			if limits.MaxTokens > 0 && sizes[i]+size > limits.MaxTokens {
+				continue
+			}
+			if limits.MaxFiles > 0 && len(chunks[i]) >= limits.MaxFiles {
+				continue
+			}
+			chunks[i] = append(chunks[i], request)
+			sizes[i] += size
+			placed = true
+			break
+		}
+		if !placed {
+			chunks = append(chunks, []*llm.ReviewRequest{request})
+			sizes = append(sizes, size)
+		}
+	}
+	return chunks
+}
+
+// requestTokens estimates the number of tokens a file adds to a prompt
+func requestTokens(request *llm.ReviewRequest) int {
+	return util.EstimateTokens(len(request.FileContent) + len(request.FileDiff))
+}
+
+// GroupProcessor reviews the files of a group and returns the result of each
+// file, keyed by path
+type GroupProcessor func(ctx context.Context, group ChangesetGroup) (map[string]*parse.ReviewResult, error)
+
+// ChangesetProcessor creates a GroupProcessor that reviews the files of a
+// group in one request, so that the model can find issues between them. The
+// issues are attributed to the files they name.
+func ChangesetProcessor(repoRoot string, provider llm.Provider, options llm.ReviewOptions) GroupProcessor {
+	extractors := extractor.NewSet(repoRoot)
+
+	return func(ctx context.Context, group ChangesetGroup) (map[string]*parse.ReviewResult, error) {
+		request := group.Request(options)
+		request.Extractor = groupExtractor(extractors, group.Paths())
+
+		logging.InfoWith("Sending changeset review request to LLM", map[string]interface{}{
+			"files": len(group.Requests),
+			"group": group.Name(),
+		})
+
+		completion, err := provider.ReviewCode(ctx, request)
+		if err != nil {
+			logging.ErrorWith("Failed to get LLM response", map[string]interface{}{
+				"group": group.Name(),
+				"error": err.Error(),
+			})
+			return nil, fmt.Errorf("failed to get LLM response for %s: %w", group.Name(), err)
+		}
+
+		// Check if the context has been cancelled
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		reviewResult := ReviewResult(completion)
+
+		// A review with a single focus found only issues of that focus
+		if len(options.Focus) == 1 {
+			reviewResult.SetFocus(options.Focus[0])
+		}
+
+		logging.InfoWith("Completed changeset review", map[string]interface{}{
+			"group":       group.Name(),
+			"issue_count": reviewResult.GetIssueCount(),
+			"provider":    reviewResult.Provider,
+		})
+
+		return reviewResult.SplitByFile(group.Paths()), nil
+	}
+}
+
+// groupExtractor returns the extractor for the files at paths if they are
+// all written in the same supported language, or nil otherwise
+func groupExtractor(extractors *extractor.Set, paths []string) *extractor.CodeExtractor {
+	first, ok := extractor.LanguageOf(paths[0])
+	if !ok {
+		return nil
+	}
+	for _, filePath := range paths[1:] {
+		if lang, ok := extractor.LanguageOf(filePath); !ok || lang != first {
+			return nil
+		}
+	}
+	return extractors.ForFile(paths[0])
+}
+
+// GroupPass is one pass of a changeset review that looks at each group once
+// per focus
+type GroupPass struct {
+	// Focus is the focus of the pass, such as "security"
+	Focus string
+	// Process reviews a group with this focus
+	Process GroupProcessor
+}
+
+// MultiPassGroupProcessor creates a GroupProcessor that reviews each group
+// once per pass and merges the results of each file. Every issue is tagged
+// with the focus of the pass that found it. The group fails if any pass
+// fails.
+func MultiPassGroupProcessor(passes []GroupPass) GroupProcessor {
+	return func(ctx context.Context, group ChangesetGroup) (map[string]*parse.ReviewResult, error) {
+		merged := make(map[string]*parse.ReviewResult)
+		for _, pass := range passes {
+			results, err := pass.Process(ctx, group)
+			if err != nil {
+				return nil, fmt.Errorf("%s review: %w", pass.Focus, err)
+			}
+			for filePath, result := range results {
+				result.SetFocus(pass.Focus)
+				if merged[filePath] == nil {
+					merged[filePath] = &parse.ReviewResult{Issues: []parse.Issue{}, Diffs: []parse.FileDiff{}}
+				}
+				merged[filePath].Merge(result)
+			}
+		}
+		return merged, nil
+	}
+}
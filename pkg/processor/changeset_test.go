@@ -0,0 +1,180 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+func TestGroupChangeset(t *testing.T) {
+	repoRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoRoot, "go.mod"), []byte("module example.com/demo\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatalf("Failed to write go.mod: %v", err)
+	}
+
+	request := func(filePath, content string) *llm.ReviewRequest {
+		return &llm.ReviewRequest{FilePath: filePath, FileContent: content, FileDiff: "+change"}
+	}
+	groupPaths := func(groups []ChangesetGroup) [][]string {
+		var paths [][]string
+		for _, group := range groups {
+			groupPaths := group.Paths()
+			sort.Strings(groupPaths)
+			paths = append(paths, groupPaths)
+		}
+		return paths
+	}
+
+	t.Run("Related files are reviewed together", func(t *testing.T) {
+		requests := []*llm.ReviewRequest{
+			request("lib/lib.go", "package lib\n\nfunc Add(a, b, c int) int { return a + b + c }\n"),
+			request("docs/readme.c", "int main() { return 0; }\n"),
+			request("app/app.go", "package app\n\nimport \"example.com/demo/lib\"\n\nfunc Run() int { return lib.Add(1, 2) }\n"),
+			request("lib/lib_test.go", "package lib\n"),
+		}
+		groups := GroupChangeset(repoRoot, requests, ChangesetLimits{MaxTokens: 10000, MaxFiles: 8})
+
+		expected := [][]string{{"app/app.go", "lib/lib.go", "lib/lib_test.go"}, {"docs/readme.c"}}
+		if got := groupPaths(groups); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected groups %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Unrelated small files are batched", func(t *testing.T) {
+		requests := []*llm.ReviewRequest{
+			request("a/a.go", "package a\n"),
+			request("b/b.go", "package b\n"),
+			request("c/c.go", "package c\n"),
+		}
+		groups := GroupChangeset(repoRoot, requests, ChangesetLimits{MaxTokens: 10000, MaxFiles: 2})
+
+		expected := [][]string{{"a/a.go", "b/b.go"}, {"c/c.go"}}
+		if got := groupPaths(groups); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected groups %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Large files are reviewed alone", func(t *testing.T) {
+		requests := []*llm.ReviewRequest{
+			request("lib/big.go", "package lib\n"+strings.Repeat("// filler\n", 1000)),
+			request("lib/small.go", "package lib\n"),
+			request("lib/other.go", "package lib\n"),
+		}
+		groups := GroupChangeset(repoRoot, requests, ChangesetLimits{MaxTokens: 500})
+
+		expected := [][]string{{"lib/other.go", "lib/small.go"}, {"lib/big.go"}}
+		if got := groupPaths(groups); !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected groups %v, got %v", expected, got)
+		}
+	})
+}
+
+func TestImportedPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		filePath string
+		content  string
+		imports  string
+		expected bool
+	}{
+		{
+			name:     "Go package of the module",
+			filePath: "cmd/main.go",
+			content:  "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/demo/pkg/server\"\n)\n",
+			imports:  "pkg/server/handler.go",
+			expected: true,
+		},
+		{
+			name:     "Go package of another module",
+			filePath: "cmd/main.go",
+			content:  "package main\n\nimport \"github.com/other/server\"\n",
+			imports:  "server/handler.go",
+			expected: false,
+		},
+		{
+			name:     "Relative JavaScript import",
+			filePath: "src/app.js",
+			content:  "import { api } from '../lib/api';\n",
+			imports:  "lib/api.ts",
+			expected: true,
+		},
+		{
+			name:     "JavaScript require",
+			filePath: "src/app.js",
+			content:  "const util = require('./util.js');\n",
+			imports:  "src/util.js",
+			expected: true,
+		},
+		{
+			name:     "Relative Python import",
+			filePath: "app/views/user.py",
+			content:  "from ..models import user\n",
+			imports:  "app/models.py",
+			expected: true,
+		},
+		{
+			name:     "Absolute Python import",
+			filePath: "app/views.py",
+			content:  "import app.models\n",
+			imports:  "app/models.py",
+			expected: true,
+		},
+		{
+			name:     "C include",
+			filePath: "src/buffer.c",
+			content:  "#include <stdio.h>\n#include \"buffer.h\"\n",
+			imports:  "src/buffer.h",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imported := importedPaths(tt.filePath, tt.content, "example.com/demo")
+			if got := importsFile(imported, tt.imports); got != tt.expected {
+				t.Errorf("Expected importsFile(%v, %s) = %v", imported, tt.imports, tt.expected)
+			}
+		})
+	}
+}
+
+func TestChangesetProcessor(t *testing.T) {
+	mockProvider := &MockLLMProvider{
+		GetCompletionFunc: func(prompt string) (string, error) {
+			return `{"issues":[
+				{"title":"Bug: Caller not updated","explanation":"Add takes three arguments","file":"app/app.go"},
+				{"title":"Style: Naming","explanation":"Details","file":"b/lib/lib.go"},
+				{"title":"Design: Coupling","explanation":"Details"}
+			],"diffs":[{"file":"app/app.go","diff":"+fix"},{"file":"unknown.go","diff":"+fix"}]}`, nil
+		},
+	}
+
+	group := ChangesetGroup{Requests: []*llm.ReviewRequest{
+		{FilePath: "lib/lib.go", FileContent: "package lib", FileDiff: "+func Add(a, b, c int) int"},
+		{FilePath: "app/app.go", FileContent: "package app", FileDiff: "+lib.Add(1, 2)"},
+	}}
+	results, err := ChangesetProcessor("/repo/root", mockProvider, llm.ReviewOptions{})(context.Background(), group)
+	if err != nil {
+		t.Fatalf("Failed to process group: %v", err)
+	}
+
+	if len(mockProvider.requests) != 1 || len(mockProvider.requests[0].Files) != 2 {
+		t.Fatalf("Expected one request with both files, got %+v", mockProvider.requests)
+	}
+	if got := len(results["app/app.go"].Issues); got != 1 {
+		t.Errorf("Expected 1 issue for app/app.go, got %d", got)
+	}
+	// Issues without a known file are attributed to the first file
+	if got := len(results["lib/lib.go"].Issues); got != 2 {
+		t.Errorf("Expected 2 issues for lib/lib.go, got %d", got)
+	}
+	if len(results["app/app.go"].Diffs) != 1 || len(results["lib/lib.go"].Diffs) != 0 {
+		t.Errorf("Expected only the diff for app/app.go to be kept, got %+v", results)
+	}
+}
@@ -78,9 +78,7 @@ func (p *ConcurrentProcessor) ProcessFiles(ctx context.Context, files []FileInfo
 				defer func() { <-semaphore }() // Release the slot when done
 			case <-ctx.Done():
 				// Context was cancelled, stop processing
-				if p.progressTracker != nil {
-					p.progressTracker.ErrorFile(file.Path, ctx.Err().Error())
-				}
+				p.trackFailure(ctx, file.Path, ctx.Err())
 				
 				// Safely add to errors map
 				errorsMutex.Lock()
@@ -99,9 +97,7 @@ func (p *ConcurrentProcessor) ProcessFiles(ctx context.Context, files []FileInfo
 			result, err := p.fileProcessor(ctx, file)
 			if err != nil {
 				// Update progress tracker
-				if p.progressTracker != nil {
-					p.progressTracker.ErrorFile(file.Path, err.Error())
-				}
+				p.trackFailure(ctx, file.Path, err)
 
 				// Safely add to errors map
 				errorsMutex.Lock()
@@ -134,6 +130,19 @@ func (p *ConcurrentProcessor) ProcessFiles(ctx context.Context, files []FileInfo
 	return results, errors
 }
 
+// trackFailure reports a file that was not reviewed to the progress tracker,
+// as cancelled when processing stopped because ctx is done
+func (p *ConcurrentProcessor) trackFailure(ctx context.Context, path string, err error) {
+	if p.progressTracker == nil {
+		return
+	}
+	if ctx.Err() != nil {
+		p.progressTracker.CancelFile(path)
+		return
+	}
+	p.progressTracker.ErrorFile(path, err.Error())
+}
+
 // ProcessFilesWithCallback processes multiple files concurrently and calls the callback function for each result or error
 func (p *ConcurrentProcessor) ProcessFilesWithCallback(
 	ctx context.Context,
@@ -169,9 +178,7 @@ func (p *ConcurrentProcessor) ProcessFilesWithCallback(
 				defer func() { <-semaphore }() // Release the slot when done
 			case <-ctx.Done():
 				// Context was cancelled, stop processing
-				if p.progressTracker != nil {
-					p.progressTracker.ErrorFile(file.Path, ctx.Err().Error())
-				}
+				p.trackFailure(ctx, file.Path, ctx.Err())
 				if errorCallback != nil {
 					errorCallback(file.Path, ctx.Err())
 				}
@@ -187,9 +194,7 @@ func (p *ConcurrentProcessor) ProcessFilesWithCallback(
 			result, err := p.fileProcessor(ctx, file)
 			if err != nil {
 				// Update progress tracker
-				if p.progressTracker != nil {
-					p.progressTracker.ErrorFile(file.Path, err.Error())
-				}
+				p.trackFailure(ctx, file.Path, err)
 
 				// Call the error callback
 				if errorCallback != nil {
@@ -273,4 +278,93 @@ func ConvertUnifiedChangedFilesToFileInfo(unifiedFiles []git.UnifiedChangedFile)
 		}
 	}
 	return files
-}
\ No newline at end of file
+}
+
+// ProcessGroups reviews groups of files concurrently with groupProcessor and
+// returns the results and errors of each file. When a group fails, all of its
+// files fail with the same error.
+func (p *ConcurrentProcessor) ProcessGroups(
+	ctx context.Context,
+	groups []ChangesetGroup,
+	groupProcessor GroupProcessor,
+) (map[string]*parse.ReviewResult, map[string]error) {
+	// Create a context that can be cancelled
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Progress is tracked for every file
+	if p.progressTracker != nil {
+		total := 0
+		for _, group := range groups {
+			total += len(group.Requests)
+		}
+		p.progressTracker.Start(total)
+	}
+
+	// Create a semaphore to limit concurrency
+	semaphore := make(chan struct{}, p.config.Concurrency.MaxTasks)
+
+	var mu sync.Mutex
+	results := make(map[string]*parse.ReviewResult)
+	errors := make(map[string]error)
+
+	fail := func(paths []string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, path := range paths {
+			p.trackFailure(ctx, path, err)
+			errors[path] = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, group := range groups {
+		wg.Add(1)
+		go func(group ChangesetGroup) {
+			defer wg.Done()
+			paths := group.Paths()
+
+			// Acquire a semaphore slot
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				fail(paths, ctx.Err())
+				return
+			}
+
+			if p.progressTracker != nil {
+				for _, path := range paths {
+					p.progressTracker.StartFile(path)
+				}
+			}
+
+			groupResults, err := groupProcessor(ctx, group)
+			if err != nil {
+				fail(paths, err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, path := range paths {
+				result := groupResults[path]
+				if result == nil {
+					result = &parse.ReviewResult{Issues: []parse.Issue{}, Diffs: []parse.FileDiff{}}
+				}
+				if p.progressTracker != nil {
+					p.progressTracker.CompleteFile(path, result.GetIssueCount())
+				}
+				results[path] = result
+			}
+		}(group)
+	}
+
+	wg.Wait()
+
+	if p.progressTracker != nil {
+		p.progressTracker.Finish()
+	}
+
+	return results, errors
+}
@@ -16,6 +16,7 @@ type MockTracker struct {
 	startedFiles map[string]bool
 	completedFiles map[string]int
 	errorFiles  map[string]string
+	cancelledFiles map[string]bool
 	finished    bool
 }
 
@@ -24,6 +25,7 @@ func NewMockTracker() *MockTracker {
 		startedFiles:   make(map[string]bool),
 		completedFiles: make(map[string]int),
 		errorFiles:     make(map[string]string),
+		cancelledFiles: make(map[string]bool),
 	}
 }
 
@@ -43,6 +45,10 @@ func (t *MockTracker) ErrorFile(path string, message string) {
 	t.errorFiles[path] = message
 }
 
+func (t *MockTracker) CancelFile(path string) {
+	t.cancelledFiles[path] = true
+}
+
 func (t *MockTracker) Finish() {
 	t.finished = true
 }
@@ -185,6 +191,34 @@ func TestConcurrentProcessor(t *testing.T) {
 		}
 	})
 
-	// Skip the context cancellation test as it's prone to race conditions
-	// and can cause the tests to hang
+	t.Run("CancelledFile", func(t *testing.T) {
+		// Use a single file so the mock tracker is not updated concurrently
+		tracker := NewMockTracker()
+
+		// Create a file processor that waits until the run is cancelled, like
+		// an in-flight provider request
+		fileProcessor := func(ctx context.Context, file FileInfo) (*parse.ReviewResult, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		processor := NewConcurrentProcessor(cfg, fileProcessor).WithProgressTracker(tracker)
+
+		// Cancel the run while the file is being processed
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(20*time.Millisecond, cancel)
+
+		_, errs := processor.ProcessFiles(ctx, []FileInfo{{Path: "file1.go", Status: "M", Type: "staged"}})
+
+		// Check that the file is reported as cancelled rather than failed
+		if !errors.Is(errs["file1.go"], context.Canceled) {
+			t.Errorf("Expected file1.go to be cancelled, got %v", errs["file1.go"])
+		}
+		if !tracker.cancelledFiles["file1.go"] {
+			t.Error("Expected file1.go to be marked as cancelled")
+		}
+		if len(tracker.errorFiles) != 0 {
+			t.Errorf("Expected no error files, got %d", len(tracker.errorFiles))
+		}
+	})
 }
\ No newline at end of file
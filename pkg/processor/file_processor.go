@@ -3,54 +3,40 @@ package processor
 import (
 	"context"
 	"fmt"
-	"strings"
-	"time"
 
+	"github.com/niels/git-llm-review/pkg/extractor"
 	"github.com/niels/git-llm-review/pkg/git"
 	"github.com/niels/git-llm-review/pkg/llm"
-	"github.com/niels/git-llm-review/pkg/llm/promptlog"
 	"github.com/niels/git-llm-review/pkg/logging"
 	"github.com/niels/git-llm-review/pkg/parse"
-	"github.com/niels/git-llm-review/pkg/prompt"
 )
 
-// ReviewFileProcessor creates a FileProcessor that processes a file for code review
+// ReviewFileProcessor creates a FileProcessor that processes a file for code review.
+// The options are used for every review request, such as additional instructions
+// for the prompt. Each provider builds the prompt in its own format, and may look
+// up type definitions in the repository while reviewing.
 func ReviewFileProcessor(
 	repoRoot string,
 	repoDetector git.RepositoryDetector,
 	provider llm.Provider,
-	providerName string,
+	options llm.ReviewOptions,
 ) FileProcessor {
+	// The extractors are shared by all files, so that each language is only
+	// set up once
+	extractors := extractor.NewSet(repoRoot)
+
 	return func(ctx context.Context, file FileInfo) (*parse.ReviewResult, error) {
 		// Log the start of processing
 		logging.InfoWith("Processing file for review", map[string]interface{}{
 			"file": file.Path,
 		})
 
-		// Get the diff for the file
-		var diff string
-		var err error
-
-		if file.Type == "unstaged" || file.Type == "unified" {
-			// Get diff for unstaged or unified file
-			unifiedFile := git.UnifiedChangedFile{
-				Path:           file.Path,
-				StagedStatus:   file.Status,
-				UnstagedStatus: file.Status,
-			}
-			diff, err = repoDetector.GetUnifiedFileDiff(repoRoot, unifiedFile)
-		} else {
-			// Get diff for staged file
-			diff, err = repoDetector.GetFileDiff(repoRoot, file.Path, true)
-		}
-
+		// Create the review request with the file diff and content
+		reviewRequest, err := NewReviewRequest(repoRoot, repoDetector, file, options)
 		if err != nil {
-			logging.ErrorWith("Failed to get diff for file", map[string]interface{}{
-				"file":  file.Path,
-				"error": err.Error(),
-			})
-			return nil, fmt.Errorf("failed to get diff for file %s: %w", file.Path, err)
+			return nil, err
 		}
+		reviewRequest.Extractor = extractors.ForFile(file.Path)
 
 		// Check if the context has been cancelled
 		select {
@@ -60,60 +46,12 @@ func ReviewFileProcessor(
 			// Continue processing
 		}
 
-		// Get the full file content
-		fileContent, err := repoDetector.GetFileContent(repoRoot, file.Path)
-		if err != nil {
-			logging.WarnWith("Failed to get full file content, proceeding with just the diff", map[string]interface{}{
-				"file":  file.Path,
-				"error": err.Error(),
-			})
-			// Continue with empty file content if we couldn't fetch it
-			fileContent = ""
-		}
-
-		// Generate prompt for code review
-		// Create a proper review request with file diff and content
-		reviewRequest := &llm.ReviewRequest{
-			FilePath:    file.Path,
-			FileDiff:    diff,
-			FileContent: fileContent,
-			Options: llm.ReviewOptions{
-				Timeout:              30 * time.Second, // Default timeout
-				MaxTokens:            1024,
-				Temperature:          0.1,
-				IncludeExplanations: true,
-			},
-		}
-
-		// Use the full-featured prompt generation that properly handles templates
-		var providerType prompt.ProviderType
-		switch strings.ToLower(providerName) {
-		case "anthropic":
-			providerType = prompt.ProviderAnthropic
-		case "openai":
-			providerType = prompt.ProviderOpenAI
-		default:
-			providerType = prompt.ProviderDefault
-		}
-		
-		reviewPrompt := prompt.CreatePrompt(reviewRequest, providerType)
-
-		// Log the prompt if the prompt logger is enabled
-		err = promptlog.LogPrompt(provider.Name(), file.Path, reviewPrompt)
-		if err != nil {
-			// Just log the error but continue with the process
-			logging.ErrorWith("Failed to log prompt", map[string]interface{}{
-				"file":  file.Path,
-				"error": err.Error(),
-			})
-		}
-
 		// Get response from LLM
 		logging.InfoWith("Sending code review request to LLM", map[string]interface{}{
 			"file": file.Path,
 		})
 
-		response, err := provider.GetCompletion(reviewPrompt)
+		completion, err := provider.ReviewCode(ctx, reviewRequest)
 		if err != nil {
 			logging.ErrorWith("Failed to get LLM response", map[string]interface{}{
 				"file":  file.Path,
@@ -131,13 +69,118 @@ func ReviewFileProcessor(
 		}
 
 		// Parse the response
-		reviewResult := parse.ParseReview(response)
+		reviewResult := ReviewResult(completion)
+
+		// A review with a single focus found only issues of that focus
+		if len(options.Focus) == 1 {
+			reviewResult.SetFocus(options.Focus[0])
+		}
 
 		logging.InfoWith("Completed review for file", map[string]interface{}{
 			"file":        file.Path,
 			"issue_count": reviewResult.GetIssueCount(),
+			"provider":    reviewResult.Provider,
 		})
 
 		return reviewResult, nil
 	}
-}
\ No newline at end of file
+}
+
+// FocusPass is one pass of a review that looks at each file once per focus
+type FocusPass struct {
+	// Focus is the focus of the pass, such as "security"
+	Focus string
+	// Process reviews a file with this focus
+	Process FileProcessor
+}
+
+// MultiPassFileProcessor creates a FileProcessor that reviews each file once
+// per pass, one after the other, and merges the results. Every issue is
+// tagged with the focus of the pass that found it. The file fails if any
+// pass fails.
+func MultiPassFileProcessor(passes []FocusPass) FileProcessor {
+	return func(ctx context.Context, file FileInfo) (*parse.ReviewResult, error) {
+		merged := &parse.ReviewResult{Issues: []parse.Issue{}, Diffs: []parse.FileDiff{}}
+		for _, pass := range passes {
+			result, err := pass.Process(ctx, file)
+			if err != nil {
+				return nil, fmt.Errorf("%s review: %w", pass.Focus, err)
+			}
+			result.SetFocus(pass.Focus)
+			merged.Merge(result)
+		}
+		return merged, nil
+	}
+}
+
+// NewReviewRequest creates the review request for a file from its diff and
+// full content. The request has no extractor, so no tools are offered.
+func NewReviewRequest(
+	repoRoot string,
+	repoDetector git.RepositoryDetector,
+	file FileInfo,
+	options llm.ReviewOptions,
+) (*llm.ReviewRequest, error) {
+	// Get the diff for the file
+	var diff string
+	var err error
+
+	if file.Type == "unstaged" || file.Type == "unified" {
+		// Get diff for unstaged or unified file
+		unifiedFile := git.UnifiedChangedFile{
+			Path:           file.Path,
+			StagedStatus:   file.Status,
+			UnstagedStatus: file.Status,
+		}
+		diff, err = repoDetector.GetUnifiedFileDiff(repoRoot, unifiedFile)
+	} else {
+		// Get diff for staged file
+		diff, err = repoDetector.GetFileDiff(repoRoot, file.Path, true)
+	}
+
+	if err != nil {
+		logging.ErrorWith("Failed to get diff for file", map[string]interface{}{
+			"file":  file.Path,
+			"error": err.Error(),
+		})
+		return nil, fmt.Errorf("failed to get diff for file %s: %w", file.Path, err)
+	}
+
+	// Get the full file content
+	fileContent, err := repoDetector.GetFileContent(repoRoot, file.Path)
+	if err != nil {
+		logging.WarnWith("Failed to get full file content, proceeding with just the diff", map[string]interface{}{
+			"file":  file.Path,
+			"error": err.Error(),
+		})
+		// Continue with empty file content if we couldn't fetch it
+		fileContent = ""
+	}
+
+	reviewRequest := &llm.ReviewRequest{
+		FilePath:    file.Path,
+		FileDiff:    diff,
+		FileContent: fileContent,
+		// Generation settings come from each provider's configuration
+		Options: options,
+	}
+	reviewRequest.Options.IncludeExplanations = true
+	return reviewRequest, nil
+}
+
+// ReviewResult parses the review in a provider's response
+func ReviewResult(completion *llm.ReviewResponse) *parse.ReviewResult {
+	reviewResult := parse.ParseReview(completion.Review)
+
+	// Record which provider produced the review, which may be a fallback
+	if usedProvider, ok := completion.Metadata[llm.MetadataProvider].(string); ok {
+		reviewResult.Provider = usedProvider
+	}
+
+	// Keep the model's reasoning for the report
+	if reasoning, ok := completion.Metadata[llm.MetadataReasoning].(string); ok {
+		reviewResult.Reasoning = reasoning
+	}
+
+	return reviewResult
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/niels/git-llm-review/pkg/config"
 	"github.com/niels/git-llm-review/pkg/git"
 	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/prompt"
 )
 
 // MockRepositoryDetector is a mock implementation of git.RepositoryDetector
@@ -59,9 +60,13 @@ func (m *MockRepositoryDetector) GetFileContent(dir string, filePath string) (st
 	return "", nil
 }
 
-// MockLLMProvider is a mock implementation of llm.Provider
+// MockLLMProvider is a mock implementation of llm.Provider. Like the real
+// providers, it reviews code by sending the prompt for the request.
 type MockLLMProvider struct {
 	GetCompletionFunc func(prompt string) (string, error)
+
+	// requests records the review requests received
+	requests []*llm.ReviewRequest
 }
 
 func (m *MockLLMProvider) Name() string {
@@ -73,10 +78,15 @@ func (m *MockLLMProvider) ValidateConfig() error {
 }
 
 func (m *MockLLMProvider) ReviewCode(ctx context.Context, request *llm.ReviewRequest) (*llm.ReviewResponse, error) {
-	return nil, nil
+	m.requests = append(m.requests, request)
+	completion, err := m.GetCompletion(ctx, prompt.CreatePrompt(request, prompt.ProviderOpenAI))
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ReviewResponse{Review: completion}, nil
 }
 
-func (m *MockLLMProvider) GetCompletion(prompt string) (string, error) {
+func (m *MockLLMProvider) GetCompletion(ctx context.Context, prompt string) (string, error) {
 	if m.GetCompletionFunc != nil {
 		return m.GetCompletionFunc(prompt)
 	}
@@ -114,7 +124,7 @@ func TestReviewFileProcessor_WithFileContent(t *testing.T) {
 		"/repo/root",
 		mockDetector,
 		mockProvider,
-		"openai",
+		llm.ReviewOptions{},
 	)
 
 	// Create a test file info
@@ -160,7 +170,7 @@ func TestReviewFileProcessor_MissingFileContent(t *testing.T) {
 		"/repo/root",
 		mockDetector,
 		mockProvider,
-		"openai",
+		llm.ReviewOptions{},
 	)
 
 	// Create a test file info
@@ -181,3 +191,108 @@ func TestReviewFileProcessor_MissingFileContent(t *testing.T) {
 		t.Error("Expected non-nil result even with missing file content")
 	}
 }
+
+func TestReviewFileProcessor_AdditionalInstructions(t *testing.T) {
+	// Create mocks
+	mockDetector := &MockRepositoryDetector{
+		GetFileDiffFunc: func(dir string, filePath string, staged bool) (string, error) {
+			return "diff --git a/test.go b/test.go\n+func hello() {}\n", nil
+		},
+		GetFileContentFunc: func(dir string, filePath string) (string, error) {
+			return "package test\nfunc hello() {}\n", nil
+		},
+	}
+
+	// Capture the prompt sent to the provider
+	var sentPrompt string
+	mockProvider := &MockLLMProvider{
+		GetCompletionFunc: func(prompt string) (string, error) {
+			sentPrompt = prompt
+			return "{\"issues\":[]}", nil
+		},
+	}
+
+	// Create the file processor with instructions from the active profile
+	processor := ReviewFileProcessor(
+		"/repo/root",
+		mockDetector,
+		mockProvider,
+		llm.ReviewOptions{AdditionalInstructions: "Only report bugs"},
+	)
+
+	if _, err := processor(context.Background(), FileInfo{Path: "test.go", Type: "staged", Status: "M"}); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	// Assert that the instructions were added to the prompt
+	if !strings.Contains(sentPrompt, "Only report bugs") {
+		t.Error("Additional instructions were not included in the prompt")
+	}
+}
+
+func TestReviewFileProcessor_SharedExtractors(t *testing.T) {
+	mockDetector := &MockRepositoryDetector{
+		GetFileDiffFunc: func(dir string, filePath string, staged bool) (string, error) {
+			return "diff --git a/test.go b/test.go\n+func hello() {}\n", nil
+		},
+		GetFileContentFunc: func(dir string, filePath string) (string, error) {
+			return "", nil
+		},
+	}
+	mockProvider := &MockLLMProvider{
+		GetCompletionFunc: func(prompt string) (string, error) {
+			return "{\"issues\":[]}", nil
+		},
+	}
+
+	processor := ReviewFileProcessor("/repo/root", mockDetector, mockProvider, llm.ReviewOptions{})
+	for _, path := range []string{"a.go", "b.go", "schema.proto"} {
+		if _, err := processor(context.Background(), FileInfo{Path: path, Type: "staged", Status: "M"}); err != nil {
+			t.Fatalf("Failed to process %s: %v", path, err)
+		}
+	}
+
+	// Files of the same language share an extractor
+	first, second, proto := mockProvider.requests[0], mockProvider.requests[1], mockProvider.requests[2]
+	if first.Extractor == nil || first.Extractor != second.Extractor {
+		t.Error("Expected the Go files to share an extractor")
+	}
+	if proto.Extractor != nil {
+		t.Error("Expected no extractor for an unsupported language")
+	}
+}
+
+func TestMultiPassFileProcessor(t *testing.T) {
+	mockDetector := &MockRepositoryDetector{
+		GetFileDiffFunc: func(dir string, filePath string, staged bool) (string, error) {
+			return "diff --git a/test.go b/test.go\n+func hello() {}\n", nil
+		},
+		GetFileContentFunc: func(dir string, filePath string) (string, error) {
+			return "", nil
+		},
+	}
+	mockProvider := &MockLLMProvider{
+		GetCompletionFunc: func(prompt string) (string, error) {
+			return `{"issues":[{"title":"Bug: Something","explanation":"Details"}],"diffs":[{"file":"test.go","diff":"+fix"}]}`, nil
+		},
+	}
+
+	passes := []FocusPass{
+		{Focus: "security", Process: ReviewFileProcessor("/repo/root", mockDetector, mockProvider, llm.ReviewOptions{Focus: []string{"security"}})},
+		{Focus: "tests", Process: ReviewFileProcessor("/repo/root", mockDetector, mockProvider, llm.ReviewOptions{Focus: []string{"tests"}})},
+	}
+	result, err := MultiPassFileProcessor(passes)(context.Background(), FileInfo{Path: "test.go", Type: "staged", Status: "M"})
+	if err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	if len(mockProvider.requests) != 2 {
+		t.Fatalf("Expected a request per pass, got %d", len(mockProvider.requests))
+	}
+	if len(result.Issues) != 2 || result.Issues[0].Focus != "security" || result.Issues[1].Focus != "tests" {
+		t.Errorf("Expected an issue tagged with each focus, got %+v", result.Issues)
+	}
+	if len(result.Diffs) != 1 {
+		t.Errorf("Expected one diff for the file, got %d", len(result.Diffs))
+	}
+}
@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// jsImportPattern matches relative imports and requires in JavaScript
+	// and TypeScript
+	jsImportPattern = regexp.MustCompile(`(?:\bfrom\s+|\brequire\(\s*|\bimport\(\s*|\bimport\s+)['"](\.{1,2}/[^'"]+)['"]`)
+
+	// pythonFromPattern matches "from module import name"
+	pythonFromPattern = regexp.MustCompile(`(?m)^\s*from\s+(\.*[\w.]*)\s+import\b`)
+
+	// pythonImportPattern matches "import module"
+	pythonImportPattern = regexp.MustCompile(`(?m)^\s*import\s+([\w.]+)`)
+
+	// includePattern matches local includes in C and C++
+	includePattern = regexp.MustCompile(`(?m)^\s*#\s*include\s+"([^"]+)"`)
+)
+
+// importedPaths returns the paths, relative to the repository root, that the
+// file at filePath imports: files, files without their extension, or
+// directories. Go imports are resolved with modulePath, the module at the
+// repository root. Imports that cannot be resolved are left out.
+func importedPaths(filePath, content, modulePath string) []string {
+	dir := path.Dir(filePath)
+	var imported []string
+
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".go":
+		if modulePath == "" {
+			return nil
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filePath, content, parser.ImportsOnly)
+		if err != nil {
+			return nil
+		}
+		for _, spec := range file.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err == nil && strings.HasPrefix(importPath, modulePath+"/") {
+				imported = append(imported, strings.TrimPrefix(importPath, modulePath+"/"))
+			}
+		}
+
+	case ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".vue":
+		for _, match := range jsImportPattern.FindAllStringSubmatch(content, -1) {
+			imported = append(imported, trimExt(path.Join(dir, match[1])))
+		}
+
+	case ".py":
+		for _, match := range pythonFromPattern.FindAllStringSubmatch(content, -1) {
+			imported = append(imported, pythonModulePath(dir, match[1]))
+		}
+		for _, match := range pythonImportPattern.FindAllStringSubmatch(content, -1) {
+			imported = append(imported, pythonModulePath(dir, match[1]))
+		}
+
+	case ".c", ".cc", ".cpp", ".cxx", ".h", ".hpp":
+		for _, match := range includePattern.FindAllStringSubmatch(content, -1) {
+			imported = append(imported, path.Join(dir, match[1]), path.Clean(match[1]))
+		}
+	}
+
+	return imported
+}
+
+// pythonModulePath returns the path of a Python module, such as "pkg.mod" or
+// the relative "..mod", imported by a file in dir
+func pythonModulePath(dir, module string) string {
+	dots := len(module) - len(strings.TrimLeft(module, "."))
+	name := strings.ReplaceAll(module[dots:], ".", "/")
+	if dots == 0 {
+		return name
+	}
+
+	// One dot is the package of the file, and every further dot its parent
+	base := dir
+	for i := 1; i < dots; i++ {
+		base = path.Dir(base)
+	}
+	return path.Join(base, name)
+}
+
+// importsFile reports whether any of the imported paths refers to the file at
+// filePath, to the file without its extension, or to its directory
+func importsFile(imported []string, filePath string) bool {
+	for _, importedPath := range imported {
+		if importedPath == filePath || importedPath == trimExt(filePath) || importedPath == path.Dir(filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimExt returns filePath without its extension
+func trimExt(filePath string) string {
+	return strings.TrimSuffix(filePath, path.Ext(filePath))
+}
+
+// goModulePath returns the path of the Go module at repoRoot, or an empty
+// string if there is none
+func goModulePath(repoRoot string) string {
+	data, err := os.ReadFile(filepath.Join(repoRoot, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
@@ -22,6 +22,8 @@ const (
 	StatusCompleted Status = "completed"
 	// StatusError indicates an error occurred while processing the file
 	StatusError Status = "error"
+	// StatusCancelled indicates processing was stopped before the file was reviewed
+	StatusCancelled Status = "cancelled"
 )
 
 // FileProgress represents the progress of a single file
@@ -44,6 +46,8 @@ type Tracker interface {
 	CompleteFile(path string, issueCount int)
 	// ErrorFile marks a file as having an error with an error message
 	ErrorFile(path string, message string)
+	// CancelFile marks a file as cancelled before it was reviewed
+	CancelFile(path string)
 	// Finish completes the progress tracking
 	Finish()
 }
@@ -60,6 +64,7 @@ type ConsoleTracker struct {
 	lastUpdate   time.Time
 	completed    int
 	errors       int
+	cancelled    int
 }
 
 // NewConsoleTracker creates a new console progress tracker
@@ -87,6 +92,7 @@ func (t *ConsoleTracker) Start(totalFiles int) {
 	t.startTime = time.Now()
 	t.completed = 0
 	t.errors = 0
+	t.cancelled = 0
 
 	fmt.Fprintf(t.writer, "Starting code review of %d files...\n", totalFiles)
 }
@@ -151,6 +157,27 @@ func (t *ConsoleTracker) ErrorFile(path string, message string) {
 	t.updateProgress()
 }
 
+// CancelFile marks a file as cancelled before it was reviewed
+func (t *ConsoleTracker) CancelFile(path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if progress, ok := t.fileProgress[path]; ok {
+		progress.Status = StatusCancelled
+		progress.EndTime = time.Now()
+	} else {
+		t.fileProgress[path] = &FileProgress{
+			Path:      path,
+			Status:    StatusCancelled,
+			StartTime: time.Now(),
+			EndTime:   time.Now(),
+		}
+	}
+
+	t.cancelled++
+	t.updateProgress()
+}
+
 // Finish completes the progress tracking
 func (t *ConsoleTracker) Finish() {
 	t.mu.Lock()
@@ -158,6 +185,11 @@ func (t *ConsoleTracker) Finish() {
 
 	duration := time.Since(t.startTime).Round(time.Second)
 	fmt.Fprintf(t.writer, "\nCode review completed in %s\n", duration)
+	if t.cancelled > 0 {
+		fmt.Fprintf(t.writer, "Processed %d files: %d completed, %d errors, %d cancelled\n",
+			t.totalFiles, t.completed, t.errors, t.cancelled)
+		return
+	}
 	fmt.Fprintf(t.writer, "Processed %d files: %d completed, %d errors\n", 
 		t.totalFiles, t.completed, t.errors)
 }
@@ -174,7 +206,8 @@ func (t *ConsoleTracker) updateProgress() {
 	fmt.Fprint(t.writer, "\r\033[K")
 
 	// Calculate progress percentage
-	progress := float64(t.completed+t.errors) / float64(t.totalFiles)
+	done := t.completed + t.errors + t.cancelled
+	progress := float64(done) / float64(t.totalFiles)
 	progressBar := createProgressBar(progress, 20)
 
 	// Get the current spinner frame
@@ -191,7 +224,7 @@ func (t *ConsoleTracker) updateProgress() {
 
 	// Format the progress message
 	progressMsg := fmt.Sprintf("%s %s %d/%d files (%.0f%%)", 
-		spinnerChar, progressBar, t.completed+t.errors, t.totalFiles, progress*100)
+		spinnerChar, progressBar, done, t.totalFiles, progress*100)
 
 	// Add currently processing files
 	if len(processingFiles) > 0 {
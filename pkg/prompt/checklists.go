@@ -0,0 +1,191 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+// languageNames are the names of languages as shown in the prompt
+var languageNames = map[string]string{
+	"go":         "Go",
+	"javascript": "JavaScript",
+	"typescript": "TypeScript",
+	"python":     "Python",
+	"java":       "Java",
+	"ruby":       "Ruby",
+	"rust":       "Rust",
+	"cpp":        "C/C++",
+}
+
+// languageContexts describe what matters most when reviewing a language
+var languageContexts = map[string]string{
+	"go":         "When reviewing Go code, consider Go's idioms and best practices such as error handling patterns, proper use of interfaces, and following the Go style guide.",
+	"javascript": "When reviewing JavaScript/TypeScript code, look for common issues like type safety, async/await patterns, and modern ES6+ features.",
+	"typescript": "When reviewing JavaScript/TypeScript code, look for common issues like type safety, async/await patterns, and modern ES6+ features.",
+	"python":     "When reviewing Python code, consider PEP 8 style guidelines, proper exception handling, and Pythonic idioms.",
+	"java":       "When reviewing Java code, look for proper exception handling, design patterns, and object-oriented principles.",
+	"ruby":       "When reviewing Ruby code, consider Ruby idioms, proper use of blocks, and following the Ruby style guide.",
+	"rust":       "When reviewing Rust code, look for proper memory management, use of ownership/borrowing, and idiomatic Rust patterns.",
+	"cpp":        "When reviewing C/C++ code, look for memory safety, undefined behavior, and clear ownership of resources.",
+}
+
+// builtinChecklists list the pitfalls that reviews of each language check for
+var builtinChecklists = map[string][]string{
+	"go": {
+		"Errors are returned with context, wrapped with %w so that callers can use errors.Is and errors.As, and never silently ignored",
+		"Goroutines have a way to stop: channel sends and receives cannot block forever, and every started goroutine is waited for or cancelled",
+		"A context.Context received by the caller is passed on to the calls that accept one, instead of context.Background() or context.TODO()",
+		"defer inside a loop holds every resource until the function returns; close resources in the loop body or in a helper function",
+		"Loop variables captured by goroutines or closures hold the expected value",
+		"Maps are initialized before being written to, and are not read and written by several goroutines without synchronization",
+		"HTTP response bodies, files and other io.Closers are closed on every path",
+		"Values containing a sync.Mutex or sync.WaitGroup are not copied",
+	},
+	"python": {
+		"Function defaults are not mutable values such as [], {} or set(), which are shared between calls",
+		"Exceptions are not swallowed by bare except: or except Exception: pass",
+		"Files, locks and connections are managed with a with statement",
+		"Closures created in a loop do not rely on late binding of the loop variable",
+		"Lists and dicts are not modified while being iterated over",
+		"Values are compared with == rather than is, except for None, True and False",
+	},
+	"javascript": {
+		"Every promise is awaited, returned or has a rejection handler; no floating promises",
+		"async callbacks are not passed to forEach or other functions that ignore the returned promise",
+		"Independent awaits in a loop are not serialized where Promise.all would do",
+		"Comparisons use === and !== rather than == and !=",
+		"this is bound as expected in callbacks and event handlers",
+		"Event listeners, timers and subscriptions are removed when no longer needed",
+	},
+	"typescript": {
+		"Every promise is awaited, returned or has a rejection handler; no floating promises",
+		"async callbacks are not passed to forEach or other functions that ignore the returned promise",
+		"Independent awaits in a loop are not serialized where Promise.all would do",
+		"any, type assertions and non-null assertions (!) do not hide real type errors",
+		"Values that may be null or undefined are checked before use",
+		"Event listeners, timers and subscriptions are removed when no longer needed",
+	},
+	"cpp": {
+		"Buffer and array accesses stay in bounds, and string copies are limited to the destination size",
+		"Memory is not used after being freed, freed twice, or leaked on error paths",
+		"Ownership is clear: prefer RAII and smart pointers over manual new/delete and malloc/free",
+		"Variables and struct members are initialized before being read",
+		"Size calculations cannot overflow, and signed and unsigned values are not mixed in comparisons",
+		"Results of allocations and system calls are checked",
+		"Pointers and references do not outlive the objects they refer to, including iterators invalidated by container changes",
+	},
+	"java": {
+		"Streams, connections and other resources are closed with try-with-resources",
+		"Exceptions are not swallowed, and are not caught as Exception or Throwable without reason",
+		"Values that may be null are checked, or modelled with Optional",
+		"equals and hashCode are overridden together",
+		"Shared mutable state is synchronized or uses concurrent collections",
+	},
+	"rust": {
+		"unwrap and expect are not used where an error can occur at runtime; errors are propagated with ?",
+		"unsafe blocks document and uphold the invariants they rely on",
+		"Async code does not block the executor with blocking I/O or long computations",
+		"clone is not used only to get past the borrow checker where a reference would do",
+		"Integer arithmetic cannot overflow, or uses checked, wrapping or saturating operations",
+	},
+}
+
+// Checklists returns the review checklist of each language: the built-in
+// checklists, extended or replaced as configured. Languages in the
+// configuration are language names, such as python, or file extensions, such
+// as py.
+func Checklists(configured map[string]config.ChecklistConfig) (map[string][]string, error) {
+	checklists := make(map[string][]string, len(builtinChecklists))
+	for language, items := range builtinChecklists {
+		checklists[language] = items
+	}
+
+	for name, checklist := range configured {
+		language := normalizeLanguage(name)
+		if language == "" {
+			return nil, fmt.Errorf("unknown language %q in checklists (expected one of: %s)",
+				name, strings.Join(knownLanguages(), ", "))
+		}
+
+		var items []string
+		if !checklist.Replace {
+			items = append(items, checklists[language]...)
+		}
+		items = append(items, checklist.Items...)
+		checklists[language] = items
+	}
+	return checklists, nil
+}
+
+// FormatChecklist returns the section of the system prompt that holds the
+// checklists for the languages of the reviewed files, or an empty string if
+// none of them has one
+func FormatChecklist(request *llm.ReviewRequest) string {
+	var sections []string
+	seen := make(map[string]bool)
+	for _, filePath := range request.Paths() {
+		language := GetLanguageFromFilePath(filePath)
+		if seen[language] {
+			continue
+		}
+		seen[language] = true
+		if section := formatChecklist(language, request.Options.Checklists[language]); section != "" {
+			sections = append(sections, section)
+		}
+	}
+	return strings.Join(sections, "\n")
+}
+
+// formatChecklist returns the checklist section for a language, or an empty
+// string if it has no items
+func formatChecklist(language string, items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+
+	name := languageNames[language]
+	if name == "" {
+		name = language
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s REVIEW CHECKLIST:\n", strings.ToUpper(name))
+	if intro := languageContexts[language]; intro != "" {
+		sb.WriteString(intro + "\n")
+	}
+	sb.WriteString("Check the changes for these common pitfalls, and report only those that actually occur:\n")
+	for _, item := range items {
+		sb.WriteString("- " + item + "\n")
+	}
+	return sb.String()
+}
+
+// normalizeLanguage returns the language named by a language name or a file
+// extension, or an empty string if there is none
+func normalizeLanguage(name string) string {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	for _, language := range languageByExtension {
+		if language == name {
+			return language
+		}
+	}
+	return languageByExtension[name]
+}
+
+// knownLanguages returns the names of all languages in sorted order
+func knownLanguages() []string {
+	seen := make(map[string]bool)
+	var languages []string
+	for _, language := range languageByExtension {
+		if !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
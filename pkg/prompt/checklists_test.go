@@ -0,0 +1,123 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+func TestChecklists(t *testing.T) {
+	t.Run("Built-in checklists", func(t *testing.T) {
+		checklists, err := Checklists(nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, language := range []string{"go", "python", "javascript", "typescript", "cpp"} {
+			if len(checklists[language]) == 0 {
+				t.Errorf("Expected a built-in checklist for %s", language)
+			}
+		}
+	})
+
+	t.Run("Extend and replace", func(t *testing.T) {
+		checklists, err := Checklists(map[string]config.ChecklistConfig{
+			"go": {Items: []string{"Handlers check permissions"}},
+			"py": {Items: []string{"Queries use parameters"}, Replace: true},
+			"c":  {Replace: true},
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		goItems := checklists["go"]
+		if len(goItems) != len(builtinChecklists["go"])+1 || goItems[len(goItems)-1] != "Handlers check permissions" {
+			t.Errorf("Expected the built-in Go checklist to be extended, got %v", goItems)
+		}
+		if len(checklists["python"]) != 1 || checklists["python"][0] != "Queries use parameters" {
+			t.Errorf("Expected the Python checklist to be replaced, got %v", checklists["python"])
+		}
+		if len(checklists["cpp"]) != 0 {
+			t.Errorf("Expected no C/C++ checklist, got %v", checklists["cpp"])
+		}
+		if len(builtinChecklists["go"]) == len(goItems) {
+			t.Error("Expected the built-in checklists to be left unchanged")
+		}
+	})
+
+	t.Run("Unknown language", func(t *testing.T) {
+		_, err := Checklists(map[string]config.ChecklistConfig{"cobol": {Items: []string{"x"}}})
+		if err == nil || !strings.Contains(err.Error(), "cobol") {
+			t.Errorf("Expected an error naming the language, got: %v", err)
+		}
+	})
+}
+
+func TestFormatChecklist(t *testing.T) {
+	builtin, err := Checklists(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	request := func(filePath string, checklists map[string][]string) *llm.ReviewRequest {
+		return &llm.ReviewRequest{
+			FilePath: filePath,
+			Options:  llm.ReviewOptions{Checklists: checklists},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		request  *llm.ReviewRequest
+		contains []string
+		empty    bool
+	}{
+		{
+			name:     "Go file",
+			request:  request("pkg/server/handler.go", builtin),
+			contains: []string{"GO REVIEW CHECKLIST:", "%w", "Goroutines", "context.Context", "defer inside a loop"},
+		},
+		{
+			name:     "Python file",
+			request:  request("app/views.py", builtin),
+			contains: []string{"PYTHON REVIEW CHECKLIST:", "mutable values"},
+		},
+		{
+			name:     "C header",
+			request:  request("src/buffer.h", builtin),
+			contains: []string{"C/C++ REVIEW CHECKLIST:", "freed twice"},
+		},
+		{
+			name:     "Configured checklist",
+			request:  request("web/app.js", map[string][]string{"javascript": {"Inputs are escaped"}}),
+			contains: []string{"JAVASCRIPT REVIEW CHECKLIST:", "- Inputs are escaped"},
+		},
+		{
+			name:    "Language without a checklist",
+			request: request("README.md", builtin),
+			empty:   true,
+		},
+		{
+			name:    "No checklists",
+			request: request("main.go", nil),
+			empty:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatChecklist(tt.request)
+			if tt.empty {
+				if got != "" {
+					t.Errorf("Expected no checklist, got:\n%s", got)
+				}
+				return
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("Expected the checklist to contain %q, got:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainHeading starts the prompt that asks for the explanation of a
+// change, so that it can be told apart from review prompts. Providers only
+// add to the end of the explain system prompt, so it starts every prompt.
+var ExplainHeading = strings.TrimSpace(getBaseSystemPrompt(SystemPromptExplain))
+
+// explainMaxDiffChars limits the size of the diff in an explain prompt
+const explainMaxDiffChars = 60000
+
+// maxCommitMessages limits the commit messages in an explain or pull
+// request prompt
+const maxCommitMessages = 30
+
+// ExplainRequest describes a change to explain
+type ExplainRequest struct {
+	// Subject names the change, such as "commit 1a2b3c4 Fix retries"
+	Subject string
+	// Messages are the messages of the commits in the change, newest first
+	Messages []string
+	// Stat lists the changed files, like git diff --stat
+	Stat string
+	// Diff is the patch of the change
+	Diff string
+	// FocusPath and FocusLine name the line the reader asked about, and
+	// FocusCode is the code around it
+	FocusPath string
+	FocusLine int
+	FocusCode string
+	// Diagram asks for a mermaid diagram of the calls involved in the change
+	Diagram bool
+}
+
+// CreateExplainPrompt creates the prompt that asks for a plain-language
+// walkthrough of a change, for readers who do not know the code yet
+func CreateExplainPrompt(request ExplainRequest, providerType ProviderType) string {
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(GetSystemPrompt(providerType, SystemPromptExplain)))
+	sb.WriteString("\n\nExplain the following change to a developer who is new to this code base. ")
+	sb.WriteString("Use plain language, name the functions and types involved, and do not review the code or suggest improvements.\n\n")
+
+	sb.WriteString("Answer in Markdown with these sections:\n")
+	sb.WriteString("## Summary\nTwo or three sentences on what the change does and why.\n\n")
+	sb.WriteString("## Walkthrough\nThe changes step by step, in the order that makes them easiest to follow, grouping files that belong together.\n\n")
+	sb.WriteString("## Why it matters\nHow the change affects behavior, callers, data and configuration, and what a reader should keep in mind when working on this code.\n")
+	if request.Diagram {
+		sb.WriteString("\n## Diagram\nA mermaid sequenceDiagram of the interaction the change affects, or a flowchart of the calls between the changed functions if that is clearer, in a ```mermaid code block. Keep it under 15 nodes or messages.\n")
+	}
+	if request.FocusPath != "" {
+		fmt.Fprintf(&sb, "\nThe reader asked about line %d of %s. Start the walkthrough with what the change means for that line.\n", request.FocusLine, request.FocusPath)
+	}
+
+	sb.WriteString("\nCHANGE: " + request.Subject + "\n")
+	if len(request.Messages) > 0 {
+		sb.WriteString("\nCOMMIT MESSAGES, NEWEST FIRST:\n" + formatCommitMessages(request.Messages))
+	}
+	if request.FocusCode != "" {
+		fmt.Fprintf(&sb, "\nCODE AROUND LINE %d OF %s:\n%s\n", request.FocusLine, request.FocusPath,
+			FormatCodeBlock(strings.TrimRight(request.FocusCode, "\n"), GetLanguageFromFilePath(request.FocusPath)))
+	}
+	if stat := strings.TrimRight(request.Stat, "\n"); stat != "" {
+		sb.WriteString("\nFILES CHANGED:\n```\n" + stat + "\n```\n")
+	}
+
+	diff := strings.TrimRight(request.Diff, "\n")
+	if len(diff) > explainMaxDiffChars {
+		diff = truncateAtLine(diff, explainMaxDiffChars-len(diffTruncationNote)) + diffTruncationNote
+	}
+	sb.WriteString("\nDIFF:\n" + FormatDiffBlock(diff) + "\n")
+	return sb.String()
+}
+
+// formatCommitMessages lists commit messages, leaving out the oldest ones
+// past maxCommitMessages
+func formatCommitMessages(messages []string) string {
+	var sb strings.Builder
+	for i, message := range messages {
+		if i == maxCommitMessages {
+			sb.WriteString("[... " + plural(len(messages)-i, "older commit") + " left out ...]\n")
+			break
+		}
+		sb.WriteString("- " + indentLines(limitMessage(message, commitMaxChars)) + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateExplainPrompt(t *testing.T) {
+	request := ExplainRequest{
+		Subject:  "commit 1a2b3c4 Add retries",
+		Messages: []string{"Add retries\n\nRetry failed requests."},
+		Stat:     " client.go | 1 +\n",
+		Diff:     "diff --git a/client.go b/client.go\n+retries := 3\n",
+	}
+
+	t.Run("Walkthrough", func(t *testing.T) {
+		explainPrompt := CreateExplainPrompt(request, ProviderOpenAI)
+		if !strings.HasPrefix(explainPrompt, ExplainHeading) {
+			t.Errorf("Expected the prompt to start with %q", ExplainHeading)
+		}
+		for _, expected := range []string{
+			"## Summary",
+			"## Walkthrough",
+			"## Why it matters",
+			"CHANGE: commit 1a2b3c4 Add retries\n",
+			"- Add retries\n\n  Retry failed requests.\n",
+			" client.go | 1 +",
+			"```diff\ndiff --git a/client.go b/client.go\n+retries := 3\n```",
+		} {
+			if !strings.Contains(explainPrompt, expected) {
+				t.Errorf("Expected the prompt to contain %q, got:\n%s", expected, explainPrompt)
+			}
+		}
+		if strings.Contains(explainPrompt, "mermaid") || strings.Contains(explainPrompt, "FindDefinitionForType") {
+			t.Error("Expected no diagram and no review instructions")
+		}
+	})
+
+	t.Run("Diagram and line", func(t *testing.T) {
+		lineRequest := request
+		lineRequest.Diagram = true
+		lineRequest.FocusPath = "client.go"
+		lineRequest.FocusLine = 12
+		lineRequest.FocusCode = ">   12  retries := 3\n"
+
+		explainPrompt := CreateExplainPrompt(lineRequest, ProviderDefault)
+		for _, expected := range []string{
+			"## Diagram\n",
+			"```mermaid",
+			"asked about line 12 of client.go",
+			"CODE AROUND LINE 12 OF client.go:\n```go\n>   12  retries := 3\n```",
+		} {
+			if !strings.Contains(explainPrompt, expected) {
+				t.Errorf("Expected the prompt to contain %q, got:\n%s", expected, explainPrompt)
+			}
+		}
+	})
+
+	t.Run("Large diffs are cut", func(t *testing.T) {
+		largeRequest := request
+		largeRequest.Diff = strings.Repeat("+// filler line\n", 10000)
+		explainPrompt := CreateExplainPrompt(largeRequest, ProviderDefault)
+		if len(explainPrompt) > explainMaxDiffChars+5000 || !strings.Contains(explainPrompt, diffTruncationNote) {
+			t.Errorf("Expected the diff to be truncated, got %d characters", len(explainPrompt))
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// minGuidelineChars is the smallest part of the size budget worth spending
+// on a guideline; guidelines that do not fit in less are left out
+const minGuidelineChars = 200
+
+// truncationNote marks a guideline that was cut to fit the size budget
+const truncationNote = "\n[... guideline truncated ...]"
+
+// omissionNote counts the guidelines left out to fit the size budget
+const omissionNote = "\n[%d more guidelines left out to keep the prompt short]\n"
+
+// LoadGuidelines reads the project guidelines that apply to the files at
+// paths, relative to repoRoot. These are the files matching the patterns,
+// which are file names or glob patterns relative to repoRoot, and every file
+// named scopedName found in the directory of a file or any directory above
+// it. Patterns that match nothing are logged and skipped.
+func LoadGuidelines(repoRoot string, patterns []string, scopedName string, paths []string) ([]llm.Guideline, error) {
+	var guidelines []llm.Guideline
+	seen := make(map[string]bool)
+
+	add := func(file, scope string) error {
+		if seen[file] {
+			return nil
+		}
+		seen[file] = true
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read guideline: %w", err)
+		}
+		content := strings.TrimSpace(string(data))
+		if content == "" {
+			return nil
+		}
+		guidelines = append(guidelines, llm.Guideline{
+			Path:    displayPath(repoRoot, file),
+			Scope:   scope,
+			Content: content,
+		})
+		return nil
+	}
+
+	// Directory-scoped guidelines, found by walking up from each file
+	if scopedName != "" {
+		checked := make(map[string]bool)
+		for _, filePath := range paths {
+			dir := path.Dir(filepath.ToSlash(filePath))
+			for !checked[dir] {
+				checked[dir] = true
+				scoped := filepath.Join(repoRoot, filepath.FromSlash(dir), scopedName)
+				if _, err := os.Stat(scoped); err == nil {
+					scope := dir
+					if scope == "." {
+						scope = ""
+					}
+					if err := add(scoped, scope); err != nil {
+						return nil, err
+					}
+				}
+				if dir == "." {
+					break
+				}
+				dir = path.Dir(dir)
+			}
+		}
+	}
+
+	// Guidelines configured for the whole repository
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(repoRoot, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guideline pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			logging.WarnWith("No guideline files found", map[string]interface{}{
+				"pattern": pattern,
+			})
+			continue
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			if err := add(match, ""); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return guidelines, nil
+}
+
+// FormatGuidelines returns the section of the system prompt that holds the
+// guidelines applying to the reviewed files, or an empty string if there are
+// none. The guidelines closest to the files come first and get the largest
+// share of the size budget; guidelines that do not fit are condensed to their
+// headings and list items, truncated, or left out.
+func FormatGuidelines(request *llm.ReviewRequest) string {
+	guidelines := applicableGuidelines(request.Options.Guidelines, request.Paths())
+	if len(guidelines) == 0 {
+		return ""
+	}
+
+	// Keep room for counting the guidelines that are left out
+	budget := request.Options.GuidelinesMaxChars
+	if budget > 0 {
+		budget -= len(fmt.Sprintf(omissionNote, len(guidelines)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("PROJECT GUIDELINES:\n")
+	sb.WriteString("The project asks reviewers to follow the guidelines below. Report changes that break them, and name the guideline in the explanation.\n")
+
+	omitted := 0
+	for _, guideline := range guidelines {
+		heading := "\n### " + guideline.Path
+		if guideline.Scope != "" {
+			heading += fmt.Sprintf(" (applies to %s/)", guideline.Scope)
+		}
+		heading += "\n\n"
+
+		content := guideline.Content
+		if budget > 0 {
+			remaining := budget - sb.Len() - len(heading) - 1
+			if remaining < minGuidelineChars {
+				omitted++
+				continue
+			}
+			content = fitGuideline(content, remaining)
+		}
+
+		sb.WriteString(heading)
+		sb.WriteString(content)
+		sb.WriteString("\n")
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&sb, omissionNote, omitted)
+	}
+
+	return sb.String()
+}
+
+// applicableGuidelines returns the guidelines whose scope contains any of
+// filePaths, the most specific scope first
+func applicableGuidelines(guidelines []llm.Guideline, filePaths []string) []llm.Guideline {
+	var applicable []llm.Guideline
+	for _, guideline := range guidelines {
+		for _, filePath := range filePaths {
+			if guideline.Scope == "" || strings.HasPrefix(filePath, guideline.Scope+"/") {
+				applicable = append(applicable, guideline)
+				break
+			}
+		}
+	}
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return scopeDepth(applicable[i].Scope) > scopeDepth(applicable[j].Scope)
+	})
+	return applicable
+}
+
+// scopeDepth returns the number of directories in a scope
+func scopeDepth(scope string) int {
+	if scope == "" {
+		return 0
+	}
+	return strings.Count(scope, "/") + 1
+}
+
+// fitGuideline shortens content to at most limit characters. Long guidelines
+// are first condensed to their outline, and truncated if that is still too
+// long.
+func fitGuideline(content string, limit int) string {
+	if len(content) <= limit {
+		return content
+	}
+
+	condensed := condenseGuideline(content)
+	if len(condensed) <= limit {
+		return condensed
+	}
+	return truncateAtLine(condensed, limit-len(truncationNote)) + truncationNote
+}
+
+// condenseGuideline keeps the headings and list items of a guideline, which
+// hold most of the rules in a typical style guide
+func condenseGuideline(content string) string {
+	var kept []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || isListItem(trimmed) {
+			kept = append(kept, line)
+		}
+	}
+	if len(kept) == 0 {
+		return content
+	}
+	return "(condensed to its headings and rules)\n" + strings.Join(kept, "\n")
+}
+
+// isListItem reports whether a line is a markdown list item
+func isListItem(line string) bool {
+	if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "+ ") {
+		return true
+	}
+	digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+	return digits > 0 && strings.HasPrefix(line[digits:], ". ")
+}
+
+// truncateAtLine cuts text to at most limit characters, at the end of a line
+// if there is one
+func truncateAtLine(text string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	if len(text) <= limit {
+		return text
+	}
+	cut := util.TruncateUTF8(text, limit)
+	if i := strings.LastIndex(cut, "\n"); i > 0 {
+		return cut[:i]
+	}
+	return cut
+}
+
+// displayPath returns file relative to repoRoot if it is inside it
+func displayPath(repoRoot, file string) string {
+	if rel, err := filepath.Rel(repoRoot, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
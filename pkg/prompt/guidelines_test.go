@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+func TestLoadGuidelines(t *testing.T) {
+	repoRoot := t.TempDir()
+	files := map[string]string{
+		"CONTRIBUTING.md":                     "Write tests for every change",
+		"docs/style.md":                       "Wrap errors with context",
+		".review-guidelines.md":               "Root rules",
+		"pkg/server/.review-guidelines.md":    "Handlers must check permissions",
+		"pkg/unrelated/.review-guidelines.md": "Not used",
+	}
+	for name, content := range files {
+		path := filepath.Join(repoRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	guidelines, err := LoadGuidelines(repoRoot, []string{"CONTRIBUTING.md", "docs/*.md", "missing.md"},
+		".review-guidelines.md", []string{"pkg/server/handler.go", "pkg/server/routes.go"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, guideline := range guidelines {
+		got[guideline.Path] = guideline.Scope
+	}
+	expected := map[string]string{
+		"pkg/server/.review-guidelines.md": "pkg/server",
+		".review-guidelines.md":            "",
+		"CONTRIBUTING.md":                  "",
+		"docs/style.md":                    "",
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d guidelines, got %v", len(expected), got)
+	}
+	for path, scope := range expected {
+		if gotScope, ok := got[path]; !ok || gotScope != scope {
+			t.Errorf("Expected %s with scope %q, got %v", path, scope, got)
+		}
+	}
+}
+
+func TestFormatGuidelines(t *testing.T) {
+	request := func(filePath string, maxChars int, guidelines ...llm.Guideline) *llm.ReviewRequest {
+		return &llm.ReviewRequest{
+			FilePath: filePath,
+			Options:  llm.ReviewOptions{Guidelines: guidelines, GuidelinesMaxChars: maxChars},
+		}
+	}
+	contributing := llm.Guideline{Path: "CONTRIBUTING.md", Content: "Write tests"}
+	server := llm.Guideline{Path: "pkg/server/.review-guidelines.md", Scope: "pkg/server", Content: "Check permissions"}
+
+	t.Run("No guidelines", func(t *testing.T) {
+		if got := FormatGuidelines(request("main.go", 0)); got != "" {
+			t.Errorf("Expected no section, got %q", got)
+		}
+	})
+
+	t.Run("Scoped guidelines come first", func(t *testing.T) {
+		got := FormatGuidelines(request("pkg/server/handler.go", 0, contributing, server))
+		serverAt := strings.Index(got, "Check permissions")
+		contributingAt := strings.Index(got, "Write tests")
+		if serverAt < 0 || contributingAt < 0 || serverAt > contributingAt {
+			t.Errorf("Expected the scoped guideline before the repository guideline, got:\n%s", got)
+		}
+		if !strings.Contains(got, "(applies to pkg/server/)") {
+			t.Errorf("Expected the scope to be named, got:\n%s", got)
+		}
+	})
+
+	t.Run("Guidelines of other directories are left out", func(t *testing.T) {
+		got := FormatGuidelines(request("pkg/client/client.go", 0, contributing, server))
+		if strings.Contains(got, "Check permissions") {
+			t.Errorf("Expected only the repository guideline, got:\n%s", got)
+		}
+	})
+
+	t.Run("Long guidelines are condensed", func(t *testing.T) {
+		long := llm.Guideline{
+			Path:    "STYLE.md",
+			Content: "# Style\n\n" + strings.Repeat("Some background prose about the project. ", 50) + "\n\n- Use short names\n1. Wrap errors",
+		}
+		got := FormatGuidelines(request("main.go", 1000, long))
+		if !strings.Contains(got, "- Use short names") || !strings.Contains(got, "1. Wrap errors") {
+			t.Errorf("Expected the rules to be kept, got:\n%s", got)
+		}
+		if strings.Contains(got, "background prose") {
+			t.Errorf("Expected the prose to be left out, got:\n%s", got)
+		}
+	})
+
+	t.Run("Guidelines are truncated to the budget", func(t *testing.T) {
+		long := llm.Guideline{Path: "STYLE.md", Content: strings.Repeat("- A rule that must be followed\n", 200)}
+		got := FormatGuidelines(request("main.go", 1000, long, contributing))
+		if len(got) > 1000 {
+			t.Errorf("Expected at most 1000 characters, got %d", len(got))
+		}
+		if !strings.Contains(got, "guideline truncated") {
+			t.Errorf("Expected a truncation note, got:\n%s", got)
+		}
+		if !strings.Contains(got, "1 more guidelines left out") {
+			t.Errorf("Expected the left out guideline to be counted, got:\n%s", got)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package prompt
+
+import (
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// intentMaxChars limits the size of the intent in a prompt, so that a long
+// branch history does not crowd out the code
+const intentMaxChars = 4000
+
+// commitMaxChars limits the size of each earlier commit message
+const commitMaxChars = 600
+
+// messageTruncationNote marks a message that was cut short
+const messageTruncationNote = "\n[... message truncated ...]"
+
+// FormatIntent returns the section of the system prompt that describes what
+// the change is meant to do and asks for the mismatches with the code, or an
+// empty string if the intent is not checked
+func FormatIntent(request *llm.ReviewRequest) string {
+	intent := request.Options.Intent
+	if intent.IsEmpty() {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("INTENDED CHANGE:\n")
+	sb.WriteString("The author describes below what the change is meant to do. Check that the code does what the description says. ")
+	sb.WriteString("Report behavior that is described but missing or incomplete, and behavior that contradicts the description, ")
+	sb.WriteString("as issues whose title starts with \"Intent:\", such as \"Intent: Retry limit from the commit message is not enforced\". ")
+	sb.WriteString("Only report what the reviewed code should do; parts of the description that belong to other files are not mismatches.\n")
+
+	if intent.Stated != "" {
+		sb.WriteString("\nStated intent:\n" + limitMessage(intent.Stated, intentMaxChars/2) + "\n")
+	}
+	if intent.Draft != "" {
+		sb.WriteString("\nCommit message:\n" + limitMessage(intent.Draft, intentMaxChars/2) + "\n")
+	}
+	if intent.Branch != "" {
+		sb.WriteString("\nBranch: " + intent.Branch + "\n")
+	}
+	if len(intent.Tickets) > 0 {
+		sb.WriteString("Tickets: " + strings.Join(intent.Tickets, ", ") + "\n")
+	}
+
+	// Add the earlier commits, newest first, while they fit
+	if len(intent.Commits) > 0 {
+		sb.WriteString("\nEarlier commits on the branch, newest first:\n")
+		for i, commit := range intent.Commits {
+			entry := "- " + indentLines(limitMessage(commit, commitMaxChars)) + "\n"
+			if sb.Len()+len(entry) > intentMaxChars {
+				sb.WriteString("[... " + plural(len(intent.Commits)-i, "older commit") + " left out ...]\n")
+				break
+			}
+			sb.WriteString(entry)
+		}
+	}
+
+	return sb.String()
+}
+
+// limitMessage shortens a message to about limit characters, at a line end,
+// or at a word if that keeps too little of a long paragraph
+func limitMessage(message string, limit int) string {
+	message = strings.TrimSpace(message)
+	if len(message) <= limit {
+		return message
+	}
+
+	limit -= len(messageTruncationNote)
+	cut := truncateAtLine(message, limit)
+	if len(cut) < limit/2 {
+		cut = util.TruncateUTF8(message, limit)
+		if i := strings.LastIndex(cut, " "); i > limit/2 {
+			cut = cut[:i]
+		}
+	}
+	return cut + messageTruncationNote
+}
+
+// indentLines indents every line of text after the first, for a list item
+func indentLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = "  " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,80 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+func TestFormatIntent(t *testing.T) {
+	request := &llm.ReviewRequest{FilePath: "client.go", FileDiff: "+retries := 5"}
+	if section := FormatIntent(request); section != "" {
+		t.Errorf("Expected no section without an intent, got %q", section)
+	}
+	request.Options.Intent = &llm.Intent{}
+	if section := FormatIntent(request); section != "" {
+		t.Errorf("Expected no section for an empty intent, got %q", section)
+	}
+
+	t.Run("Intent in the system prompt", func(t *testing.T) {
+		request.Options.Intent = &llm.Intent{
+			Draft:   "Limit retries to 3",
+			Branch:  "feature/PROJ-12-retry",
+			Tickets: []string{"PROJ-12"},
+			Commits: []string{"Add retries\n\nRetry failed requests."},
+		}
+		systemPrompt := CreateSystemPrompt(request, ProviderDefault)
+		for _, expected := range []string{
+			"INTENDED CHANGE:",
+			`title starts with "Intent:"`,
+			"Commit message:\nLimit retries to 3\n",
+			"Branch: feature/PROJ-12-retry\n",
+			"Tickets: PROJ-12\n",
+			"- Add retries\n\n  Retry failed requests.\n",
+		} {
+			if !strings.Contains(systemPrompt, expected) {
+				t.Errorf("Expected the system prompt to contain %q, got:\n%s", expected, systemPrompt)
+			}
+		}
+	})
+
+	t.Run("Long history is cut", func(t *testing.T) {
+		var commits []string
+		for i := 0; i < 50; i++ {
+			commits = append(commits, fmt.Sprintf("Commit %d\n\n%s", i, strings.Repeat("Details. ", 100)))
+		}
+		request.Options.Intent = &llm.Intent{Stated: "Limit retries", Commits: commits}
+
+		section := FormatIntent(request)
+		if len(section) > intentMaxChars+100 {
+			t.Errorf("Expected the intent to stay within the budget, got %d characters", len(section))
+		}
+		if !strings.Contains(section, "- Commit 0\n") || strings.Contains(section, "- Commit 49\n") {
+			t.Error("Expected the newest commits to be kept and the oldest left out")
+		}
+		if !strings.Contains(section, "older commits left out") {
+			t.Error("Expected a note about the commits left out")
+		}
+	})
+}
+
+func TestLimitMessageUTF8(t *testing.T) {
+	// Messages without spaces or line breaks are cut inside the text, but
+	// never inside a character
+	for _, message := range []string{strings.Repeat("é", 500), "a" + strings.Repeat("日本", 300)} {
+		limited := limitMessage(message, 301)
+		if !utf8.ValidString(limited) {
+			t.Errorf("Expected valid UTF-8, got %q", limited)
+		}
+		if !strings.HasSuffix(limited, messageTruncationNote) {
+			t.Errorf("Expected the message to be truncated, got %q", limited)
+		}
+	}
+
+	if cut := truncateAtLine(strings.Repeat("é", 10), 5); cut != "éé" {
+		t.Errorf("Expected the text to be cut before the split character, got %q", cut)
+	}
+}
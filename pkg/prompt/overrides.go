@@ -0,0 +1,244 @@
+package prompt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/version"
+)
+
+// DefaultTemplatesDir is the directory, relative to the repository root, that
+// holds the prompt templates overriding the built-in prompts
+const DefaultTemplatesDir = ".git-llm-reviewer/prompts"
+
+// Names of the prompts that can be overridden. A template named after the
+// prompt, such as review.tmpl, applies to every provider; one named after the
+// prompt and a provider, such as review.openai.tmpl, applies to that provider
+// only and takes precedence.
+const (
+	// TemplateReview is the user prompt of a review
+	TemplateReview = "review"
+	// TemplateSystem is the system prompt of a review
+	TemplateSystem = "system"
+	// TemplateChangeset is the user prompt of a review of several files
+	TemplateChangeset = "changeset"
+)
+
+// templateExt is the extension of template files
+const templateExt = ".tmpl"
+
+// TemplateSet holds the prompt templates loaded from a directory
+type TemplateSet struct {
+	// Dir is the directory the templates were loaded from
+	Dir string
+
+	// Templates maps template names, such as "review" or "system.anthropic",
+	// to their text
+	Templates map[string]string
+
+	// Version identifies the prompts, to tell which prompts produced a report
+	Version string
+}
+
+// LoadTemplates loads and validates the prompt templates in dir. A missing
+// directory is not an error; the built-in prompts are used instead.
+func LoadTemplates(dir string) (*TemplateSet, error) {
+	set := &TemplateSet{Dir: dir, Templates: make(map[string]string)}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			set.Version = TemplateVersion(nil)
+			return set, nil
+		}
+		return nil, fmt.Errorf("failed to read prompt templates: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != templateExt {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), templateExt)
+		if !isTemplateName(name) {
+			return nil, fmt.Errorf("unknown prompt template %s (expected one of: %s)",
+				filepath.Join(dir, entry.Name()), strings.Join(templateFileNames(), ", "))
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template: %w", err)
+		}
+
+		// Render the template once so that mistakes, such as unknown
+		// fields, are reported before any review starts
+		if _, err := renderTemplate(name, string(data), sampleTemplateData()); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+		}
+		set.Templates[name] = string(data)
+	}
+
+	set.Version = TemplateVersion(set.Templates)
+	return set, nil
+}
+
+// Names returns the names of the loaded templates in sorted order
+func (s *TemplateSet) Names() []string {
+	names := make([]string, 0, len(s.Templates))
+	for name := range s.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateVersion returns a version string that identifies the prompts built
+// from templates. The built-in prompts are identified by the application
+// version, and overridden ones by a hash of the templates.
+func TemplateVersion(templates map[string]string) string {
+	if len(templates) == 0 {
+		return "builtin-" + version.Version
+	}
+
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s\x00%s\x00", name, templates[name])
+	}
+	return "custom-" + hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// CreateSystemPrompt creates the system prompt for the given request and
+// provider, from an overriding template if the request has one, followed by
+// the focus of the review, the checklists for the languages of the files and
+// the project guidelines that apply to them
+func CreateSystemPrompt(request *llm.ReviewRequest, providerType ProviderType) string {
+	systemPrompt, ok := renderOverride(request, TemplateSystem, providerType)
+	if !ok {
+		systemPrompt = GetSystemPrompt(providerType, SystemPromptReview)
+	}
+
+	// Narrow the review down to the requested focus
+	if len(request.Options.Focus) > 0 {
+		foci := make([]SystemPromptType, len(request.Options.Focus))
+		for i, focus := range request.Options.Focus {
+			foci[i] = SystemPromptType(focus)
+		}
+		systemPrompt += "\n\n" + FocusSection(foci)
+	}
+
+	// Add the summary of the change the files are part of
+	if summary := FormatChangeSummary(request); summary != "" {
+		systemPrompt += "\n\n" + summary
+	}
+
+	// Ask for the mismatches between the intent of the change and the code
+	if intent := FormatIntent(request); intent != "" {
+		systemPrompt += "\n\n" + intent
+	}
+
+	// Add the pitfalls to check for in the languages of the files
+	if checklist := FormatChecklist(request); checklist != "" {
+		systemPrompt += "\n\n" + checklist
+	}
+
+	// Add the project guidelines that apply to the files
+	if guidelines := FormatGuidelines(request); guidelines != "" {
+		systemPrompt += "\n\n" + guidelines
+	}
+	return systemPrompt
+}
+
+// renderOverride renders the template that overrides the named prompt for
+// the provider. It returns false if there is none, or if it cannot be
+// rendered, so that the built-in prompt is used.
+func renderOverride(request *llm.ReviewRequest, name string, providerType ProviderType) (string, bool) {
+	templates := request.Options.PromptTemplates
+	for _, key := range []string{name + "." + providerType.String(), name} {
+		tmplStr, ok := templates[key]
+		if !ok {
+			continue
+		}
+
+		data := NewTemplateData(request)
+		data.Provider = providerType.String()
+		rendered, err := renderTemplate(key, tmplStr, data)
+		if err != nil {
+			logging.WarnWith("Failed to render prompt template, using the built-in prompt", map[string]interface{}{
+				"template": key + templateExt,
+				"file":     request.FilePath,
+				"error":    err.Error(),
+			})
+			return "", false
+		}
+		return rendered, true
+	}
+	return "", false
+}
+
+// renderTemplate parses and executes a template
+func renderTemplate(name, tmplStr string, data *TemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// isTemplateName reports whether name is the name of a prompt that can be
+// overridden, for all providers or for one
+func isTemplateName(name string) bool {
+	for _, fileName := range templateFileNames() {
+		if fileName == name+templateExt {
+			return true
+		}
+	}
+	return false
+}
+
+// templateFileNames returns the file names of all templates that can
+// override a prompt
+func templateFileNames() []string {
+	var names []string
+	for _, prompt := range []string{TemplateReview, TemplateSystem, TemplateChangeset} {
+		names = append(names, prompt+templateExt)
+		for _, providerType := range []ProviderType{ProviderOpenAI, ProviderAnthropic} {
+			names = append(names, prompt+"."+providerType.String()+templateExt)
+		}
+	}
+	return names
+}
+
+// sampleTemplateData returns template data used to check templates
+func sampleTemplateData() *TemplateData {
+	file := llm.ReviewFile{
+		FilePath:    "pkg/example/example.go",
+		FileContent: "package example\n\nfunc Example() {}\n",
+		FileDiff:    "@@ -1,2 +1,3 @@\n package example\n+\n+func Example() {}\n",
+	}
+	return NewTemplateData(&llm.ReviewRequest{
+		FilePath:    file.FilePath,
+		FileContent: file.FileContent,
+		FileDiff:    file.FileDiff,
+		Files:       []llm.ReviewFile{file},
+	})
+}
@@ -0,0 +1,123 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+}
+
+func TestLoadTemplates(t *testing.T) {
+	t.Run("Missing directory uses the built-in prompts", func(t *testing.T) {
+		set, err := LoadTemplates(filepath.Join(t.TempDir(), "missing"))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(set.Templates) != 0 {
+			t.Errorf("Expected no templates, got %v", set.Names())
+		}
+		if !strings.HasPrefix(set.Version, "builtin-") {
+			t.Errorf("Expected a built-in version, got %q", set.Version)
+		}
+	})
+
+	t.Run("Templates are loaded by name", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "review.tmpl", "Review {{.FilePath}}")
+		writeTemplate(t, dir, "system.anthropic.tmpl", "Be brief")
+		writeTemplate(t, dir, "notes.txt", "not a template")
+
+		set, err := LoadTemplates(dir)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := strings.Join(set.Names(), ","); got != "review,system.anthropic" {
+			t.Errorf("Expected review and system.anthropic, got %s", got)
+		}
+		if !strings.HasPrefix(set.Version, "custom-") {
+			t.Errorf("Expected a custom version, got %q", set.Version)
+		}
+	})
+
+	t.Run("Unknown template names are rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "reveiw.tmpl", "Review {{.FilePath}}")
+
+		if _, err := LoadTemplates(dir); err == nil || !strings.Contains(err.Error(), "unknown prompt template") {
+			t.Errorf("Expected an unknown template error, got %v", err)
+		}
+	})
+
+	t.Run("Templates with unknown fields are rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTemplate(t, dir, "review.tmpl", "Review {{.Path}}")
+
+		if _, err := LoadTemplates(dir); err == nil || !strings.Contains(err.Error(), "invalid prompt template") {
+			t.Errorf("Expected an invalid template error, got %v", err)
+		}
+	})
+}
+
+func TestTemplateVersion(t *testing.T) {
+	a := TemplateVersion(map[string]string{"review": "one", "system": "two"})
+	b := TemplateVersion(map[string]string{"system": "two", "review": "one"})
+	c := TemplateVersion(map[string]string{"review": "one", "system": "three"})
+
+	if a != b {
+		t.Errorf("Expected the same version for the same templates, got %q and %q", a, b)
+	}
+	if a == c {
+		t.Errorf("Expected a different version for changed templates, got %q", a)
+	}
+}
+
+func TestPromptOverrides(t *testing.T) {
+	request := &llm.ReviewRequest{
+		FilePath: "pkg/main.go",
+		FileDiff: "--- a/pkg/main.go\n+++ b/pkg/main.go\n@@ -1 +1,2 @@\n-old\n+new\n+line\n",
+		Options: llm.ReviewOptions{
+			PromptTemplates: map[string]string{
+				"review":           "{{.Provider}}: {{.FileName}} {{.Language}} +{{.AddedLines}}/-{{.RemovedLines}}",
+				"review.anthropic": "Anthropic review of {{.FilePath}}",
+				"system":           "Custom system prompt",
+			},
+		},
+	}
+
+	t.Run("Template for all providers", func(t *testing.T) {
+		if got := CreatePrompt(request, ProviderOpenAI); got != "openai: main.go go +2/-1" {
+			t.Errorf("Unexpected prompt: %q", got)
+		}
+	})
+
+	t.Run("Provider template takes precedence", func(t *testing.T) {
+		if got := CreatePrompt(request, ProviderAnthropic); got != "Anthropic review of pkg/main.go" {
+			t.Errorf("Unexpected prompt: %q", got)
+		}
+	})
+
+	t.Run("System prompt", func(t *testing.T) {
+		if got := CreateSystemPrompt(request, ProviderOpenAI); got != "Custom system prompt" {
+			t.Errorf("Unexpected system prompt: %q", got)
+		}
+	})
+
+	t.Run("Built-in prompts without templates", func(t *testing.T) {
+		builtin := &llm.ReviewRequest{FilePath: request.FilePath, FileDiff: request.FileDiff}
+		if got := CreateSystemPrompt(builtin, ProviderOpenAI); got != GetSystemPrompt(ProviderOpenAI, SystemPromptReview) {
+			t.Errorf("Expected the built-in system prompt, got %q", got)
+		}
+		if got := CreatePrompt(builtin, ProviderOpenAI); !strings.Contains(got, "FULL FILE CONTENT (STAGED VERSION)") {
+			t.Errorf("Expected the built-in review prompt, got %q", got)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PRDescriptionHeading starts the prompt that asks for the description of a
+// pull request, so that it can be told apart from review prompts
+const PRDescriptionHeading = "Write the title and description of a pull request"
+
+// prMaxDiffChars limits the size of the diff in a pull request prompt
+const prMaxDiffChars = 60000
+
+// prTemplateMaxChars limits the size of a pull request template in a prompt
+const prTemplateMaxChars = 8000
+
+// titlePattern matches the title line of a pull request description, such
+// as "TITLE: Add retries", "**Title:** Add retries" or "**Title**: Add retries"
+var titlePattern = regexp.MustCompile(`(?i)^[#*\s]*title\**\s*:\**\s*(.+)$`)
+
+// PRDescriptionRequest describes the branch of a pull request
+type PRDescriptionRequest struct {
+	// Branch is the branch of the pull request and Base the branch it is
+	// merged into
+	Branch string
+	Base   string
+	// Messages are the messages of the commits on the branch, newest first
+	Messages []string
+	// Stat lists the changed files, like git diff --stat
+	Stat string
+	// Diff is the patch of the branch against its base
+	Diff string
+	// Template is the pull request template to fill in, if any
+	Template string
+}
+
+// CreatePRDescriptionPrompt creates the prompt that asks for the title and
+// body of a pull request, following the template of the request if it has one
+func CreatePRDescriptionPrompt(request PRDescriptionRequest) string {
+	var sb strings.Builder
+	sb.WriteString(PRDescriptionHeading + " from its diff and commit messages.\n\n")
+	sb.WriteString("Write for reviewers who have not seen the change. Be specific and concise, describe only what the diff shows, ")
+	sb.WriteString("do not review the code and do not answer in JSON.\n\n")
+	sb.WriteString("Start the answer with a line of the form \"TITLE: <title>\", where the title is a short imperative summary of at most 72 characters, ")
+	sb.WriteString("followed by an empty line and the body in Markdown.\n\n")
+
+	if template := strings.TrimSpace(request.Template); template != "" {
+		if len(template) > prTemplateMaxChars {
+			template = truncateAtLine(template, prTemplateMaxChars)
+		}
+		sb.WriteString("The body must fill in the following pull request template section by section. ")
+		sb.WriteString("Keep its headings, their order and its checklists, replace the placeholder text and HTML comments under each heading with content for this change, ")
+		sb.WriteString("tick only the checklist items the diff shows to be done, and write \"N/A\" under a heading the change gives nothing for. ")
+		sb.WriteString("Where the template has room for them, cover the summary, motivation, notable changes per area, testing notes, and risk and rollback notes.\n\n")
+		sb.WriteString("TEMPLATE:\n```markdown\n" + template + "\n```\n")
+	} else {
+		sb.WriteString("The body must have these sections:\n")
+		sb.WriteString("## Summary\nWhat the pull request does, in two or three sentences.\n\n")
+		sb.WriteString("## Motivation\nWhy the change is needed, as far as the commit messages and the diff show it.\n\n")
+		sb.WriteString("## Notable changes\nA short list per area, such as a package, directory or component, of the changes worth a reviewer's attention.\n\n")
+		sb.WriteString("## Testing\nThe tests added or changed, and what a reviewer should check by hand.\n\n")
+		sb.WriteString("## Risks and rollback\nWhat could break, who is affected, and how to roll the change back, including migrations or configuration that need care.\n")
+	}
+
+	fmt.Fprintf(&sb, "\nBRANCH: %s, to be merged into %s\n", request.Branch, request.Base)
+	if len(request.Messages) > 0 {
+		sb.WriteString("\nCOMMIT MESSAGES, NEWEST FIRST:\n" + formatCommitMessages(request.Messages))
+	}
+	if stat := strings.TrimRight(request.Stat, "\n"); stat != "" {
+		sb.WriteString("\nFILES CHANGED:\n```\n" + stat + "\n```\n")
+	}
+
+	diff := strings.TrimRight(request.Diff, "\n")
+	if len(diff) > prMaxDiffChars {
+		diff = truncateAtLine(diff, prMaxDiffChars-len(diffTruncationNote)) + diffTruncationNote
+	}
+	sb.WriteString("\nDIFF:\n" + FormatDiffBlock(diff) + "\n")
+	return sb.String()
+}
+
+// ParsePRDescription splits the answer to a pull request prompt into the
+// title and the body. The title is taken from the TITLE line that starts the
+// answer, or else the first line is the title.
+func ParsePRDescription(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") {
+		text = strings.TrimSpace(strings.TrimSuffix(text[strings.Index(text, "\n")+1:], "```"))
+	}
+
+	first, body, _ := strings.Cut(text, "\n")
+	title := strings.TrimSpace(strings.TrimLeft(first, "# "))
+	if match := titlePattern.FindStringSubmatch(strings.TrimSpace(first)); match != nil {
+		title = strings.Trim(strings.TrimSpace(match[1]), "*`\"")
+	}
+	return title, strings.TrimSpace(body)
+}
@@ -0,0 +1,100 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreatePRDescriptionPrompt(t *testing.T) {
+	request := PRDescriptionRequest{
+		Branch:   "feature/retry",
+		Base:     "main",
+		Messages: []string{"Add retries"},
+		Stat:     " client.go | 1 +\n",
+		Diff:     "diff --git a/client.go b/client.go\n+retries := 3\n",
+	}
+
+	t.Run("Default sections", func(t *testing.T) {
+		descriptionPrompt := CreatePRDescriptionPrompt(request)
+		if !strings.HasPrefix(descriptionPrompt, PRDescriptionHeading) {
+			t.Errorf("Expected the prompt to start with %q", PRDescriptionHeading)
+		}
+		for _, expected := range []string{
+			"TITLE: <title>",
+			"## Motivation",
+			"## Notable changes",
+			"## Risks and rollback",
+			"BRANCH: feature/retry, to be merged into main",
+			"- Add retries\n",
+			"```diff\ndiff --git a/client.go b/client.go\n+retries := 3\n```",
+		} {
+			if !strings.Contains(descriptionPrompt, expected) {
+				t.Errorf("Expected the prompt to contain %q, got:\n%s", expected, descriptionPrompt)
+			}
+		}
+	})
+
+	t.Run("Template", func(t *testing.T) {
+		templateRequest := request
+		templateRequest.Template = "## What\n\n<!-- describe the change -->\n\n## Checklist\n- [ ] Tests\n"
+		descriptionPrompt := CreatePRDescriptionPrompt(templateRequest)
+		if !strings.Contains(descriptionPrompt, "TEMPLATE:\n```markdown\n## What\n\n<!-- describe the change -->\n\n## Checklist\n- [ ] Tests\n```") {
+			t.Errorf("Expected the prompt to contain the template, got:\n%s", descriptionPrompt)
+		}
+		if strings.Contains(descriptionPrompt, "## Motivation") {
+			t.Error("Expected the template to replace the default sections")
+		}
+	})
+}
+
+func TestParsePRDescription(t *testing.T) {
+	tests := []struct {
+		name          string
+		text          string
+		expectedTitle string
+		expectedBody  string
+	}{
+		{
+			name:          "Title line",
+			text:          "TITLE: Add retries\n\n## Summary\n\nRetries failed requests.",
+			expectedTitle: "Add retries",
+			expectedBody:  "## Summary\n\nRetries failed requests.",
+		},
+		{
+			name:          "Formatted title line",
+			text:          "**Title**: `Add retries`\n\n## Summary\nRetries.",
+			expectedTitle: "Add retries",
+			expectedBody:  "## Summary\nRetries.",
+		},
+		{
+			name:          "Body lines about titles stay in the body",
+			text:          "Add retries\n\n## Title handling\nTitles of the config keys changed.",
+			expectedTitle: "Add retries",
+			expectedBody:  "## Title handling\nTitles of the config keys changed.",
+		},
+		{
+			name:          "First line without a colon",
+			text:          "Titles of the config keys changed\n\nThe keys are renamed.",
+			expectedTitle: "Titles of the config keys changed",
+			expectedBody:  "The keys are renamed.",
+		},
+		{
+			name:          "Code block without title line",
+			text:          "```markdown\n# Add retries\n\nRetries failed requests.\n```",
+			expectedTitle: "Add retries",
+			expectedBody:  "Retries failed requests.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, body := ParsePRDescription(tt.text)
+			if title != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, title)
+			}
+			if body != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, body)
+			}
+		})
+	}
+}
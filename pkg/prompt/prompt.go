@@ -21,10 +21,46 @@ const (
 	ProviderAnthropic
 )
 
+// String returns the name of the provider type, as used in template names
+func (p ProviderType) String() string {
+	switch p {
+	case ProviderOpenAI:
+		return "openai"
+	case ProviderAnthropic:
+		return "anthropic"
+	default:
+		return "default"
+	}
+}
+
+// ProviderTypeFor returns the provider type of the named provider
+func ProviderTypeFor(providerName string) ProviderType {
+	switch strings.ToLower(providerName) {
+	case "openai":
+		return ProviderOpenAI
+	case "anthropic":
+		return ProviderAnthropic
+	default:
+		return ProviderDefault
+	}
+}
+
 // CreatePrompt creates a prompt for the given request and provider
 func CreatePrompt(request *llm.ReviewRequest, providerType ProviderType) string {
+	// Files reviewed together have a prompt of their own
+	name := TemplateReview
+	if len(request.Files) > 0 {
+		name = TemplateChangeset
+	}
+
+	// Use the template from the repository if there is one
+	if rendered, ok := renderOverride(request, name, providerType); ok {
+		return rendered
+	}
+
 	// Create template data
 	data := NewTemplateData(request)
+	data.Provider = providerType.String()
 	
 	// Select the appropriate template based on provider type
 	var tmplStr string
@@ -36,6 +72,9 @@ func CreatePrompt(request *llm.ReviewRequest, providerType ProviderType) string
 	default:
 		tmplStr = DefaultTemplate
 	}
+	if len(request.Files) > 0 {
+		tmplStr = ChangesetTemplate
+	}
 	
 	// Parse and execute the template
 	tmpl, err := template.New("prompt").Parse(tmplStr)
@@ -67,6 +106,38 @@ func FormatDiffForProvider(diff string, providerType ProviderType) string {
 	return fmt.Sprintf("```diff\n%s\n```", diff)
 }
 
+// languageByExtension maps file extensions to the languages they are written in
+var languageByExtension = map[string]string{
+	"go":    "go",
+	"js":    "javascript",
+	"jsx":   "javascript",
+	"mjs":   "javascript",
+	"cjs":   "javascript",
+	"ts":    "typescript",
+	"tsx":   "typescript",
+	"py":    "python",
+	"java":  "java",
+	"rb":    "ruby",
+	"php":   "php",
+	"c":     "cpp",
+	"h":     "cpp",
+	"cpp":   "cpp",
+	"cc":    "cpp",
+	"cxx":   "cpp",
+	"hpp":   "cpp",
+	"cs":    "csharp",
+	"rs":    "rust",
+	"swift": "swift",
+	"kt":    "kotlin",
+	"sh":    "bash",
+	"html":  "html",
+	"css":   "css",
+	"json":  "json",
+	"yaml":  "yaml",
+	"yml":   "yaml",
+	"md":    "markdown",
+}
+
 // GetLanguageFromFilePath determines the programming language from the file path
 func GetLanguageFromFilePath(filePath string) string {
 	// Extract extension
@@ -75,47 +146,5 @@ func GetLanguageFromFilePath(filePath string) string {
 		return ""
 	}
 	
-	ext := parts[len(parts)-1]
-	
-	// Map extension to language
-	switch strings.ToLower(ext) {
-	case "go":
-		return "go"
-	case "js":
-		return "javascript"
-	case "ts":
-		return "typescript"
-	case "py":
-		return "python"
-	case "java":
-		return "java"
-	case "rb":
-		return "ruby"
-	case "php":
-		return "php"
-	case "c", "cpp", "cc":
-		return "cpp"
-	case "cs":
-		return "csharp"
-	case "rs":
-		return "rust"
-	case "swift":
-		return "swift"
-	case "kt":
-		return "kotlin"
-	case "sh":
-		return "bash"
-	case "html":
-		return "html"
-	case "css":
-		return "css"
-	case "json":
-		return "json"
-	case "yaml", "yml":
-		return "yaml"
-	case "md":
-		return "markdown"
-	default:
-		return ""
-	}
+	return languageByExtension[strings.ToLower(parts[len(parts)-1])]
 }
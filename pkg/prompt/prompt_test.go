@@ -175,3 +175,88 @@ index 1234567..abcdefg 100644
 		t.Errorf("Anthropic diff formatting does not use diff code blocks")
 	}
 }
+
+func TestFocus(t *testing.T) {
+	t.Run("Focus names are checked", func(t *testing.T) {
+		foci, err := ParseFocus([]string{"Security", "tests", "security"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(foci) != 2 || foci[0] != SystemPromptSecurity || foci[1] != SystemPromptTests {
+			t.Errorf("Expected security and tests, got %v", foci)
+		}
+		if _, err := ParseFocus([]string{"speed"}); err == nil {
+			t.Error("Expected an error for an unknown focus")
+		}
+	})
+
+	t.Run("Every focus has a system prompt", func(t *testing.T) {
+		review := GetSystemPrompt(ProviderDefault, SystemPromptReview)
+		for _, focus := range FocusTypes() {
+			systemPrompt := GetSystemPrompt(ProviderDefault, focus)
+			if !strings.HasPrefix(systemPrompt, review) || !strings.Contains(systemPrompt, "FOCUS: ") {
+				t.Errorf("Expected a focused review prompt for %s, got %q", focus, systemPrompt)
+			}
+		}
+	})
+
+	t.Run("Combined foci are tagged by the model", func(t *testing.T) {
+		request := &llm.ReviewRequest{FilePath: "main.go", Options: llm.ReviewOptions{Focus: []string{"security", "performance"}}}
+		systemPrompt := CreateSystemPrompt(request, ProviderOpenAI)
+		if !strings.Contains(systemPrompt, "FOCUS: Security") || !strings.Contains(systemPrompt, "FOCUS: Performance") {
+			t.Errorf("Expected both foci in the system prompt, got %q", systemPrompt)
+		}
+		if !strings.Contains(systemPrompt, `Add a "focus" field`) {
+			t.Errorf("Expected the model to be asked for the focus of each issue, got %q", systemPrompt)
+		}
+	})
+}
+
+func TestChangesetPrompt(t *testing.T) {
+	request := &llm.ReviewRequest{
+		FilePath: "lib/lib.go and 1 more files",
+		Files: []llm.ReviewFile{
+			{FilePath: "lib/lib.go", FileContent: "package lib", FileDiff: "+func Add(a, b, c int) int"},
+			{FilePath: "app/app.py", FileDiff: "+lib.add(1, 2)"},
+		},
+		Options: llm.ReviewOptions{AdditionalInstructions: "Check the callers"},
+	}
+
+	for _, providerType := range []ProviderType{ProviderDefault, ProviderOpenAI, ProviderAnthropic} {
+		got := CreatePrompt(request, providerType)
+		for _, want := range []string{
+			"span 2 files",
+			"File: lib/lib.go", "```go\npackage lib\n```", "+func Add(a, b, c int) int",
+			"File: app/app.py", "+lib.add(1, 2)",
+			"Check the callers",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("Expected the %s prompt to contain %q, got:\n%s", providerType, want, got)
+			}
+		}
+		// Files without content only show their diff
+		if strings.Count(got, "FULL FILE CONTENT (STAGED VERSION):") != 1 {
+			t.Errorf("Expected the content of one file, got:\n%s", got)
+		}
+	}
+
+	t.Run("Combined diff", func(t *testing.T) {
+		got := GenerateReviewPrompt("File: lib/lib.go\n+func Add()\n\nFile: app/app.go\n+lib.Add()\n",
+			map[string]string{"lib/lib.go": "package lib"}, "openai")
+		if !strings.Contains(got, "span 2 files") || !strings.Contains(got, "File: app/app.go") || strings.Contains(got, "{{") {
+			t.Errorf("Expected a changeset prompt, got:\n%s", got)
+		}
+	})
+
+	t.Run("Checklists of every language", func(t *testing.T) {
+		checklists, err := Checklists(nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		request.Options.Checklists = checklists
+		got := CreateSystemPrompt(request, ProviderOpenAI)
+		if !strings.Contains(got, "GO REVIEW CHECKLIST:") || !strings.Contains(got, "PYTHON REVIEW CHECKLIST:") {
+			t.Errorf("Expected the Go and Python checklists, got:\n%s", got)
+		}
+	})
+}
@@ -2,68 +2,59 @@ package prompt
 
 import (
 	"strings"
-	"path/filepath"
+
+	"github.com/niels/git-llm-review/pkg/llm"
 )
 
-// GenerateReviewPrompt creates a prompt for code review based on the provided diff and file content
+// GenerateReviewPrompt creates a prompt for code review based on the provided diff and file content.
+// The diff holds a section for each file that starts with a "File: <path>" line; several files
+// are reviewed together in one changeset prompt.
 func GenerateReviewPrompt(diff string, fileContent map[string]string, provider string) string {
-	// Get the appropriate template based on the provider
-	var template string
-	switch strings.ToLower(provider) {
-	case "anthropic":
-		template = AnthropicTemplate
-	default:
-		template = OpenAITemplate
+	files := splitCombinedDiff(diff, fileContent)
+	if len(files) == 0 {
+		return ""
+	}
+
+	request := &llm.ReviewRequest{
+		FilePath:    files[0].FilePath,
+		FileContent: files[0].FileContent,
+		FileDiff:    files[0].FileDiff,
+	}
+	if len(files) > 1 {
+		request.Files = files
+	}
+
+	// Providers other than Anthropic get the OpenAI prompt
+	providerType := ProviderTypeFor(provider)
+	if providerType == ProviderDefault {
+		providerType = ProviderOpenAI
 	}
+	return CreatePrompt(request, providerType)
+}
 
-	// Create the final prompt by replacing placeholders
-	var result strings.Builder
+// splitCombinedDiff splits a diff with a "File: <path>" section for each file
+// into the files of a changeset review
+func splitCombinedDiff(diff string, fileContent map[string]string) []llm.ReviewFile {
+	var files []llm.ReviewFile
 
-	// Split the diff by files 
+	// Split the diff by files
 	diffParts := strings.Split(diff, "File: ")
-	
+
 	// The first element is empty because diff starts with "File: ", so we skip it
-	if len(diffParts) > 1 {
-		for i := 1; i < len(diffParts); i++ {
-			diffPart := diffParts[i]
-			
-			// Extract file path - it's the first line of each part
-			lines := strings.SplitN(diffPart, "\n", 2)
-			if len(lines) < 2 {
-				continue
-			}
-			
-			filePath := strings.TrimSpace(lines[0])
-			fileDiff := lines[1]
-			
-			// Get file content if available
-			content, hasContent := fileContent[filePath]
-			
-			// Get language based on file extension
-			ext := filepath.Ext(filePath)
-			language := ""
-			if ext != "" {
-				language = ext[1:] // remove the dot
-			}
-			
-			// Replace placeholders in the template
-			filePrompt := template
-			filePrompt = strings.ReplaceAll(filePrompt, "{{.FilePath}}", filePath)
-			filePrompt = strings.ReplaceAll(filePrompt, "{{.FileDiff}}", fileDiff)
-			filePrompt = strings.ReplaceAll(filePrompt, "{{.Language}}", language)
-			
-			if hasContent {
-				filePrompt = strings.ReplaceAll(filePrompt, "{{.FileContent}}", content)
-				filePrompt = strings.ReplaceAll(filePrompt, "{{.HasFileContent}}", "true")
-			} else {
-				filePrompt = strings.ReplaceAll(filePrompt, "{{.FileContent}}", "")
-				filePrompt = strings.ReplaceAll(filePrompt, "{{.HasFileContent}}", "false")
-			}
-			
-			result.WriteString(filePrompt)
-			result.WriteString("\n\n")
+	for i := 1; i < len(diffParts); i++ {
+		// Extract file path - it's the first line of each part
+		lines := strings.SplitN(diffParts[i], "\n", 2)
+		if len(lines) < 2 {
+			continue
 		}
+
+		filePath := strings.TrimSpace(lines[0])
+		files = append(files, llm.ReviewFile{
+			FilePath:    filePath,
+			FileContent: fileContent[filePath],
+			FileDiff:    strings.TrimRight(lines[1], "\n"),
+		})
 	}
-	
-	return result.String()
+
+	return files
 }
@@ -0,0 +1,96 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TestSuggestionHeading starts the prompt that asks for missing tests, so
+// that it can be told apart from review prompts
+const TestSuggestionHeading = "Suggest the missing test cases for the changed code"
+
+// testMaxDiffChars limits the size of the diff in a test prompt
+const testMaxDiffChars = 20000
+
+// testMaxExampleChars limits the size of each test file shown in a test prompt
+const testMaxExampleChars = 12000
+
+// codeBlockPattern finds the content of the first fenced code block
+var codeBlockPattern = regexp.MustCompile("(?s)```[^\n]*\n(.*?)\n```")
+
+// TestSuggestionRequest describes the changed functions of a file to suggest
+// tests for
+type TestSuggestionRequest struct {
+	// FilePath is the changed file and TestPath the test file to write
+	FilePath string
+	TestPath string
+	// Functions are the functions touched by the change
+	Functions []string
+	// Diff is the change to the file
+	Diff string
+	// ExistingTests is the content of the test file at TestPath, if it exists
+	ExistingTests string
+	// StylePath is a test file of the project near the changed file, and
+	// StyleExample its content, showing the style to follow
+	StylePath    string
+	StyleExample string
+}
+
+// CreateTestSuggestionPrompt creates the prompt that asks for a runnable
+// test file with the test cases the changed functions of a file are missing,
+// in the style of the project's tests
+func CreateTestSuggestionPrompt(request TestSuggestionRequest) string {
+	language := GetLanguageFromFilePath(request.FilePath)
+
+	var sb strings.Builder
+	sb.WriteString(TestSuggestionHeading + " in " + request.FilePath + ".\n\n")
+	sb.WriteString("For each changed function below, work out the cases its tests should cover: the usual inputs, edge cases, error paths and the behavior the diff changes. ")
+	sb.WriteString("Write test cases for the ones that are not tested yet.\n\n")
+	fmt.Fprintf(&sb, "Answer with the complete, runnable test file %s in a single code block and nothing else. ", request.TestPath)
+	sb.WriteString("Follow the style of the project's tests: the same test framework, package, imports, helpers, naming and layout of cases. ")
+	sb.WriteString("Test through the public behavior, do not change the code under test, and where the expected value cannot be known from the code, ")
+	sb.WriteString("leave a TODO comment instead of guessing. Do not review the code and do not answer in JSON.\n")
+	if request.ExistingTests != "" {
+		fmt.Fprintf(&sb, "\nThe test file %s already exists. Do not repeat its tests, and name the new tests so that they do not clash with it.\n", request.TestPath)
+	}
+
+	sb.WriteString("\nCHANGED FUNCTIONS:\n")
+	for _, function := range request.Functions {
+		sb.WriteString(FormatCodeBlock(strings.TrimRight(function, "\n"), language) + "\n")
+	}
+
+	diff := strings.TrimRight(request.Diff, "\n")
+	if len(diff) > testMaxDiffChars {
+		diff = truncateAtLine(diff, testMaxDiffChars-len(diffTruncationNote)) + diffTruncationNote
+	}
+	sb.WriteString("\nDIFF:\n" + FormatDiffBlock(diff) + "\n")
+
+	if request.ExistingTests != "" {
+		fmt.Fprintf(&sb, "\nEXISTING TESTS IN %s:\n%s\n", request.TestPath,
+			FormatCodeBlock(limitExample(request.ExistingTests), GetLanguageFromFilePath(request.TestPath)))
+	}
+	if request.StyleExample != "" && request.StylePath != request.TestPath {
+		fmt.Fprintf(&sb, "\nTESTS OF THE PROJECT TO TAKE THE STYLE FROM, %s:\n%s\n", request.StylePath,
+			FormatCodeBlock(limitExample(request.StyleExample), GetLanguageFromFilePath(request.StylePath)))
+	}
+	return sb.String()
+}
+
+// ExtractTestCode returns the code of the test file in the answer to a test
+// prompt: the content of its first code block, or the whole answer without one
+func ExtractTestCode(text string) string {
+	if match := codeBlockPattern.FindStringSubmatch(text); match != nil {
+		return strings.TrimSpace(match[1]) + "\n"
+	}
+	return strings.TrimSpace(text) + "\n"
+}
+
+// limitExample cuts a test file shown in a test prompt to its first lines
+func limitExample(content string) string {
+	content = strings.TrimRight(content, "\n")
+	if len(content) > testMaxExampleChars {
+		content = truncateAtLine(content, testMaxExampleChars) + "\n[... rest of the file left out ...]"
+	}
+	return content
+}
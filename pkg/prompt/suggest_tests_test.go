@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCreateTestSuggestionPrompt(t *testing.T) {
+	request := TestSuggestionRequest{
+		FilePath:     "calc/calc.go",
+		TestPath:     "calc/calc_test.go",
+		Functions:    []string{"func Sub(a, b int) int {\n\treturn a - b\n}"},
+		Diff:         "@@ -7,3 +7,3 @@\n-\treturn b - a\n+\treturn a - b\n",
+		StylePath:    "calc/add_test.go",
+		StyleExample: "package calc\n\nfunc TestAdd(t *testing.T) {}\n",
+	}
+
+	testPrompt := CreateTestSuggestionPrompt(request)
+	if !strings.HasPrefix(testPrompt, TestSuggestionHeading+" in calc/calc.go.\n") {
+		t.Errorf("Expected the prompt to start with the heading and the file, got:\n%s", testPrompt)
+	}
+	for _, expected := range []string{
+		"runnable test file calc/calc_test.go",
+		"CHANGED FUNCTIONS:\n```go\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n```",
+		"+\treturn a - b",
+		"TESTS OF THE PROJECT TO TAKE THE STYLE FROM, calc/add_test.go:\n```go\npackage calc",
+	} {
+		if !strings.Contains(testPrompt, expected) {
+			t.Errorf("Expected the prompt to contain %q, got:\n%s", expected, testPrompt)
+		}
+	}
+	if strings.Contains(testPrompt, "EXISTING TESTS") {
+		t.Error("Expected no existing tests")
+	}
+
+	// The existing tests of the file are shown once, not again as the style
+	request.StylePath = request.TestPath
+	request.ExistingTests = "package calc\n\nfunc TestSub(t *testing.T) {}\n"
+	request.StyleExample = request.ExistingTests
+	testPrompt = CreateTestSuggestionPrompt(request)
+	if !strings.Contains(testPrompt, "EXISTING TESTS IN calc/calc_test.go:") || !strings.Contains(testPrompt, "do not clash") {
+		t.Errorf("Expected the existing tests, got:\n%s", testPrompt)
+	}
+	if strings.Contains(testPrompt, "TAKE THE STYLE FROM") {
+		t.Error("Expected the existing tests not to be repeated as the style example")
+	}
+}
+
+func TestExtractTestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{
+			name:     "Code block",
+			text:     "Here are the tests:\n```python\ndef test_greet():\n    pass\n```\nThey cover greet.",
+			expected: "def test_greet():\n    pass\n",
+		},
+		{
+			name:     "Plain code",
+			text:     "\npackage calc\n\nfunc TestSub(t *testing.T) {}\n\n",
+			expected: "package calc\n\nfunc TestSub(t *testing.T) {}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractTestCode(tt.text); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
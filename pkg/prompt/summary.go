@@ -0,0 +1,119 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+// SummaryHeading starts the prompt that asks for a summary of a change, so
+// that it can be told apart from review prompts
+const SummaryHeading = "Summarize the following code change"
+
+// summaryMaxDiffChars limits the size of the diffs in a summary prompt;
+// the files whose diffs do not fit are only listed
+const summaryMaxDiffChars = 40000
+
+// diffTruncationNote marks a diff that was cut short in a summary prompt
+const diffTruncationNote = "\n[... diff truncated ...]"
+
+// diffStatWidth is the width of the widest bar of a diff stat
+const diffStatWidth = 40
+
+// CreateSummaryPrompt creates the prompt that asks for a concise summary of a
+// change to files, which gives the review of each file the context of the
+// whole change
+func CreateSummaryPrompt(files []llm.ReviewFile) string {
+	var sb strings.Builder
+	sb.WriteString(SummaryHeading + " for the reviewers of its files.\n\n")
+	sb.WriteString("Write at most 200 words of plain text or a short list covering:\n")
+	sb.WriteString("1. The purpose of the change, as far as the diffs show it\n")
+	sb.WriteString("2. The files touched and their role in the change\n")
+	sb.WriteString("3. Key API changes: added, removed or changed exported functions, types, signatures, configuration and behavior that other code depends on\n\n")
+	sb.WriteString("Do not review the code, do not report issues and do not answer in JSON.\n\n")
+
+	sb.WriteString("FILES CHANGED:\n")
+	sb.WriteString("```\n" + FormatDiffStat(files) + "```\n")
+
+	omitted := 0
+	for _, file := range files {
+		heading := "\nFile: " + file.FilePath + "\n"
+		remaining := summaryMaxDiffChars - sb.Len() - len(heading)
+		if remaining < minGuidelineChars {
+			omitted++
+			continue
+		}
+
+		diff := file.FileDiff
+		if len(diff) > remaining {
+			diff = truncateAtLine(diff, remaining-len(diffTruncationNote)) + diffTruncationNote
+		}
+		sb.WriteString(heading)
+		sb.WriteString(FormatDiffBlock(diff) + "\n")
+	}
+	if omitted > 0 {
+		fmt.Fprintf(&sb, "\n[The diffs of %d more files were left out to keep the prompt short]\n", omitted)
+	}
+
+	return sb.String()
+}
+
+// FormatDiffStat formats the number of lines added and removed in each file,
+// like git diff --stat
+func FormatDiffStat(files []llm.ReviewFile) string {
+	if len(files) == 0 {
+		return ""
+	}
+
+	nameWidth, maxChanges := 0, 0
+	added := make([]int, len(files))
+	removed := make([]int, len(files))
+	for i, file := range files {
+		added[i], removed[i] = countChangedLines(file.FileDiff)
+		if len(file.FilePath) > nameWidth {
+			nameWidth = len(file.FilePath)
+		}
+		if added[i]+removed[i] > maxChanges {
+			maxChanges = added[i] + removed[i]
+		}
+	}
+
+	var sb strings.Builder
+	totalAdded, totalRemoved := 0, 0
+	for i, file := range files {
+		plus, minus := added[i], removed[i]
+		if maxChanges > diffStatWidth {
+			plus = (plus*diffStatWidth + maxChanges - 1) / maxChanges
+			minus = (minus*diffStatWidth + maxChanges - 1) / maxChanges
+		}
+		line := fmt.Sprintf(" %-*s | %d %s%s", nameWidth, file.FilePath, added[i]+removed[i],
+			strings.Repeat("+", plus), strings.Repeat("-", minus))
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+		totalAdded += added[i]
+		totalRemoved += removed[i]
+	}
+	fmt.Fprintf(&sb, " %s changed, %s(+), %s(-)\n",
+		plural(len(files), "file"), plural(totalAdded, "insertion"), plural(totalRemoved, "deletion"))
+	return sb.String()
+}
+
+// plural formats a count of things, like "1 file" or "2 files"
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// FormatChangeSummary returns the section of the system prompt that holds the
+// summary of the whole change, or an empty string if there is none
+func FormatChangeSummary(request *llm.ReviewRequest) string {
+	summary := strings.TrimSpace(request.Options.ChangeSummary)
+	if summary == "" {
+		return ""
+	}
+	return "CONTEXT OF THE OVERALL CHANGE:\n" +
+		"The reviewed code is part of a larger change, summarized below. Use the summary to understand the purpose of the changes and how they relate to other files, but only report issues in the reviewed code.\n\n" +
+		summary + "\n"
+}
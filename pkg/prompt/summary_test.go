@@ -0,0 +1,65 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+)
+
+func TestCreateSummaryPrompt(t *testing.T) {
+	files := []llm.ReviewFile{
+		{FilePath: "lib/lib.go", FileDiff: "@@ -1 +1 @@\n-func Add(a, b int) int\n+func Add(a, b, c int) int"},
+		{FilePath: "app/app.go", FileDiff: "@@ -1 +1,2 @@\n+import \"example.com/demo/lib\"\n+var x = lib.Add(1, 2, 3)"},
+	}
+
+	t.Run("Stat and diffs", func(t *testing.T) {
+		summaryPrompt := CreateSummaryPrompt(files)
+		if !strings.HasPrefix(summaryPrompt, SummaryHeading) {
+			t.Errorf("Expected the prompt to start with %q", SummaryHeading)
+		}
+		for _, expected := range []string{
+			" lib/lib.go | 2 +-\n",
+			" app/app.go | 2 ++\n",
+			" 2 files changed, 3 insertions(+), 1 deletion(-)",
+			"File: lib/lib.go\n",
+			"+func Add(a, b, c int) int",
+			"File: app/app.go\n",
+		} {
+			if !strings.Contains(summaryPrompt, expected) {
+				t.Errorf("Expected the prompt to contain %q, got:\n%s", expected, summaryPrompt)
+			}
+		}
+	})
+
+	t.Run("Large diffs are cut", func(t *testing.T) {
+		large := []llm.ReviewFile{
+			{FilePath: "big.go", FileDiff: strings.Repeat("+// filler line\n", 5000)},
+			{FilePath: "other.go", FileDiff: "+change"},
+		}
+		summaryPrompt := CreateSummaryPrompt(large)
+		if len(summaryPrompt) > summaryMaxDiffChars+500 {
+			t.Errorf("Expected the prompt to stay within the budget, got %d characters", len(summaryPrompt))
+		}
+		if !strings.Contains(summaryPrompt, diffTruncationNote) {
+			t.Error("Expected the large diff to be truncated")
+		}
+		if !strings.Contains(summaryPrompt, " other.go | 1 +") || !strings.Contains(summaryPrompt, "1 more files were left out") {
+			t.Errorf("Expected the left out file to be listed in the stat only, got:\n%s", summaryPrompt[len(summaryPrompt)-300:])
+		}
+	})
+}
+
+func TestFormatChangeSummary(t *testing.T) {
+	request := &llm.ReviewRequest{FilePath: "app/app.go", FileDiff: "+change"}
+	if section := FormatChangeSummary(request); section != "" {
+		t.Errorf("Expected no section without a summary, got %q", section)
+	}
+
+	request.Options.ChangeSummary = "Adds a third argument to lib.Add.\n"
+	systemPrompt := CreateSystemPrompt(request, ProviderDefault)
+	if !strings.Contains(systemPrompt, "CONTEXT OF THE OVERALL CHANGE:") ||
+		!strings.Contains(systemPrompt, "Adds a third argument to lib.Add.") {
+		t.Errorf("Expected the summary in the system prompt, got:\n%s", systemPrompt)
+	}
+}
@@ -1,5 +1,10 @@
 package prompt
 
+import (
+	"fmt"
+	"strings"
+)
+
 // SystemPromptType represents the type of system prompt
 type SystemPromptType string
 
@@ -8,10 +13,175 @@ const (
 	SystemPromptReview SystemPromptType = "review"
 	// SystemPromptExplain is a prompt for code explanation
 	SystemPromptExplain SystemPromptType = "explain"
+
+	// Focused reviews look at one kind of issue in depth
+	SystemPromptSecurity    SystemPromptType = "security"
+	SystemPromptPerformance SystemPromptType = "performance"
+	SystemPromptConcurrency SystemPromptType = "concurrency"
+	SystemPromptTests       SystemPromptType = "tests"
+	SystemPromptAPICompat   SystemPromptType = "api-compat"
+	SystemPromptReadability SystemPromptType = "readability"
 )
 
+// focusPrompt describes what a focused review looks for
+type focusPrompt struct {
+	title     string
+	intro     string
+	checklist []string
+}
+
+// focusPrompts holds the focus of each focused review type
+var focusPrompts = map[SystemPromptType]focusPrompt{
+	SystemPromptSecurity: {
+		title: "Security",
+		intro: "Review the changes as a security engineer. Report only issues an attacker could exploit or that weaken the security of the system.",
+		checklist: []string{
+			"Injection: SQL, shell commands, templates, paths and log lines built from untrusted input",
+			"Authentication and authorization checks that are missing, bypassable or done in the wrong order",
+			"Secrets, tokens or personal data that are hard-coded, logged or returned to clients",
+			"Cryptography: weak algorithms, predictable randomness, disabled certificate verification",
+			"Unvalidated sizes, indexes and lengths that allow out-of-bounds access or resource exhaustion",
+			"Unsafe deserialization, file uploads, redirects and server-side requests to user-controlled URLs",
+		},
+	},
+	SystemPromptPerformance: {
+		title: "Performance",
+		intro: "Review the changes for performance. Report only issues with a measurable cost in time, memory or I/O on realistic inputs.",
+		checklist: []string{
+			"Work repeated inside loops that could be done once, such as compiling patterns or opening connections",
+			"Algorithms with a worse complexity than needed, such as nested loops over large collections",
+			"Allocations in hot paths, string concatenation in loops and buffers that are not reused or presized",
+			"N+1 queries, unbatched I/O and network calls without timeouts",
+			"Unbounded caches, queues or slices that grow with the input",
+		},
+	},
+	SystemPromptConcurrency: {
+		title: "Concurrency",
+		intro: "Review the changes for concurrency bugs. Report only issues that can cause data races, deadlocks, leaks or wrong results under concurrent use.",
+		checklist: []string{
+			"Shared state read or written without synchronization",
+			"Locks taken in inconsistent order, held across blocking calls, or not released on every path",
+			"Goroutines, threads or tasks that are never stopped, or that block forever on a channel",
+			"Cancellation and timeouts that are not propagated to the work they should stop",
+			"Check-then-act sequences that are not atomic",
+		},
+	},
+	SystemPromptTests: {
+		title: "Tests",
+		intro: "Review the changes for test coverage and test quality. Report changed behaviour that is untested and tests that do not check what they claim.",
+		checklist: []string{
+			"New or changed behaviour, including error paths and edge cases, without a test",
+			"Tests without assertions, or with assertions that cannot fail",
+			"Tests that depend on timing, ordering, the network or shared global state",
+			"Tests that were removed or weakened along with the change",
+			"Test names and failure messages that do not say what went wrong",
+		},
+	},
+	SystemPromptAPICompat: {
+		title: "API compatibility",
+		intro: "Review the changes for compatibility with existing users of the code. Report only changes that can break callers, clients or stored data.",
+		checklist: []string{
+			"Exported functions, types, fields or constants that were removed, renamed or changed signature",
+			"Changed defaults, error values or behaviour that callers may rely on",
+			"Changes to wire formats, file formats, database schemas or configuration keys without migration",
+			"HTTP or RPC endpoints, status codes and fields that changed meaning",
+			"Deprecations without a documented replacement",
+		},
+	},
+	SystemPromptReadability: {
+		title: "Readability",
+		intro: "Review the changes for readability and maintainability. Report only issues that make the code harder to understand or change safely.",
+		checklist: []string{
+			"Names that do not say what a value or function is for",
+			"Functions that do too many things or nest too deeply",
+			"Duplicated logic that should be shared",
+			"Comments that are missing where the code is not obvious, or that no longer match the code",
+			"Magic numbers and strings that should be named constants",
+		},
+	},
+}
+
+// FocusTypes returns the focused review types in a fixed order
+func FocusTypes() []SystemPromptType {
+	return []SystemPromptType{
+		SystemPromptSecurity,
+		SystemPromptPerformance,
+		SystemPromptConcurrency,
+		SystemPromptTests,
+		SystemPromptAPICompat,
+		SystemPromptReadability,
+	}
+}
+
+// IsFocus reports whether promptType is a focused review type
+func IsFocus(promptType SystemPromptType) bool {
+	_, ok := focusPrompts[promptType]
+	return ok
+}
+
+// ParseFocus checks the names of focused review types, such as "security",
+// and returns them without duplicates
+func ParseFocus(names []string) ([]SystemPromptType, error) {
+	var foci []SystemPromptType
+	seen := make(map[SystemPromptType]bool)
+	for _, name := range names {
+		focus := SystemPromptType(strings.ToLower(strings.TrimSpace(name)))
+		if focus == "" || seen[focus] {
+			continue
+		}
+		if !IsFocus(focus) {
+			valid := make([]string, 0, len(focusPrompts))
+			for _, focusType := range FocusTypes() {
+				valid = append(valid, string(focusType))
+			}
+			return nil, fmt.Errorf("unknown review focus %q (expected one of: %s)", name, strings.Join(valid, ", "))
+		}
+		seen[focus] = true
+		foci = append(foci, focus)
+	}
+	return foci, nil
+}
+
+// FocusSection returns the part of the system prompt that asks for a review
+// with the given foci. With more than one focus, the model is asked to name
+// the focus of each issue.
+func FocusSection(foci []SystemPromptType) string {
+	if len(foci) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i, focus := range foci {
+		prompt, ok := focusPrompts[focus]
+		if !ok {
+			continue
+		}
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "FOCUS: %s\n%s\nCheck in particular for:\n", prompt.title, prompt.intro)
+		for _, item := range prompt.checklist {
+			fmt.Fprintf(&sb, "- %s\n", item)
+		}
+	}
+
+	if len(foci) > 1 {
+		names := make([]string, len(foci))
+		for i, focus := range foci {
+			names[i] = fmt.Sprintf("%q", focus)
+		}
+		fmt.Fprintf(&sb, "\nAdd a \"focus\" field to each issue with the focus it belongs to: %s.\n", strings.Join(names, ", "))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
 // GetSystemPrompt returns the system prompt for the specified provider and type
 func GetSystemPrompt(providerType ProviderType, promptType SystemPromptType) string {
+	// A focused review is a review with a focus section
+	if IsFocus(promptType) {
+		return GetSystemPrompt(providerType, SystemPromptReview) + "\n\n" + FocusSection([]SystemPromptType{promptType})
+	}
+
 	// Get the base prompt by type
 	basePrompt := getBaseSystemPrompt(promptType)
 
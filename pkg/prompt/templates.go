@@ -1,6 +1,9 @@
 package prompt
 
 import (
+	"path/filepath"
+	"strings"
+
 	"github.com/niels/git-llm-review/pkg/llm"
 )
 
@@ -129,6 +132,53 @@ Provide your response in the JSON format specified above.
 {{.AdditionalInstructions}}
 `
 
+// ChangesetTemplate is the template for reviewing several related files in
+// one request, with a "File:" section for each file
+const ChangesetTemplate = `You are a code review assistant. Please review the following code changes, which were made together, and provide feedback.
+
+Your response should be in JSON format with the following structure:
+{
+  "issues": [
+    {
+      "title": "Issue title (e.g., 'Bug: Potential null pointer', 'Style: Inconsistent naming')",
+      "explanation": "Detailed explanation of the issue",
+      "file": "The file path where the issue is found"
+    }
+  ],
+  "diffs": [
+    {
+      "file": "The file path",
+      "diff": "Consolidated diff showing all suggested fixes for this file"
+    }
+  ]
+}
+
+IMPORTANT: The changes span {{len .Files}} files. Review them as one change: look for changes in one file that break code in another, such as a changed function signature, type or constant whose callers were not updated, and for changes that do not agree with each other.
+
+IMPORTANT: Set the "file" of every issue and diff to the path of the file it applies to, exactly as given after "File:" below. Report an issue that spans files in the file that needs to be fixed.
+
+IMPORTANT: Group all issues by file and provide only ONE consolidated diff per file that addresses all issues for that file. The FULL FILE CONTENT shows the STAGED version of each file; make your diff suggestions AGAINST THIS STAGED VERSION.
+{{range .Files}}
+File: {{.FilePath}}
+{{if .HasFileContent}}
+FULL FILE CONTENT (STAGED VERSION):
+` + "```{{.Language}}\n{{.FileContent}}\n```" + `
+{{end}}
+DIFF (CHANGES MADE FROM ORIGINAL TO STAGED):
+` + "```diff\n{{.FileDiff}}\n```" + `
+{{end}}
+Remember to focus on:
+1. Bugs and potential issues, including those between the files
+2. Code style and best practices
+3. Performance concerns
+4. Security vulnerabilities
+5. Maintainability and readability
+
+Provide your response in the JSON format specified above.
+
+{{.AdditionalInstructions}}
+`
+
 // TemplateData holds the data to be used in templates
 type TemplateData struct {
 	FilePath               string
@@ -137,16 +187,59 @@ type TemplateData struct {
 	Language               string
 	AdditionalInstructions string
 	HasFileContent         bool
+
+	// FileName and Extension are the base name and extension of FilePath
+	FileName  string
+	Extension string
+
+	// AddedLines and RemovedLines count the changed lines in FileDiff
+	AddedLines   int
+	RemovedLines int
+
+	// Provider is the name of the provider the prompt is sent to, such as
+	// "openai" or "anthropic"
+	Provider string
+
+	// Focus lists the kinds of issues the review concentrates on, such as
+	// "security"; empty for a general review
+	Focus []string
+
+	// Files are the files of a changeset review; empty when one file is
+	// reviewed
+	Files []TemplateFile
+}
+
+// TemplateFile holds the data of one file of a changeset review
+type TemplateFile struct {
+	FilePath       string
+	FileContent    string
+	FileDiff       string
+	Language       string
+	HasFileContent bool
 }
 
 // NewTemplateData creates a new TemplateData from a review request
 func NewTemplateData(request *llm.ReviewRequest) *TemplateData {
 	// Default values
 	data := &TemplateData{
-		FilePath:       request.FilePath,
-		FileContent:    request.FileContent,
-		FileDiff:       request.FileDiff,
-		HasFileContent: request.FileContent != "",
+		FilePath:               request.FilePath,
+		FileContent:            request.FileContent,
+		FileDiff:               request.FileDiff,
+		HasFileContent:         request.FileContent != "",
+		AdditionalInstructions: request.Options.AdditionalInstructions,
+		FileName:               filepath.Base(request.FilePath),
+		Extension:              strings.TrimPrefix(filepath.Ext(request.FilePath), "."),
+		Focus:                  request.Options.Focus,
+	}
+	data.AddedLines, data.RemovedLines = countChangedLines(request.FileDiff)
+	for _, file := range request.Files {
+		data.Files = append(data.Files, TemplateFile{
+			FilePath:       file.FilePath,
+			FileContent:    file.FileContent,
+			FileDiff:       file.FileDiff,
+			Language:       GetLanguageFromFilePath(file.FilePath),
+			HasFileContent: file.FileContent != "",
+		})
 	}
 
 	// Detect language from file extension if not provided
@@ -156,3 +249,18 @@ func NewTemplateData(request *llm.ReviewRequest) *TemplateData {
 
 	return data
 }
+
+// countChangedLines counts the added and removed lines of a diff, leaving
+// out the file headers
+func countChangedLines(diff string) (added, removed int) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	return added, removed
+}
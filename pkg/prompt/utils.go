@@ -60,22 +60,8 @@ func EnhancePromptWithLanguageContext(prompt string, language string) string {
 	}
 	
 	// Add language-specific context based on the file type
-	var languageContext string
-	
-	switch strings.ToLower(language) {
-	case "go":
-		languageContext = "When reviewing Go code, consider Go's idioms and best practices such as error handling patterns, proper use of interfaces, and following the Go style guide."
-	case "javascript", "typescript":
-		languageContext = "When reviewing JavaScript/TypeScript code, look for common issues like type safety, async/await patterns, and modern ES6+ features."
-	case "python":
-		languageContext = "When reviewing Python code, consider PEP 8 style guidelines, proper exception handling, and Pythonic idioms."
-	case "java":
-		languageContext = "When reviewing Java code, look for proper exception handling, design patterns, and object-oriented principles."
-	case "ruby":
-		languageContext = "When reviewing Ruby code, consider Ruby idioms, proper use of blocks, and following the Ruby style guide."
-	case "rust":
-		languageContext = "When reviewing Rust code, look for proper memory management, use of ownership/borrowing, and idiomatic Rust patterns."
-	default:
+	languageContext, ok := languageContexts[strings.ToLower(language)]
+	if !ok {
 		// No specific context for other languages
 		return prompt
 	}
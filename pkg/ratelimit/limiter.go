@@ -0,0 +1,297 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/logging"
+)
+
+// Options configures a Limiter
+type Options struct {
+	// RequestsPerMinute limits the number of requests started per minute (0 = unlimited)
+	RequestsPerMinute int
+
+	// TokensPerMinute limits the number of estimated prompt tokens sent per minute (0 = unlimited)
+	TokensPerMinute int
+
+	// MaxConcurrency is the maximum number of requests in flight (0 = unlimited)
+	MaxConcurrency int
+
+	// Adaptive halves the concurrency limit when the server signals rate
+	// limiting and raises it again after successful requests
+	Adaptive bool
+
+	// MaxPause limits how long a delay requested by the server holds back
+	// new requests (DefaultMaxPause if 0)
+	MaxPause time.Duration
+}
+
+// DefaultMaxPause is the longest a delay requested by the server holds back
+// new requests unless configured otherwise
+const DefaultMaxPause = 5 * time.Minute
+
+// Outcome describes the result of a request made under a Limiter
+type Outcome struct {
+	// RateLimited is true if the server rejected the request because of rate limiting
+	RateLimited bool
+
+	// Success is true if the server accepted the request
+	Success bool
+
+	// RetryAfter is the delay requested by the server before the next request
+	RetryAfter time.Duration
+}
+
+// Limiter limits requests to a shared endpoint using token buckets for
+// requests and tokens per minute, and an adaptive limit on concurrent
+// requests. A Limiter is safe for concurrent use by multiple workers.
+type Limiter struct {
+	mu sync.Mutex
+
+	requests *bucket
+	tokens   *bucket
+
+	adaptive       bool
+	maxConcurrency int
+	concurrency    int
+	inFlight       int
+	successes      int
+	pausedUntil    time.Time
+	maxPause       time.Duration
+
+	// changed is closed and replaced whenever a slot is released
+	changed chan struct{}
+
+	now func() time.Time
+}
+
+// New creates a new Limiter with the given options
+func New(opts Options) *Limiter {
+	l := &Limiter{
+		adaptive:       opts.Adaptive,
+		maxConcurrency: opts.MaxConcurrency,
+		concurrency:    opts.MaxConcurrency,
+		maxPause:       opts.MaxPause,
+		changed:        make(chan struct{}),
+		now:            time.Now,
+	}
+
+	if l.maxPause <= 0 {
+		l.maxPause = DefaultMaxPause
+	}
+
+	now := l.now()
+	if opts.RequestsPerMinute > 0 {
+		l.requests = newBucket(opts.RequestsPerMinute, now)
+	}
+	if opts.TokensPerMinute > 0 {
+		l.tokens = newBucket(opts.TokensPerMinute, now)
+	}
+
+	return l
+}
+
+// Concurrency returns the current limit on concurrent requests (0 = unlimited)
+func (l *Limiter) Concurrency() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.concurrency
+}
+
+// Wait blocks until a request with the given estimated token count may be
+// sent, or until ctx is done. The returned function must be called with the
+// outcome of the request once it has completed.
+func (l *Limiter) Wait(ctx context.Context, tokens int) (func(Outcome), error) {
+	logged := false
+	for {
+		l.mu.Lock()
+		delay := l.reserve(tokens)
+		changed := l.changed
+		l.mu.Unlock()
+
+		if delay == 0 {
+			return l.release, nil
+		}
+
+		if !logged {
+			logging.DebugWith("Waiting for rate limiter", map[string]interface{}{
+				"delay":  delay.String(),
+				"tokens": tokens,
+			})
+			logged = true
+		}
+
+		// Wait for the computed delay, a released slot or cancellation
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a concurrency slot and the bucket capacity for one request
+// if they are all available and returns 0. Otherwise it returns how long to
+// wait before trying again. The caller must hold l.mu.
+func (l *Limiter) reserve(tokens int) time.Duration {
+	now := l.now()
+
+	// Honour a pause requested by the server
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	// Wait for a slot to be released; the delay is only an upper bound
+	if l.concurrency > 0 && l.inFlight >= l.concurrency {
+		return time.Second
+	}
+
+	var delay time.Duration
+	if l.requests != nil {
+		delay = maxDuration(delay, l.requests.delay(1, now))
+	}
+	if l.tokens != nil {
+		delay = maxDuration(delay, l.tokens.delay(tokens, now))
+	}
+	if delay > 0 {
+		return delay
+	}
+
+	if l.requests != nil {
+		l.requests.take(1)
+	}
+	if l.tokens != nil {
+		l.tokens.take(tokens)
+	}
+	l.inFlight++
+
+	return 0
+}
+
+// release records the outcome of a request and frees its concurrency slot
+func (l *Limiter) release(outcome Outcome) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+
+	if outcome.RetryAfter > 0 {
+		// A server that gets the reset header wrong must not stall the run
+		pause := outcome.RetryAfter
+		if pause > l.maxPause {
+			logging.WarnWith("Requested pause too long, pausing for the maximum", map[string]interface{}{
+				"retry_after": pause.String(),
+				"max_pause":   l.maxPause.String(),
+			})
+			pause = l.maxPause
+		}
+		if until := l.now().Add(pause); until.After(l.pausedUntil) {
+			l.pausedUntil = until
+		}
+	}
+
+	if l.adaptive && l.concurrency > 0 {
+		switch {
+		case outcome.RateLimited:
+			// Back off multiplicatively
+			previous := l.concurrency
+			l.concurrency = maxInt(1, l.concurrency/2)
+			l.successes = 0
+			if l.concurrency != previous {
+				logging.InfoWith("Rate limited by provider, reducing concurrency", map[string]interface{}{
+					"concurrency": l.concurrency,
+					"retry_after": outcome.RetryAfter.String(),
+				})
+			}
+		case outcome.Success:
+			// Ramp up additively after a full window of successes
+			l.successes++
+			if l.successes >= l.concurrency && l.concurrency < l.maxConcurrency {
+				l.concurrency++
+				l.successes = 0
+				logging.DebugWith("Increasing concurrency", map[string]interface{}{
+					"concurrency": l.concurrency,
+				})
+			}
+		}
+	}
+
+	// Wake up waiting workers
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// bucket is a token bucket that refills continuously up to its capacity
+type bucket struct {
+	capacity   float64
+	available  float64
+	perSecond  float64
+	lastRefill time.Time
+}
+
+// newBucket creates a full bucket that refills perMinute units per minute
+func newBucket(perMinute int, now time.Time) *bucket {
+	return &bucket{
+		capacity:   float64(perMinute),
+		available:  float64(perMinute),
+		perSecond:  float64(perMinute) / 60,
+		lastRefill: now,
+	}
+}
+
+// refill adds the units accumulated since the last refill
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	if elapsed > 0 {
+		b.available += elapsed * b.perSecond
+		if b.available > b.capacity {
+			b.available = b.capacity
+		}
+		b.lastRefill = now
+	}
+}
+
+// delay returns how long to wait until n units can be taken. Requests larger
+// than the capacity are allowed once the bucket is full, so they are delayed
+// rather than blocked forever.
+func (b *bucket) delay(n int, now time.Time) time.Duration {
+	b.refill(now)
+
+	needed := float64(n)
+	if needed > b.capacity {
+		needed = b.capacity
+	}
+	if b.available >= needed {
+		return 0
+	}
+
+	seconds := (needed - b.available) / b.perSecond
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// take removes n units from the bucket, going into debt for oversized requests
+func (b *bucket) take(n int) {
+	b.available -= float64(n)
+}
+
+// maxDuration returns the larger of two durations
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// maxInt returns the larger of two integers
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,298 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for deterministic limiter tests
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestLimiter creates a limiter driven by a fake clock
+func newTestLimiter(opts Options) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := New(opts)
+	l.now = clock.Now
+	if l.requests != nil {
+		l.requests.lastRefill = clock.Now()
+	}
+	if l.tokens != nil {
+		l.tokens.lastRefill = clock.Now()
+	}
+	return l, clock
+}
+
+func TestRequestsPerMinute(t *testing.T) {
+	l, clock := newTestLimiter(Options{RequestsPerMinute: 60})
+
+	// The bucket starts full, so a minute's worth of requests go through at once
+	for i := 0; i < 60; i++ {
+		if delay := l.reserve(0); delay != 0 {
+			t.Fatalf("Request %d: expected no delay, got %v", i, delay)
+		}
+		l.release(Outcome{Success: true})
+	}
+
+	// The next request has to wait for one request to be refilled
+	delay := l.reserve(0)
+	if delay <= 0 || delay > time.Second {
+		t.Errorf("Expected a delay of up to 1s, got %v", delay)
+	}
+
+	// After a second there is room for one more request
+	clock.Advance(time.Second)
+	if delay := l.reserve(0); delay != 0 {
+		t.Errorf("Expected no delay after refill, got %v", delay)
+	}
+}
+
+func TestTokensPerMinute(t *testing.T) {
+	l, clock := newTestLimiter(Options{TokensPerMinute: 600})
+
+	// Use most of the budget
+	if delay := l.reserve(500); delay != 0 {
+		t.Fatalf("Expected no delay, got %v", delay)
+	}
+	l.release(Outcome{Success: true})
+
+	// 200 tokens need 100 more than are available, which takes 10 seconds
+	delay := l.reserve(200)
+	if delay < 9*time.Second || delay > 10*time.Second {
+		t.Errorf("Expected a delay of about 10s, got %v", delay)
+	}
+
+	clock.Advance(10 * time.Second)
+	if delay := l.reserve(200); delay != 0 {
+		t.Errorf("Expected no delay after refill, got %v", delay)
+	}
+}
+
+func TestOversizedRequestAllowedWhenBucketFull(t *testing.T) {
+	l, clock := newTestLimiter(Options{TokensPerMinute: 100})
+
+	// A request larger than the whole budget is allowed from a full bucket
+	if delay := l.reserve(1000); delay != 0 {
+		t.Fatalf("Expected oversized request to be allowed, got delay %v", delay)
+	}
+	l.release(Outcome{Success: true})
+
+	// It leaves the bucket in debt, so the next request waits
+	if delay := l.reserve(10); delay <= 0 {
+		t.Errorf("Expected a delay after an oversized request")
+	}
+
+	clock.Advance(10 * time.Minute)
+	if delay := l.reserve(10); delay != 0 {
+		t.Errorf("Expected no delay once the debt is paid off, got %v", delay)
+	}
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	l, _ := newTestLimiter(Options{MaxConcurrency: 8, Adaptive: true})
+
+	// A rate limited response halves the concurrency
+	l.reserve(0)
+	l.release(Outcome{RateLimited: true})
+	if got := l.Concurrency(); got != 4 {
+		t.Errorf("Expected concurrency 4 after a 429, got %d", got)
+	}
+
+	l.reserve(0)
+	l.release(Outcome{RateLimited: true})
+	l.reserve(0)
+	l.release(Outcome{RateLimited: true})
+	l.reserve(0)
+	l.release(Outcome{RateLimited: true})
+	if got := l.Concurrency(); got != 1 {
+		t.Errorf("Expected concurrency to bottom out at 1, got %d", got)
+	}
+
+	// A window of successes raises the concurrency by one
+	l.reserve(0)
+	l.release(Outcome{Success: true})
+	if got := l.Concurrency(); got != 2 {
+		t.Errorf("Expected concurrency 2 after success, got %d", got)
+	}
+
+	// It never goes above the configured maximum
+	for i := 0; i < 100; i++ {
+		l.reserve(0)
+		l.release(Outcome{Success: true})
+	}
+	if got := l.Concurrency(); got != 8 {
+		t.Errorf("Expected concurrency to be capped at 8, got %d", got)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	l, _ := newTestLimiter(Options{MaxConcurrency: 1})
+
+	release, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Expected first request to proceed, got: %v", err)
+	}
+
+	// The second request must wait until the first one is released
+	acquired := make(chan struct{})
+	go func() {
+		second, err := l.Wait(context.Background(), 0)
+		if err == nil {
+			second(Outcome{Success: true})
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected second request to wait for a free slot")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release(Outcome{Success: true})
+
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected second request to proceed after release")
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	l, _ := newTestLimiter(Options{RequestsPerMinute: 1})
+
+	release, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Expected first request to proceed, got: %v", err)
+	}
+	release(Outcome{Success: true})
+
+	// The bucket is empty for the next minute, so a cancelled wait returns early
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got: %v", err)
+	}
+}
+
+func TestPauseLimitedToMaxPause(t *testing.T) {
+	l, clock := newTestLimiter(Options{MaxPause: time.Minute})
+
+	// A reset header read as decades of seconds must not stall the run
+	l.reserve(0)
+	l.release(Outcome{RateLimited: true, RetryAfter: 55 * 365 * 24 * time.Hour})
+	if delay := l.reserve(0); delay != time.Minute {
+		t.Errorf("Expected the pause to be limited to a minute, got %v", delay)
+	}
+
+	clock.Advance(time.Minute)
+	if delay := l.reserve(0); delay != 0 {
+		t.Errorf("Expected no delay after the pause, got %v", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		expected time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": []string{"3"}}, 3 * time.Second},
+		{"http date", http.Header{"Retry-After": []string{now.Add(5 * time.Second).Format(http.TimeFormat)}}, 5 * time.Second},
+		{"openai reset", http.Header{"X-Ratelimit-Reset-Requests": []string{"1m30s"}}, 90 * time.Second},
+		{"anthropic reset", http.Header{"Anthropic-Ratelimit-Requests-Reset": []string{now.Add(7 * time.Second).Format(time.RFC3339)}}, 7 * time.Second},
+		{"epoch reset", http.Header{"X-Ratelimit-Reset": []string{"1704110420"}}, 20 * time.Second},
+		{"none", http.Header{}, 0},
+		{"invalid", http.Header{"Retry-After": []string{"soon"}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.header, now); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTransportHonoursRetryAfter(t *testing.T) {
+	// The server rate limits the first request and accepts the rest
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	limiter := New(Options{MaxConcurrency: 4, Adaptive: true})
+	client := &http.Client{Transport: NewTransport(nil, limiter)}
+
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"prompt":"hello"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", resp.StatusCode)
+	}
+
+	if got := limiter.Concurrency(); got != 2 {
+		t.Errorf("Expected concurrency 2 after a 429, got %d", got)
+	}
+
+	// The next request is held back until the Retry-After delay has passed
+	start := time.Now()
+	resp, err = client.Post(server.URL, "application/json", strings.NewReader(`{"prompt":"hello"}`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("Expected the request to wait for Retry-After, waited %v", elapsed)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestShared(t *testing.T) {
+	a := Shared("endpoint-a", Options{RequestsPerMinute: 10})
+	b := Shared("endpoint-a", Options{RequestsPerMinute: 20})
+	c := Shared("endpoint-b", Options{RequestsPerMinute: 10})
+
+	if a != b {
+		t.Error("Expected limiters for the same key to be shared")
+	}
+	if a == c {
+		t.Error("Expected limiters for different keys to be separate")
+	}
+}
@@ -0,0 +1,164 @@
+package ratelimit
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// Transport is an http.RoundTripper that sends requests through a Limiter
+type Transport struct {
+	// Base is the underlying transport (http.DefaultTransport if nil)
+	Base http.RoundTripper
+
+	// Limiter limits the requests sent through this transport
+	Limiter *Limiter
+}
+
+// NewTransport creates a rate limited transport on top of base
+func NewTransport(base http.RoundTripper, limiter *Limiter) *Transport {
+	return &Transport{
+		Base:    base,
+		Limiter: limiter,
+	}
+}
+
+// RoundTrip waits for the limiter, sends the request and reports the outcome
+// back to the limiter
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Limiter == nil {
+		return base.RoundTrip(req)
+	}
+
+	// Estimate the prompt size from the request body
+	tokens, err := estimateRequestTokens(req)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := t.Limiter.Wait(req.Context(), tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		release(Outcome{})
+		return nil, err
+	}
+
+	// Reset headers are sent with every response, so only treat them as a
+	// request to pause when the server turned the request away
+	outcome := Outcome{
+		RateLimited: resp.StatusCode == http.StatusTooManyRequests,
+		Success:     resp.StatusCode >= 200 && resp.StatusCode < 300,
+	}
+	if outcome.RateLimited || resp.StatusCode == http.StatusServiceUnavailable {
+		outcome.RetryAfter = ParseRetryAfter(resp.Header, time.Now())
+	}
+	release(outcome)
+
+	return resp, nil
+}
+
+// estimateRequestTokens estimates the number of tokens in the request body,
+// restoring the body so it can still be sent
+func estimateRequestTokens(req *http.Request) (int, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0, nil
+	}
+	if req.ContentLength > 0 {
+		return util.EstimateTokens(int(req.ContentLength)), nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return 0, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return util.EstimateTokens(len(body)), nil
+}
+
+// ParseRetryAfter returns the delay requested by the Retry-After header, or
+// by the x-ratelimit-reset headers some providers send instead. It returns 0
+// if no delay was requested.
+func ParseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		// Either a number of seconds or an HTTP date
+		if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+
+	var delay time.Duration
+	for _, name := range []string{
+		"X-Ratelimit-Reset",
+		"X-Ratelimit-Reset-Requests",
+		"X-Ratelimit-Reset-Tokens",
+		"Anthropic-Ratelimit-Requests-Reset",
+		"Anthropic-Ratelimit-Tokens-Reset",
+	} {
+		if d := parseResetValue(header.Get(name), now); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// parseResetValue parses a rate limit reset header, which is either a Go
+// style duration ("6m0s", "20ms"), a number of seconds, a Unix time or an
+// RFC 3339 time. A number is a Unix time if it lies after now.
+func parseResetValue(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		if seconds > float64(now.Unix()) {
+			return time.Unix(0, int64(seconds*float64(time.Second))).Sub(now)
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+var (
+	sharedMu       sync.Mutex
+	sharedLimiters = make(map[string]*Limiter)
+)
+
+// Shared returns the limiter registered under key, creating it with opts if
+// it does not exist yet. Providers that talk to the same endpoint with the
+// same key share a limiter, and therefore share its budget.
+func Shared(key string, opts Options) *Limiter {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	if limiter, ok := sharedLimiters[key]; ok {
+		return limiter
+	}
+
+	limiter := New(opts)
+	sharedLimiters[key] = limiter
+	return limiter
+}
@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/niels/git-llm-review/pkg/config"
 	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
 )
 
 // Common retryable errors for LLM API calls
@@ -40,7 +40,8 @@ func DefaultLLMRetryableErrors() []error {
 	}
 }
 
-// IsLLMErrorRetryable checks if an LLM error is retryable
+// IsLLMErrorRetryable checks if an LLM error is retryable. Only typed errors
+// are recognised; the text of an error is never matched.
 func IsLLMErrorRetryable(err error) bool {
 	if err == nil {
 		return false
@@ -51,43 +52,34 @@ func IsLLMErrorRetryable(err error) bool {
 		return true
 	}
 
-	// Check for specific error types
-	if errors.Is(err, llm.ErrTimeout) {
-		return true
+	// Authentication failures will not go away by retrying, and an open
+	// circuit breaker has already decided the provider is down
+	if errors.Is(err, llm.ErrAuthenticationFailure) || errors.Is(err, llm.ErrProviderUnavailable) {
+		return false
 	}
 
-	// Check for HTTP status codes in error message
-	errMsg := err.Error()
-	if strings.Contains(errMsg, "429") || // Too Many Requests
-		strings.Contains(errMsg, "500") || // Internal Server Error
-		strings.Contains(errMsg, "502") || // Bad Gateway
-		strings.Contains(errMsg, "503") || // Service Unavailable
-		strings.Contains(errMsg, "504") {  // Gateway Timeout
+	// Check for specific error types
+	if errors.Is(err, llm.ErrTimeout) {
 		return true
 	}
 
-	// Check for common error messages
-	for _, retryableErr := range DefaultLLMRetryableErrors() {
-		if strings.Contains(strings.ToLower(errMsg), strings.ToLower(retryableErr.Error())) {
-			return true
-		}
-	}
-
-	// Check for rate limit errors specifically
-	if strings.Contains(strings.ToLower(errMsg), "rate limit") ||
-	   strings.Contains(strings.ToLower(errMsg), "rate_limit") ||
-	   strings.Contains(strings.ToLower(errMsg), "too many requests") {
-		return true
+	// Check for HTTP status codes reported by the API
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return IsRetryableStatus(statusErr.StatusCode)
 	}
 
-	return false
+	// Check for transient network errors
+	return IsRetryableNetworkError(err)
 }
 
 // LLMAPIFunc is a function that makes an API call to an LLM provider
 type LLMAPIFunc func() (*http.Response, error)
 
-// DoLLMRequest executes an LLM API request with retry logic
-func DoLLMRequest(fn LLMAPIFunc, cfg *config.Config) (*http.Response, error) {
+// DoLLMRequest executes an LLM API request with retry logic. Providers retry
+// at the HTTP level through Transport instead; this is kept for callers that
+// make API calls through other means.
+func DoLLMRequest(ctx context.Context, fn LLMAPIFunc, cfg *config.Config) (*http.Response, error) {
 	// Create retry options from config
 	opts := FromConfig(cfg)
 	
@@ -97,17 +89,18 @@ func DoLLMRequest(fn LLMAPIFunc, cfg *config.Config) (*http.Response, error) {
 	// Set custom logger if not already set
 	if opts.Logger == nil {
 		opts.Logger = func(format string, args ...interface{}) {
-			fmt.Printf("[LLM Retry] "+format+"\n", args...)
+			logging.Info("[LLM Retry] " + fmt.Sprintf(format, args...))
 		}
 	}
 	
 	// Execute with retry
-	result, err := Do(func() (interface{}, error) {
+	result, err := Do(ctx, func() (interface{}, error) {
 		resp, err := fn()
 		
-		// Check for rate limit response (status code 429)
-		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
-			return nil, errors.New("rate limit exceeded")
+		// Turn retryable status codes, such as 429, into errors
+		if resp != nil && IsRetryableStatus(resp.StatusCode) {
+			resp.Body.Close()
+			return nil, &StatusError{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
 		}
 		
 		return resp, err
@@ -3,7 +3,9 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"syscall"
 	"testing"
 
 	"github.com/niels/git-llm-review/pkg/config"
@@ -16,17 +18,13 @@ func TestIsLLMErrorRetryable(t *testing.T) {
 	retryableErrors := []error{
 		context.DeadlineExceeded,
 		llm.NewTimeoutError("request timed out"),
-		errors.New("rate limit exceeded"),
-		errors.New("too many requests"),
-		errors.New("received status code 429"),
-		errors.New("received status code 500"),
-		errors.New("received status code 502"),
-		errors.New("received status code 503"),
-		errors.New("received status code 504"),
-		errors.New("connection reset by peer"),
-		errors.New("server error occurred"),
-		errors.New("internal server error"),
-		errors.New("service unavailable"),
+		&StatusError{StatusCode: http.StatusTooManyRequests},
+		&StatusError{StatusCode: http.StatusInternalServerError},
+		&StatusError{StatusCode: http.StatusBadGateway},
+		&StatusError{StatusCode: http.StatusServiceUnavailable},
+		&StatusError{StatusCode: http.StatusGatewayTimeout},
+		fmt.Errorf("read: %w", syscall.ECONNRESET),
+		llm.NewProviderError("API error", &StatusError{StatusCode: http.StatusBadGateway}),
 	}
 
 	for _, err := range retryableErrors {
@@ -46,6 +44,14 @@ func TestIsLLMErrorRetryable(t *testing.T) {
 		errors.New("received status code 404"),
 		llm.NewInvalidRequestError("invalid parameter"),
 		llm.NewAuthenticationError("invalid API key"),
+		&StatusError{StatusCode: http.StatusUnauthorized, Message: "rate limit key revoked"},
+		errors.New("file has more than 500 lines"),
+		errors.New("model returned 503 characters of invalid JSON"),
+		// The text of an error is not matched
+		errors.New("rate limit exceeded"),
+		errors.New("received status code 503"),
+		errors.New("connection reset by peer"),
+		errors.New("internal server error"),
 	}
 
 	for _, err := range nonRetryableErrors {
@@ -63,7 +69,7 @@ func TestDoLLMRequest(t *testing.T) {
 	mockLLMAPIFunc := func() (*http.Response, error) {
 		attempts++
 		if attempts <= maxFailures {
-			return nil, errors.New("rate limit exceeded")
+			return nil, &StatusError{StatusCode: http.StatusTooManyRequests}
 		}
 		return &http.Response{
 			StatusCode: http.StatusOK,
@@ -88,7 +94,7 @@ func TestDoLLMRequest(t *testing.T) {
 	}
 
 	// Execute with retry
-	resp, err := DoLLMRequest(mockLLMAPIFunc, cfg)
+	resp, err := DoLLMRequest(context.Background(), mockLLMAPIFunc, cfg)
 
 	// Verify results
 	if err != nil {
@@ -112,7 +118,7 @@ func TestDoLLMRequest(t *testing.T) {
 	}
 
 	// Execute without retry
-	resp, err = DoLLMRequest(mockLLMAPIFunc, disabledCfg)
+	resp, err = DoLLMRequest(context.Background(), mockLLMAPIFunc, disabledCfg)
 
 	// Verify results
 	if err == nil {
@@ -135,7 +141,7 @@ func TestDoLLMRequest(t *testing.T) {
 	}
 
 	// Execute with retry
-	resp, err = DoLLMRequest(nonRetryableMockFunc, cfg)
+	resp, err = DoLLMRequest(context.Background(), nonRetryableMockFunc, cfg)
 
 	// Verify results
 	if err == nil {
@@ -1,11 +1,14 @@
 package retry
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/niels/git-llm-review/pkg/logging"
 )
 
 // RetryFunc is a function that can be retried
@@ -50,12 +53,18 @@ func DefaultOptions() Options {
 		MaxDelay:      5 * time.Second,
 		BackoffFactor: 2.0,
 		JitterFactor:  0.2,
-		Logger:        func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) },
+		Logger:        defaultLogger,
 	}
 }
 
-// Do executes the given function with retry logic
-func Do(fn RetryFunc, opts Options) (interface{}, error) {
+// defaultLogger logs retry attempts through the application logger
+func defaultLogger(format string, args ...interface{}) {
+	logging.Info(fmt.Sprintf(format, args...))
+}
+
+// Do executes the given function with retry logic. Waiting between attempts
+// stops as soon as ctx is done.
+func Do(ctx context.Context, fn RetryFunc, opts Options) (interface{}, error) {
 	var result interface{}
 	var err error
 	var delay time.Duration = 0
@@ -65,11 +74,16 @@ func Do(fn RetryFunc, opts Options) (interface{}, error) {
 
 	// Set default logger if not provided
 	if opts.Logger == nil {
-		opts.Logger = func(format string, args ...interface{}) { fmt.Printf(format+"\n", args...) }
+		opts.Logger = defaultLogger
 	}
 
 	// Try the function up to MaxRetries+1 times
 	for attempt := 0; attempt <= opts.MaxRetries; attempt++ {
+		// Stop if the caller has given up
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// Execute the function
 		result, err = fn()
 
@@ -115,7 +129,13 @@ func Do(fn RetryFunc, opts Options) (interface{}, error) {
 		opts.Logger("Retry attempt %d after %v: %v", attempt+1, delay, err)
 
 		// Wait before the next attempt
-		time.Sleep(delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("retry cancelled after %d attempts: %w", attempt+1, ctx.Err())
+		case <-timer.C:
+		}
 	}
 
 	// This should never be reached due to the return in the loop
@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"errors"
 	"testing"
 	"time"
@@ -30,7 +31,7 @@ func TestRetrySuccess(t *testing.T) {
 	}
 
 	// Execute with retry
-	result, err := Do(testFunc, opts)
+	result, err := Do(context.Background(), testFunc, opts)
 
 	// Verify results
 	if err != nil {
@@ -66,7 +67,7 @@ func TestRetryMaxAttemptsExceeded(t *testing.T) {
 	}
 
 	// Execute with retry
-	_, err := Do(testFunc, opts)
+	_, err := Do(context.Background(), testFunc, opts)
 
 	// Verify results
 	if err == nil {
@@ -98,7 +99,7 @@ func TestRetryNonRetryableError(t *testing.T) {
 	}
 
 	// Execute with retry
-	_, err := Do(testFunc, opts)
+	_, err := Do(context.Background(), testFunc, opts)
 
 	// Verify results
 	if err == nil {
@@ -138,7 +139,7 @@ func TestRetryBackoff(t *testing.T) {
 	}
 
 	// Execute with retry
-	_, _ = Do(testFunc, opts)
+	_, _ = Do(context.Background(), testFunc, opts)
 
 	// Verify results
 	if len(delays) != 3 {
@@ -201,7 +202,7 @@ func TestRetryWithJitter(t *testing.T) {
 			RetryableErrors: []error{errors.New("temporary error")},
 		}
 
-		_, _ = Do(testFunc, opts)
+		_, _ = Do(context.Background(), testFunc, opts)
 	}
 
 	// Then, measure delays with jitter
@@ -229,7 +230,7 @@ func TestRetryWithJitter(t *testing.T) {
 			RetryableErrors: []error{errors.New("temporary error")},
 		}
 
-		_, _ = Do(testFunc, opts)
+		_, _ = Do(context.Background(), testFunc, opts)
 	}
 
 	// Check that jitter delays have some variation
@@ -296,7 +297,7 @@ func TestRetryWithCustomIsRetryableFunc(t *testing.T) {
 	}
 
 	// Execute with retry
-	result, err := Do(testFunc, opts)
+	result, err := Do(context.Background(), testFunc, opts)
 
 	// Verify results
 	if err != nil {
@@ -311,3 +312,43 @@ func TestRetryWithCustomIsRetryableFunc(t *testing.T) {
 		t.Errorf("Expected 3 attempts, got: %d", attempts)
 	}
 }
+
+// TestRetryStopsWhenCancelled tests that waiting between attempts ends as soon
+// as the context is cancelled
+func TestRetryStopsWhenCancelled(t *testing.T) {
+	attempts := 0
+	testFunc := func() (interface{}, error) {
+		attempts++
+		return nil, errors.New("retryable")
+	}
+
+	// Configure a delay far longer than the test should take
+	opts := Options{
+		MaxRetries:    3,
+		InitialDelay:  time.Minute,
+		MaxDelay:      time.Minute,
+		BackoffFactor: 2.0,
+		IsRetryableFunc: func(err error) bool {
+			return true
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := Do(ctx, testFunc, opts)
+
+	// Verify results
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to end the wait, took %v", elapsed)
+	}
+
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got: %d", attempts)
+	}
+}
@@ -0,0 +1,294 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/ratelimit"
+)
+
+// StatusError describes an HTTP error response from an LLM API
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error returns the error message
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP status %d: %s", e.StatusCode, e.Message)
+}
+
+// IsRetryableStatus reports whether a request that received the given HTTP
+// status code may succeed if it is sent again
+func IsRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529: // Anthropic: overloaded
+		return true
+	default:
+		return false
+	}
+}
+
+// IsRetryableNetworkError reports whether err is a transient network error
+func IsRetryableNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// The caller gave up, retrying cannot help
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
+
+// sendOnceKey marks the context of requests that must not be sent twice
+type sendOnceKey struct{}
+
+// SendOnce returns a context for requests that must not reach the server
+// twice, such as the upload of a file or the creation of a batch job, which
+// would be duplicated and billed again. Once written, such a request is only
+// sent again if the server turned it away with 429.
+func SendOnce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sendOnceKey{}, true)
+}
+
+// isIdempotent reports whether sending req twice has the same effect as
+// sending it once
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// Transport is an http.RoundTripper that retries requests that fail with a
+// transient network error or a retryable status code. It honours the delay
+// requested by the server through Retry-After and rate limit reset headers.
+// A request that is not idempotent is not retried after a network error
+// once it was written, since the server may already have received it.
+type Transport struct {
+	// Base is the underlying transport (http.DefaultTransport if nil)
+	Base http.RoundTripper
+
+	// Options configures the number of retries and the backoff
+	Options Options
+}
+
+// NewTransport creates a retrying transport on top of base
+func NewTransport(base http.RoundTripper, opts Options) *Transport {
+	return &Transport{
+		Base:    base,
+		Options: opts,
+	}
+}
+
+// RoundTrip sends the request, retrying it as configured
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Options.MaxRetries <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	// Make sure the body can be sent more than once
+	getBody, err := rewindableBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	sendOnce := ctx.Value(sendOnceKey{}) != nil
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var delay time.Duration
+
+	for attempt := 0; ; attempt++ {
+		// Send a fresh copy of the body with every attempt
+		attemptReq := req
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		// Note whether the request was written, and may have reached the server
+		var written atomic.Bool
+		attemptReq = attemptReq.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			WroteRequest: func(info httptrace.WroteRequestInfo) {
+				if info.Err == nil {
+					written.Store(true)
+				}
+			},
+		}))
+
+		resp, err := base.RoundTrip(attemptReq)
+
+		// Decide whether to retry
+		var reason string
+		var retryAfter time.Duration
+		switch {
+		case err != nil:
+			if !IsRetryableNetworkError(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			if written.Load() && (sendOnce || !isIdempotent(req)) {
+				logging.WarnWith("Not retrying LLM request, it may have reached the server", map[string]interface{}{
+					"url":    req.URL.Redacted(),
+					"reason": err.Error(),
+				})
+				return nil, err
+			}
+			reason = err.Error()
+		case IsRetryableStatus(resp.StatusCode) && (!sendOnce || resp.StatusCode == http.StatusTooManyRequests):
+			// Other than with 429, the server may have acted on a request
+			// that must be sent once before failing
+			reason = resp.Status
+			retryAfter = ratelimit.ParseRetryAfter(resp.Header, time.Now())
+		default:
+			if attempt > 0 {
+				logging.InfoWith("LLM request succeeded after retry", map[string]interface{}{
+					"url":      req.URL.Redacted(),
+					"attempts": attempt + 1,
+					"status":   resp.StatusCode,
+				})
+			}
+			return resp, nil
+		}
+
+		if attempt >= t.Options.MaxRetries {
+			logging.WarnWith("LLM request failed after all retries", map[string]interface{}{
+				"url":      req.URL.Redacted(),
+				"attempts": attempt + 1,
+				"reason":   reason,
+			})
+			return resp, err
+		}
+
+		// Back off exponentially, but wait at least as long as the server asked
+		delay = t.nextDelay(attempt, delay, rnd)
+		wait := delay
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+
+		// Give up early if the wait would outlast the caller's deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			logging.WarnWith("Not retrying LLM request, delay exceeds deadline", map[string]interface{}{
+				"url":    req.URL.Redacted(),
+				"delay":  wait.String(),
+				"reason": reason,
+			})
+			return resp, err
+		}
+
+		logging.WarnWith("Retrying LLM request", map[string]interface{}{
+			"url":         req.URL.Redacted(),
+			"attempt":     attempt + 1,
+			"max_retries": t.Options.MaxRetries,
+			"delay":       wait.String(),
+			"reason":      reason,
+		})
+
+		// Release the failed response before waiting
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// nextDelay returns the backoff delay before retry number attempt+1
+func (t *Transport) nextDelay(attempt int, previous time.Duration, rnd *rand.Rand) time.Duration {
+	opts := t.Options
+
+	delay := opts.InitialDelay
+	if attempt > 0 {
+		factor := opts.BackoffFactor
+		if factor <= 0 {
+			factor = 2.0
+		}
+		delay = time.Duration(float64(previous) * factor)
+	}
+	if opts.MaxDelay > 0 && delay > opts.MaxDelay {
+		delay = opts.MaxDelay
+	}
+
+	// Apply jitter
+	if opts.JitterFactor > 0 {
+		jitter := float64(delay) * opts.JitterFactor
+		delay = time.Duration(float64(delay) + (rnd.Float64()*jitter*2 - jitter))
+	}
+
+	return delay
+}
+
+// rewindableBody returns a function that produces a fresh copy of the
+// request body for every attempt, or nil if the request has no body
+func rewindableBody(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		req.Body.Close()
+		return req.GetBody, nil
+	}
+
+	// Buffer the body so it can be replayed
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}, nil
+}
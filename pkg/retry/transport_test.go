@@ -0,0 +1,310 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fastOptions returns retry options with short delays for tests
+func fastOptions(maxRetries int) Options {
+	return Options{
+		MaxRetries:    maxRetries,
+		InitialDelay:  time.Millisecond,
+		MaxDelay:      10 * time.Millisecond,
+		BackoffFactor: 2.0,
+	}
+}
+
+// TestTransportRetriesServerErrors verifies that retryable status codes are
+// retried and that the request body is sent in full every time
+func TestTransportRetriesServerErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"prompt":"hello"}` {
+			t.Errorf("Attempt %d: unexpected body %q", atomic.LoadInt32(&attempts)+1, body)
+		}
+
+		// Fail the first two attempts with different retryable errors
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(529)
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, fastOptions(3))}
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"prompt":"hello"}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+// TestTransportDoesNotRetryAuthErrors verifies that authentication failures
+// and other client errors are returned immediately
+func TestTransportDoesNotRetryAuthErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden} {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(status)
+		}))
+
+		client := &http.Client{Transport: NewTransport(nil, fastOptions(3))}
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		if resp.StatusCode != status {
+			t.Errorf("Expected status %d, got %d", status, resp.StatusCode)
+		}
+		if attempts != 1 {
+			t.Errorf("Status %d: expected 1 attempt, got %d", status, attempts)
+		}
+	}
+}
+
+// TestTransportReturnsLastResponse verifies that the final failed response is
+// returned to the caller once the retries are exhausted
+func TestTransportReturnsLastResponse(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"rate limited"}}`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, fastOptions(2))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "rate limited") {
+		t.Errorf("Expected the error body to be readable, got %q", body)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+// TestTransportHonoursRetryAfter verifies that the server's requested delay
+// takes precedence over a shorter backoff
+func TestTransportHonoursRetryAfter(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("x-ratelimit-reset-requests", "300ms")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, fastOptions(3))}
+	start := time.Now()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected to wait at least 300ms, waited %v", elapsed)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+// TestTransportRetriesNetworkErrors verifies that an idempotent request is
+// retried when the connection drops
+func TestTransportRetriesNetworkErrors(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			// Drop the connection without sending a response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, fastOptions(3))}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+// TestTransportDoesNotResendWrittenRequests verifies that a POST request is
+// not sent again once the server may have received it
+func TestTransportDoesNotResendWrittenRequests(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		io.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil, fastOptions(3))}
+	if _, err := client.Post(server.URL, "application/json", strings.NewReader("{}")); err == nil {
+		t.Fatal("Expected the dropped connection to fail the request")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+// refusingTransport fails the first requests with a connection error before
+// anything is written, then hands requests to the default transport
+type refusingTransport struct {
+	failures int32
+}
+
+func (rt *refusingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.AddInt32(&rt.failures, -1) >= 0 {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// TestTransportRetriesUnsentRequests verifies that a POST request is retried
+// when the connection fails before the request is written
+func TestTransportRetriesUnsentRequests(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(&refusingTransport{failures: 2}, fastOptions(3))}
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if attempts != 1 {
+		t.Errorf("Expected the request to reach the server once, got %d", attempts)
+	}
+}
+
+// TestTransportSendOnce verifies that a request that must be sent once is
+// only retried when the server turned it away with 429
+func TestTransportSendOnce(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				w.WriteHeader(status)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req, _ := http.NewRequestWithContext(SendOnce(context.Background()), http.MethodPost, server.URL, strings.NewReader("{}"))
+		resp, err := NewTransport(nil, fastOptions(3)).RoundTrip(req)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		resp.Body.Close()
+		server.Close()
+
+		expected := int32(1)
+		if status == http.StatusTooManyRequests {
+			expected = 2
+		}
+		if attempts != expected {
+			t.Errorf("Expected %d attempts after status %d, got %d", expected, status, attempts)
+		}
+	}
+}
+
+// TestTransportStopsWhenCancelled verifies that waiting for a retry ends when
+// the request context is cancelled
+func TestTransportStopsWhenCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil, fastOptions(3))}
+
+	start := time.Now()
+	_, err := client.Do(req)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected cancellation to end the wait, took %v", elapsed)
+	}
+}
+
+// TestIsRetryableStatus verifies the classification of status codes
+func TestIsRetryableStatus(t *testing.T) {
+	retryable := []int{408, 429, 500, 502, 503, 504, 529}
+	for _, code := range retryable {
+		if !IsRetryableStatus(code) {
+			t.Errorf("Expected status %d to be retryable", code)
+		}
+	}
+
+	nonRetryable := []int{200, 400, 401, 403, 404, 422}
+	for _, code := range nonRetryable {
+		if IsRetryableStatus(code) {
+			t.Errorf("Expected status %d not to be retryable", code)
+		}
+	}
+}
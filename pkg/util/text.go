@@ -3,19 +3,56 @@ package util
 import (
 	"log"
 	"strings"
+	"unicode/utf8"
 )
 
 // RemoveThinkTags removes <think>...</think> tags from the content if present.
 // It always looks for the closing </think> tag, regardless of opening tag,
 // and removes all content before and including that tag.
 func RemoveThinkTags(content string) string {
-	// Always look for the closing </think> tag, regardless of opening tag
-	endIndex := strings.Index(content, "</think>")
-	if endIndex != -1 {
-		// Remove everything before and including the </think> tag
-		cleanedContent := strings.TrimSpace(content[endIndex+len("</think>"):])
+	thinking, cleanedContent := ExtractThinkTags(content)
+	if thinking != "" {
 		log.Printf("Removed thinking section, content now starts with: %.100s...", cleanedContent)
-		return cleanedContent
 	}
-	return content
+	return cleanedContent
+}
+
+// ExtractThinkTags splits content into the reasoning inside <think>...</think>
+// tags and the answer that follows them. Like RemoveThinkTags it only looks
+// for the closing </think> tag, since some models omit the opening tag. The
+// reasoning is empty when the content has no thinking section.
+func ExtractThinkTags(content string) (thinking, answer string) {
+	endIndex := strings.Index(content, "</think>")
+	if endIndex == -1 {
+		return "", content
+	}
+
+	thinking = strings.TrimSpace(content[:endIndex])
+	thinking = strings.TrimSpace(strings.TrimPrefix(thinking, "<think>"))
+	answer = strings.TrimSpace(content[endIndex+len("</think>"):])
+	return thinking, answer
+}
+
+// EstimateTokens returns a rough estimate of the number of tokens in a text
+// of the given length in bytes, assuming about four characters per token.
+func EstimateTokens(length int) int {
+	if length <= 0 {
+		return 0
+	}
+	return (length + 3) / 4
+}
+
+// TruncateUTF8 returns at most the first limit bytes of text, cut before a
+// character rather than in the middle of one, so the result stays valid UTF-8
+func TruncateUTF8(text string, limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
 }
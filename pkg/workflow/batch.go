@@ -0,0 +1,218 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/batch"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/parse"
+	"github.com/niels/git-llm-review/pkg/processor"
+	"github.com/niels/git-llm-review/pkg/prompt"
+	"github.com/niels/git-llm-review/pkg/version"
+)
+
+// submitBatch submits the reviews of files as one batch job and saves the
+// job, so that its results can be collected with CollectBatch
+func (w *ReviewWorkflow) submitBatch(ctx context.Context, repoRoot string, files []processor.FileInfo) error {
+	batcher, err := w.batcher()
+	if err != nil {
+		return err
+	}
+
+	// Build the review request of every file; files whose diff cannot be
+	// read are left out of the batch
+	var requests []llm.BatchRequest
+	var jobFiles []batch.File
+	passes := w.focusPasses()
+	for i, file := range files {
+		request, err := processor.NewReviewRequest(repoRoot, w.repoDetector, file, w.reviewOptions())
+		if err != nil {
+			fmt.Printf("  Skipping %s: %s\n", file.Path, err.Error())
+			continue
+		}
+		if len(passes) == 0 {
+			requestID := fmt.Sprintf("file-%d", i+1)
+			requests = append(requests, llm.BatchRequest{ID: requestID, Request: request})
+			jobFiles = append(jobFiles, batch.File{RequestID: requestID, Path: file.Path, Focus: w.singleFocus()})
+			continue
+		}
+
+		// Send a request per focus when each focus is reviewed separately
+		for _, focus := range passes {
+			focusRequest := *request
+			focusRequest.Options = w.reviewOptionsFor(focus)
+			focusRequest.Options.IncludeExplanations = request.Options.IncludeExplanations
+			requestID := fmt.Sprintf("file-%d-%s", i+1, focus)
+			requests = append(requests, llm.BatchRequest{ID: requestID, Request: &focusRequest})
+			jobFiles = append(jobFiles, batch.File{RequestID: requestID, Path: file.Path, Focus: focus})
+		}
+	}
+	if len(requests) == 0 {
+		return fmt.Errorf("no files to submit")
+	}
+
+	fmt.Printf("\nSubmitting %d reviews as a batch job...\n", len(requests))
+	id, err := batcher.SubmitBatch(ctx, requests)
+	if err != nil {
+		return fmt.Errorf("failed to submit batch: %w", err)
+	}
+
+	provider := w.primaryProvider()
+	job := &batch.Job{
+		ID:          id,
+		Provider:    provider.Name(),
+		Profile:     w.config.ActiveProfile,
+		SubmittedAt: time.Now(),
+		Files:       jobFiles,
+	}
+	if w.templates != nil {
+		job.TemplateVersion = w.templates.Version
+	}
+	if namer, ok := provider.(llm.ModelNamer); ok {
+		job.Model = namer.Model()
+	}
+	if err := batch.Save(batch.Dir(repoRoot), job); err != nil {
+		return fmt.Errorf("batch %s was submitted but could not be saved: %w", id, err)
+	}
+
+	fmt.Printf("Submitted batch %s to %s\n", id, llm.DescribeProvider(provider))
+	fmt.Printf("Check its progress with '%s batch status' and collect the results with '%s batch collect'\n",
+		version.AppName, version.AppName)
+	return nil
+}
+
+// BatchStatus returns the batch job with the given ID, or the most recently
+// submitted one if id is empty, together with its progress
+func (w *ReviewWorkflow) BatchStatus(ctx context.Context, id string) (*batch.Job, *llm.BatchInfo, error) {
+	defer w.finishCassette()
+	job, batcher, err := w.loadBatch(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := batcher.BatchStatus(ctx, job.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get batch status: %w", err)
+	}
+	return job, info, nil
+}
+
+// CollectBatch downloads the results of a finished batch job and reports them
+// like the results of a normal run. The most recently submitted job is
+// collected if id is empty.
+func (w *ReviewWorkflow) CollectBatch(ctx context.Context, id string) (*Statistics, error) {
+	defer w.finishCassette()
+	job, batcher, err := w.loadBatch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := batcher.BatchStatus(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch status: %w", err)
+	}
+	if !info.Done {
+		return nil, fmt.Errorf("batch %s is still %s (%d of %d requests done), try again later",
+			job.ID, info.Status, info.Succeeded+info.Failed, info.Total)
+	}
+
+	batchResults, err := batcher.BatchResults(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch results: %w", err)
+	}
+	logging.InfoWith("Collected batch results", map[string]interface{}{
+		"batch":   job.ID,
+		"results": len(batchResults),
+	})
+
+	// Match the results to the files of the job, merging the results of a
+	// file that was reviewed once per focus
+	results := make(map[string]*parse.ReviewResult)
+	fileErrors := make(map[string]error)
+	for _, file := range job.Files {
+		result, ok := batchResults[file.RequestID]
+		switch {
+		case !ok:
+			fileErrors[file.Path] = fmt.Errorf("no result for file %s in batch %s", file.Path, job.ID)
+		case result.Err != nil:
+			fileErrors[file.Path] = fmt.Errorf("failed to get LLM response for file %s: %w", file.Path, result.Err)
+		default:
+			reviewResult := processor.ReviewResult(result.Response)
+			reviewResult.SetFocus(file.Focus)
+			if existing, ok := results[file.Path]; ok {
+				existing.Merge(reviewResult)
+			} else {
+				results[file.Path] = reviewResult
+			}
+		}
+	}
+	for path := range fileErrors {
+		delete(results, path)
+	}
+
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Collected batch %s submitted at %s\n", job.ID, job.SubmittedAt.Format(time.RFC1123))
+
+	// Report the templates the batch was built with, which may have changed
+	// since it was submitted
+	if job.TemplateVersion != "" {
+		w.templates = &prompt.TemplateSet{Version: job.TemplateVersion}
+		w.markdownOutput.WithTemplateVersion(job.TemplateVersion)
+	}
+
+	stats := &Statistics{
+		IssuesByType:  make(map[string]int),
+		IssuesByFocus: make(map[string]int),
+		IssuesByFile:  make(map[string]int),
+		Duration:      time.Since(job.SubmittedAt),
+	}
+	return w.report(results, fileErrors, stats, filepath.Base(repoRoot), false)
+}
+
+// loadBatch loads a saved batch job and checks that it was submitted to the
+// configured provider
+func (w *ReviewWorkflow) loadBatch(id string) (*batch.Job, llm.Batcher, error) {
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, nil, err
+	}
+	job, err := batch.Load(batch.Dir(repoRoot), id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	batcher, err := w.batcher()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The batch can only be read with the provider and key it was sent with
+	provider := w.primaryProvider()
+	model := ""
+	if namer, ok := provider.(llm.ModelNamer); ok {
+		model = namer.Model()
+	}
+	if provider.Name() != job.Provider || model != job.Model {
+		return nil, nil, fmt.Errorf("batch %s was submitted to %s (%s), but %s is configured; use the same configuration and profile",
+			job.ID, job.Provider, job.Model, llm.DescribeProvider(provider))
+	}
+	return job, batcher, nil
+}
+
+// batcher returns the configured provider if it supports batch jobs.
+// Fallback providers are not used for batches.
+func (w *ReviewWorkflow) batcher() (llm.Batcher, error) {
+	provider := w.primaryProvider()
+	batcher, ok := provider.(llm.Batcher)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support batch reviews", provider.Name())
+	}
+	return batcher, nil
+}
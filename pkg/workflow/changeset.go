@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/parse"
+	"github.com/niels/git-llm-review/pkg/processor"
+)
+
+// changesetMode reports whether related files are reviewed together
+func (w *ReviewWorkflow) changesetMode() bool {
+	return w.options.Changeset || w.config.Changeset.Enabled
+}
+
+// reviewChangeset reviews the files in groups of related files, so that the
+// model sees how the changes to one file affect the others, and returns the
+// results and errors of each file
+func (w *ReviewWorkflow) reviewChangeset(ctx context.Context, repoRoot string, files []processor.FileInfo) (map[string]*parse.ReviewResult, map[string]error) {
+	errors := make(map[string]error)
+
+	// Read the diff and content of every file to group them by size
+	var requests []*llm.ReviewRequest
+	for _, file := range files {
+		request, err := processor.NewReviewRequest(repoRoot, w.repoDetector, file, w.reviewOptions())
+		if err != nil {
+			errors[file.Path] = err
+			continue
+		}
+		requests = append(requests, request)
+	}
+
+	groups := processor.GroupChangeset(repoRoot, requests, processor.ChangesetLimits{
+		MaxTokens: w.config.Changeset.MaxTokens,
+		MaxFiles:  w.config.Changeset.MaxFiles,
+	})
+	logging.InfoWith("Grouped changeset for review", map[string]interface{}{
+		"files":  len(requests),
+		"groups": len(groups),
+	})
+	fmt.Printf("\nReviewing %d files in %d requests with concurrency %d...\n", len(requests), len(groups), w.config.Concurrency.MaxTasks)
+
+	// Review each group once per focus if requested
+	var groupProcessor processor.GroupProcessor
+	if passes := w.focusPasses(); len(passes) > 0 {
+		groupPasses := make([]processor.GroupPass, 0, len(passes))
+		for _, focus := range passes {
+			groupPasses = append(groupPasses, processor.GroupPass{
+				Focus:   focus,
+				Process: processor.ChangesetProcessor(repoRoot, w.provider, w.reviewOptionsFor(focus)),
+			})
+		}
+		groupProcessor = processor.MultiPassGroupProcessor(groupPasses)
+	} else {
+		groupProcessor = processor.ChangesetProcessor(repoRoot, w.provider, w.reviewOptions())
+	}
+
+	results, groupErrors := processor.NewConcurrentProcessor(w.config, nil).
+		WithProgressTracker(w.progressTracker).
+		ProcessGroups(ctx, groups, groupProcessor)
+	for path, err := range groupErrors {
+		errors[path] = err
+	}
+	return results, errors
+}
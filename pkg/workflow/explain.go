@@ -0,0 +1,214 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/git"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/prompt"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// focusContextLines is the number of lines shown above and below the line
+// an explanation is asked for
+const focusContextLines = 15
+
+var (
+	// fileLinePattern matches targets such as pkg/server.go:42
+	fileLinePattern = regexp.MustCompile(`^(.+):([0-9]+)$`)
+
+	// unsafeNamePattern matches the characters left out of report names
+	unsafeNamePattern = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// Explanation is a plain-language walkthrough of a change
+type Explanation struct {
+	// Subject names the change, such as "commit 1a2b3c4 Fix retries"
+	Subject string
+	// Text is the explanation in markdown
+	Text string
+	// Provider is the provider that wrote the explanation
+	Provider string
+	// ReportPath is the markdown report the explanation was written to, if any
+	ReportPath string
+}
+
+// Explain explains a change to a reader who does not know the code: the
+// staged changes if target is empty, or else a commit, a range such as
+// main..feature, or the commit that last changed a line, given as
+// path:line. The explanation is shown as it arrives and written to the
+// output directory if one is set. With diagram, it includes a mermaid
+// diagram of the calls involved.
+func (w *ReviewWorkflow) Explain(ctx context.Context, target string, diagram bool) (*Explanation, error) {
+	defer w.finishCassette()
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := w.explainRequest(repoRoot, target)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(request.Diff) == "" {
+		return nil, fmt.Errorf("no changes to explain in %s", request.Subject)
+	}
+	request.Diagram = diagram
+
+	logging.InfoWith("Explaining change", map[string]interface{}{
+		"change":  request.Subject,
+		"diagram": diagram,
+	})
+	provider := w.primaryProvider()
+	explainPrompt := prompt.CreateExplainPrompt(request, prompt.ProviderTypeFor(provider.Name()))
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(w.config.LLM.Timeout)*time.Second)
+	defer cancel()
+
+	fmt.Printf("=== Explanation of %s ===\n\n", request.Subject)
+	text, err := llm.StreamCompletion(timeoutCtx, w.provider, explainPrompt, func(chunk string) {
+		fmt.Print(chunk)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain %s: %w", request.Subject, err)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		fmt.Println()
+	}
+
+	explanation := &Explanation{
+		Subject:  request.Subject,
+		Text:     strings.TrimSpace(util.RemoveThinkTags(text)),
+		Provider: llm.DescribeProvider(provider),
+	}
+
+	// Write the explanation to the output directory, if requested
+	if w.options.OutputPath != "" {
+		reportPath := filepath.Join(w.options.OutputPath, explainReportName(target, w.options.All))
+		if err := w.markdownOutput.WriteExplanation(explanation.Subject, explanation.Text, explanation.Provider,
+			filepath.Base(repoRoot), reportPath); err != nil {
+			return nil, fmt.Errorf("failed to write explanation: %w", err)
+		}
+		explanation.ReportPath = reportPath
+		fmt.Printf("\nExplanation written to %s\n", reportPath)
+	}
+	return explanation, nil
+}
+
+// explainRequest reads the change named by target from the repository
+func (w *ReviewWorkflow) explainRequest(repoRoot, target string) (prompt.ExplainRequest, error) {
+	reader, ok := w.repoDetector.(git.HistoryReader)
+	if !ok {
+		return prompt.ExplainRequest{}, fmt.Errorf("reading changes from the repository is not supported")
+	}
+	if strings.HasPrefix(target, "-") {
+		return prompt.ExplainRequest{}, fmt.Errorf("invalid change %q", target)
+	}
+
+	var change *git.Change
+	var request prompt.ExplainRequest
+	var err error
+	switch match := fileLinePattern.FindStringSubmatch(target); {
+	case target == "":
+		change, err = reader.GetWorkingChange(repoRoot, w.options.All)
+
+	case strings.Contains(target, ".."):
+		change, err = reader.GetRangeChange(repoRoot, target)
+
+	case match != nil && fileExists(match[1]):
+		line, _ := strconv.Atoi(match[2])
+		request, change, err = w.lineChange(reader, repoRoot, match[1], line)
+
+	default:
+		change, err = reader.GetCommitChange(repoRoot, target)
+	}
+	if err != nil {
+		return prompt.ExplainRequest{}, err
+	}
+
+	request.Subject = change.Description
+	request.Messages = change.Messages
+	request.Stat = change.Stat
+	request.Diff = change.Diff
+	return request, nil
+}
+
+// lineChange finds the change that last touched a line of the file at path,
+// relative to the current directory: the commit that changed it, or the
+// uncommitted changes to the file. The request holds the code around the line.
+func (w *ReviewWorkflow) lineChange(reader git.HistoryReader, repoRoot, path string, line int) (prompt.ExplainRequest, *git.Change, error) {
+	relPath, err := repoPath(repoRoot, path)
+	if err != nil {
+		return prompt.ExplainRequest{}, nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(repoRoot, relPath))
+	if err != nil {
+		return prompt.ExplainRequest{}, nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	code, err := codeAround(string(content), line)
+	if err != nil {
+		return prompt.ExplainRequest{}, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	request := prompt.ExplainRequest{FocusPath: relPath, FocusLine: line, FocusCode: code}
+
+	commit, err := reader.GetLineCommit(repoRoot, relPath, line)
+	if err != nil {
+		return prompt.ExplainRequest{}, nil, err
+	}
+	var change *git.Change
+	if commit == "" {
+		change, err = reader.GetWorkingChange(repoRoot, true, relPath)
+	} else {
+		change, err = reader.GetCommitChange(repoRoot, commit)
+	}
+	return request, change, err
+}
+
+// codeAround returns the lines of content around line, numbered, with the
+// line itself marked
+func codeAround(content string, line int) (string, error) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if line < 1 || line > len(lines) {
+		return "", fmt.Errorf("line %d is out of range, the file has %d lines", line, len(lines))
+	}
+
+	first := max(line-focusContextLines, 1)
+	last := min(line+focusContextLines, len(lines))
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		marker := "  "
+		if i == line {
+			marker = "> "
+		}
+		fmt.Fprintf(&sb, "%s%4d  %s\n", marker, i, lines[i-1])
+	}
+	return sb.String(), nil
+}
+
+// explainReportName returns the name of the report that explains target, or
+// the staged or, with all, the uncommitted changes if target is empty
+func explainReportName(target string, all bool) string {
+	name := strings.Trim(unsafeNamePattern.ReplaceAllString(target, "_"), "_.")
+	switch {
+	case name != "":
+	case all:
+		name = "uncommitted"
+	default:
+		name = "staged"
+	}
+	return "explain_" + name + ".md"
+}
+
+// fileExists reports whether path names a file
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
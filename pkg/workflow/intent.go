@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/git"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+)
+
+// ticketPattern matches ticket references such as PROJ-123 or #42
+var ticketPattern = regexp.MustCompile(`\b([A-Z]{2}[A-Z0-9]*)-[0-9]+\b|(?:^|[\s(\[])(#[0-9]+)\b`)
+
+// notTicketPrefixes are the names of standards, encodings and algorithms
+// that look like ticket references, such as UTF-8 or SHA-256
+var notTicketPrefixes = map[string]bool{
+	"AES": true, "HTTP": true, "IEEE": true, "ISO": true, "MD": true, "RFC": true,
+	"RSA": true, "SHA": true, "SSL": true, "TLS": true, "UCS": true, "UTF": true,
+}
+
+// intentMode reports whether the changes are checked against their intent
+func (w *ReviewWorkflow) intentMode() bool {
+	return w.options.Intent || w.options.IntentMessage != "" || config.Enabled(w.config.Prompt.Intent)
+}
+
+// gatherIntent collects what the change is meant to do: the stated intent,
+// the commit message being written, the earlier commits on the branch, the
+// branch name and the tickets they reference. Sources that cannot be read
+// are left out.
+func (w *ReviewWorkflow) gatherIntent(repoRoot string) {
+	intent := &llm.Intent{Stated: strings.TrimSpace(w.options.IntentMessage)}
+
+	if reader, ok := w.repoDetector.(git.IntentReader); ok {
+		warn := func(source string, err error) {
+			logging.WarnWith("Failed to read the intent of the change", map[string]interface{}{
+				"source": source,
+				"error":  err.Error(),
+			})
+		}
+
+		var err error
+		if intent.Draft, err = reader.GetCommitMessageDraft(repoRoot); err != nil {
+			warn("commit message", err)
+		}
+		if intent.Branch, err = reader.GetBranchName(repoRoot); err != nil {
+			warn("branch", err)
+		}
+		if intent.Commits, err = reader.GetBranchCommitMessages(repoRoot, w.config.Prompt.IntentMaxCommits); err != nil {
+			warn("commits", err)
+		}
+	}
+
+	texts := append([]string{intent.Stated, intent.Draft, intent.Branch}, intent.Commits...)
+	intent.Tickets = ticketReferences(texts...)
+
+	if intent.IsEmpty() {
+		fmt.Println("No intent found for the change; pass --intent-message to state it")
+		return
+	}
+	w.intent = intent
+
+	var sources []string
+	if intent.Stated != "" {
+		sources = append(sources, "stated intent")
+	}
+	if intent.Draft != "" {
+		sources = append(sources, "commit message")
+	}
+	if intent.Branch != "" {
+		sources = append(sources, "branch "+intent.Branch)
+	}
+	if len(intent.Commits) > 0 {
+		sources = append(sources, fmt.Sprintf("%d earlier commits", len(intent.Commits)))
+	}
+	if len(intent.Tickets) > 0 {
+		sources = append(sources, "tickets "+strings.Join(intent.Tickets, ", "))
+	}
+	fmt.Printf("Checking the changes against their intent: %s\n", strings.Join(sources, "; "))
+	logging.InfoWith("Gathered the intent of the change", map[string]interface{}{
+		"sources": strings.Join(sources, "; "),
+	})
+}
+
+// ticketReferences returns the distinct tickets referenced in texts, such as
+// PROJ-123 or #42, in the order they first appear
+func ticketReferences(texts ...string) []string {
+	seen := make(map[string]bool)
+	var tickets []string
+	for _, text := range texts {
+		for _, match := range ticketPattern.FindAllStringSubmatch(text, -1) {
+			ticket := match[0]
+			if match[2] != "" {
+				ticket = match[2]
+			} else if notTicketPrefixes[match[1]] {
+				continue
+			}
+			if !seen[ticket] {
+				seen[ticket] = true
+				tickets = append(tickets, ticket)
+			}
+		}
+	}
+	return tickets
+}
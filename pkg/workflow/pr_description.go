@@ -0,0 +1,173 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/git"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/prompt"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// prTemplatePaths are the places a pull request template is looked for,
+// relative to the repository root
+var prTemplatePaths = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+}
+
+// PRDescriptionOptions selects the branch of a pull request and its template
+type PRDescriptionOptions struct {
+	// Branch is the branch of the pull request, the current branch if empty
+	Branch string
+	// Base is the branch the pull request is merged into, the default branch
+	// if empty
+	Base string
+	// TemplatePath is the template to fill in; if empty, the repository's
+	// pull request template is used if it has one
+	TemplatePath string
+	// NoTemplate leaves out any template
+	NoTemplate bool
+}
+
+// PRDescription is the title and body of a pull request
+type PRDescription struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Branch and Base are the branch of the pull request and its base
+	Branch string `json:"branch"`
+	Base   string `json:"base"`
+	// Template is the template the body follows, if any
+	Template string `json:"template,omitempty"`
+}
+
+// String returns the description as a commit message: the title, an empty
+// line and the body
+func (d *PRDescription) String() string {
+	return d.Title + "\n\n" + d.Body + "\n"
+}
+
+// DescribePullRequest writes the title and body of a pull request for a
+// branch from its diff against the base and its commit messages. Progress is
+// reported on stderr, so that the description can be piped.
+func (w *ReviewWorkflow) DescribePullRequest(ctx context.Context, options PRDescriptionOptions) (*PRDescription, error) {
+	defer w.finishCassette()
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, err
+	}
+	reader, ok := w.repoDetector.(git.HistoryReader)
+	if !ok {
+		return nil, fmt.Errorf("reading changes from the repository is not supported")
+	}
+	for _, name := range []string{options.Branch, options.Base} {
+		if strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("invalid branch %q", name)
+		}
+	}
+
+	branch := options.Branch
+	if branch == "" {
+		branch = w.currentBranch(repoRoot)
+	}
+	base, mergeBase, err := reader.GetBranchBase(repoRoot, branch, options.Base)
+	if err != nil {
+		return nil, err
+	}
+	change, err := reader.GetRangeChange(repoRoot, mergeBase+".."+branch)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(change.Diff) == "" {
+		return nil, fmt.Errorf("%s has no changes against %s", branch, base)
+	}
+
+	templatePath, template, err := loadPRTemplate(repoRoot, options)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.InfoWith("Describing pull request", map[string]interface{}{
+		"branch":   branch,
+		"base":     base,
+		"commits":  len(change.Messages),
+		"template": templatePath,
+	})
+	fmt.Fprintf(os.Stderr, "Describing %s against %s", branch, base)
+	if templatePath != "" {
+		fmt.Fprintf(os.Stderr, " with the template %s", templatePath)
+	}
+	fmt.Fprintln(os.Stderr, "...")
+
+	descriptionPrompt := prompt.CreatePRDescriptionPrompt(prompt.PRDescriptionRequest{
+		Branch:   branch,
+		Base:     base,
+		Messages: change.Messages,
+		Stat:     change.Stat,
+		Diff:     change.Diff,
+		Template: template,
+	})
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(w.config.LLM.Timeout)*time.Second)
+	defer cancel()
+	text, err := w.provider.GetCompletion(timeoutCtx, descriptionPrompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe %s: %w", branch, err)
+	}
+
+	title, body := prompt.ParsePRDescription(util.RemoveThinkTags(text))
+	if title == "" {
+		return nil, fmt.Errorf("failed to describe %s: the answer has no title", branch)
+	}
+	return &PRDescription{
+		Title:    title,
+		Body:     body,
+		Branch:   branch,
+		Base:     base,
+		Template: templatePath,
+	}, nil
+}
+
+// currentBranch returns the name of the current branch, or HEAD if it is
+// detached or its name cannot be read
+func (w *ReviewWorkflow) currentBranch(repoRoot string) string {
+	if reader, ok := w.repoDetector.(git.IntentReader); ok {
+		if branch, err := reader.GetBranchName(repoRoot); err == nil && branch != "" {
+			return branch
+		}
+	}
+	return "HEAD"
+}
+
+// loadPRTemplate returns the path and content of the pull request template
+// to fill in: the one given in options, or else the repository's own. The
+// path is empty if there is no template.
+func loadPRTemplate(repoRoot string, options PRDescriptionOptions) (string, string, error) {
+	if options.NoTemplate {
+		return "", "", nil
+	}
+	if options.TemplatePath != "" {
+		content, err := os.ReadFile(options.TemplatePath)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read template: %w", err)
+		}
+		return options.TemplatePath, string(content), nil
+	}
+
+	for _, path := range prTemplatePaths {
+		content, err := os.ReadFile(filepath.Join(repoRoot, path))
+		if err == nil && strings.TrimSpace(string(content)) != "" {
+			return path, string(content), nil
+		}
+	}
+	return "", "", nil
+}
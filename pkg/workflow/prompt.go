@@ -0,0 +1,165 @@
+package workflow
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/niels/git-llm-review/pkg/git"
+	"github.com/niels/git-llm-review/pkg/processor"
+	"github.com/niels/git-llm-review/pkg/prompt"
+)
+
+// RenderedPrompt is the prompt that would be sent to review a file
+type RenderedPrompt struct {
+	// Provider is the name of the provider the prompt is built for
+	Provider string
+	// Focus is the focus of the review pass the prompt is sent in, when each
+	// focus is reviewed separately
+	Focus string
+	// System and User are the system prompt and the user prompt
+	System string
+	User   string
+	// TemplateVersion identifies the templates the prompt was built from
+	TemplateVersion string
+}
+
+// RenderPrompt builds the prompts for reviewing the changed file at path,
+// without sending them: one prompt, or one per focus when each focus is
+// reviewed separately. The path is relative to the current directory. No
+// provider is set up, so no API key is needed.
+func RenderPrompt(options Options, path string) ([]RenderedPrompt, error) {
+	cfg, err := loadConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	repoDetector := git.NewRepositoryDetector()
+	templates, err := loadPromptTemplates(cfg, repoDetector)
+	if err != nil {
+		return nil, err
+	}
+
+	focus, focusMode, err := reviewFocus(cfg, options)
+	if err != nil {
+		return nil, err
+	}
+
+	checklists, err := prompt.Checklists(cfg.Prompt.Checklists)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ReviewWorkflow{
+		options:      options,
+		config:       cfg,
+		repoDetector: repoDetector,
+		templates:    templates,
+		focus:        focus,
+		focusMode:    focusMode,
+		checklists:   checklists,
+	}
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := w.changedFile(repoRoot, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.loadGuidelines(repoRoot, []processor.FileInfo{file}); err != nil {
+		return nil, err
+	}
+	if w.intentMode() {
+		w.gatherIntent(repoRoot)
+	}
+	request, err := processor.NewReviewRequest(repoRoot, repoDetector, file, w.reviewOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	providerName := cfg.LLM.Provider
+	providerType := prompt.ProviderTypeFor(providerName)
+	render := func(focus string) RenderedPrompt {
+		return RenderedPrompt{
+			Provider:        providerName,
+			Focus:           focus,
+			System:          prompt.CreateSystemPrompt(request, providerType),
+			User:            prompt.CreatePrompt(request, providerType),
+			TemplateVersion: templates.Version,
+		}
+	}
+
+	passes := w.focusPasses()
+	if len(passes) == 0 {
+		return []RenderedPrompt{render("")}, nil
+	}
+	rendered := make([]RenderedPrompt, 0, len(passes))
+	for _, focus := range passes {
+		request.Options.Focus = []string{focus}
+		rendered = append(rendered, render(focus))
+	}
+	return rendered, nil
+}
+
+// changedFile finds the file at path among the files that would be reviewed
+func (w *ReviewWorkflow) changedFile(repoRoot, path string) (processor.FileInfo, error) {
+	relPath, err := repoPath(repoRoot, path)
+	if err != nil {
+		return processor.FileInfo{}, err
+	}
+
+	var files []processor.FileInfo
+	if w.options.All {
+		changedFiles, err := w.repoDetector.GetAllChangedFiles(repoRoot, w.config)
+		if err != nil {
+			return processor.FileInfo{}, fmt.Errorf("failed to get changed files: %w", err)
+		}
+		files = processor.ConvertChangedFilesToFileInfo(changedFiles)
+	} else {
+		stagedFiles, err := w.repoDetector.GetStagedFiles(repoRoot, w.config)
+		if err != nil {
+			return processor.FileInfo{}, fmt.Errorf("failed to get staged files: %w", err)
+		}
+		files = processor.ConvertStagedFilesToFileInfo(stagedFiles)
+	}
+
+	for _, file := range files {
+		if file.Path == relPath {
+			return file, nil
+		}
+	}
+	if w.options.All {
+		return processor.FileInfo{}, fmt.Errorf("%s has no changes to review", relPath)
+	}
+	return processor.FileInfo{}, fmt.Errorf("%s has no staged changes to review (use --all to include unstaged changes)", relPath)
+}
+
+// repoPath returns path, relative to the current directory, as a path
+// relative to the repository root
+func repoPath(repoRoot, path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	// Compare resolved paths, since the repository root may be reached
+	// through a symbolic link
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(absPath)); err == nil {
+		absPath = filepath.Join(resolved, filepath.Base(absPath))
+	}
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		repoRoot = resolved
+	}
+
+	relPath, err := filepath.Rel(repoRoot, absPath)
+	if err != nil {
+		return "", fmt.Errorf("%s is not in the repository", path)
+	}
+	relPath = filepath.ToSlash(relPath)
+	if relPath == ".." || strings.HasPrefix(relPath, "../") {
+		return "", fmt.Errorf("%s is not in the repository", path)
+	}
+	return relPath, nil
+}
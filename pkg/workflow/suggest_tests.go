@@ -0,0 +1,239 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/extractor"
+	"github.com/niels/git-llm-review/pkg/git"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/prompt"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// maxTestFunctions limits the changed functions of a file that tests are
+// suggested for in one request
+const maxTestFunctions = 15
+
+// TestSuggestion is a test file suggested for the changed functions of a file
+type TestSuggestion struct {
+	// FilePath is the changed file, relative to the repository root
+	FilePath string
+	// TestPath is the test file the suggestion is meant as, relative to the
+	// repository root
+	TestPath string
+	// Functions are the changed functions the tests are for
+	Functions []string
+	// Code is the suggested test file
+	Code string
+	// WrittenTo is the file the suggestion was written to, if any
+	WrittenTo string
+}
+
+// SuggestTests asks for the test cases that the functions touched by the
+// staged changes, or with --all by all uncommitted changes, are missing, as
+// a runnable test file per changed file in the style of the tests near it.
+// The changes can be limited to paths. The suggestions are printed, or
+// written to outputDir under their path in the repository if it is set;
+// files that already exist there are never overwritten.
+func (w *ReviewWorkflow) SuggestTests(ctx context.Context, outputDir string, paths ...string) ([]TestSuggestion, error) {
+	defer w.finishCassette()
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, err
+	}
+	reader, ok := w.repoDetector.(git.HistoryReader)
+	if !ok {
+		return nil, fmt.Errorf("reading changes from the repository is not supported")
+	}
+
+	relPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		relPath, err := repoPath(repoRoot, path)
+		if err != nil {
+			return nil, err
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	change, err := reader.GetWorkingChange(repoRoot, w.options.All, relPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	changedLines := git.ChangedLines(change.Diff)
+	files := make([]string, 0, len(changedLines))
+	for file := range changedLines {
+		if _, ok := extractor.LanguageOf(file); ok && !extractor.IsTestFile(file) {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no changed Go, JavaScript or Python code in the %s", change.Description)
+	}
+
+	extractors := extractor.NewSet(repoRoot)
+	var suggestions []TestSuggestion
+	for _, file := range files {
+		if ctx.Err() != nil {
+			return suggestions, ctx.Err()
+		}
+
+		content, err := w.changedContent(repoRoot, file)
+		if err != nil {
+			logging.WarnWith("Failed to read changed file", map[string]interface{}{
+				"file":  file,
+				"error": err.Error(),
+			})
+			continue
+		}
+		functions := changedFunctions(extractors.ForFile(file), content, changedLines[file])
+		if len(functions) == 0 {
+			logging.InfoWith("No changed functions", map[string]interface{}{"file": file})
+			continue
+		}
+
+		fmt.Printf("Suggesting tests for %d changed functions in %s...\n", len(functions), file)
+		suggestion, err := w.suggestTestsFor(ctx, reader, repoRoot, file, functions)
+		if err != nil {
+			logging.WarnWith("Failed to suggest tests", map[string]interface{}{
+				"file":  file,
+				"error": err.Error(),
+			})
+			fmt.Printf("Could not suggest tests for %s: %s\n", file, err.Error())
+			continue
+		}
+
+		if outputDir == "" {
+			fmt.Printf("\n=== Suggested tests for %s (%s) ===\n\n%s\n", file, suggestion.TestPath, suggestion.Code)
+		} else if err := writeTestSuggestion(outputDir, suggestion); err != nil {
+			fmt.Printf("%s; the suggested tests are:\n\n%s\n", err.Error(), suggestion.Code)
+		} else {
+			fmt.Printf("Suggested tests written to %s\n", suggestion.WrittenTo)
+		}
+		suggestions = append(suggestions, *suggestion)
+	}
+
+	if len(suggestions) == 0 {
+		return nil, fmt.Errorf("no tests suggested for the %s", change.Description)
+	}
+	return suggestions, nil
+}
+
+// suggestTestsFor asks for the tests that the changed functions of file are
+// missing
+func (w *ReviewWorkflow) suggestTestsFor(ctx context.Context, reader git.HistoryReader, repoRoot, file string, functions []string) (*TestSuggestion, error) {
+	fileChange, err := reader.GetWorkingChange(repoRoot, w.options.All, file)
+	if err != nil {
+		return nil, err
+	}
+
+	request := prompt.TestSuggestionRequest{
+		FilePath:  file,
+		Functions: functions,
+		Diff:      fileChange.Diff,
+	}
+	stylePath := extractor.NearestTestFile(filepath.Join(repoRoot, file))
+	if stylePath != "" {
+		request.StylePath, _ = filepath.Rel(repoRoot, stylePath)
+		if content, err := os.ReadFile(stylePath); err == nil {
+			request.StyleExample = string(content)
+		}
+	}
+	request.TestPath = extractor.TestFilePath(file, request.StylePath)
+	if content, err := os.ReadFile(filepath.Join(repoRoot, request.TestPath)); err == nil {
+		request.ExistingTests = string(content)
+	}
+
+	logging.InfoWith("Suggesting tests", map[string]interface{}{
+		"file":      file,
+		"functions": len(functions),
+		"style":     request.StylePath,
+	})
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(w.config.LLM.Timeout)*time.Second)
+	defer cancel()
+	text, err := w.provider.GetCompletion(timeoutCtx, prompt.CreateTestSuggestionPrompt(request))
+	if err != nil {
+		return nil, err
+	}
+
+	code := prompt.ExtractTestCode(util.RemoveThinkTags(text))
+	if strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("the answer has no tests")
+	}
+	return &TestSuggestion{
+		FilePath:  file,
+		TestPath:  request.TestPath,
+		Functions: functions,
+		Code:      code,
+	}, nil
+}
+
+// changedContent returns the version of file that the line numbers of the
+// change refer to: the staged version, or with --all the working tree
+func (w *ReviewWorkflow) changedContent(repoRoot, file string) ([]byte, error) {
+	if w.options.All {
+		return os.ReadFile(filepath.Join(repoRoot, file))
+	}
+	content, err := w.repoDetector.GetFileContent(repoRoot, file)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// changedFunctions returns the functions of the content of a file that
+// contain the changed lines, each once, up to maxTestFunctions
+func changedFunctions(codeExtractor *extractor.CodeExtractor, content []byte, lines []int) []string {
+	if codeExtractor == nil {
+		return nil
+	}
+
+	var functions []string
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		function, err := codeExtractor.ExtractFunctionAtLineIn(content, line)
+		if err != nil || seen[function] {
+			continue
+		}
+		seen[function] = true
+		functions = append(functions, function)
+		if len(functions) == maxTestFunctions {
+			break
+		}
+	}
+	return functions
+}
+
+// writeTestSuggestion writes the suggested test file under outputDir at its
+// path in the repository, without overwriting a file that exists there
+func writeTestSuggestion(outputDir string, suggestion *TestSuggestion) error {
+	path := filepath.Join(outputDir, suggestion.TestPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists and was not overwritten", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if _, err := file.WriteString(suggestion.Code); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	suggestion.WrittenTo = path
+	return nil
+}
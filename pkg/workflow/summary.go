@@ -0,0 +1,78 @@
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/llm"
+	"github.com/niels/git-llm-review/pkg/logging"
+	"github.com/niels/git-llm-review/pkg/processor"
+	"github.com/niels/git-llm-review/pkg/prompt"
+	"github.com/niels/git-llm-review/pkg/util"
+)
+
+// changeSummaryMode reports whether the whole change is summarized before
+// the files are reviewed
+func (w *ReviewWorkflow) changeSummaryMode() bool {
+	return w.options.ChangeSummary || config.Enabled(w.config.Prompt.ChangeSummary)
+}
+
+// summarizeChange asks the provider for a summary of the change to files,
+// which is added to the prompt of every file and shown in the reports. A
+// summary that cannot be generated is left out, and the files are reviewed
+// without it.
+func (w *ReviewWorkflow) summarizeChange(ctx context.Context, repoRoot string, files []processor.FileInfo) {
+	var changed []llm.ReviewFile
+	for _, file := range files {
+		request, err := processor.NewReviewRequest(repoRoot, w.repoDetector, file, llm.ReviewOptions{})
+		if err != nil {
+			logging.WarnWith("Leaving file out of the change summary", map[string]interface{}{
+				"file":  file.Path,
+				"error": err.Error(),
+			})
+			continue
+		}
+		changed = append(changed, llm.ReviewFile{
+			FilePath:    request.FilePath,
+			FileContent: request.FileContent,
+			FileDiff:    request.FileDiff,
+		})
+	}
+	if len(changed) == 0 {
+		return
+	}
+	w.diffStat = prompt.FormatDiffStat(changed)
+
+	fmt.Printf("\nSummarizing the change to %d files...\n", len(changed))
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(w.config.LLM.Timeout)*time.Second)
+	defer cancel()
+
+	response, err := llm.CompleteWithMetadata(timeoutCtx, w.provider, prompt.CreateSummaryPrompt(changed))
+	if err != nil {
+		logging.WarnWith("Failed to summarize the change", map[string]interface{}{
+			"error": err.Error(),
+		})
+		fmt.Printf("Could not summarize the change, reviewing the files without a summary: %s\n", err.Error())
+		return
+	}
+
+	w.changeSummary = strings.TrimSpace(util.RemoveThinkTags(response.Review))
+	logging.InfoWith("Summarized the change", map[string]interface{}{
+		"files":  len(changed),
+		"length": len(w.changeSummary),
+	})
+}
+
+// printChangeSummary shows the summary of the whole change, if there is one
+func (w *ReviewWorkflow) printChangeSummary() {
+	if w.changeSummary == "" {
+		return
+	}
+	fmt.Printf("\n=== Overall change ===\n%s\n", w.changeSummary)
+	if w.diffStat != "" {
+		fmt.Printf("\n%s", w.diffStat)
+	}
+}
@@ -3,24 +3,30 @@ package workflow
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/niels/git-llm-review/pkg/cassette"
 	"github.com/niels/git-llm-review/pkg/config"
 	"github.com/niels/git-llm-review/pkg/git"
 	"github.com/niels/git-llm-review/pkg/llm"
-	"github.com/niels/git-llm-review/pkg/llm/anthropic"
+	_ "github.com/niels/git-llm-review/pkg/llm/anthropic" // Register the providers
 	"github.com/niels/git-llm-review/pkg/llm/exchangelog"
-	"github.com/niels/git-llm-review/pkg/llm/openai"
+	_ "github.com/niels/git-llm-review/pkg/llm/fake"
+	_ "github.com/niels/git-llm-review/pkg/llm/openai"
 	"github.com/niels/git-llm-review/pkg/llm/promptlog"
+	"github.com/niels/git-llm-review/pkg/llm/transport"
 	"github.com/niels/git-llm-review/pkg/logging"
 	"github.com/niels/git-llm-review/pkg/output"
 	"github.com/niels/git-llm-review/pkg/parse"
 	"github.com/niels/git-llm-review/pkg/processor"
 	"github.com/niels/git-llm-review/pkg/progress"
+	"github.com/niels/git-llm-review/pkg/prompt"
 )
 
 // Options represents the options for the review workflow
@@ -30,17 +36,37 @@ type Options struct {
 	OutputFormat    string
 	OutputPath      string
 	ProviderName    string
+	Profile         string // named profile from the configuration
 	VerboseOutput   bool
 	LogPrompts      bool
 	LogFullExchange bool
+	RecordPath      string   // record provider traffic to this cassette
+	ReplayPath      string   // replay provider traffic from this cassette
+	Batch           bool     // submit the reviews as a batch job instead of waiting for them
+	Focus           []string // kinds of issues to concentrate on, overriding the configuration
+	FocusMode       string   // FocusCombined or FocusSeparate, overriding the configuration
+	Changeset       bool     // review related files together in one prompt
+	ChangeSummary   bool     // summarize the whole change and add the summary to every prompt
+	Intent          bool     // check that the changes do what the commit message and branch say
+	IntentMessage   string   // what the change is meant to do, as stated by the user
 }
 
+// Ways of reviewing with more than one focus
+const (
+	// FocusCombined reviews each file once, for all foci together
+	FocusCombined = "combined"
+	// FocusSeparate reviews each file once per focus
+	FocusSeparate = "separate"
+)
+
 // Statistics represents review statistics
 type Statistics struct {
 	FilesProcessed  int
 	FilesWithErrors int
+	FilesCancelled  int
 	TotalIssues     int
 	IssuesByType    map[string]int
+	IssuesByFocus   map[string]int
 	IssuesByFile    map[string]int
 	Duration        time.Duration
 }
@@ -54,8 +80,17 @@ type ReviewWorkflow struct {
 	terminalOutput  *output.TerminalFormatter
 	markdownOutput  *output.MarkdownFormatter
 	progressTracker progress.Tracker
+	templates       *prompt.TemplateSet
+	guidelines      []llm.Guideline
+	focus           []string
+	focusMode       string
+	checklists      map[string][]string
+	changeSummary   string
+	diffStat        string
+	intent          *llm.Intent
 	diffHighlights  []diffHighlight
 	pendingDiffs    []pendingDiff
+	closeCassette   func() error
 }
 
 type diffHighlight struct {
@@ -75,21 +110,57 @@ type pendingDiff struct {
 // NewReviewWorkflow creates a new review workflow with the given options
 func NewReviewWorkflow(options Options) (*ReviewWorkflow, error) {
 	// Load configuration
-	var cfg *config.Config
-	var err error
+	cfg, err := loadConfig(options)
+	if err != nil {
+		return nil, err
+	}
 
-	if options.ConfigPath != "" {
-		cfg, err = config.Load(options.ConfigPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load configuration: %w", err)
-		}
-	} else {
-		cfg = config.Default()
+	// Check the HTTP settings before any review starts
+	network, err := transport.Network(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid http settings: %w", err)
+	}
+
+	// Record or replay provider traffic if requested
+	closeCassette, err := applyCassette(cfg, options, network)
+	if err != nil {
+		return nil, err
 	}
 
+	// Give the cassette up if the workflow cannot be set up
+	created := false
+	defer func() {
+		if !created && closeCassette != nil {
+			closeCassette()
+		}
+	}()
+
 	// Initialize repository detector
 	repoDetector := git.NewRepositoryDetector()
 
+	// Check the prompt templates of the repository before any review starts
+	templates, err := loadPromptTemplates(cfg, repoDetector)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the requested review focus
+	focus, focusMode, err := reviewFocus(cfg, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// A batch job holds one request per file
+	if options.Batch && options.Changeset {
+		return nil, fmt.Errorf("changeset reviews cannot be submitted as a batch")
+	}
+
+	// Check the configured language checklists
+	checklists, err := prompt.Checklists(cfg.Prompt.Checklists)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize prompt logger if requested
 	if options.LogPrompts {
 		logging.Info("Initializing prompt logger")
@@ -128,37 +199,24 @@ func NewReviewWorkflow(options Options) (*ReviewWorkflow, error) {
 		logging.Debug("Exchange logging is disabled")
 	}
 
-	// Initialize LLM provider
-	var provider llm.Provider
-
-	providerName := options.ProviderName
-	if providerName == "" {
-		// If provider name is not specified, use the one from config
-		providerName = cfg.LLM.Provider
-	}
-
-	switch strings.ToLower(providerName) {
-	case "openai":
-		provider, err = openai.NewProvider(cfg)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize OpenAI provider: %w", err)
-		}
-	case "anthropic":
-		provider, err = anthropic.NewProvider(cfg)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize Anthropic provider: %w", err)
-		}
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", providerName)
+	// Initialize LLM provider, wrapped in a fallback chain if fallbacks are
+	// configured. This reads the API keys from their configured sources.
+	provider, err := llm.CreateProviderFromConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialize output formatters
 	terminalOutput := output.NewTerminalFormatter(true) // Always use color in the workflow
-	markdownOutput := output.NewMarkdownFormatter()
+	markdownOutput := output.NewMarkdownFormatter().
+		WithRationale(cfg.Output.IncludeRationale).
+		WithProfile(cfg.ActiveProfile).
+		WithTemplateVersion(templates.Version)
 
 	// Initialize progress tracker
 	progressTracker := progress.NewConsoleTracker()
 
+	created = true
 	return &ReviewWorkflow{
 		options:         options,
 		config:          cfg,
@@ -167,38 +225,205 @@ func NewReviewWorkflow(options Options) (*ReviewWorkflow, error) {
 		terminalOutput:  terminalOutput,
 		markdownOutput:  markdownOutput,
 		progressTracker: progressTracker,
+		templates:       templates,
+		focus:           focus,
+		focusMode:       focusMode,
+		checklists:      checklists,
 		diffHighlights:  make([]diffHighlight, 0),
 		pendingDiffs:    make([]pendingDiff, 0),
+		closeCassette:   closeCassette,
 	}, nil
 }
 
-// Run executes the review workflow
-func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
-	startTime := time.Now()
+// loadConfig loads the configuration given in the options, or the default
+// configuration, and applies the requested or default profile and provider
+func loadConfig(options Options) (*config.Config, error) {
+	cfg := config.Default()
+	if options.ConfigPath != "" {
+		var err error
+		cfg, err = config.Load(options.ConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+	}
 
-	stats := &Statistics{
-		IssuesByType: make(map[string]int),
-		IssuesByFile: make(map[string]int),
+	// Apply the requested or default profile
+	if err := cfg.ApplyProfile(options.Profile); err != nil {
+		return nil, err
 	}
 
-	// Step 1: Detect Git repository
-	logging.Info("Detecting Git repository...")
-	isRepo, err := w.repoDetector.IsGitRepository(".")
+	// The provider given on the command line replaces the configured one
+	// before the API keys and the transport are set up for it
+	cfg.OverrideProvider(options.ProviderName)
+	if cfg.ActiveProfile != "" {
+		logging.InfoWith("Using profile", map[string]interface{}{
+			"profile":  cfg.ActiveProfile,
+			"provider": cfg.LLM.Provider,
+			"model":    cfg.LLM.Model,
+		})
+	}
+	return cfg, nil
+}
+
+// loadPromptTemplates loads the templates that override the built-in
+// prompts. A relative templates directory is resolved against the root of the
+// repository in the current directory.
+func loadPromptTemplates(cfg *config.Config, repoDetector git.RepositoryDetector) (*prompt.TemplateSet, error) {
+	dir := cfg.Prompt.TemplatesDir
+	if dir == "" {
+		dir = prompt.DefaultTemplatesDir
+	}
+	if !filepath.IsAbs(dir) {
+		if repoRoot, err := repoDetector.GetRepositoryRoot("."); err == nil {
+			dir = filepath.Join(repoRoot, dir)
+		}
+	}
+
+	templates, err := prompt.LoadTemplates(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to detect Git repository: %w", err)
+		return nil, err
 	}
-	if !isRepo {
-		return nil, fmt.Errorf("not a Git repository")
+	if len(templates.Templates) > 0 {
+		logging.InfoWith("Using prompt templates", map[string]interface{}{
+			"dir":       templates.Dir,
+			"templates": strings.Join(templates.Names(), ", "),
+			"version":   templates.Version,
+		})
 	}
+	return templates, nil
+}
 
-	// Get repository root
-	repoRoot, err := w.repoDetector.GetRepositoryRoot(".")
+// reviewFocus returns the foci of the review and how to review them, from
+// the options or else the configuration
+func reviewFocus(cfg *config.Config, options Options) ([]string, string, error) {
+	names := cfg.Prompt.Focus
+	if len(options.Focus) > 0 {
+		names = options.Focus
+	}
+	foci, err := prompt.ParseFocus(names)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository root: %w", err)
+		return nil, "", err
 	}
-	logging.InfoWith("Git repository detected", map[string]interface{}{
-		"root": repoRoot,
+
+	mode := cfg.Prompt.FocusMode
+	if options.FocusMode != "" {
+		mode = options.FocusMode
+	}
+	switch mode {
+	case "":
+		mode = FocusCombined
+	case FocusCombined, FocusSeparate:
+	default:
+		return nil, "", fmt.Errorf("unknown focus mode %q (expected %s or %s)", mode, FocusCombined, FocusSeparate)
+	}
+
+	focus := make([]string, len(foci))
+	for i, f := range foci {
+		focus[i] = string(f)
+	}
+	if len(focus) > 0 {
+		logging.InfoWith("Using review focus", map[string]interface{}{
+			"focus": strings.Join(focus, ", "),
+			"mode":  mode,
+		})
+	}
+	return focus, mode, nil
+}
+
+// replayAPIKey is used when replaying a cassette without an API key, since
+// no request reaches the provider
+const replayAPIKey = "cassette-replay"
+
+// applyCassette sets up recording or replaying of provider traffic from the
+// options, which take precedence over the configuration file. Recorded
+// requests are sent through network. The returned function closes the
+// cassette; it is nil when no cassette is used.
+func applyCassette(cfg *config.Config, options Options, network http.RoundTripper) (func() error, error) {
+	if options.RecordPath != "" && options.ReplayPath != "" {
+		return nil, fmt.Errorf("cannot record and replay a cassette at the same time")
+	}
+	if options.RecordPath != "" {
+		cfg.Cassette = config.CassetteConfig{Mode: cassette.ModeRecord, Path: options.RecordPath}
+	}
+	if options.ReplayPath != "" {
+		cfg.Cassette = config.CassetteConfig{Mode: cassette.ModeReplay, Path: options.ReplayPath}
+	}
+
+	switch cfg.Cassette.Mode {
+	case "":
+		return nil, nil
+	case cassette.ModeRecord, cassette.ModeReplay:
+	default:
+		return nil, fmt.Errorf("unknown cassette mode: %q", cfg.Cassette.Mode)
+	}
+	if cfg.Cassette.Path == "" {
+		return nil, fmt.Errorf("no cassette path configured")
+	}
+
+	// Open the cassette now so that a missing or corrupt file is reported
+	// before any review starts
+	if _, err := cassette.Open(cfg.Cassette.Mode, cfg.Cassette.Path, network); err != nil {
+		return nil, err
+	}
+
+	// No request reaches the provider when replaying, so there is no need
+	// to run a key command or read a key file
+	if cfg.Cassette.Mode == cassette.ModeReplay {
+		useReplayKey(&cfg.LLM)
+		for i := range cfg.LLM.Fallbacks {
+			useReplayKey(&cfg.LLM.Fallbacks[i])
+		}
+	}
+
+	logging.InfoWith("Using provider cassette", map[string]interface{}{
+		"mode": cfg.Cassette.Mode,
+		"path": cfg.Cassette.Path,
 	})
+	mode, path := cfg.Cassette.Mode, cfg.Cassette.Path
+	return func() error { return cassette.Close(mode, path) }, nil
+}
+
+// useReplayKey replaces the key sources of a provider with a placeholder key
+// unless a key is set in the configuration
+func useReplayKey(llmCfg *config.LLMConfig) {
+	if llmCfg.APIKey == "" {
+		llmCfg.APIKey = replayAPIKey
+	}
+	llmCfg.APIKeyFile = ""
+	llmCfg.APIKeyCommand = ""
+}
+
+// finishCassette closes the cassette of the workflow, if any, once no more
+// requests are sent through it
+func (w *ReviewWorkflow) finishCassette() {
+	if w.closeCassette == nil {
+		return
+	}
+	if err := w.closeCassette(); err != nil {
+		logging.WarnWith("Failed to close cassette", map[string]interface{}{
+			"path":  w.config.Cassette.Path,
+			"error": err.Error(),
+		})
+	}
+	w.closeCassette = nil
+}
+
+// Run executes the review workflow
+func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
+	defer w.finishCassette()
+	startTime := time.Now()
+
+	stats := &Statistics{
+		IssuesByType:  make(map[string]int),
+		IssuesByFocus: make(map[string]int),
+		IssuesByFile:  make(map[string]int),
+	}
+
+	// Step 1: Detect Git repository
+	repoRoot, err := w.repositoryRoot()
+	if err != nil {
+		return nil, err
+	}
 
 	// Step 2: Get repository name from the root directory
 	repoName := filepath.Base(repoRoot)
@@ -236,7 +461,10 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 		"count": len(files),
 	})
 
-	// Display files to be reviewed
+	// Display the active profile and the files to be reviewed
+	if w.config.ActiveProfile != "" {
+		fmt.Printf("Using profile %s: %s\n", w.config.ActiveProfile, llm.DescribeProvider(w.primaryProvider()))
+	}
 	fmt.Printf("Found %d files to review:\n", len(files))
 	for _, file := range files {
 		statusDesc := file.Status
@@ -246,13 +474,60 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 		fmt.Printf("  %s %s\n", statusDesc, file.Path)
 	}
 
-	// Step 4: Create file processor
-	fileProcessor := processor.ReviewFileProcessor(
-		repoRoot,
-		w.repoDetector,
-		w.provider,
-		w.provider.Name(),
-	)
+	// Read the project guidelines that apply to the files
+	if err := w.loadGuidelines(repoRoot, files); err != nil {
+		return nil, err
+	}
+
+	// Read what the change is meant to do, if requested
+	if w.intentMode() {
+		w.gatherIntent(repoRoot)
+	}
+
+	// Summarize the whole change for the review of each file, if requested.
+	// Batch jobs are reviewed without a summary: every prompt of the job
+	// needs it, so it would be a separate request at the full price.
+	if w.changeSummaryMode() && w.options.Batch {
+		logging.Info("Skipping the change summary for the batch job")
+		fmt.Println("\nThe change is not summarized for batch jobs")
+	} else if w.changeSummaryMode() {
+		w.summarizeChange(ctx, repoRoot, files)
+	}
+
+	// Submit the reviews as a batch job instead, if requested
+	if w.options.Batch {
+		return stats, w.submitBatch(ctx, repoRoot, files)
+	}
+
+	// Review related files together, if requested
+	if w.changesetMode() {
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(w.config.LLM.Timeout)*time.Second)
+		defer cancel()
+
+		results, errors := w.reviewChangeset(timeoutCtx, repoRoot, files)
+		stats.Duration = time.Since(startTime)
+		return w.report(results, errors, stats, repoName, timeoutCtx.Err() != nil)
+	}
+
+	// Step 4: Create file processor, with a pass per focus if requested
+	var fileProcessor processor.FileProcessor
+	if passes := w.focusPasses(); len(passes) > 0 {
+		focusPasses := make([]processor.FocusPass, 0, len(passes))
+		for _, focus := range passes {
+			focusPasses = append(focusPasses, processor.FocusPass{
+				Focus:   focus,
+				Process: processor.ReviewFileProcessor(repoRoot, w.repoDetector, w.provider, w.reviewOptionsFor(focus)),
+			})
+		}
+		fileProcessor = processor.MultiPassFileProcessor(focusPasses)
+	} else {
+		fileProcessor = processor.ReviewFileProcessor(
+			repoRoot,
+			w.repoDetector,
+			w.provider,
+			w.reviewOptions(),
+		)
+	}
 
 	// Step 5: Create concurrent processor with progress tracker
 	concurrentProcessor := processor.NewConcurrentProcessor(w.config, fileProcessor).
@@ -271,9 +546,19 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 	results, errors := concurrentProcessor.ProcessFiles(timeoutCtx, files)
 
 	// Step 7: Collect statistics and display results
+	stats.Duration = time.Since(startTime)
+	return w.report(results, errors, stats, repoName, timeoutCtx.Err() != nil)
+}
+
+// report displays the review results and statistics, and writes the
+// markdown reports if an output directory is set. cancelled is true when the
+// run was interrupted or timed out before every file was reviewed.
+func (w *ReviewWorkflow) report(results map[string]*parse.ReviewResult, errors map[string]error, stats *Statistics, repoName string, cancelled bool) (*Statistics, error) {
 	stats.FilesProcessed = len(results)
 	stats.FilesWithErrors = len(errors)
-	stats.Duration = time.Since(startTime)
+
+	// Display the summary of the whole change
+	w.printChangeSummary()
 
 	// Display errors
 	if len(errors) > 0 {
@@ -286,6 +571,17 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 			})
 			fmt.Printf("  %s: %s\n", filePath, err.Error())
 		}
+
+		// Point out when files were skipped because the provider is down
+		if unavailable := countProviderUnavailable(errors); unavailable > 0 {
+			fmt.Printf("\nThe provider was unavailable; %d files were not reviewed. The report below is partial.\n", unavailable)
+		}
+
+		// Point out when the run was interrupted or timed out
+		if cancelled {
+			stats.FilesCancelled = countCancelled(errors)
+			fmt.Printf("\nThe review was cancelled; %d files were not reviewed. The report below is partial.\n", stats.FilesCancelled)
+		}
 	}
 
 	// Display results in terminal
@@ -297,9 +593,15 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 		for _, issue := range result.Issues {
 			issueType := extractIssueType(issue.Title)
 			stats.IssuesByType[issueType]++
+			if issue.Focus != "" {
+				stats.IssuesByFocus[issue.Focus]++
+			}
 		}
 
 		fmt.Printf("\n=== Review for %s (%d issues) ===\n", filePath, issueCount)
+		if result.Provider != "" && result.Provider != llm.DescribeProvider(w.primaryProvider()) {
+			fmt.Printf("Reviewed by fallback provider: %s\n", result.Provider)
+		}
 		formattedReview := w.terminalOutput.FormatReview(result)
 
 		// Process the formatted review to handle the diff placeholders
@@ -456,6 +758,9 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 	fmt.Println("\nSummary:")
 	fmt.Printf("Files processed: %d\n", stats.FilesProcessed)
 	fmt.Printf("Files with errors: %d\n", stats.FilesWithErrors)
+	if stats.FilesCancelled > 0 {
+		fmt.Printf("Files cancelled: %d\n", stats.FilesCancelled)
+	}
 	fmt.Printf("Total issues found: %d\n", stats.TotalIssues)
 
 	if len(stats.IssuesByType) > 0 {
@@ -465,6 +770,13 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 		}
 	}
 
+	if len(stats.IssuesByFocus) > 0 {
+		fmt.Println("Issues by focus:")
+		for focus, count := range stats.IssuesByFocus {
+			fmt.Printf("  %s: %d\n", focus, count)
+		}
+	}
+
 	if len(stats.IssuesByFile) > 0 {
 		fmt.Println("Issues by file:")
 		for filePath, count := range stats.IssuesByFile {
@@ -477,6 +789,131 @@ func (w *ReviewWorkflow) Run(ctx context.Context) (*Statistics, error) {
 	return stats, nil
 }
 
+// repositoryRoot detects the Git repository in the current directory and
+// returns its root
+func (w *ReviewWorkflow) repositoryRoot() (string, error) {
+	logging.Info("Detecting Git repository...")
+	isRepo, err := w.repoDetector.IsGitRepository(".")
+	if err != nil {
+		return "", fmt.Errorf("failed to detect Git repository: %w", err)
+	}
+	if !isRepo {
+		return "", fmt.Errorf("not a Git repository")
+	}
+
+	// Get repository root
+	repoRoot, err := w.repoDetector.GetRepositoryRoot(".")
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository root: %w", err)
+	}
+	logging.InfoWith("Git repository detected", map[string]interface{}{
+		"root": repoRoot,
+	})
+	return repoRoot, nil
+}
+
+// reviewOptions returns the options used for every review request
+func (w *ReviewWorkflow) reviewOptions() llm.ReviewOptions {
+	options := llm.ReviewOptions{
+		AdditionalInstructions: w.config.Prompt.AdditionalInstructions,
+	}
+	if w.templates != nil {
+		options.PromptTemplates = w.templates.Templates
+	}
+	options.Guidelines = w.guidelines
+	options.GuidelinesMaxChars = w.config.Prompt.GuidelinesMaxChars
+	options.Checklists = w.checklists
+	options.ChangeSummary = w.changeSummary
+	options.Intent = w.intent
+	if len(w.focusPasses()) == 0 {
+		options.Focus = w.focus
+	}
+	return options
+}
+
+// reviewOptionsFor returns the options used for the review requests of a
+// pass with a single focus
+func (w *ReviewWorkflow) reviewOptionsFor(focus string) llm.ReviewOptions {
+	options := w.reviewOptions()
+	options.Focus = []string{focus}
+	return options
+}
+
+// singleFocus returns the focus of a review with exactly one focus, which
+// every issue found belongs to, or an empty string otherwise
+func (w *ReviewWorkflow) singleFocus() string {
+	if len(w.focus) == 1 {
+		return w.focus[0]
+	}
+	return ""
+}
+
+// focusPasses returns the focus of each pass when every file is reviewed once
+// per focus, or nil when each file is reviewed once
+func (w *ReviewWorkflow) focusPasses() []string {
+	if w.focusMode == FocusSeparate && len(w.focus) > 1 {
+		return w.focus
+	}
+	return nil
+}
+
+// loadGuidelines reads the configured project guidelines and the
+// directory-scoped guidelines that apply to files
+func (w *ReviewWorkflow) loadGuidelines(repoRoot string, files []processor.FileInfo) error {
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+
+	guidelines, err := prompt.LoadGuidelines(repoRoot, w.config.Prompt.Guidelines, w.config.Prompt.GuidelineFile, paths)
+	if err != nil {
+		return err
+	}
+	if len(guidelines) > 0 {
+		names := make([]string, 0, len(guidelines))
+		for _, guideline := range guidelines {
+			names = append(names, guideline.Path)
+		}
+		logging.InfoWith("Using project guidelines", map[string]interface{}{
+			"guidelines": strings.Join(names, ", "),
+		})
+	}
+	w.guidelines = guidelines
+	return nil
+}
+
+// primaryProvider returns the first provider of the fallback chain, or the
+// configured provider if no fallbacks are used
+func (w *ReviewWorkflow) primaryProvider() llm.Provider {
+	if chain, ok := w.provider.(*llm.FallbackProvider); ok {
+		return chain.Providers()[0]
+	}
+	return w.provider
+}
+
+// countProviderUnavailable counts the errors caused by an unavailable provider
+func countProviderUnavailable(fileErrors map[string]error) int {
+	count := 0
+	for _, err := range fileErrors {
+		if errors.Is(err, llm.ErrProviderUnavailable) {
+			count++
+		}
+	}
+	return count
+}
+
+// countCancelled returns the number of files whose review was stopped by
+// cancellation or a timeout
+func countCancelled(fileErrors map[string]error) int {
+	count := 0
+	for _, err := range fileErrors {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, llm.ErrTimeout) {
+			count++
+		}
+	}
+	return count
+}
+
 // extractIssueType extracts the issue type from the title
 func extractIssueType(title string) string {
 	// Common issue types
@@ -484,6 +921,7 @@ func extractIssueType(title string) string {
 		"Bug", "Error", "Security", "Performance",
 		"Style", "Documentation", "Optimization",
 		"Refactoring", "Testing", "Maintainability",
+		"Intent",
 	}
 
 	// Check if the title starts with any of the issue types
@@ -513,11 +951,28 @@ func (w *ReviewWorkflow) writeSummaryReport(filePath string, results map[string]
 	// Write header
 	fmt.Fprintf(writer, "# Code Review Summary for %s\n\n", repoName)
 	fmt.Fprintf(writer, "Generated on: %s\n\n", time.Now().Format(time.RFC1123))
+	if w.config.ActiveProfile != "" {
+		fmt.Fprintf(writer, "Profile: %s\n\n", w.config.ActiveProfile)
+	}
+	if w.templates != nil {
+		fmt.Fprintf(writer, "Prompt templates: %s\n\n", w.templates.Version)
+	}
+
+	// Write the summary of the whole change
+	if w.changeSummary != "" {
+		fmt.Fprintf(writer, "## Overall Change\n\n%s\n\n", w.changeSummary)
+		if w.diffStat != "" {
+			fmt.Fprintf(writer, "```\n%s```\n\n", w.diffStat)
+		}
+	}
 
 	// Write statistics
 	fmt.Fprintf(writer, "## Statistics\n\n")
 	fmt.Fprintf(writer, "- Files processed: %d\n", stats.FilesProcessed)
 	fmt.Fprintf(writer, "- Files with errors: %d\n", stats.FilesWithErrors)
+	if stats.FilesCancelled > 0 {
+		fmt.Fprintf(writer, "- Files cancelled: %d\n", stats.FilesCancelled)
+	}
 	fmt.Fprintf(writer, "- Total issues found: %d\n", stats.TotalIssues)
 	fmt.Fprintf(writer, "- Time taken: %s\n\n", stats.Duration.Round(time.Second))
 
@@ -530,6 +985,15 @@ func (w *ReviewWorkflow) writeSummaryReport(filePath string, results map[string]
 		fmt.Fprintf(writer, "\n")
 	}
 
+	// Write issues by focus
+	if len(stats.IssuesByFocus) > 0 {
+		fmt.Fprintf(writer, "### Issues by Focus\n\n")
+		for focus, count := range stats.IssuesByFocus {
+			fmt.Fprintf(writer, "- %s: %d\n", focus, count)
+		}
+		fmt.Fprintf(writer, "\n")
+	}
+
 	// Write issues by file
 	if len(stats.IssuesByFile) > 0 {
 		fmt.Fprintf(writer, "### Issues by File\n\n")
@@ -543,6 +1007,9 @@ func (w *ReviewWorkflow) writeSummaryReport(filePath string, results map[string]
 	fmt.Fprintf(writer, "## File Summaries\n\n")
 	for filePath, result := range results {
 		fmt.Fprintf(writer, "### [%s](%s)\n\n", filePath, strings.ReplaceAll(filePath, "/", "_")+".md")
+		if result.Provider != "" {
+			fmt.Fprintf(writer, "Reviewed by: %s\n\n", result.Provider)
+		}
 
 		if len(result.Issues) == 0 {
 			fmt.Fprintf(writer, "No issues found\n\n")
@@ -551,7 +1018,11 @@ func (w *ReviewWorkflow) writeSummaryReport(filePath string, results map[string]
 
 		fmt.Fprintf(writer, "Found %d issues:\n\n", len(result.Issues))
 		for _, issue := range result.Issues {
-			fmt.Fprintf(writer, "1. **%s**  \n", issue.Title)
+			if issue.Focus != "" {
+				fmt.Fprintf(writer, "1. **[%s] %s**  \n", issue.Focus, issue.Title)
+			} else {
+				fmt.Fprintf(writer, "1. **%s**  \n", issue.Title)
+			}
 			fmt.Fprintf(writer, "   %s\n\n", issue.Explanation)
 		}
 	}
@@ -2,11 +2,19 @@ package workflow
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/niels/git-llm-review/pkg/config"
+	"github.com/niels/git-llm-review/pkg/extractor"
 	"github.com/niels/git-llm-review/pkg/git"
 	"github.com/niels/git-llm-review/pkg/llm"
 )
@@ -80,7 +88,7 @@ func (m *MockLLMProvider) ReviewCode(ctx context.Context, request *llm.ReviewReq
 	return m.reviewCodeFunc(ctx, request)
 }
 
-func (m *MockLLMProvider) GetCompletion(prompt string) (string, error) {
+func (m *MockLLMProvider) GetCompletion(ctx context.Context, prompt string) (string, error) {
 	return m.getCompletionFunc(prompt)
 }
 
@@ -342,3 +350,504 @@ extensions:
 		}
 	})
 }
+
+// TestReviewWorkflowCassette verifies that the full pipeline, including the
+// real OpenAI provider, can be recorded to a cassette and replayed from it
+// without network access
+func TestReviewWorkflowCassette(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Stand-in for the OpenAI chat completions endpoint
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"created": 1700000000,
+			"model": "gpt-4",
+			"choices": [{
+				"index": 0,
+				"finish_reason": "stop",
+				"message": {
+					"role": "assistant",
+					"content": "{\"issues\": [{\"title\": \"Recorded issue\", \"explanation\": \"Found by the recorded provider\", \"diff\": \"\"}]}"
+				}
+			}]
+		}`))
+	}))
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	configContent := `
+llm:
+  provider: openai
+  api_url: ` + server.URL + `
+  api_key: test-api-key
+  model: gpt-4
+  timeout: 30
+extensions:
+  - .go
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	// Mock repository with a single staged file
+	newRepoDetector := func(diff string) *MockRepositoryDetector {
+		return &MockRepositoryDetector{
+			isGitRepositoryFunc: func(path string) (bool, error) {
+				return true, nil
+			},
+			getRepositoryRootFunc: func(path string) (string, error) {
+				return "/mock/repo/root", nil
+			},
+			getStagedFilesFunc: func(repoRoot string, cfg *config.Config) ([]git.StagedFile, error) {
+				return []git.StagedFile{{Path: "main.go", Status: "M"}}, nil
+			},
+			getFileDiffFunc: func(repoRoot string, filePath string, staged bool) (string, error) {
+				return diff, nil
+			},
+			getDiffWithOptionsFunc: func(repoRoot string, filePath string, staged bool, options git.DiffOptions) (string, error) {
+				return diff, nil
+			},
+			getFileContentFunc: func(repoRoot string, filePath string) (string, error) {
+				return "package main\n", nil
+			},
+		}
+	}
+
+	run := func(options Options, diff string) *Statistics {
+		t.Helper()
+		options.ConfigPath = configPath
+		options.OutputFormat = "terminal"
+
+		workflow, err := NewReviewWorkflow(options)
+		if err != nil {
+			t.Fatalf("Failed to create workflow: %v", err)
+		}
+		workflow.repoDetector = newRepoDetector(diff)
+
+		stats, err := workflow.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Workflow failed: %v", err)
+		}
+		return stats
+	}
+
+	diff := "@@ -1 +1 @@\n-package old\n+package main\n"
+
+	// Record against the stand-in server
+	cassettePath := filepath.Join(tempDir, "review.cassette.jsonl")
+	stats := run(Options{RecordPath: cassettePath}, diff)
+	if stats.TotalIssues != 1 {
+		t.Fatalf("Expected 1 issue while recording, got %d", stats.TotalIssues)
+	}
+	server.Close()
+	recordedCalls := atomic.LoadInt32(&calls)
+
+	// The cassette must not contain the API key
+	data, err := os.ReadFile(cassettePath)
+	if err != nil {
+		t.Fatalf("Failed to read cassette: %v", err)
+	}
+	if strings.Contains(string(data), "test-api-key") {
+		t.Errorf("Expected the cassette not to contain the API key")
+	}
+
+	// Replay with the server gone
+	stats = run(Options{ReplayPath: cassettePath}, diff)
+	if stats.TotalIssues != 1 || stats.FilesWithErrors != 0 {
+		t.Errorf("Expected the replay to find 1 issue without errors, got %d issues and %d errors",
+			stats.TotalIssues, stats.FilesWithErrors)
+	}
+	if atomic.LoadInt32(&calls) != recordedCalls {
+		t.Errorf("Expected the replay not to reach the server")
+	}
+
+	// A request that was not recorded fails instead of reaching the network
+	unmatchedPath := filepath.Join(tempDir, "unmatched.cassette.jsonl")
+	if err := os.WriteFile(unmatchedPath, data, 0644); err != nil {
+		t.Fatalf("Failed to copy cassette: %v", err)
+	}
+	stats = run(Options{ReplayPath: unmatchedPath}, "@@ -1 +1 @@\n-package main\n+package changed\n")
+	if stats.FilesWithErrors != 1 {
+		t.Errorf("Expected the unmatched request to fail, got %d files with errors", stats.FilesWithErrors)
+	}
+}
+
+// TestReviewWorkflowBatch verifies that reviews submitted as a batch job are
+// saved in the repository and reported once the job is collected
+func TestReviewWorkflowBatch(t *testing.T) {
+	repoRoot := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repoRoot, ".git"), 0755); err != nil {
+		t.Fatalf("Failed to create Git directory: %v", err)
+	}
+
+	// Stand-in for the Anthropic message batches endpoints
+	var ended, completions int32
+	var submitted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/messages":
+			atomic.AddInt32(&completions, 1)
+			http.Error(w, "unexpected request outside the batch", http.StatusBadRequest)
+		case "/v1/messages/batches":
+			var body struct {
+				Requests []struct {
+					CustomID string `json:"custom_id"`
+				} `json:"requests"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for _, request := range body.Requests {
+				submitted = append(submitted, request.CustomID)
+			}
+			w.Write([]byte(`{"id":"msgbatch_1","processing_status":"in_progress"}`))
+		case "/v1/messages/batches/msgbatch_1":
+			if atomic.LoadInt32(&ended) == 0 {
+				w.Write([]byte(`{"id":"msgbatch_1","processing_status":"in_progress"}`))
+				return
+			}
+			w.Write([]byte(`{"id":"msgbatch_1","processing_status":"ended"}`))
+		case "/v1/messages/batches/msgbatch_1/results":
+			for _, id := range submitted {
+				fmt.Fprintf(w, `{"custom_id":%q,"result":{"type":"succeeded","message":{"content":[{"type":"text","text":"{\"issues\": [{\"title\": \"Bug: batch issue\"}]}"}]}}}`+"\n", id)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(repoRoot, "config.yaml")
+	configContent := `
+llm:
+  provider: anthropic
+  api_url: ` + server.URL + `
+  api_key: test-api-key
+  model: claude-3-5-sonnet-latest
+  timeout: 30
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	repoDetector := &MockRepositoryDetector{
+		isGitRepositoryFunc: func(path string) (bool, error) {
+			return true, nil
+		},
+		getRepositoryRootFunc: func(path string) (string, error) {
+			return repoRoot, nil
+		},
+		getStagedFilesFunc: func(repoRoot string, cfg *config.Config) ([]git.StagedFile, error) {
+			return []git.StagedFile{{Path: "main.go", Status: "M"}, {Path: "util.go", Status: "A"}}, nil
+		},
+		getFileDiffFunc: func(repoRoot string, filePath string, staged bool) (string, error) {
+			return "+package main\n", nil
+		},
+		getFileContentFunc: func(repoRoot string, filePath string) (string, error) {
+			return "package main\n", nil
+		},
+	}
+	newWorkflow := func(options Options) *ReviewWorkflow {
+		t.Helper()
+		options.ConfigPath = configPath
+		workflow, err := NewReviewWorkflow(options)
+		if err != nil {
+			t.Fatalf("Failed to create workflow: %v", err)
+		}
+		workflow.repoDetector = repoDetector
+		return workflow
+	}
+
+	// Submit the batch; the change is not summarized outside the batch
+	if _, err := newWorkflow(Options{Batch: true, ChangeSummary: true}).Run(context.Background()); err != nil {
+		t.Fatalf("Workflow failed: %v", err)
+	}
+	if len(submitted) != 2 {
+		t.Fatalf("Expected 2 requests in the batch, got %d", len(submitted))
+	}
+	if calls := atomic.LoadInt32(&completions); calls != 0 {
+		t.Errorf("Expected no requests outside the batch, got %d", calls)
+	}
+	if _, err := os.Stat(filepath.Join(repoRoot, ".git", "git-llm-review", "batches", "msgbatch_1.json")); err != nil {
+		t.Fatalf("Expected the batch job to be saved: %v", err)
+	}
+
+	// The job is not collected before it has ended
+	outputDir := filepath.Join(repoRoot, "reports")
+	workflow := newWorkflow(Options{OutputPath: outputDir})
+	job, info, err := workflow.BatchStatus(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if job.ID != "msgbatch_1" || info.Done {
+		t.Errorf("Expected the last batch in progress, got %s: %+v", job.ID, info)
+	}
+	if _, err := workflow.CollectBatch(context.Background(), ""); err == nil || !strings.Contains(err.Error(), "still in_progress") {
+		t.Errorf("Expected an error for a batch in progress, got: %v", err)
+	}
+
+	// Collect the results through the normal reports
+	atomic.StoreInt32(&ended, 1)
+	stats, err := workflow.CollectBatch(context.Background(), "msgbatch_1")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if stats.FilesProcessed != 2 || stats.TotalIssues != 2 || stats.IssuesByType["Bug"] != 2 {
+		t.Errorf("Unexpected statistics: %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "review_main.go.md")); err != nil {
+		t.Errorf("Expected a report for main.go: %v", err)
+	}
+
+	// A provider without batch support cannot collect the batch
+	other := newWorkflow(Options{})
+	other.provider = &MockLLMProvider{nameFunc: func() string { return "Anthropic" }}
+	if _, err := other.CollectBatch(context.Background(), "msgbatch_1"); err == nil {
+		t.Error("Expected an error for a provider without batch support")
+	}
+}
+
+func TestTicketReferences(t *testing.T) {
+	tickets := ticketReferences(
+		"feature/PROJ-12-retry",
+		"Limit retries (fixes #42)\n\nSee PROJ-12 and OPS-7.",
+		"Not a ticket: issue#3",
+		"Not tickets either: UTF-8, SHA-256, ISO-8601, HTTP-2, RFC-7231 and X-1",
+	)
+	expected := []string{"PROJ-12", "#42", "OPS-7"}
+	if strings.Join(tickets, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected tickets %v, got %v", expected, tickets)
+	}
+}
+
+func TestCodeAround(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 40; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	code, err := codeAround(content, 3)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	codeLines := strings.Split(strings.TrimRight(code, "\n"), "\n")
+	if len(codeLines) != 3+focusContextLines || codeLines[0] != "     1  line 1" || codeLines[2] != ">    3  line 3" {
+		t.Errorf("Expected the lines up to 15 below line 3 with line 3 marked, got:\n%s", code)
+	}
+
+	if _, err := codeAround(content, 41); err == nil {
+		t.Error("Expected an error for a line past the end of the file")
+	}
+}
+
+func TestExplainReportName(t *testing.T) {
+	tests := []struct {
+		target   string
+		all      bool
+		expected string
+	}{
+		{target: "", expected: "explain_staged.md"},
+		{target: "", all: true, expected: "explain_uncommitted.md"},
+		{target: "HEAD~2", expected: "explain_HEAD_2.md"},
+		{target: "main..feature/retry", expected: "explain_main..feature_retry.md"},
+		{target: "pkg/server.go:42", expected: "explain_pkg_server.go_42.md"},
+	}
+
+	for _, tt := range tests {
+		if got := explainReportName(tt.target, tt.all); got != tt.expected {
+			t.Errorf("explainReportName(%q, %v) = %q, expected %q", tt.target, tt.all, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadPRTemplate(t *testing.T) {
+	repoRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repoRoot, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoRoot, "docs", "pull_request_template.md"), []byte("## What\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, template, err := loadPRTemplate(repoRoot, PRDescriptionOptions{})
+	if err != nil || path != "docs/pull_request_template.md" || template != "## What\n" {
+		t.Errorf("Expected the template of the repository, got %q, %q, %v", path, template, err)
+	}
+
+	if path, _, err := loadPRTemplate(repoRoot, PRDescriptionOptions{NoTemplate: true}); err != nil || path != "" {
+		t.Errorf("Expected no template, got %q, %v", path, err)
+	}
+	if _, _, err := loadPRTemplate(repoRoot, PRDescriptionOptions{TemplatePath: filepath.Join(repoRoot, "missing.md")}); err == nil {
+		t.Error("Expected an error for a missing template")
+	}
+	if path, _, err := loadPRTemplate(t.TempDir(), PRDescriptionOptions{}); err != nil || path != "" {
+		t.Errorf("Expected no template in a repository without one, got %q, %v", path, err)
+	}
+}
+
+func TestWriteTestSuggestion(t *testing.T) {
+	outputDir := t.TempDir()
+	suggestion := &TestSuggestion{TestPath: "calc/calc_test.go", Code: "package calc\n"}
+
+	if err := writeTestSuggestion(outputDir, suggestion); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	path := filepath.Join(outputDir, "calc", "calc_test.go")
+	if suggestion.WrittenTo != path {
+		t.Errorf("Expected the suggestion to be written to %s, got %s", path, suggestion.WrittenTo)
+	}
+
+	// An existing file is never overwritten
+	second := &TestSuggestion{TestPath: "calc/calc_test.go", Code: "package other\n"}
+	if err := writeTestSuggestion(outputDir, second); err == nil || !strings.Contains(err.Error(), "not overwritten") {
+		t.Errorf("Expected an error for an existing file, got: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != "package calc\n" || second.WrittenTo != "" {
+		t.Errorf("Expected the first suggestion to be kept, got %q", content)
+	}
+}
+
+// TestChangedFunctionsOfStagedChange verifies that the functions of a staged
+// change are found in the staged version of a file with unstaged edits
+func TestChangedFunctionsOfStagedChange(t *testing.T) {
+	repoRoot := t.TempDir()
+	gitRun := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repoRoot, "-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git %s failed: %v: %s", strings.Join(args, " "), err, output)
+		}
+	}
+	writeCalc := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repoRoot, "calc.go"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write calc.go: %v", err)
+		}
+	}
+
+	committed := "package calc\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n"
+	gitRun("init", "-q")
+	writeCalc(committed)
+	gitRun("add", "calc.go")
+	gitRun("commit", "-q", "-m", "Add calc")
+
+	// Stage a change to Sub, then add lines above it without staging them
+	staged := strings.Replace(committed, "return a - b", "return a - b - 0", 1)
+	writeCalc(staged)
+	gitRun("add", "calc.go")
+	writeCalc("package calc\n\n// Add adds\n// two numbers\n// and returns\n// the sum\n" + strings.TrimPrefix(staged, "package calc\n\n"))
+
+	w := &ReviewWorkflow{repoDetector: git.NewRepositoryDetector()}
+	change, err := w.repoDetector.(git.HistoryReader).GetWorkingChange(repoRoot, false)
+	if err != nil {
+		t.Fatalf("Failed to get staged change: %v", err)
+	}
+	content, err := w.changedContent(repoRoot, "calc.go")
+	if err != nil {
+		t.Fatalf("Failed to read staged file: %v", err)
+	}
+
+	functions := changedFunctions(extractor.NewSet(repoRoot).ForFile("calc.go"), content, git.ChangedLines(change.Diff)["calc.go"])
+	if len(functions) != 1 || !strings.HasPrefix(functions[0], "func Sub") {
+		t.Errorf("Expected the staged change to be in Sub, got %q", functions)
+	}
+}
+
+func TestProviderOverride(t *testing.T) {
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-from-env")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+llm:
+  provider: openai
+  api_url: https://openai.example.com/v1
+  api_key: sk-openai
+  model: gpt-4o
+  timeout: 90
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := loadConfig(Options{ConfigPath: configPath, ProviderName: "anthropic"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.ResolveAPIKeys(); err != nil {
+		t.Fatalf("Failed to resolve API keys: %v", err)
+	}
+
+	// The key, URL and model are those of the provider on the command line
+	if cfg.LLM.Provider != "anthropic" || cfg.LLM.APIKey != "sk-ant-from-env" || cfg.LLM.APIKeySource != "ANTHROPIC_API_KEY" {
+		t.Errorf("Expected the anthropic key from the environment, got %q from %q for %s", cfg.LLM.APIKey, cfg.LLM.APIKeySource, cfg.LLM.Provider)
+	}
+	if cfg.LLM.APIURL != "" || cfg.LLM.Model != "" || cfg.LLM.Timeout != 90 {
+		t.Errorf("Expected no configured URL and model but the configured timeout, got %+v", cfg.LLM)
+	}
+
+	// The same provider keeps the configuration
+	cfg, err = loadConfig(Options{ConfigPath: configPath, ProviderName: "OpenAI"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.LLM.APIKey != "sk-openai" || cfg.LLM.Model != "gpt-4o" {
+		t.Errorf("Expected the configured key and model, got %+v", cfg.LLM)
+	}
+}
+
+func TestCreateProvider(t *testing.T) {
+	temperature := 5.0
+	tests := []struct {
+		name          string
+		llm           config.LLMConfig
+		expectedError string
+	}{
+		{name: "Provider names ignore case", llm: config.LLMConfig{Provider: "Fake"}},
+		{name: "Fallback chain", llm: config.LLMConfig{Provider: "fake", Fallbacks: []config.LLMConfig{{Provider: "fake"}}}},
+		{name: "Unsupported provider", llm: config.LLMConfig{Provider: "nope"}, expectedError: "unsupported provider: nope"},
+		{name: "Invalid generation settings", llm: config.LLMConfig{Provider: "fake", GenerationConfig: config.GenerationConfig{Temperature: &temperature}}, expectedError: "invalid generation settings"},
+		{name: "Missing fixtures", llm: config.LLMConfig{Provider: "fake", Fake: config.FakeConfig{Fixtures: filepath.Join(t.TempDir(), "missing")}}, expectedError: "failed to initialize fake provider"},
+		{name: "Invalid fallback", llm: config.LLMConfig{Provider: "fake", Fallbacks: []config.LLMConfig{{Provider: "nope"}}}, expectedError: "failed to create fallback provider 1 (nope)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := llm.CreateProviderFromConfig(&config.Config{LLM: tt.llm})
+			if tt.expectedError == "" {
+				if err != nil || provider == nil {
+					t.Fatalf("Expected a provider, got error %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("Expected an error containing %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestCassetteKeptWhenSetupFails(t *testing.T) {
+	tempDir := t.TempDir()
+	cassettePath := filepath.Join(tempDir, "review.cassette.jsonl")
+	if err := os.WriteFile(cassettePath, []byte("{}\n"), 0600); err != nil {
+		t.Fatalf("Failed to write cassette: %v", err)
+	}
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("llm:\n  provider: nope\n  api_key: test-api-key\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	if _, err := NewReviewWorkflow(Options{ConfigPath: configPath, RecordPath: cassettePath}); err == nil {
+		t.Fatal("Expected the unsupported provider to fail the setup")
+	}
+	if data, _ := os.ReadFile(cassettePath); string(data) != "{}\n" {
+		t.Errorf("Expected the cassette to be kept, got %q", data)
+	}
+}